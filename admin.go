@@ -0,0 +1,252 @@
+package gpoll
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrAdminTokenRequired = errors.New("the admin handler requires a token")
+
+// Create an http.Handler exposing JSON endpoints for operating the Poller. Mount it under any path prefix using
+// http.StripPrefix. Every request must carry the token as a bearer token in the Authorization header. Returns
+// ErrAdminTokenRequired if the token is empty, see NewInsecureAdminHandler.
+//
+// The following endpoints are exposed:
+//
+//	GET  /status       the poller's Status
+//	POST /pause        pause polling
+//	POST /resume       resume polling
+//	POST /poll         poll immediately
+//	GET  /events       recently delivered commits. Accepts a since query param containing a Sequence.
+//	POST /pin          pin delivery to the sha or tag in the revision query param
+//	POST /unpin        resume delivery after pinning
+//	POST /rollback     deliver a rollback to the sha or tag in the revision query param
+//	GET  /debug        runtime internals e.g. goroutines and storage size
+//	GET  /quarantine   commits withheld from delivery, oldest first
+//	POST /release      deliver the oldest quarantined commit, identified by the sha query param
+//	POST /discard      drop the quarantined commit identified by the sha query param
+func NewAdminHandler(poller Poller, token string) (http.Handler, error) {
+	if token == "" {
+		return nil, ErrAdminTokenRequired
+	}
+	return newAdminHandler(poller, token), nil
+}
+
+// Create the handler of NewAdminHandler without authenticating requests. Only use it when every request has already
+// been authenticated e.g. by a proxy in front of the handler.
+func NewInsecureAdminHandler(poller Poller) http.Handler {
+	return newAdminHandler(poller, "")
+}
+
+func newAdminHandler(poller Poller, token string) http.Handler {
+	a := &admin{
+		poller: poller,
+		token:  token,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", a.method(http.MethodGet, a.status))
+	mux.HandleFunc("/pause", a.method(http.MethodPost, a.pause))
+	mux.HandleFunc("/resume", a.method(http.MethodPost, a.resume))
+	mux.HandleFunc("/poll", a.method(http.MethodPost, a.poll))
+	mux.HandleFunc("/events", a.method(http.MethodGet, a.events))
+	mux.HandleFunc("/pin", a.method(http.MethodPost, a.pin))
+	mux.HandleFunc("/unpin", a.method(http.MethodPost, a.unpin))
+	mux.HandleFunc("/rollback", a.method(http.MethodPost, a.rollback))
+	mux.HandleFunc("/debug", a.method(http.MethodGet, a.debug))
+	mux.HandleFunc("/quarantine", a.method(http.MethodGet, a.quarantine))
+	mux.HandleFunc("/release", a.method(http.MethodPost, a.release))
+	mux.HandleFunc("/discard", a.method(http.MethodPost, a.discard))
+	a.mux = mux
+
+	return a
+}
+
+type admin struct {
+	poller Poller
+	token  string
+	mux    *http.ServeMux
+}
+
+type adminStatus struct {
+	Remote        string    `json:"remote"`
+	Branch        string    `json:"branch"`
+	Running       bool      `json:"running"`
+	Paused        bool      `json:"paused"`
+	LastPoll      time.Time `json:"lastPoll"`
+	LastError     string    `json:"lastError,omitempty"`
+	LastDelivered string    `json:"lastDelivered,omitempty"`
+	Sequence      uint64    `json:"sequence"`
+	Held          int       `json:"held"`
+	PinnedTo      string    `json:"pinnedTo,omitempty"`
+	Lag           int       `json:"lag"`
+	Succeeded     uint64    `json:"succeeded"`
+	Failed        uint64    `json:"failed"`
+}
+
+type adminError struct {
+	Error string `json:"error"`
+}
+
+func (a *admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(r) {
+		writeJson(w, http.StatusUnauthorized, adminError{Error: "unauthorized"})
+		return
+	}
+	a.mux.ServeHTTP(w, r)
+}
+
+func (a *admin) authorized(r *http.Request) bool {
+	// Only created without a token by NewInsecureAdminHandler.
+	if a.token == "" {
+		return true
+	}
+	return hasBearerToken(r, a.token)
+}
+
+// Whether the Authorization header of the request is the non-empty token through the Bearer scheme.
+func hasBearerToken(r *http.Request, token string) bool {
+	given := r.Header.Get("Authorization")
+	if token == "" || !strings.HasPrefix(given, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(given, "Bearer ")), []byte(token)) == 1
+}
+
+func (a *admin) method(method string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			writeJson(w, http.StatusMethodNotAllowed, adminError{Error: "method not allowed"})
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (a *admin) status(w http.ResponseWriter, _ *http.Request) {
+	writeJson(w, http.StatusOK, toAdminStatus(a.poller.Status()))
+}
+
+func toAdminStatus(s Status) adminStatus {
+	resp := adminStatus{
+		Remote:        s.Remote,
+		Branch:        s.Branch,
+		Running:       s.Running,
+		Paused:        s.Paused,
+		LastPoll:      s.LastPoll,
+		LastDelivered: s.LastDelivered.Sha,
+		Sequence:      s.Sequence,
+		Held:          s.Held,
+		PinnedTo:      s.PinnedTo,
+		Lag:           s.Lag,
+		Succeeded:     s.Succeeded,
+		Failed:        s.Failed,
+	}
+	if s.LastError != nil {
+		resp.LastError = s.LastError.Error()
+	}
+	return resp
+}
+
+func (a *admin) pause(w http.ResponseWriter, r *http.Request) {
+	a.poller.Pause()
+	a.status(w, r)
+}
+
+func (a *admin) resume(w http.ResponseWriter, r *http.Request) {
+	a.poller.Resume()
+	a.status(w, r)
+}
+
+func (a *admin) poll(w http.ResponseWriter, _ *http.Request) {
+	a.poller.Trigger()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (a *admin) events(w http.ResponseWriter, r *http.Request) {
+	var since uint64
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		since, err = strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			writeJson(w, http.StatusBadRequest, adminError{Error: "since must be a sequence number"})
+			return
+		}
+	}
+	writeJson(w, http.StatusOK, a.poller.Events(since))
+}
+
+func (a *admin) pin(w http.ResponseWriter, r *http.Request) {
+	revision := r.URL.Query().Get("revision")
+	if revision == "" {
+		writeJson(w, http.StatusBadRequest, adminError{Error: "revision is required"})
+		return
+	}
+	if err := a.poller.PinTo(revision); err != nil {
+		writeJson(w, http.StatusBadRequest, adminError{Error: err.Error()})
+		return
+	}
+	a.status(w, r)
+}
+
+func (a *admin) unpin(w http.ResponseWriter, r *http.Request) {
+	a.poller.Unpin()
+	a.status(w, r)
+}
+
+func (a *admin) rollback(w http.ResponseWriter, r *http.Request) {
+	revision := r.URL.Query().Get("revision")
+	if revision == "" {
+		writeJson(w, http.StatusBadRequest, adminError{Error: "revision is required"})
+		return
+	}
+	if err := a.poller.RollbackTo(revision); err != nil {
+		writeJson(w, http.StatusBadRequest, adminError{Error: err.Error()})
+		return
+	}
+	a.status(w, r)
+}
+
+func (a *admin) debug(w http.ResponseWriter, _ *http.Request) {
+	writeJson(w, http.StatusOK, a.poller.Debug())
+}
+
+func (a *admin) quarantine(w http.ResponseWriter, _ *http.Request) {
+	writeJson(w, http.StatusOK, a.poller.Quarantine())
+}
+
+func (a *admin) release(w http.ResponseWriter, r *http.Request) {
+	a.decide(w, r, a.poller.Release)
+}
+
+func (a *admin) discard(w http.ResponseWriter, r *http.Request) {
+	a.decide(w, r, a.poller.Discard)
+}
+
+// Releases or discards the quarantined commit in the sha query param and responds with what is still quarantined.
+func (a *admin) decide(w http.ResponseWriter, r *http.Request, decide func(sha string) error) {
+	sha := r.URL.Query().Get("sha")
+	if sha == "" {
+		writeJson(w, http.StatusBadRequest, adminError{Error: "sha is required"})
+		return
+	}
+	if err := decide(sha); err == ErrNotQuarantined {
+		writeJson(w, http.StatusNotFound, adminError{Error: err.Error()})
+		return
+	} else if err != nil {
+		writeJson(w, http.StatusConflict, adminError{Error: err.Error()})
+		return
+	}
+	a.quarantine(w, r)
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
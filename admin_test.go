@@ -0,0 +1,106 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/mocks"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type AdminTest struct {
+	suite.Suite
+
+	poller *mocks.Poller
+}
+
+func (s *AdminTest) SetupTest() {
+	s.poller = new(mocks.Poller)
+	s.poller.On("Status").Return(gpoll.Status{Running: true})
+}
+
+func (s *AdminTest) TestRequiresToken() {
+	// -- When
+	//
+	h, err := gpoll.NewAdminHandler(s.poller, "")
+
+	// -- Then
+	//
+	s.Nil(h)
+	s.Equal(gpoll.ErrAdminTokenRequired, err)
+}
+
+func (s *AdminTest) TestAuthenticatesRequests() {
+	// -- Given
+	//
+	h, err := gpoll.NewAdminHandler(s.poller, "secret")
+	s.Require().NoError(err)
+
+	// -- When
+	//
+	missing := s.get(h, "")
+	wrong := s.get(h, "Bearer wrong")
+	raw := s.get(h, "secret")
+	basic := s.get(h, "Basic secret")
+	right := s.get(h, "Bearer secret")
+
+	// -- Then
+	//
+	s.Equal(http.StatusUnauthorized, missing)
+	s.Equal(http.StatusUnauthorized, wrong)
+	s.Equal(http.StatusUnauthorized, raw)
+	s.Equal(http.StatusUnauthorized, basic)
+	s.Equal(http.StatusOK, right)
+}
+
+func (s *AdminTest) TestInsecureHandler() {
+	// -- Given
+	//
+	h := gpoll.NewInsecureAdminHandler(s.poller)
+
+	// -- When
+	//
+	code := s.get(h, "")
+
+	// -- Then
+	//
+	s.Equal(http.StatusOK, code)
+}
+
+func (s *AdminTest) TestTenantHandlerRequiresBearerToken() {
+	// -- Given
+	//
+	m, err := gpoll.NewMultiPoller(map[string]gpoll.PollConfig{})
+	s.Require().NoError(err)
+	s.Require().NoError(m.AddTenant(gpoll.Tenant{Name: "acme", Directory: s.T().TempDir()}))
+	h := gpoll.NewTenantAdminHandler(m, map[string]string{"secret": "acme"})
+
+	// -- When
+	//
+	raw := s.getPath(h, "/repos", "secret")
+	right := s.getPath(h, "/repos", "Bearer secret")
+
+	// -- Then
+	//
+	s.Equal(http.StatusUnauthorized, raw)
+	s.Equal(http.StatusOK, right)
+}
+
+func (s *AdminTest) get(h http.Handler, authorization string) int {
+	return s.getPath(h, "/status", authorization)
+}
+
+func (s *AdminTest) getPath(h http.Handler, path, authorization string) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAdmin(t *testing.T) {
+	suite.Run(t, new(AdminTest))
+}
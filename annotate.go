@@ -0,0 +1,172 @@
+package gpoll
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Computes an annotation for a commit e.g. the owners of the changed files or the pull request it came from.
+type AnnotateFunc func(commit CommitDiff) (string, error)
+
+type AnnotationConfig struct {
+	// Named functions whose results are added to every commit's Annotations under their name. Each function is called
+	// at most once per commit as long as its result is kept in the Cache.
+	Annotators map[string]AnnotateFunc
+
+	// Where annotations are memoized. Use NewFileAnnotationCache to keep them across restarts. Defaults to an in-memory
+	// cache of the annotations of the last 1024 commits.
+	Cache AnnotationCache
+}
+
+// Memoizes the annotations of commits.
+type AnnotationCache interface {
+	// Get the annotation of the commit with the sha made by the named AnnotateFunc.
+	Get(sha, name string) (string, bool)
+
+	// Keep the annotation of the commit with the sha made by the named AnnotateFunc.
+	Set(sha, name, value string) error
+}
+
+const defaultAnnotationCacheSize = 1024
+
+// Create an AnnotationCache keeping the annotations of up to size commits in memory. Once full, the annotations of the
+// least recently added commit are evicted.
+func NewMemoryAnnotationCache(size int) AnnotationCache {
+	return &memoryAnnotationCache{
+		size:    size,
+		entries: make(map[string]map[string]string),
+	}
+}
+
+type memoryAnnotationCache struct {
+	lock    sync.Mutex
+	size    int
+	entries map[string]map[string]string
+	// The shas in entries, oldest first.
+	order []string
+}
+
+func (m *memoryAnnotationCache) Get(sha, name string) (string, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	v, ok := m.entries[sha][name]
+	return v, ok
+}
+
+func (m *memoryAnnotationCache) Set(sha, name, value string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.set(sha, name, value)
+	return nil
+}
+
+// Must be called while holding the lock.
+func (m *memoryAnnotationCache) set(sha, name, value string) {
+	if _, ok := m.entries[sha]; !ok {
+		m.entries[sha] = make(map[string]string)
+		m.order = append(m.order, sha)
+		if len(m.order) > m.size {
+			delete(m.entries, m.order[0])
+			m.order = m.order[1:]
+		}
+	}
+	m.entries[sha][name] = value
+}
+
+// Create an AnnotationCache keeping the annotations of up to size commits, persisted as JSON to the file at fp so
+// annotations survive restarts. The file is created if it doesn't exist, and replaced atomically on every change. Once
+// full, the annotations of the least recently added commit are evicted.
+func NewFileAnnotationCache(fp string, size int) (AnnotationCache, error) {
+	f := &fileAnnotationCache{
+		memoryAnnotationCache: memoryAnnotationCache{
+			size:    size,
+			entries: make(map[string]map[string]string),
+		},
+		fp: fp,
+	}
+	b, err := ioutil.ReadFile(fp)
+	if os.IsNotExist(err) {
+		return f, nil
+	} else if err != nil {
+		return nil, err
+	}
+	commits := make([]annotatedCommit, 0)
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		// Written by earlier versions as the annotations by sha, which don't record the order they were added in.
+		bySha := make(map[string]map[string]string)
+		if err := json.Unmarshal(trimmed, &bySha); err != nil {
+			return nil, err
+		}
+		for sha, annotations := range bySha {
+			commits = append(commits, annotatedCommit{Sha: sha, Annotations: annotations})
+		}
+		sort.Slice(commits, func(i, j int) bool {
+			return commits[i].Sha < commits[j].Sha
+		})
+	} else if err := json.Unmarshal(b, &commits); err != nil {
+		return nil, err
+	}
+	for _, c := range commits {
+		for name, value := range c.Annotations {
+			f.set(c.Sha, name, value)
+		}
+	}
+	return f, nil
+}
+
+type fileAnnotationCache struct {
+	memoryAnnotationCache
+	fp string
+}
+
+// The annotations of a commit as persisted by a file cache, oldest first.
+type annotatedCommit struct {
+	Sha         string
+	Annotations map[string]string
+}
+
+func (f *fileAnnotationCache) Set(sha, name, value string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.set(sha, name, value)
+
+	commits := make([]annotatedCommit, 0, len(f.order))
+	for _, sha := range f.order {
+		commits = append(commits, annotatedCommit{Sha: sha, Annotations: f.entries[sha]})
+	}
+	b, err := json.Marshal(commits)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.fp, b, 0600)
+}
+
+// Adds the annotations of every configured AnnotateFunc to the commit, computing those that aren't cached.
+func (p *poller) annotate(commit *CommitDiff) {
+	config := p.config.Annotations
+	if len(config.Annotators) == 0 {
+		return
+	}
+
+	commit.Annotations = make(map[string]string, len(config.Annotators))
+	for name, annotate := range config.Annotators {
+		if v, ok := p.annotations.Get(commit.To.Sha, name); ok {
+			commit.Annotations[name] = v
+			continue
+		}
+
+		v, err := annotate(*commit)
+		if err != nil {
+			p.onError(err)
+			continue
+		}
+		commit.Annotations[name] = v
+		if err := p.annotations.Set(commit.To.Sha, name, v); err != nil {
+			p.onError(err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type AnnotateTest struct {
+	suite.Suite
+
+	dir string
+	fp  string
+}
+
+func (s *AnnotateTest) SetupTest() {
+	dir, err := ioutil.TempDir("", "gpoll")
+	s.Require().NoError(err)
+	s.dir = dir
+	s.fp = filepath.Join(dir, "annotations.json")
+}
+
+func (s *AnnotateTest) TearDownTest() {
+	_ = os.RemoveAll(s.dir)
+}
+
+func (s *AnnotateTest) TestFileCacheEvictsOldestCommit() {
+	// -- Given
+	//
+	cache := s.open(2)
+	s.NoError(cache.Set("a", "owner", "x"))
+	s.NoError(cache.Set("b", "owner", "y"))
+
+	// -- When
+	//
+	s.NoError(cache.Set("c", "owner", "z"))
+
+	// -- Then
+	//
+	for _, c := range []gpoll.AnnotationCache{cache, s.open(2)} {
+		_, ok := c.Get("a", "owner")
+		s.False(ok)
+		v, ok := c.Get("c", "owner")
+		s.True(ok)
+		s.Equal("z", v)
+	}
+	_, err := os.Stat(s.fp + ".tmp")
+	s.True(os.IsNotExist(err))
+}
+
+func (s *AnnotateTest) TestFileCacheKeepsOrderAcrossRestarts() {
+	// -- Given
+	//
+	cache := s.open(2)
+	s.NoError(cache.Set("b", "owner", "x"))
+	s.NoError(cache.Set("a", "owner", "y"))
+
+	// -- When
+	//
+	cache = s.open(2)
+	s.NoError(cache.Set("c", "owner", "z"))
+
+	// -- Then
+	//
+	_, ok := cache.Get("b", "owner")
+	s.False(ok)
+	_, ok = cache.Get("a", "owner")
+	s.True(ok)
+}
+
+func (s *AnnotateTest) TestFileCacheReadsAnnotationsBySha() {
+	// -- Given
+	//
+	s.Require().NoError(ioutil.WriteFile(s.fp, []byte(`{"a":{"owner":"x"}}`), 0600))
+
+	// -- When
+	//
+	cache := s.open(2)
+
+	// -- Then
+	//
+	v, ok := cache.Get("a", "owner")
+	s.True(ok)
+	s.Equal("x", v)
+}
+
+func (s *AnnotateTest) open(size int) gpoll.AnnotationCache {
+	cache, err := gpoll.NewFileAnnotationCache(s.fp, size)
+	s.Require().NoError(err)
+	return cache
+}
+
+func TestAnnotate(t *testing.T) {
+	suite.Run(t, new(AnnotateTest))
+}
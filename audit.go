@@ -0,0 +1,366 @@
+package gpoll
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The format audit records are written in.
+type AuditFormat int
+
+const (
+	// One JSON encoded AuditRecord per line.
+	AuditFormatJSON AuditFormat = iota
+
+	// One ArcSight Common Event Format record per line, as ingested by most SIEMs.
+	AuditFormatCEF
+)
+
+// What an AuditRecord records.
+type AuditAction string
+
+const (
+	// The poller started with the configuration in the Details.
+	AuditActionStart AuditAction = "start"
+
+	// The poller was stopped.
+	AuditActionStop AuditAction = "stop"
+
+	// An operator changed how the poller delivers e.g. paused it or pinned it. The operation and its arguments are in
+	// the Details.
+	AuditActionOperate AuditAction = "operate"
+
+	// The credentials were presented to the remote. The auth method, and whether the remote accepted them, are in the
+	// Details.
+	AuditActionCredentialUse AuditAction = "credential-use"
+
+	// The commit with the Sha was delivered to the Targets.
+	AuditActionDeliver AuditAction = "deliver"
+)
+
+type AuditConfig struct {
+	// Where audit records are written, one per line. Records are never buffered so the sink sees them in order as they
+	// happen. Old records are removed as per the Retention if it's a PrunableAuditSink e.g. NewFileAuditSink. If not
+	// set, nothing is audited.
+	Sink io.Writer
+
+	// The format of the records. Defaults to AuditFormatJSON.
+	Format AuditFormat
+
+	// Who is operating the poller e.g. the name of the service account or the team owning it. Defaults to the user
+	// running the process.
+	Actor string
+}
+
+// A record of something done by or to the poller that compliance requires to be traceable. Records never contain
+// credentials.
+type AuditRecord struct {
+	Time   time.Time   `json:"time"`
+	Action AuditAction `json:"action"`
+	Actor  string      `json:"actor"`
+
+	// The remote without any credentials embedded in its URL.
+	Remote string `json:"remote"`
+	Branch string `json:"branch"`
+
+	// The sha of the delivered commit. Empty for other actions.
+	Sha string `json:"sha,omitempty"`
+
+	// Where the commit was delivered e.g. channel, handler or handler:<name>. Empty for other actions.
+	Targets []string `json:"targets,omitempty"`
+
+	// Anything else describing the action.
+	Details map[string]string `json:"details,omitempty"`
+
+	// How the commit was delivered to the Targets, tracing it back to the poll that found it. Only set when delivering.
+	Receipt *Receipt `json:"receipt,omitempty"`
+
+	// Whether the action succeeded.
+	Success bool `json:"success"`
+}
+
+// Writes the record to the audit Sink, filling in who did it to which repo and when.
+func (p *poller) audit(record AuditRecord) {
+	sink := p.config.Audit.Sink
+	if sink == nil {
+		return
+	}
+	record.Time = time.Now()
+	record.Actor = p.auditActor
+	record.Remote = redactRemote(p.config.Git.Remote)
+	record.Branch = p.config.Git.Branch
+	for k, v := range record.Details {
+		record.Details[k] = p.redactString(v)
+	}
+
+	var line []byte
+	if p.config.Audit.Format == AuditFormatCEF {
+		line = []byte(record.CEF())
+	} else {
+		var err error
+		if line, err = json.Marshal(record); err != nil {
+			p.onError(err)
+			return
+		}
+	}
+	p.auditLock.Lock()
+	_, err := sink.Write(append(line, '\n'))
+	p.auditLock.Unlock()
+	if err != nil {
+		p.onError(fmt.Errorf("failed to write audit record: %s", err.Error()))
+	}
+}
+
+// Records an operation by an operator.
+func (p *poller) auditOperation(operation string, details map[string]string, err error) {
+	if p.config.Audit.Sink == nil {
+		return
+	}
+	if details == nil {
+		details = make(map[string]string, 1)
+	}
+	details["operation"] = operation
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	p.audit(AuditRecord{Action: AuditActionOperate, Details: details, Success: err == nil})
+}
+
+// Records that the credentials were presented to the remote.
+func (p *poller) auditCredentialUse(auth transport.AuthMethod, err error) {
+	details := map[string]string{}
+	if auth != nil {
+		details["method"] = auth.Name()
+		if basic, ok := auth.(*http.BasicAuth); ok {
+			details["username"] = basic.Username
+		}
+	}
+	rejected := err == transport.ErrAuthenticationRequired || err == transport.ErrAuthorizationFailed
+	if rejected {
+		details["error"] = err.Error()
+	}
+	p.audit(AuditRecord{Action: AuditActionCredentialUse, Details: details, Success: !rejected})
+}
+
+func (p *poller) auditStart() {
+	if p.config.Audit.Sink == nil {
+		return
+	}
+	auth := "basic"
+	if p.config.Git.Auth.SshKey != "" || len(p.config.Git.Auth.SshKeys) > 0 || p.config.Git.Auth.SshKeyDir != "" {
+		auth = "ssh"
+	}
+	p.audit(AuditRecord{
+		Action: AuditActionStart,
+		Details: map[string]string{
+			"interval": p.config.Interval.String(),
+			"auth":     auth,
+			"mirror":   fmt.Sprint(p.config.Git.Mirror),
+		},
+		Success: true,
+	})
+}
+
+func (p *poller) auditDelivery(commit CommitDiff) {
+	if p.config.Audit.Sink == nil {
+		return
+	}
+	targets := []string{"channel"}
+	if p.config.HandleCommit != nil || p.config.HandleCommitContext != nil {
+		targets = append(targets, "handler")
+	}
+	p.lock.RLock()
+	for _, h := range p.handlers {
+		targets = append(targets, "handler:"+h.Name)
+	}
+	p.lock.RUnlock()
+	if p.config.Outbox.Store != nil {
+		targets = append(targets, "outbox")
+	}
+	var receipt *Receipt
+	if r, ok := p.receipts.get(commit.ID); ok {
+		receipt = &r
+	}
+	p.audit(AuditRecord{
+		Action:  AuditActionDeliver,
+		Sha:     commit.To.Sha,
+		Targets: targets,
+		Details: map[string]string{"id": commit.ID, "poll": commit.PollID},
+		Receipt: receipt,
+		Success: true,
+	})
+}
+
+// An audit Sink whose records can be pruned as per the Retention e.g. NewFileAuditSink. Used by the poller if the Sink
+// implements it.
+type PrunableAuditSink interface {
+	io.Writer
+
+	// Remove the records from before the time, and then the oldest records beyond the max. A zero time or max doesn't
+	// remove by it.
+	PruneAudit(before time.Time, max int) error
+}
+
+// Create an audit Sink appending to the file at fp, which is created if it doesn't exist. The sink is a
+// PrunableAuditSink for records in either AuditFormat.
+func NewFileAuditSink(fp string) (io.WriteCloser, error) {
+	f := &fileAuditSink{fp: fp}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+type fileAuditSink struct {
+	lock sync.Mutex
+	fp   string
+	file *os.File
+}
+
+func (f *fileAuditSink) open() error {
+	file, err := os.OpenFile(f.fp, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	f.file = file
+	return nil
+}
+
+func (f *fileAuditSink) Write(b []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.file.Write(b)
+}
+
+func (f *fileAuditSink) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.file.Close()
+}
+
+// Rewrites the file without the pruned records, reopening it to append to the rewritten file.
+func (f *fileAuditSink) PruneAudit(before time.Time, max int) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	b, err := ioutil.ReadFile(f.fp)
+	if err != nil {
+		return err
+	}
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	// A record whose time can't be read is as old as the one before it.
+	times := make([]time.Time, len(lines))
+	var last time.Time
+	for i, line := range lines {
+		if t, ok := auditRecordTime(line); ok {
+			last = t
+		}
+		times[i] = last
+	}
+	from := retainFrom(len(lines), func(i int) time.Time {
+		return times[i]
+	}, before, max)
+	if from == 0 {
+		return nil
+	}
+
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	werr := writeFileAtomic(f.fp, bytes.Join(lines[from:], nil), 0600)
+	if err := f.open(); err != nil {
+		return err
+	}
+	return werr
+}
+
+var cefTime = regexp.MustCompile(`[| ]rt=(\d+)`)
+
+// Read the time of a record in either AuditFormat.
+func auditRecordTime(line []byte) (time.Time, bool) {
+	if m := cefTime.FindSubmatch(line); m != nil && bytes.HasPrefix(line, []byte("CEF:")) {
+		ms, err := strconv.ParseInt(string(m[1]), 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(0, ms*int64(time.Millisecond)), true
+	}
+	var record struct {
+		Time time.Time `json:"time"`
+	}
+	if err := json.Unmarshal(line, &record); err != nil || record.Time.IsZero() {
+		return time.Time{}, false
+	}
+	return record.Time, true
+}
+
+// Format the record as a line of ArcSight Common Event Format.
+func (a AuditRecord) CEF() string {
+	severity := 3
+	if !a.Success {
+		severity = 7
+	}
+	ext := []string{
+		"rt=" + fmt.Sprint(a.Time.UnixNano()/int64(time.Millisecond)),
+		"suser=" + cefValue(a.Actor),
+		"request=" + cefValue(a.Remote),
+		"cs1Label=branch",
+		"cs1=" + cefValue(a.Branch),
+		"outcome=" + map[bool]string{true: "success", false: "failure"}[a.Success],
+	}
+	if a.Sha != "" {
+		ext = append(ext, "cs2Label=sha", "cs2="+cefValue(a.Sha))
+	}
+	if len(a.Targets) > 0 {
+		ext = append(ext, "cs3Label=targets", "cs3="+cefValue(strings.Join(a.Targets, ",")))
+	}
+	if len(a.Details) > 0 {
+		keys := make([]string, 0, len(a.Details))
+		for k := range a.Details {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		details := make([]string, len(keys))
+		for i, k := range keys {
+			details[i] = k + "=" + a.Details[k]
+		}
+		ext = append(ext, "msg="+cefValue(strings.Join(details, " ")))
+	}
+	return fmt.Sprintf("CEF:0|gpoll|gpoll|1|%s|%s|%d|%s", cefHeader(string(a.Action)), cefHeader(string(a.Action)),
+		severity, strings.Join(ext, " "))
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+func cefHeader(s string) string {
+	return cefHeaderEscaper.Replace(s)
+}
+
+func cefValue(s string) string {
+	return cefValueEscaper.Replace(s)
+}
+
+func defaultAuditActor() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
@@ -1,14 +1,16 @@
 package gpoll
 
 import (
+	"errors"
+	"fmt"
 	"golang.org/x/crypto/ssh"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
 	gitssh "gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
 	"io/ioutil"
-	"os"
-	"path"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 func usernamePassword(username, password string) (transport.AuthMethod, error) {
@@ -18,23 +20,18 @@ func usernamePassword(username, password string) (transport.AuthMethod, error) {
 	}, nil
 }
 
-func sshKeyFromFile(fp string) (transport.AuthMethod, error) {
-	if strings.HasPrefix(fp, "~/") {
-		home, _ := os.UserHomeDir()
-		fp = path.Join(home, fp[2:])
-	}
-	key, err := ioutil.ReadFile(fp)
+func sshKeyFromFile(fp string, crypto SshCryptoConfig) (transport.AuthMethod, error) {
+	key, err := ioutil.ReadFile(expandHome(fp))
 	if err != nil {
 		return nil, err
 	}
-	return sshKey(key)
-}
-
-func sshKey(key []byte) (transport.AuthMethod, error) {
-	signer, err := ssh.ParsePrivateKey(key)
+	signer, err := parseSshKey(key)
 	if err != nil {
 		return nil, err
 	}
+	if !crypto.allowsKey(signer) {
+		return nil, disallowedKeyError(fp, signer)
+	}
 
 	return &gitssh.PublicKeys{
 		User:   "git",
@@ -42,10 +39,139 @@ func sshKey(key []byte) (transport.AuthMethod, error) {
 	}, nil
 }
 
-func toAuthMethod(config *GitAuthConfig) (transport.AuthMethod, error) {
+// Offers every key to the remote in order until one is accepted.
+func sshKeyCandidates(config *GitAuthConfig, crypto SshCryptoConfig) (transport.AuthMethod, error) {
+	files := config.SshKeys
 	if config.SshKey != "" {
-		return sshKeyFromFile(config.SshKey)
+		files = append([]string{config.SshKey}, files...)
+	}
+
+	signers := make([]ssh.Signer, 0, len(files))
+	for _, fp := range files {
+		key, err := ioutil.ReadFile(expandHome(fp))
+		if err != nil {
+			return nil, err
+		}
+		signer, err := parseSshKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ssh key %s: %s", fp, err.Error())
+		}
+		if !crypto.allowsKey(signer) {
+			return nil, disallowedKeyError(fp, signer)
+		}
+		signers = append(signers, signer)
+	}
+
+	if config.SshKeyDir != "" {
+		dir := expandHome(config.SshKeyDir)
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		// Public keys, known hosts and the like don't parse as private keys and are skipped along with keys that need a
+		// passphrase, and so are keys of types that aren't allowed.
+		for _, e := range entries {
+			if e.IsDir() || strings.HasSuffix(e.Name(), ".pub") {
+				continue
+			}
+			key, err := ioutil.ReadFile(filepath.Join(dir, e.Name()))
+			if err != nil {
+				continue
+			}
+			if signer, err := parseSshKey(key); err == nil && crypto.allowsKey(signer) {
+				signers = append(signers, signer)
+			}
+		}
+	}
+
+	if len(signers) == 0 {
+		return nil, errors.New("no ssh keys found")
+	}
+
+	return &gitssh.PublicKeysCallback{
+		User: "git",
+		Callback: func() ([]ssh.Signer, error) {
+			return signers, nil
+		},
+	}, nil
+}
+
+func hasAuth(config *GitAuthConfig) bool {
+	return config.SshKey != "" || len(config.SshKeys) > 0 || config.SshKeyDir != "" || config.Username != "" ||
+		config.Password != ""
+}
+
+func disallowedKeyError(fp string, signer ssh.Signer) error {
+	return fmt.Errorf("ssh key %s is of type %s which is not one of the allowed KeyTypes", fp,
+		signer.PublicKey().Type())
+}
+
+func toAuthMethod(config *GitAuthConfig, crypto SshCryptoConfig) (transport.AuthMethod, error) {
+	if len(config.SshKeys) > 0 || config.SshKeyDir != "" {
+		return sshKeyCandidates(config, crypto)
+	} else if config.SshKey != "" {
+		return sshKeyFromFile(config.SshKey, crypto)
 	} else {
 		return usernamePassword(config.Username, config.Password)
 	}
 }
+
+// Returns fresh credentials for the remote e.g. a token minted through OIDC or workload identity.
+type AuthRefreshFunc func() (GitAuthConfig, error)
+
+// Get the current auth method, first refreshing the credentials if the AuthRefreshInterval has passed. The current
+// credentials are kept if refreshing fails as they are refreshed again once the remote rejects them.
+func (g *gitImpl) auth() transport.AuthMethod {
+	g.authLock.RLock()
+	auth := g.authMethod
+	stale := g.refresh != nil && g.refreshInterval > 0 && time.Since(g.refreshedAt) > g.refreshInterval
+	g.authLock.RUnlock()
+
+	if stale && g.refreshAuth() == nil {
+		g.authLock.RLock()
+		auth = g.authMethod
+		g.authLock.RUnlock()
+	}
+	return auth
+}
+
+func (g *gitImpl) refreshAuth() error {
+	config, err := g.refresh()
+	if err != nil {
+		return err
+	}
+	auth, err := toAuthMethod(&config, g.transport.SshCrypto)
+	if err != nil {
+		return err
+	}
+
+	g.authLock.Lock()
+	defer g.authLock.Unlock()
+	g.authMethod = applyTransport(g.transport, auth, g.hostKeyAddr, g.httpClient)
+	g.refreshedAt = time.Now()
+	return nil
+}
+
+// Runs the operation with the current auth method. If the remote rejects the credentials and an AuthRefresh is
+// configured, the credentials are refreshed and the operation is retried once.
+func (g *gitImpl) withAuth(op func(auth transport.AuthMethod) error) error {
+	err := g.useAuth(op)
+	if g.refresh == nil || (err != transport.ErrAuthenticationRequired && err != transport.ErrAuthorizationFailed) {
+		return err
+	}
+
+	if rerr := g.refreshAuth(); rerr != nil {
+		return fmt.Errorf("failed to refresh credentials after %s: %s", err.Error(), rerr.Error())
+	}
+	return g.useAuth(op)
+}
+
+// Runs the operation with the current auth method, reporting the use of the credentials to the onAuth hook.
+func (g *gitImpl) useAuth(op func(auth transport.AuthMethod) error) error {
+	auth := g.auth()
+	err := op(auth)
+	if g.onAuth != nil {
+		g.onAuth(unwrapAuth(auth), err)
+	}
+	return err
+}
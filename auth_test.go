@@ -0,0 +1,158 @@
+package gpoll_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/ssh"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type AuthTest struct {
+	suite.Suite
+
+	dir string
+}
+
+func (s *AuthTest) SetupTest() {
+	dir, err := ioutil.TempDir("", "gpoll")
+	s.Require().NoError(err)
+	s.dir = dir
+}
+
+func (s *AuthTest) TearDownTest() {
+	_ = os.RemoveAll(s.dir)
+}
+
+// Write a new private key to the path relative to the test's directory, returning its public key.
+func (s *AuthTest) writeKey(name string) ssh.PublicKey {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	s.Require().NoError(err)
+	fp := filepath.Join(s.dir, name)
+	s.Require().NoError(os.MkdirAll(filepath.Dir(fp), 0700))
+	b := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	s.Require().NoError(ioutil.WriteFile(fp, b, 0600))
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	s.Require().NoError(err)
+	return pub
+}
+
+func (s *AuthTest) sshConfig(auth gpoll.GitAuthConfig) gpoll.GitConfig {
+	return gpoll.GitConfig{Auth: auth, Remote: "git@github.com:acme/api.git"}
+}
+
+func (s *AuthTest) TestOffersEveryKeyInOrder() {
+	// -- Given
+	//
+	first := s.writeKey("first")
+	second := s.writeKey("second")
+	dirB := s.writeKey("keys/b")
+	dirA := s.writeKey("keys/a")
+	s.Require().NoError(ioutil.WriteFile(filepath.Join(s.dir, "keys", "a.pub"), ssh.MarshalAuthorizedKey(dirA), 0600))
+	s.Require().NoError(ioutil.WriteFile(filepath.Join(s.dir, "keys", "known_hosts"), []byte("github.com ssh-rsa AAAA\n"),
+		0600))
+
+	// -- When
+	//
+	signers, err := gpoll.SshKeyCandidates(s.sshConfig(gpoll.GitAuthConfig{
+		SshKey:    filepath.Join(s.dir, "first"),
+		SshKeys:   []string{filepath.Join(s.dir, "second")},
+		SshKeyDir: filepath.Join(s.dir, "keys"),
+	}))
+
+	// -- Then
+	//
+	s.Require().NoError(err)
+	expected := []ssh.PublicKey{first, second, dirA, dirB}
+	if s.Len(signers, len(expected)) {
+		for i, k := range expected {
+			s.True(bytes.Equal(k.Marshal(), signers[i].PublicKey().Marshal()), "key %d is out of order", i)
+		}
+	}
+}
+
+func (s *AuthTest) TestRejectsMissingKeys() {
+	// -- Given
+	//
+	s.Require().NoError(os.Mkdir(filepath.Join(s.dir, "empty"), 0700))
+	s.Require().NoError(ioutil.WriteFile(filepath.Join(s.dir, "garbage"), []byte("not a key"), 0600))
+
+	// -- When
+	//
+	_, empty := gpoll.SshKeyCandidates(s.sshConfig(gpoll.GitAuthConfig{SshKeyDir: filepath.Join(s.dir, "empty")}))
+	_, missing := gpoll.SshKeyCandidates(s.sshConfig(gpoll.GitAuthConfig{
+		SshKeys: []string{filepath.Join(s.dir, "missing")},
+	}))
+	_, garbage := gpoll.SshKeyCandidates(s.sshConfig(gpoll.GitAuthConfig{
+		SshKeys: []string{filepath.Join(s.dir, "garbage")},
+	}))
+
+	// -- Then
+	//
+	s.EqualError(empty, "no ssh keys found")
+	s.Error(missing)
+	if s.Error(garbage) {
+		s.Contains(garbage.Error(), "failed to parse ssh key")
+	}
+}
+
+func (s *AuthTest) TestSelectsKeysByRemote() {
+	// -- Given
+	//
+	keys := []gpoll.RemoteSshKey{
+		{Remote: "github.com/acme/*", SshKey: "acme"},
+		{Remote: "github.com", SshKey: "github"},
+		{Remote: "gitlab.com/*/*", SshKey: "gitlab"},
+	}
+
+	// -- When
+	//
+	acme := gpoll.SelectSshKeys(keys, "git@github.com:acme/api.git")
+	other := gpoll.SelectSshKeys(keys, "https://github.com/other/api.git")
+	gitlab := gpoll.SelectSshKeys(keys, "ssh://git@gitlab.com/group/api.git")
+	none := gpoll.SelectSshKeys(keys, "git@bitbucket.org:acme/api.git")
+
+	// -- Then
+	//
+	s.Equal([]string{"acme", "github"}, acme)
+	s.Equal([]string{"github"}, other)
+	s.Equal([]string{"gitlab"}, gitlab)
+	s.Empty(none)
+}
+
+func (s *AuthTest) TestMultiPollerOffersSelectedKeys() {
+	// -- Given
+	//
+	m, err := gpoll.NewMultiPollerWithKeys(nil, []gpoll.RemoteSshKey{
+		{Remote: "github.com", SshKey: filepath.Join(s.dir, "missing")},
+	})
+	s.Require().NoError(err)
+
+	// -- When
+	//
+	selected := m.AddRepo("api", gpoll.PollConfig{Git: gpoll.GitConfig{Remote: "git@github.com:acme/api.git"}})
+	configured := m.AddRepo("web", gpoll.PollConfig{Git: gpoll.GitConfig{
+		Remote: "https://github.com/acme/web.git",
+		Auth:   gpoll.GitAuthConfig{Username: "jane", Password: "secret"},
+	}})
+
+	// -- Then
+	//
+	// The selected key doesn't exist so only the repo without auth of its own fails.
+	if s.Error(selected) {
+		s.Contains(selected.Error(), "missing")
+	}
+	s.NoError(configured)
+	s.Equal([]string{"web"}, m.ListRepos())
+}
+
+func TestAuth(t *testing.T) {
+	suite.Run(t, new(AuthTest))
+}
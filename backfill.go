@@ -0,0 +1,86 @@
+package gpoll
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"strings"
+	"time"
+)
+
+func (p *poller) Backfill(path string, since time.Time) error {
+	diffs, err := p.backfillDiffs(strings.TrimSuffix(p.relativePath(path), "/"), since)
+	if err != nil {
+		return err
+	}
+	p.applyMailmap(diffs)
+
+	// Held so the backfill isn't interleaved with the delivery of newly polled commits.
+	p.deliverLock.Lock()
+	defer p.deliverLock.Unlock()
+	for _, d := range diffs {
+		p.handleCommit(d)
+	}
+	return nil
+}
+
+// Diffs every commit in the history of the clone that changed the slash separated path since the time, oldest first,
+// keeping only the changes within the path.
+func (p *poller) backfillDiffs(rel string, since time.Time) ([]CommitDiff, error) {
+	p.repoLock.Lock()
+	defer p.repoLock.Unlock()
+	if p.repo == nil {
+		return nil, ErrNotStarted
+	}
+
+	commits, err := p.git.History(p.repo, rel, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := make([]CommitDiff, 0, len(commits))
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+		if c.Committer.When.Before(since) {
+			continue
+		}
+		diff, err := p.parentDiff(c)
+		if err != nil {
+			return nil, err
+		}
+
+		changes := make([]FileChange, 0, len(diff.Changes))
+		for _, change := range diff.Changes {
+			if change.Filepath != rel && !strings.HasPrefix(change.Filepath, rel+"/") {
+				continue
+			}
+			if p.config.FileChangeFilter != nil && !p.config.FileChangeFilter(change) {
+				continue
+			}
+			change.Filepath = p.formatPath(change.Filepath)
+			changes = append(changes, change)
+		}
+		diff.Changes = changes
+		diff.Backfill = true
+		diffs = append(diffs, *diff)
+	}
+	return diffs, nil
+}
+
+// Diffs the commit against its first parent. Every file is created by a commit without parents.
+func (p *poller) parentDiff(c *object.Commit) (*CommitDiff, error) {
+	if c.NumParents() == 0 {
+		files, err := p.git.ListFiles(c)
+		if err != nil {
+			return nil, err
+		}
+		return &CommitDiff{
+			Changes: files,
+			To:      *p.git.ToInternal(c),
+		}, nil
+	}
+
+	parent, err := c.Parent(0)
+	if err != nil {
+		return nil, err
+	}
+	return p.git.Diff(parent, c)
+}
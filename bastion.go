@@ -0,0 +1,127 @@
+package gpoll
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	gitssh "gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// A jump host SSH remotes are reached through, like the ProxyJump of OpenSSH, for remotes on networks that can only be
+// entered through a bastion.
+//
+// go-git resolves the address of SSH remotes for the whole process, so the jump host is used for every SSH remote on
+// the same host, and the last poller configured for a host wins. Connections are tunnelled through a listener on the
+// loopback interface, while the host key of the remote is still verified against the remote's own host.
+type BastionConfig struct {
+	// The address of the jump host as host:port. The port defaults to 22. If not set, remotes are connected to
+	// directly.
+	Host string
+
+	// The user to log into the jump host as. Required with a Host.
+	User string
+
+	// The filepath to the SSH key logging into the jump host. Required with a Host.
+	SshKey string
+
+	// The filepath to the known_hosts file verifying the host key of the jump host. Defaults to the files in the
+	// SSH_KNOWN_HOSTS environment variable, or ~/.ssh/known_hosts, as for the remote.
+	KnownHosts string
+}
+
+// Opens connections to the remote through the jump host, itself reached through the forward dialer.
+type bastionDialer struct {
+	host    string
+	client  *ssh.ClientConfig
+	forward tunnelDialer
+
+	lock sync.Mutex
+	conn *ssh.Client
+}
+
+func newBastionDialer(config TransportConfig, forward tunnelDialer) (*bastionDialer, error) {
+	b := config.Bastion
+	if b.User == "" || b.SshKey == "" {
+		return nil, errors.New("a bastion requires a user and an ssh key")
+	}
+	if _, _, err := net.SplitHostPort(b.Host); err != nil {
+		b.Host = net.JoinHostPort(b.Host, strconv.Itoa(gitssh.DefaultPort))
+	}
+
+	key, err := ioutil.ReadFile(expandHome(b.SshKey))
+	if err != nil {
+		return nil, err
+	}
+	signer, err := parseSshKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the ssh key of the bastion %s: %s", b.SshKey, err.Error())
+	}
+	if !config.SshCrypto.allowsKey(signer) {
+		return nil, disallowedKeyError(b.SshKey, signer)
+	}
+	var files []string
+	if b.KnownHosts != "" {
+		files = append(files, expandHome(b.KnownHosts))
+	}
+	hostKeys, err := gitssh.NewKnownHostsCallback(files...)
+	if err != nil {
+		return nil, err
+	}
+	client := &ssh.ClientConfig{
+		User:            b.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeys,
+		Timeout:         config.ConnectTimeout,
+	}
+	config.SshCrypto.apply(client)
+	return &bastionDialer{
+		host:    b.Host,
+		client:  client,
+		forward: forward,
+	}, nil
+}
+
+// Opens a connection to the target through the jump host, reconnecting to the jump host if its connection was lost.
+func (b *bastionDialer) dial(target string) (net.Conn, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.conn != nil {
+		if conn, err := b.conn.Dial("tcp", target); err == nil {
+			return conn, nil
+		}
+		_ = b.conn.Close()
+		b.conn = nil
+	}
+	c, err := b.connect()
+	if err != nil {
+		return nil, err
+	}
+	b.conn = c
+	return c.Dial("tcp", target)
+}
+
+func (b *bastionDialer) connect() (*ssh.Client, error) {
+	conn, err := b.forward.dial(b.host)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, b.host, b.client)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+func (b *bastionDialer) close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.conn != nil {
+		_ = b.conn.Close()
+	}
+	b.forward.close()
+}
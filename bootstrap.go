@@ -0,0 +1,52 @@
+package gpoll
+
+import (
+	"fmt"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// Returned when starting if the head of the clone is neither the ExpectedInitialSha nor a descendant of it.
+type InitialShaMismatchError struct {
+	// The ExpectedInitialSha.
+	Expected string
+
+	// The sha of the head of the clone.
+	Head string
+}
+
+func (i *InitialShaMismatchError) Error() string {
+	return fmt.Sprintf("head of the clone %s does not descend from the expected initial sha %s", i.Head, i.Expected)
+}
+
+// Checks that the head of the clone is the ExpectedInitialSha or a descendant of it.
+func (p *poller) verifyInitialSha(repo *git.Repository) error {
+	expected := p.config.ExpectedInitialSha
+	if expected == "" {
+		return nil
+	}
+	head, err := p.git.HeadCommit(repo)
+	if err != nil {
+		return err
+	}
+	if head.Hash.String() == expected {
+		return nil
+	}
+
+	mismatch := &InitialShaMismatchError{
+		Expected: expected,
+		Head:     head.Hash.String(),
+	}
+	c, err := repo.CommitObject(plumbing.NewHash(expected))
+	if err == plumbing.ErrObjectNotFound {
+		return mismatch
+	} else if err != nil {
+		return err
+	}
+	if ok, err := c.IsAncestor(head); err != nil {
+		return err
+	} else if !ok {
+		return mismatch
+	}
+	return nil
+}
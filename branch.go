@@ -0,0 +1,165 @@
+package gpoll
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"time"
+)
+
+// What the poller does once its Branch is deleted on the remote.
+type BranchMissingPolicy int
+
+const (
+	// Keep polling, emitting a BranchMissing event after every poll, and resume delivering once the branch is
+	// recreated.
+	BranchMissingWait BranchMissingPolicy = iota
+
+	// Stop the poller. The BranchMissingError is passed to OnError and kept as the LastError of the Status.
+	BranchMissingStop
+
+	// Switch to the default branch of the remote i.e. the one its HEAD points to. Commits on the default branch that
+	// weren't on the deleted branch are delivered as usual, and the Branch of the Status is the default branch from
+	// then on.
+	BranchMissingSwitchToDefault
+)
+
+// Returned by polls once the branch no longer exists on the remote.
+type BranchMissingError struct {
+	Remote string
+	Branch string
+}
+
+func (b *BranchMissingError) Error() string {
+	return fmt.Sprintf("branch %s does not exist on %s", b.Branch, b.Remote)
+}
+
+// Emitted after every poll that finds the branch deleted on the remote.
+type BranchMissing struct {
+	// The deleted branch.
+	Branch string
+
+	// When the branch was first found missing.
+	Since time.Time
+
+	// The branch polled from now on. Only set with BranchMissingSwitchToDefault once switched.
+	SwitchedTo string
+}
+
+func (b BranchMissing) EventType() EventType {
+	return EventTypeBranchMissing
+}
+
+func (b BranchMissing) String() string {
+	if b.SwitchedTo != "" {
+		return fmt.Sprintf("branch %s is missing, switched to %s", b.Branch, b.SwitchedTo)
+	}
+	return fmt.Sprintf("branch %s missing since %s", b.Branch, b.Since.Format(time.RFC3339))
+}
+
+// Applies the BranchMissingPolicy if the poll found the branch deleted. Returns whether the poller must stop.
+func (p *poller) handleMissingBranch(err error) bool {
+	missing := &BranchMissingError{}
+	p.lock.Lock()
+	if !errors.As(err, &missing) {
+		p.branchMissingSince = time.Time{}
+		p.lock.Unlock()
+		return false
+	}
+	if p.branchMissingSince.IsZero() {
+		p.branchMissingSince = time.Now()
+	}
+	event := BranchMissing{Branch: missing.Branch, Since: p.branchMissingSince}
+	p.lock.Unlock()
+
+	switch p.config.BranchMissing {
+	case BranchMissingStop:
+		p.emit(event)
+		p.onError(err)
+		return true
+	case BranchMissingSwitchToDefault:
+		branch, err := p.switchToDefaultBranch()
+		if err != nil {
+			p.onError(err)
+		} else {
+			event.SwitchedTo = branch
+		}
+	}
+	p.emit(event)
+	return false
+}
+
+// Points the clone at the default branch of the remote, from the commit it is currently at, so the next poll delivers
+// what the default branch has on top of it.
+func (p *poller) switchToDefaultBranch() (string, error) {
+	p.repoLock.Lock()
+	defer p.repoLock.Unlock()
+
+	branch, err := p.git.DefaultBranch(p.repo)
+	if err != nil {
+		return "", err
+	}
+	if branch == p.config.Git.Branch {
+		return "", fmt.Errorf("the default branch %s is missing as well", branch)
+	}
+	head, err := p.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	ref := plumbing.NewBranchReferenceName(branch)
+	if err := p.repo.Storer.SetReference(plumbing.NewHashReference(ref, head.Hash())); err != nil {
+		return "", err
+	}
+	if err := p.repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, ref)); err != nil {
+		return "", err
+	}
+
+	p.lock.Lock()
+	p.config.Git.Branch = branch
+	p.branchMissingSince = time.Time{}
+	p.lock.Unlock()
+	return branch, nil
+}
+
+// The branch the HEAD of the remote points to. Remotes that don't advertise what HEAD points to are matched by sha,
+// preferring main and master.
+func (g *gitImpl) DefaultBranch(repo *git.Repository) (string, error) {
+	rem, err := repo.Remote(remoteName)
+	if err != nil {
+		return "", err
+	}
+	rfs, err := g.listRemote(rem)
+	if err != nil {
+		return "", err
+	}
+
+	var head *plumbing.Reference
+	for _, r := range rfs {
+		if r.Name() == plumbing.HEAD {
+			head = r
+		}
+	}
+	if head == nil {
+		return "", errors.New("the remote has no HEAD")
+	}
+	if head.Type() == plumbing.SymbolicReference {
+		return head.Target().Short(), nil
+	}
+
+	var candidates []string
+	for _, r := range rfs {
+		if r.Name().IsBranch() && r.Hash() == head.Hash() {
+			candidates = append(candidates, r.Name().Short())
+		}
+	}
+	for _, preferred := range []string{"main", "master"} {
+		if containsString(candidates, preferred) {
+			return preferred, nil
+		}
+	}
+	if len(candidates) == 0 {
+		return "", errors.New("no branch of the remote is at its HEAD")
+	}
+	return candidates[0], nil
+}
@@ -0,0 +1,104 @@
+package gpoll
+
+import (
+	"fmt"
+	"time"
+)
+
+const defaultBudgetWindow = time.Minute
+
+// Bounds the resources polling the repo may use so a single pathological repo can't starve the others polled by the
+// same process. Once a budget is exceeded polling is throttled until the end of the Window, and a BudgetExceeded event
+// is emitted.
+type BudgetConfig struct {
+	// The most time polls may spend fetching and diffing the remote within each Window. The time taken is an estimate of
+	// the CPU time as it includes waiting on the network. Defaults to no limit.
+	CPUTime time.Duration
+
+	// The most bytes the commits found by polls within each Window may be estimated to take up in memory, from the
+	// sizes of their changed files, their paths and messages. Defaults to no limit.
+	Memory int64
+
+	// How long usage is accumulated before being reset. Defaults to 1m.
+	Window time.Duration
+}
+
+// Emitted once polling is throttled because the usage within the current Window exceeded the Budget.
+type BudgetExceeded struct {
+	// The time spent fetching and diffing within the Window.
+	CPUTime time.Duration
+
+	// The estimated bytes of the commits found within the Window.
+	Memory int64
+
+	// The budget that was exceeded.
+	Budget BudgetConfig
+
+	// When polling resumes.
+	ThrottledUntil time.Time
+}
+
+func (b BudgetExceeded) EventType() EventType {
+	return EventTypeBudgetExceeded
+}
+
+func (b BudgetExceeded) String() string {
+	return fmt.Sprintf("polls used %s and %d bytes which exceeds the budget of %s and %d bytes per %s, throttled until %s",
+		b.CPUTime, b.Memory, b.Budget.CPUTime, b.Budget.Memory, b.Budget.Window, b.ThrottledUntil.Format(time.RFC3339))
+}
+
+func (b BudgetConfig) enabled() bool {
+	return b.CPUTime > 0 || b.Memory > 0
+}
+
+// Adds the cost of a poll to the usage of the current Window, throttling polling and emitting a BudgetExceeded event
+// once the Budget is exceeded.
+func (p *poller) charge(cpu time.Duration, changes []CommitDiff) {
+	budget := p.config.Budget
+	if !budget.enabled() {
+		return
+	}
+
+	p.lock.Lock()
+	now := time.Now()
+	if now.Sub(p.budgetWindowStart) >= budget.Window {
+		p.budgetWindowStart = now
+		p.cpuUsed = 0
+		p.memoryUsed = 0
+	}
+	p.cpuUsed += cpu
+	p.memoryUsed += estimateMemory(changes)
+	exceeded := (budget.CPUTime > 0 && p.cpuUsed > budget.CPUTime) || (budget.Memory > 0 && p.memoryUsed > budget.Memory)
+	if !exceeded || now.Before(p.throttledUntil) {
+		p.lock.Unlock()
+		return
+	}
+	p.throttledUntil = p.budgetWindowStart.Add(budget.Window)
+	event := BudgetExceeded{
+		CPUTime:        p.cpuUsed,
+		Memory:         p.memoryUsed,
+		Budget:         budget,
+		ThrottledUntil: p.throttledUntil,
+	}
+	p.lock.Unlock()
+
+	p.emit(event)
+}
+
+func (p *poller) isThrottled() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return time.Now().Before(p.throttledUntil)
+}
+
+// Estimates the bytes the commits take up in memory.
+func estimateMemory(changes []CommitDiff) int64 {
+	var size int64
+	for _, c := range changes {
+		size += int64(len(c.From.Message) + len(c.To.Message))
+		for _, f := range c.Changes {
+			size += f.Size + int64(len(f.Filepath))
+		}
+	}
+	return size
+}
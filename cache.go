@@ -0,0 +1,117 @@
+package gpoll
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A cache, like a CDN or a key-value store, whose entries can be invalidated by key.
+type CacheClient interface {
+	// Invalidate every entry with one of the keys. Errors are passed to the OnError of the CacheInvalidatorConfig.
+	Invalidate(ctx context.Context, keys []string) error
+}
+
+// Maps a changed file to the keys of the cache entries rendered from it. Returning none leaves the cache alone.
+type CacheKeyFunc func(change FileChange) []string
+
+type CacheInvalidatorConfig struct {
+	// The cache the keys are invalidated in. Required.
+	Client CacheClient `validate:"required"`
+
+	// Maps every changed file to the keys it invalidates. Required.
+	Keys CacheKeyFunc `validate:"required"`
+
+	// The maximum number of keys per call to Invalidate. More keys are split over several calls. Defaults to 100.
+	BatchSize int
+
+	// How long to collect keys after a commit before invalidating them, so a burst of commits invalidates every key
+	// once. Defaults to 0 which invalidates the keys of every commit as it's handled.
+	Window time.Duration
+
+	// Called when invalidating a batch of keys fails.
+	OnError func(err error)
+}
+
+// Invalidates the cache entries affected by every delivered commit, e.g. the pages of a content site rendered from
+// the changed files. Keys are deduplicated and batched. Use HandleCommit as the HandleCommitContext of a PollConfig.
+// The initial delivery isn't invalidated since nothing changed.
+type CacheInvalidator struct {
+	config CacheInvalidatorConfig
+
+	lock    sync.Mutex
+	pending map[string]bool
+	timer   *time.Timer
+}
+
+// Create a CacheInvalidator from config.
+func NewCacheInvalidator(config CacheInvalidatorConfig) *CacheInvalidator {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	return &CacheInvalidator{
+		config:  config,
+		pending: make(map[string]bool),
+	}
+}
+
+// Invalidate the keys of the changed files, either immediately or once the Window passes.
+func (c *CacheInvalidator) HandleCommit(ctx context.Context, commit CommitDiff) {
+	c.lock.Lock()
+	for _, change := range commit.Changes {
+		if change.ChangeType == ChangeTypeInit {
+			continue
+		}
+		for _, key := range c.config.Keys(change) {
+			c.pending[key] = true
+		}
+	}
+	if len(c.pending) == 0 || c.config.Window <= 0 {
+		c.lock.Unlock()
+		c.report(c.Flush(ctx))
+		return
+	}
+	if c.timer == nil {
+		c.timer = time.AfterFunc(c.config.Window, func() {
+			c.report(c.Flush(context.Background()))
+		})
+	}
+	c.lock.Unlock()
+}
+
+// Invalidate every pending key now, in sorted batches of at most BatchSize. Every batch is attempted even if one fails,
+// and the first error is returned. Keys of failed batches aren't retried. Call before shutting down when using a
+// Window.
+func (c *CacheInvalidator) Flush(ctx context.Context) error {
+	c.lock.Lock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	keys := make([]string, 0, len(c.pending))
+	for k := range c.pending {
+		keys = append(keys, k)
+	}
+	c.pending = make(map[string]bool)
+	c.lock.Unlock()
+
+	sort.Strings(keys)
+	var firstErr error
+	for start := 0; start < len(keys); start += c.config.BatchSize {
+		end := start + c.config.BatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if err := c.config.Client.Invalidate(ctx, keys[start:end]); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (c *CacheInvalidator) report(err error) {
+	if err != nil && c.config.OnError != nil {
+		c.config.OnError(err)
+	}
+}
@@ -0,0 +1,252 @@
+package gpoll
+
+import (
+	"fmt"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// How an inconsistency between the checkpoint and the local clone is resolved on start.
+type ReconcilePolicy int
+
+const (
+	// Reset the clone to the checkpoint so delivery resumes right after the last delivered commit. If the clone diverged
+	// from the checkpoint, it is reset to the last commit they have in common instead.
+	ReconcilePolicyTrustCheckpoint ReconcilePolicy = iota
+
+	// Keep the clone and move the checkpoint to it. Commits between the two are never delivered.
+	ReconcilePolicyTrustClone
+
+	// Fail to start.
+	ReconcilePolicyFail
+)
+
+func (r ReconcilePolicy) String() string {
+	switch r {
+	case ReconcilePolicyTrustCheckpoint:
+		return "trust-checkpoint"
+	case ReconcilePolicyTrustClone:
+		return "trust-clone"
+	default:
+		return "fail"
+	}
+}
+
+// How the checkpoint relates to the local clone.
+type CheckpointRelation int
+
+const (
+	// The clone is at the checkpoint.
+	CheckpointRelationConsistent CheckpointRelation = iota
+
+	// The clone is ahead of the checkpoint e.g. commits were fetched but the process crashed before delivering them.
+	CheckpointRelationCloneAhead
+
+	// The clone is behind the checkpoint e.g. the clone was restored from an older backup.
+	CheckpointRelationCloneBehind
+
+	// The clone and the checkpoint diverged e.g. the branch was force pushed.
+	CheckpointRelationDiverged
+
+	// The checkpoint isn't a commit within the clone.
+	CheckpointRelationUnknown
+)
+
+func (c CheckpointRelation) String() string {
+	switch c {
+	case CheckpointRelationConsistent:
+		return "consistent"
+	case CheckpointRelationCloneAhead:
+		return "clone ahead of checkpoint"
+	case CheckpointRelationCloneBehind:
+		return "clone behind checkpoint"
+	case CheckpointRelationDiverged:
+		return "clone diverged from checkpoint"
+	default:
+		return "checkpoint not found in clone"
+	}
+}
+
+type CheckpointConfig struct {
+	// Where the sha of the last delivered commit is kept across restarts e.g. NewFileCheckpointStore. If not set, the
+	// checkpoint isn't kept.
+	Store CheckpointStore
+
+	// How the clone is reconciled with the checkpoint on start if they are inconsistent. Defaults to
+	// ReconcilePolicyTrustCheckpoint.
+	Policy ReconcilePolicy
+}
+
+// Durable storage of the sha of the last delivered commit.
+type CheckpointStore interface {
+	// Get the stored sha. Empty if none has been stored.
+	Load() (string, error)
+
+	// Replace the stored sha.
+	Save(sha string) error
+}
+
+// Create a CheckpointStore persisted to the file at fp. The file is created if it doesn't exist. The store is a
+// PrunableHeadLogStore whose log is appended to the file at fp with a .heads suffix.
+func NewFileCheckpointStore(fp string) CheckpointStore {
+	return &fileCheckpointStore{fp: fp}
+}
+
+type fileCheckpointStore struct {
+	lock sync.Mutex
+	fp   string
+}
+
+func (f *fileCheckpointStore) Load() (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	b, err := ioutil.ReadFile(f.fp)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func (f *fileCheckpointStore) Save(sha string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return writeFileAtomic(f.fp, []byte(sha+"\n"), 0600)
+}
+
+// Emitted on start when the checkpoint was inconsistent with the local clone, describing how it was reconciled.
+type Reconciliation struct {
+	// The sha of the stored checkpoint.
+	Checkpoint string
+
+	// The sha of the HEAD of the local clone before reconciling.
+	Clone string
+
+	// The sha of the branch on the remote. Empty if the remote couldn't be read.
+	Remote string
+
+	// How the checkpoint related to the clone.
+	Relation CheckpointRelation
+
+	// The policy that was applied.
+	Policy ReconcilePolicy
+
+	// What was done to reconcile e.g. reset the clone to 1a2b3c.
+	Action string
+}
+
+func (r Reconciliation) EventType() EventType {
+	return EventTypeReconciliation
+}
+
+func (r Reconciliation) String() string {
+	return fmt.Sprintf("%s (checkpoint %s, clone %s, remote %s): %s", r.Relation, r.Checkpoint, r.Clone, r.Remote,
+		r.Action)
+}
+
+// Compares the stored checkpoint with the HEAD of the local clone and reconciles them according to the policy. Returns
+// an error if the poller must not start.
+func (p *poller) reconcile() error {
+	config := p.config.Checkpoint
+	if config.Store == nil {
+		return nil
+	}
+	checkpoint, err := config.Store.Load()
+	if err != nil {
+		return err
+	}
+	head, err := p.git.HeadCommit(p.repo)
+	if err != nil {
+		return err
+	}
+	if checkpoint == "" {
+		return config.Store.Save(head.Hash.String())
+	}
+
+	relation, base, err := checkpointRelation(p.repo.CommitObject, checkpoint, head)
+	if err != nil {
+		return err
+	}
+	if relation == CheckpointRelationConsistent {
+		return nil
+	}
+
+	r := Reconciliation{
+		Checkpoint: checkpoint,
+		Clone:      head.Hash.String(),
+		Relation:   relation,
+		Policy:     config.Policy,
+	}
+	if remote, err := p.git.FetchLatestRemoteCommit(p.repo, p.config.Git.Branch); err == nil {
+		r.Remote = remote.Hash.String()
+	} else {
+		p.onError(err)
+	}
+
+	switch {
+	case config.Policy == ReconcilePolicyTrustClone:
+		r.Action = fmt.Sprintf("moved the checkpoint to %s", r.Clone)
+		err = config.Store.Save(r.Clone)
+	case config.Policy == ReconcilePolicyTrustCheckpoint && base != nil:
+		r.Action = fmt.Sprintf("reset the clone to %s", base.Hash)
+		err = p.git.Reset(p.repo, p.config.Git.Branch, base.Hash.String())
+	default:
+		r.Action = "failed to start"
+		p.emit(r)
+		return fmt.Errorf("checkpoint %s is inconsistent with the clone at %s: %s", checkpoint, r.Clone, relation)
+	}
+	if err != nil {
+		r.Action = fmt.Sprintf("failed to reconcile: %s", err.Error())
+	}
+	p.emit(r)
+	return err
+}
+
+func (p *poller) saveCheckpoint(sha string) {
+	if p.config.Checkpoint.Store == nil {
+		return
+	}
+	if err := p.config.Checkpoint.Store.Save(sha); err != nil {
+		p.onError(err)
+	}
+}
+
+// Gets how the checkpoint relates to the head along with the commit the clone would be reset to in order to trust the
+// checkpoint. The commit is nil if there isn't one.
+func checkpointRelation(get func(h plumbing.Hash) (*object.Commit, error), checkpoint string,
+	head *object.Commit) (CheckpointRelation, *object.Commit, error) {
+	if head.Hash.String() == checkpoint {
+		return CheckpointRelationConsistent, head, nil
+	}
+	c, err := get(plumbing.NewHash(checkpoint))
+	if err == plumbing.ErrObjectNotFound {
+		return CheckpointRelationUnknown, nil, nil
+	} else if err != nil {
+		return 0, nil, err
+	}
+
+	if ok, err := c.IsAncestor(head); err != nil {
+		return 0, nil, err
+	} else if ok {
+		return CheckpointRelationCloneAhead, c, nil
+	}
+	if ok, err := head.IsAncestor(c); err != nil {
+		return 0, nil, err
+	} else if ok {
+		return CheckpointRelationCloneBehind, c, nil
+	}
+
+	bases, err := c.MergeBase(head)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(bases) == 0 {
+		return CheckpointRelationDiverged, nil, nil
+	}
+	return CheckpointRelationDiverged, bases[0], nil
+}
@@ -0,0 +1,49 @@
+package gpoll
+
+import (
+	"os"
+	"time"
+)
+
+// Stops keeping the standby up to date and waits for any refresh in progress to finish.
+func (p *poller) haltStandby() {
+	if p.standbyStop == nil {
+		return
+	}
+	close(p.standbyStop)
+	<-p.standbyExited
+	p.standbyStop, p.standbyExited = nil, nil
+}
+
+// Removes the clones from the filesystem once polling stopped if CleanupOnStop is set. The next start clones again.
+func (p *poller) cleanup() {
+	if !p.config.CleanupOnStop {
+		return
+	}
+
+	p.standbyLock.Lock()
+	defer p.standbyLock.Unlock()
+	p.repoLock.Lock()
+	defer p.repoLock.Unlock()
+
+	p.lock.Lock()
+	dirs := []string{p.cloneDirectory(), p.standbyDirectory}
+	p.repo = nil
+	p.directory.Store(p.config.Git.CloneDirectory)
+	p.standbyUpdatedAt = time.Time{}
+	p.lock.Unlock()
+	p.standby = nil
+	p.standbyDirectory = p.config.Standby.Directory
+
+	if p.config.Git.Storage.Type != StorageTypeFilesystem {
+		return
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			p.onError(err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/eddieowens/gpoll"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultConfigFile = "gpoll.json"
+
+// The config file read through the -config flag and written by the init command.
+type fileConfig struct {
+	// Identifies the repo in the watch view. Defaults to the last element of the remote without its .git suffix.
+	Name     string `json:"name,omitempty"`
+	Remote   string `json:"remote"`
+	Branch   string `json:"branch"`
+	SshKey   string `json:"sshKey,omitempty"`
+	Username string `json:"username,omitempty"`
+	// The name of the environment variable holding the password so it is never written to disk.
+	PasswordEnv string   `json:"passwordEnv,omitempty"`
+	Directory   string   `json:"directory,omitempty"`
+	Interval    string   `json:"interval,omitempty"`
+	Include     []string `json:"include,omitempty"`
+	// Where the sha of the last delivered commit is kept across restarts.
+	CheckpointFile string `json:"checkpointFile,omitempty"`
+	// Further repos polled by the watch command. Each is applied over the rest of the file and the flags, so the fields
+	// a repo leaves out are shared by every repo.
+	Repos []fileConfig `json:"repos,omitempty"`
+}
+
+func readConfig(fp string) (*fileConfig, error) {
+	b, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	c := &fileConfig{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func writeConfig(fp string, c *fileConfig) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fp, append(b, '\n'), 0600)
+}
+
+// Applies the fields set in the file, leaving the flags of those left out as they are e.g. at their defaults.
+func (f *fileConfig) apply(r *repoFlags) error {
+	if f.Name != "" {
+		r.name = f.Name
+	}
+	if f.Remote != "" {
+		r.remote = f.Remote
+	}
+	if f.Branch != "" {
+		r.branch = f.Branch
+	}
+	if f.SshKey != "" {
+		r.sshKey = f.SshKey
+	}
+	if f.Username != "" {
+		r.username = f.Username
+	}
+	if f.PasswordEnv != "" {
+		r.password = os.Getenv(f.PasswordEnv)
+	}
+	if f.Directory != "" {
+		r.directory = f.Directory
+	}
+	if f.Interval != "" {
+		i, err := time.ParseDuration(f.Interval)
+		if err != nil {
+			return err
+		}
+		r.interval = i
+	}
+	if len(f.Include) > 0 {
+		r.include = strings.Join(f.Include, ",")
+	}
+	if f.CheckpointFile != "" {
+		r.checkpoint = f.CheckpointFile
+	}
+	return nil
+}
+
+func (f *fileConfig) gitConfig() gpoll.GitConfig {
+	r := &repoFlags{}
+	_ = f.apply(r)
+	return r.pollConfig().Git
+}
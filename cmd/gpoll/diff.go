@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/eddieowens/gpoll"
+	"os"
+)
+
+type diffFile struct {
+	Path   string `json:"path"`
+	Change string `json:"change"`
+}
+
+type diffOutput struct {
+	From  string     `json:"from"`
+	To    string     `json:"to"`
+	Files []diffFile `json:"files"`
+}
+
+// Prints the files changed since a revision. Exits with 1 if any files changed, 0 if none did and 2 on error so it can
+// drive shell conditionals e.g. gpoll diff -since 1h -include infra || make infra.
+func diff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	rf := &repoFlags{}
+	rf.register(fs)
+	since := fs.String("since", "", "A commit sha or a duration e.g. 24h to diff the branch head against. Required.")
+	format := fs.String("format", "text", "The output format, either text, json or template.")
+	tmpl := fs.String("template", "", "A Go text/template over the diff used by the template format.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gpoll diff -since <sha|duration> [flags]")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Exit codes:")
+		fmt.Fprintln(fs.Output(), "  0  no files changed")
+		fmt.Fprintln(fs.Output(), "  1  files changed")
+		fmt.Fprintln(fs.Output(), "  2  error")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	if err := rf.parse(fs, args); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 2
+	}
+
+	if *since == "" {
+		fmt.Fprintln(os.Stderr, "-since is required")
+		return 2
+	}
+	// Checked before cloning so a typo doesn't cost a clone.
+	var t *gpoll.CommitTemplate
+	switch *format {
+	case "text", "json":
+	case "template":
+		var err error
+		if t, err = gpoll.NewCommitTemplate(*tmpl); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 2
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %s\n", *format)
+		return 2
+	}
+
+	config := rf.pollConfig()
+	d, err := gpoll.DiffSince(config.Git, *since)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 2
+	}
+	rf.touchHealthcheck()
+
+	out := diffOutput{
+		From:  d.From.Sha,
+		To:    d.To.Sha,
+		Files: make([]diffFile, 0),
+	}
+	changes := make([]gpoll.FileChange, 0, len(d.Changes))
+	for _, c := range d.Changes {
+		if config.FileChangeFilter != nil && !config.FileChangeFilter(c) {
+			continue
+		}
+		changes = append(changes, c)
+		out.Files = append(out.Files, diffFile{
+			Path:   c.Filepath,
+			Change: c.ChangeType.String(),
+		})
+	}
+	d.Changes = changes
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(out)
+	case "text":
+		for _, f := range out.Files {
+			fmt.Printf("%s\t%s\n", f.Change, f.Path)
+		}
+	case "template":
+		s, err := t.Execute(*d)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 2
+		}
+		fmt.Print(s)
+	}
+
+	if len(out.Files) > 0 {
+		return 1
+	}
+	return 0
+}
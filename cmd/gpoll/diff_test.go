@@ -0,0 +1,106 @@
+package main
+
+import (
+	"github.com/eddieowens/gpoll/gpolltest/server"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type DiffTest struct {
+	suite.Suite
+
+	server *server.Server
+}
+
+func (s *DiffTest) SetupTest() {
+	srv, err := server.New()
+	s.Require().NoError(err)
+	s.server = srv
+}
+
+func (s *DiffTest) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *DiffTest) TestExitsWith0WhenNothingChanged() {
+	// -- Given
+	//
+	base, err := s.server.Head()
+	s.Require().NoError(err)
+	_, err = s.server.Commit("add infra", map[string]string{"infra/a.tf": "a"})
+	s.Require().NoError(err)
+	head, err := s.server.Head()
+	s.Require().NoError(err)
+
+	// -- When
+	//
+	unchanged := s.diff("-since", head)
+	excluded := s.diff("-since", base, "-include", "k8s")
+
+	// -- Then
+	//
+	s.Equal(0, unchanged)
+	s.Equal(0, excluded)
+}
+
+func (s *DiffTest) TestExitsWith1WhenFilesChanged() {
+	// -- Given
+	//
+	base, err := s.server.Head()
+	s.Require().NoError(err)
+	_, err = s.server.Commit("add infra", map[string]string{"infra/a.tf": "a"})
+	s.Require().NoError(err)
+
+	// -- When
+	//
+	changed := s.diff("-since", base)
+	included := s.diff("-since", base, "-include", "infra", "-format", "json")
+
+	// -- Then
+	//
+	s.Equal(1, changed)
+	s.Equal(1, included)
+}
+
+func (s *DiffTest) TestExitsWith2OnError() {
+	// -- Given
+	//
+	dir, err := ioutil.TempDir("", "gpoll")
+	s.Require().NoError(err)
+	defer os.RemoveAll(dir)
+	healthcheck := filepath.Join(dir, "healthy")
+
+	// -- When
+	//
+	invalid := s.diff("-since", "not-a-sha")
+	missing := s.diff()
+	format := s.diff("-since", "1h", "-format", "yaml", "-healthcheck-file", healthcheck)
+	template := s.diff("-since", "1h", "-format", "template", "-template", "{{")
+
+	// -- Then
+	//
+	s.Equal(2, invalid)
+	s.Equal(2, missing)
+	s.Equal(2, format)
+	s.Equal(2, template)
+	// The healthcheck is only touched after the repo is cloned so the format was rejected first.
+	_, err = os.Stat(healthcheck)
+	s.True(os.IsNotExist(err))
+}
+
+func (s *DiffTest) diff(args ...string) int {
+	config := s.server.GitConfig()
+	return diff(append([]string{
+		"-remote", config.Remote,
+		"-branch", config.Branch,
+		"-username", config.Auth.Username,
+		"-password", config.Auth.Password,
+	}, args...))
+}
+
+func TestDiff(t *testing.T) {
+	suite.Run(t, new(DiffTest))
+}
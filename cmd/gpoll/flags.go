@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/eddieowens/gpoll"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Flags shared by every command that polls a repo.
+type repoFlags struct {
+	config    string
+	remote    string
+	branch    string
+	sshKey    string
+	username  string
+	password  string
+	directory string
+	interval  time.Duration
+	include   string
+	// A file touched after every successful poll.
+	healthcheck string
+	// A file the sha of the last delivered commit is kept in across restarts.
+	checkpoint string
+	// Identifies the repo, see repoID.
+	name string
+	// The config file, if one was given.
+	file *fileConfig
+}
+
+func (r *repoFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&r.config, "config", "", "A config file created by gpoll init. Flags override its values.")
+	fs.StringVar(&r.remote, "remote", "", "The remote git repository to poll.")
+	fs.StringVar(&r.branch, "branch", "master", "The branch to poll.")
+	fs.StringVar(&r.sshKey, "ssh-key", "", "The filepath to the SSH key.")
+	fs.StringVar(&r.username, "username", "", "The username for the git repo.")
+	fs.StringVar(&r.password, "password", "", "The password for the git repo.")
+	fs.StringVar(&r.directory, "dir", "", "The directory the repo is cloned into.")
+	fs.DurationVar(&r.interval, "interval", 30*time.Second, "The polling interval.")
+	fs.StringVar(&r.include, "include", "", "Comma separated path patterns. Only matching files are included.")
+	fs.StringVar(&r.healthcheck, "healthcheck-file", "", "A file that is touched after every successful poll.")
+	fs.StringVar(&r.checkpoint, "checkpoint-file", "",
+		"A file the sha of the last delivered commit is kept in so a restart resumes from it.")
+}
+
+// Parse the flags, applying the config file first if one was given so that explicitly set flags take precedence.
+func (r *repoFlags) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if r.config == "" {
+		return nil
+	}
+
+	explicit := make(map[string]string)
+	fs.Visit(func(fl *flag.Flag) {
+		explicit[fl.Name] = fl.Value.String()
+	})
+
+	f, err := readConfig(r.config)
+	if err != nil {
+		return err
+	}
+	if err := f.apply(r); err != nil {
+		return err
+	}
+	r.file = f
+
+	for name, value := range explicit {
+		if err := fs.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get the flags of every repo to poll. These are the flags themselves unless the config file lists repos, in which case
+// every repo is applied over a copy of them.
+func (r *repoFlags) repos() ([]*repoFlags, error) {
+	if r.file == nil || len(r.file.Repos) == 0 {
+		return []*repoFlags{r}, nil
+	}
+
+	repos := make([]*repoFlags, 0, len(r.file.Repos))
+	directories := make(map[string]string)
+	checkpoints := make(map[string]string)
+	for _, f := range r.file.Repos {
+		repo := *r
+		if err := f.apply(&repo); err != nil {
+			return nil, err
+		}
+		// The repos would otherwise overwrite each other's clone or checkpoint.
+		if other, ok := directories[repo.directory]; ok && repo.directory != "" {
+			return nil, fmt.Errorf("repos %s and %s share the directory %s", other, repo.repoID(), repo.directory)
+		}
+		if other, ok := checkpoints[repo.checkpoint]; ok && repo.checkpoint != "" {
+			return nil, fmt.Errorf("repos %s and %s share the checkpoint file %s", other, repo.repoID(), repo.checkpoint)
+		}
+		directories[repo.directory] = repo.repoID()
+		checkpoints[repo.checkpoint] = repo.repoID()
+		repos = append(repos, &repo)
+	}
+	return repos, nil
+}
+
+// Identifies the repo, either by its name or the last element of its remote without the .git suffix.
+func (r *repoFlags) repoID() string {
+	if r.name != "" {
+		return r.name
+	}
+	return strings.TrimSuffix(path.Base(r.remote), ".git")
+}
+
+func (r *repoFlags) pollConfig() gpoll.PollConfig {
+	config := gpoll.PollConfig{
+		Git: gpoll.GitConfig{
+			Auth: gpoll.GitAuthConfig{
+				SshKey:   r.sshKey,
+				Username: r.username,
+				Password: r.password,
+			},
+			Remote:         r.remote,
+			Branch:         r.branch,
+			CloneDirectory: r.directory,
+			Progress: func(progress gpoll.Progress) {
+				fmt.Fprintln(os.Stderr, progress)
+			},
+		},
+		Interval: r.interval,
+		OnPoll: func(err error) {
+			if err == nil {
+				r.touchHealthcheck()
+			}
+		},
+	}
+
+	if r.sshKey == "" && r.username == "" && r.password == "" {
+		if token := cachedToken(r.remote); token != "" {
+			config.Git.Auth.Username = oauthUsername
+			config.Git.Auth.Password = token
+		}
+	}
+
+	if r.checkpoint != "" {
+		config.Checkpoint.Store = gpoll.NewFileCheckpointStore(r.checkpoint)
+	}
+	if r.include != "" {
+		config.FileChangeFilter = gpoll.IncludePaths(strings.Split(r.include, ",")...)
+	}
+	return config
+}
+
+// Touch the healthcheck file if one was given.
+func (r *repoFlags) touchHealthcheck() {
+	if r.healthcheck == "" {
+		return
+	}
+	f, err := os.OpenFile(r.healthcheck, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return
+	}
+	_ = f.Close()
+	now := time.Now()
+	if err := os.Chtimes(r.healthcheck, now, now); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
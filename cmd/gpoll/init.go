@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/eddieowens/gpoll"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+func initConfig(args []string) int {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	out := fs.String("out", defaultConfigFile, "Where to write the config file.")
+	skipPreflight := fs.Bool("skip-preflight", false, "Write the config without checking the remote is reachable.")
+	_ = fs.Parse(args)
+
+	if _, err := os.Stat(*out); err == nil {
+		fmt.Fprintf(os.Stderr, "%s already exists\n", *out)
+		return 1
+	}
+
+	p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	c := &fileConfig{}
+	c.Remote = p.ask("Remote", "")
+	c.Branch = p.ask("Branch", "master")
+	switch p.choose("Auth method", "ssh", "password", "oauth") {
+	case "ssh":
+		c.SshKey = p.ask("SSH key", "~/.ssh/id_rsa")
+	case "password":
+		c.Username = p.ask("Username", "")
+		c.PasswordEnv = p.ask("Environment variable holding the password", "GPOLL_PASSWORD")
+	case "oauth":
+		provider := p.choose("Provider", "github", "gitlab")
+		clientId := p.ask("OAuth client ID", os.Getenv("GPOLL_OAUTH_CLIENT_ID"))
+		u, err := url.Parse(c.Remote)
+		if err != nil || u.Hostname() == "" {
+			fmt.Fprintln(os.Stderr, "oauth requires an HTTPS remote")
+			return 1
+		}
+		if p.err == nil {
+			if err := deviceLogin(provider, u.Hostname(), clientId, p.out); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				return 1
+			}
+		}
+	}
+	for {
+		c.Interval = p.ask("Interval", "30s")
+		if _, err := time.ParseDuration(c.Interval); err == nil {
+			break
+		}
+		fmt.Fprintln(p.out, "Not a valid duration e.g. 30s, 5m")
+	}
+	if include := p.ask("Include paths (comma separated, blank for all)", ""); include != "" {
+		c.Include = strings.Split(include, ",")
+	}
+
+	if p.err != nil {
+		fmt.Fprintln(os.Stderr, p.err.Error())
+		return 1
+	}
+
+	if !*skipPreflight {
+		fmt.Fprintf(p.out, "Checking %s...\n", c.Remote)
+		if err := gpoll.Preflight(c.gitConfig()); err != nil {
+			fmt.Fprintf(os.Stderr, "preflight failed: %s\n", err.Error())
+			return 1
+		}
+	}
+
+	if err := writeConfig(*out, c); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	fmt.Fprintf(p.out, "Wrote %s\n", *out)
+	return 0
+}
+
+// Asks questions on the terminal. The first error encountered is kept and all later questions return their defaults.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	err error
+}
+
+func (p *prompter) ask(question, def string) string {
+	for p.err == nil {
+		if def != "" {
+			fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(p.out, "%s: ", question)
+		}
+
+		line, err := p.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			p.err = err
+			break
+		}
+
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+		if answer != "" {
+			return answer
+		}
+	}
+	return def
+}
+
+func (p *prompter) choose(question string, options ...string) string {
+	for p.err == nil {
+		answer := p.ask(fmt.Sprintf("%s (%s)", question, strings.Join(options, "/")), options[0])
+		for _, o := range options {
+			if answer == o {
+				return o
+			}
+		}
+	}
+	return options[0]
+}
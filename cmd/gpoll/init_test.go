@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type InitTest struct {
+	suite.Suite
+
+	dir string
+	fp  string
+}
+
+func (s *InitTest) SetupTest() {
+	dir, err := ioutil.TempDir("", "gpoll")
+	s.Require().NoError(err)
+	s.dir = dir
+	s.fp = filepath.Join(dir, defaultConfigFile)
+}
+
+func (s *InitTest) TearDownTest() {
+	_ = os.RemoveAll(s.dir)
+}
+
+func (s *InitTest) TestWritesAnswers() {
+	// -- Given
+	//
+	s.answer("https://example.com/repo.git", "", "password", "jane", "", "soon", "5m", "k8s,infra")
+
+	// -- When
+	//
+	code := initConfig([]string{"-out", s.fp, "-skip-preflight"})
+
+	// -- Then
+	//
+	s.Equal(0, code)
+	c, err := readConfig(s.fp)
+	s.Require().NoError(err)
+	s.Equal(&fileConfig{
+		Remote:      "https://example.com/repo.git",
+		Branch:      "master",
+		Username:    "jane",
+		PasswordEnv: "GPOLL_PASSWORD",
+		Interval:    "5m",
+		Include:     []string{"k8s", "infra"},
+	}, c)
+	b, err := ioutil.ReadFile(s.fp)
+	s.Require().NoError(err)
+	s.NotContains(string(b), "password\"")
+}
+
+func (s *InitTest) TestRefusesToOverwrite() {
+	// -- Given
+	//
+	s.Require().NoError(ioutil.WriteFile(s.fp, []byte("{}"), 0600))
+
+	// -- When
+	//
+	code := initConfig([]string{"-out", s.fp, "-skip-preflight"})
+
+	// -- Then
+	//
+	s.Equal(1, code)
+}
+
+func (s *InitTest) TestFlagsOverrideConfig() {
+	// -- Given
+	//
+	s.Require().NoError(writeConfig(s.fp, &fileConfig{
+		Remote:   "https://example.com/repo.git",
+		Branch:   "main",
+		Interval: "5m",
+	}))
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	rf := &repoFlags{}
+	rf.register(fs)
+
+	// -- When
+	//
+	err := rf.parse(fs, []string{"-config", s.fp, "-branch", "release"})
+
+	// -- Then
+	//
+	s.Require().NoError(err)
+	s.Equal("https://example.com/repo.git", rf.remote)
+	s.Equal("release", rf.branch)
+	s.Equal(5*time.Minute, rf.interval)
+}
+
+func (s *InitTest) TestMinimalConfigKeepsDefaults() {
+	// -- Given
+	//
+	s.Require().NoError(ioutil.WriteFile(s.fp, []byte(`{"remote": "https://example.com/repo.git"}`), 0600))
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	rf := &repoFlags{}
+	rf.register(fs)
+
+	// -- When
+	//
+	err := rf.parse(fs, []string{"-config", s.fp, "-dir", "/tmp/repo"})
+
+	// -- Then
+	//
+	s.Require().NoError(err)
+	s.Equal("https://example.com/repo.git", rf.remote)
+	s.Equal("master", rf.branch)
+	s.Equal("/tmp/repo", rf.directory)
+}
+
+func (s *InitTest) TestReposAreAppliedOverConfig() {
+	// -- Given
+	//
+	s.Require().NoError(ioutil.WriteFile(s.fp, []byte(`{
+		"branch": "main",
+		"interval": "5m",
+		"repos": [
+			{"remote": "https://example.com/infra.git", "checkpointFile": "/tmp/infra"},
+			{"name": "apps", "remote": "https://example.com/k8s.git", "branch": "release"}
+		]
+	}`), 0600))
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	rf := &repoFlags{}
+	rf.register(fs)
+	s.Require().NoError(rf.parse(fs, []string{"-config", s.fp, "-include", "prod"}))
+
+	// -- When
+	//
+	repos, err := rf.repos()
+
+	// -- Then
+	//
+	s.Require().NoError(err)
+	s.Require().Len(repos, 2)
+	s.Equal("infra", repos[0].repoID())
+	s.Equal("main", repos[0].branch)
+	s.Equal("/tmp/infra", repos[0].checkpoint)
+	s.Equal("apps", repos[1].repoID())
+	s.Equal("release", repos[1].branch)
+	s.Empty(repos[1].checkpoint)
+	for _, r := range repos {
+		s.Equal(5*time.Minute, r.interval)
+		s.Equal("prod", r.include)
+	}
+}
+
+func (s *InitTest) TestReposMayNotShareCheckpoint() {
+	// -- Given
+	//
+	s.Require().NoError(ioutil.WriteFile(s.fp, []byte(`{
+		"checkpointFile": "/tmp/checkpoint",
+		"repos": [
+			{"remote": "https://example.com/infra.git"},
+			{"remote": "https://example.com/k8s.git"}
+		]
+	}`), 0600))
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	rf := &repoFlags{}
+	rf.register(fs)
+	s.Require().NoError(rf.parse(fs, []string{"-config", s.fp}))
+
+	// -- When
+	//
+	_, err := rf.repos()
+
+	// -- Then
+	//
+	s.EqualError(err, "repos infra and k8s share the checkpoint file /tmp/checkpoint")
+}
+
+// Answer the prompts through stdin, one answer per line.
+func (s *InitTest) answer(answers ...string) {
+	fp := filepath.Join(s.dir, "stdin")
+	s.Require().NoError(ioutil.WriteFile(fp, []byte(strings.Join(answers, "\n")+"\n"), 0600))
+	f, err := os.Open(fp)
+	s.Require().NoError(err)
+	stdin := os.Stdin
+	os.Stdin = f
+	s.T().Cleanup(func() {
+		os.Stdin = stdin
+		_ = f.Close()
+	})
+}
+
+func TestInit(t *testing.T) {
+	suite.Run(t, new(InitTest))
+}
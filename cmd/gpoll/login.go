@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The username sent alongside a cached OAuth token. Both GitHub and GitLab accept it for token auth over HTTPS.
+const oauthUsername = "oauth2"
+
+// The endpoints of an OAuth 2.0 device authorization grant.
+type deviceFlow struct {
+	codeUrl  string
+	tokenUrl string
+	scope    string
+}
+
+// Get the device flow of the provider hosted at host.
+func newDeviceFlow(provider, host string) (*deviceFlow, error) {
+	switch provider {
+	case "github":
+		return &deviceFlow{
+			codeUrl:  fmt.Sprintf("https://%s/login/device/code", host),
+			tokenUrl: fmt.Sprintf("https://%s/login/oauth/access_token", host),
+			scope:    "repo",
+		}, nil
+	case "gitlab":
+		return &deviceFlow{
+			codeUrl:  fmt.Sprintf("https://%s/oauth/authorize_device", host),
+			tokenUrl: fmt.Sprintf("https://%s/oauth/token", host),
+			scope:    "read_repository",
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown provider %s, must be github or gitlab", provider)
+}
+
+type deviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationUri string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+type deviceToken struct {
+	AccessToken string `json:"access_token"`
+	Error       string `json:"error"`
+}
+
+// Run the flow, telling the user where to enter the code through out, and wait for them to authorize the client.
+func (d *deviceFlow) login(clientId string, out io.Writer) (string, error) {
+	code := &deviceCode{}
+	err := d.post(d.codeUrl, url.Values{"client_id": {clientId}, "scope": {d.scope}}, code)
+	if err != nil {
+		return "", err
+	}
+	if code.DeviceCode == "" {
+		return "", errors.New("no device code was returned")
+	}
+
+	fmt.Fprintf(out, "Open %s and enter the code %s\n", code.VerificationUri, code.UserCode)
+
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+
+		token := &deviceToken{}
+		err := d.post(d.tokenUrl, url.Values{
+			"client_id":   {clientId},
+			"device_code": {code.DeviceCode},
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		}, token)
+		if err != nil {
+			return "", err
+		}
+
+		switch token.Error {
+		case "":
+			return token.AccessToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return "", fmt.Errorf("authorization failed: %s", token.Error)
+		}
+	}
+	return "", errors.New("the code expired before it was entered")
+}
+
+func (d *deviceFlow) post(u string, form url.Values, v interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Pending authorizations are reported by GitLab with a 400 alongside the error in the body.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("request to %s failed with status %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Where tokens obtained through login are cached, keyed by host.
+func tokenCacheFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".gpoll", "tokens.json"), nil
+}
+
+func readTokens() (map[string]string, error) {
+	fp, err := tokenCacheFile()
+	if err != nil {
+		return nil, err
+	}
+	tokens := make(map[string]string)
+	b, err := ioutil.ReadFile(fp)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func cacheToken(host, token string) error {
+	tokens, err := readTokens()
+	if err != nil {
+		return err
+	}
+	tokens[host] = token
+
+	fp, err := tokenCacheFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fp), 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fp, append(b, '\n'), 0600)
+}
+
+// Get the token cached for the host of an HTTP(S) remote. Empty if there is none.
+func cachedToken(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return ""
+	}
+	tokens, err := readTokens()
+	if err != nil {
+		return ""
+	}
+	return tokens[u.Hostname()]
+}
+
+// Obtains a token through the device flow of GitHub or GitLab and caches it. Repos on the host that are polled over
+// HTTPS without a username or password then use the token.
+func login(args []string) int {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	provider := fs.String("provider", "github", "The git provider, either github or gitlab.")
+	host := fs.String("host", "", "The host of the provider. Defaults to github.com or gitlab.com.")
+	clientId := fs.String("client-id", os.Getenv("GPOLL_OAUTH_CLIENT_ID"),
+		"The client ID of the OAuth app. Defaults to the GPOLL_OAUTH_CLIENT_ID environment variable.")
+	_ = fs.Parse(args)
+
+	if *clientId == "" {
+		fmt.Fprintln(os.Stderr, "-client-id is required")
+		return 2
+	}
+	if *host == "" {
+		*host = *provider + ".com"
+	}
+
+	if err := deviceLogin(*provider, *host, *clientId, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	fmt.Fprintf(os.Stdout, "Logged in to %s\n", *host)
+	return 0
+}
+
+func deviceLogin(provider, host, clientId string, out io.Writer) error {
+	flow, err := newDeviceFlow(provider, host)
+	if err != nil {
+		return err
+	}
+	token, err := flow.login(clientId, out)
+	if err != nil {
+		return err
+	}
+	return cacheToken(host, token)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type LoginTest struct {
+	suite.Suite
+
+	home string
+	// The HOME to restore after the test.
+	realHome string
+	// The responses of the token endpoint, in order. The last is repeated.
+	tokens []deviceToken
+	lock   sync.Mutex
+	forms  []map[string]string
+	server *httptest.Server
+}
+
+func (s *LoginTest) SetupTest() {
+	home, err := ioutil.TempDir("", "gpoll")
+	s.Require().NoError(err)
+	s.home = home
+	// The token cache is kept in the home directory.
+	s.realHome = os.Getenv("HOME")
+	s.Require().NoError(os.Setenv("HOME", home))
+
+	s.forms = make([]map[string]string, 0)
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		form := make(map[string]string)
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		s.forms = append(s.forms, form)
+
+		var resp interface{}
+		switch r.URL.Path {
+		case "/code":
+			resp = deviceCode{
+				DeviceCode:      "device",
+				UserCode:        "ABCD-1234",
+				VerificationUri: "https://example.com/device",
+				ExpiresIn:       60,
+				Interval:        1,
+			}
+		case "/token":
+			resp = s.tokens[0]
+			if len(s.tokens) > 1 {
+				s.tokens = s.tokens[1:]
+			}
+			if resp.(deviceToken).Error != "" {
+				// As GitLab does.
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func (s *LoginTest) TearDownTest() {
+	s.server.Close()
+	_ = os.Setenv("HOME", s.realHome)
+	_ = os.RemoveAll(s.home)
+}
+
+func (s *LoginTest) flow() *deviceFlow {
+	return &deviceFlow{codeUrl: s.server.URL + "/code", tokenUrl: s.server.URL + "/token", scope: "repo"}
+}
+
+func (s *LoginTest) TestPollsUntilAuthorized() {
+	// -- Given
+	//
+	s.tokens = []deviceToken{{Error: "authorization_pending"}, {AccessToken: "token"}}
+	out := &strings.Builder{}
+
+	// -- When
+	//
+	token, err := s.flow().login("client", out)
+
+	// -- Then
+	//
+	s.NoError(err)
+	s.Equal("token", token)
+	s.Contains(out.String(), "Open https://example.com/device and enter the code ABCD-1234")
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.Len(s.forms, 3) {
+		s.Equal(map[string]string{"client_id": "client", "scope": "repo"}, s.forms[0])
+		s.Equal("device", s.forms[2]["device_code"])
+		s.Equal("urn:ietf:params:oauth:grant-type:device_code", s.forms[2]["grant_type"])
+	}
+}
+
+func (s *LoginTest) TestFailsWhenDenied() {
+	// -- Given
+	//
+	s.tokens = []deviceToken{{Error: "access_denied"}}
+
+	// -- When
+	//
+	_, err := s.flow().login("client", ioutil.Discard)
+
+	// -- Then
+	//
+	s.EqualError(err, "authorization failed: access_denied")
+}
+
+func (s *LoginTest) TestCachedTokenAuthenticatesHttpsRemotes() {
+	// -- Given
+	//
+	s.Require().NoError(cacheToken("github.com", "token"))
+	s.Require().NoError(cacheToken("gitlab.com", "other"))
+
+	// -- When
+	//
+	https := &repoFlags{remote: "https://github.com/acme/api.git"}
+	ssh := &repoFlags{remote: "git@github.com:acme/api.git"}
+	explicit := &repoFlags{remote: "https://github.com/acme/api.git", username: "jane", password: "secret"}
+
+	// -- Then
+	//
+	auth := https.pollConfig().Git.Auth
+	s.Equal(oauthUsername, auth.Username)
+	s.Equal("token", auth.Password)
+	s.Empty(ssh.pollConfig().Git.Auth.Password)
+	s.Equal("secret", explicit.pollConfig().Git.Auth.Password)
+	s.Equal("other", cachedToken("https://gitlab.com/group/api.git"))
+	info, err := os.Stat(filepath.Join(s.home, ".gpoll", "tokens.json"))
+	s.Require().NoError(err)
+	s.Equal(os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestLogin(t *testing.T) {
+	suite.Run(t, new(LoginTest))
+}
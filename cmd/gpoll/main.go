@@ -0,0 +1,66 @@
+// Command gpoll polls Git repositories from the command line.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string) int
+}
+
+var commands = []command{
+	{
+		name:        "init",
+		description: "Interactively create a config file.",
+		run:         initConfig,
+	},
+	{
+		name:        "login",
+		description: "Log in to GitHub or GitLab through the browser and cache the token.",
+		run:         login,
+	},
+	{
+		name:        "diff",
+		description: "Print the files changed since a commit or duration. Exits with 0 if none changed, 1 if any did and 2 on error.",
+		run:         diff,
+	},
+	{
+		name:        "watch",
+		description: "Watch repos and show live commits, changed files, lag and errors per repo.",
+		run:         watch,
+	},
+	{
+		name:        "sidecar",
+		description: "Write commits and events as JSON lines to a Unix socket or named pipe.",
+		run:         sidecar,
+	},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			os.Exit(c.run(os.Args[2:]))
+		}
+	}
+
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: gpoll <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.description)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/eddieowens/gpoll"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Polls a repo and writes every commit and event as newline delimited JSON to a Unix domain socket or a named pipe so
+// processes written in any language can consume them.
+func sidecar(args []string) int {
+	fs := flag.NewFlagSet("sidecar", flag.ExitOnError)
+	rf := &repoFlags{}
+	rf.register(fs)
+	socket := fs.String("socket", "", "A Unix domain socket to listen on. Every connected client receives every line.")
+	pipe := fs.String("pipe", "", "A named pipe to write to. Created if it doesn't exist.")
+	if err := rf.parse(fs, args); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 2
+	}
+	if (*socket == "") == (*pipe == "") {
+		fmt.Fprintln(os.Stderr, "exactly one of -socket or -pipe is required")
+		return 2
+	}
+
+	out, err := gpoll.NewSidecar(gpoll.SidecarConfig{
+		Socket: *socket,
+		Pipe:   *pipe,
+		OnError: func(err error) {
+			fmt.Fprintln(os.Stderr, err.Error())
+		},
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer out.Close()
+
+	config := rf.pollConfig()
+	config.HandleCommit = out.HandleCommit
+	config.HandleEvent = out.HandleEvent
+	config.OnError = func(err error) {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+
+	poller, err := gpoll.NewPoller(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	c, err := poller.StartAsync()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	go func() {
+		for range c {
+		}
+	}()
+
+	<-signals
+	poller.StopAndWait()
+	return 0
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/eddieowens/gpoll"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	watchMaxCommits = 10
+	watchMaxErrors  = 5
+	clearScreen     = "\033[H\033[2J"
+)
+
+// Watches the repo of the flags, or every repo listed in the config file, rendering a section per repo.
+func watch(args []string) int {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	rf := &repoFlags{}
+	rf.register(fs)
+	refresh := fs.Duration("refresh", time.Second, "How often the screen is redrawn.")
+	tmpl := fs.String("template", "", "A Go text/template over each commit shown in place of the default format.")
+	if err := rf.parse(fs, args); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 2
+	}
+
+	var t *gpoll.CommitTemplate
+	if *tmpl != "" {
+		var err error
+		if t, err = gpoll.NewCommitTemplate(*tmpl); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 2
+		}
+	}
+	repos, err := rf.repos()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 2
+	}
+	configs := make(map[string]gpoll.PollConfig)
+	for _, r := range repos {
+		if _, ok := configs[r.repoID()]; ok {
+			fmt.Fprintf(os.Stderr, "more than one repo is named %s\n", r.repoID())
+			return 2
+		}
+		configs[r.repoID()] = r.pollConfig()
+	}
+
+	group, err := gpoll.NewPollerGroup(configs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	views := make(map[string]*watchView)
+	for _, id := range group.Repos() {
+		p, err := group.Poller(id)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+		views[id] = &watchView{name: id, poller: p, template: t}
+	}
+
+	// Registered before starting so a SIGTERM from e.g. docker stop during the clone isn't lost. Stopping waits for the
+	// in-flight delivery so the checkpoint holds the last delivered commit.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	for _, id := range group.Repos() {
+		fmt.Fprintf(os.Stdout, "Cloning %s...\n", configs[id].Git.Remote)
+	}
+	events, err := group.Start()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	go func() {
+		for e := range events {
+			view := views[e.Repo]
+			switch {
+			case e.Commit != nil:
+				view.addCommit(*e.Commit)
+			case e.Err != nil:
+				view.addError(e.Err)
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(*refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			renderViews(os.Stdout, group.Repos(), views)
+		case sig := <-signals:
+			fmt.Fprintf(os.Stdout, "Received %s, stopping...\n", sig)
+			group.Stop()
+			return 0
+		}
+	}
+}
+
+type watchError struct {
+	when time.Time
+	err  error
+}
+
+// A live view of a single polled repo.
+type watchView struct {
+	lock    sync.Mutex
+	name    string
+	poller  gpoll.Poller
+	commits []gpoll.CommitDiff
+	errors  []watchError
+	// Formats the commits if set.
+	template *gpoll.CommitTemplate
+}
+
+func (w *watchView) addCommit(commit gpoll.CommitDiff) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.commits = append(w.commits, commit)
+	if len(w.commits) > watchMaxCommits {
+		w.commits = w.commits[1:]
+	}
+}
+
+func (w *watchView) addError(err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.errors = append(w.errors, watchError{when: time.Now(), err: err})
+	if len(w.errors) > watchMaxErrors {
+		w.errors = w.errors[1:]
+	}
+}
+
+// Clears the screen and renders the view of every repo, in order.
+func renderViews(out io.Writer, ids []string, views map[string]*watchView) {
+	b := &strings.Builder{}
+	b.WriteString(clearScreen)
+	for i, id := range ids {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		views[id].render(b)
+	}
+	_, _ = io.WriteString(out, b.String())
+}
+
+func (w *watchView) render(out io.Writer) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	s := w.poller.Status()
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "== %s: %s (%s)\n", w.name, s.Remote, s.Branch)
+	state := "running"
+	if s.Paused {
+		state = "paused"
+	} else if !s.Running {
+		state = "stopped"
+	}
+	fmt.Fprintf(b, "state: %s  delivered: %d  held: %d  last poll: %s\n", state, s.Sequence, s.Held,
+		formatSince(s.LastPoll))
+	if s.LastError != nil {
+		fmt.Fprintf(b, "last poll failed: %s\n", s.LastError.Error())
+	}
+
+	b.WriteString("\nCommits\n")
+	if len(w.commits) == 0 {
+		b.WriteString("  waiting for commits...\n")
+	}
+	for i := len(w.commits) - 1; i >= 0; i-- {
+		c := w.commits[i]
+		if w.template != nil {
+			writeTemplate(b, w.template, c)
+			continue
+		}
+		lag := c.ReceivedAt.Sub(c.To.Committer.When).Round(time.Second)
+		fmt.Fprintf(b, "  %.7s %-50.50s %s (lag %s)\n", c.To.Sha, firstLine(c.To.Message), c.To.Author.Name, lag)
+		for _, f := range c.Changes {
+			fmt.Fprintf(b, "      %-6s %s\n", f.ChangeType, f.Filepath)
+		}
+	}
+
+	if len(w.errors) > 0 {
+		b.WriteString("\nErrors\n")
+		for i := len(w.errors) - 1; i >= 0; i-- {
+			e := w.errors[i]
+			fmt.Fprintf(b, "  %s %s\n", e.when.Format(time.Kitchen), e.err.Error())
+		}
+	}
+
+	_, _ = io.WriteString(out, b.String())
+}
+
+func formatSince(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return time.Since(t).Round(time.Second).String() + " ago"
+}
+
+func firstLine(s string) string {
+	return strings.SplitN(strings.TrimSpace(s), "\n", 2)[0]
+}
+
+// Writes the commit as rendered by the template, ending it with a newline if it doesn't already.
+func writeTemplate(w io.Writer, t *gpoll.CommitTemplate, commit gpoll.CommitDiff) {
+	s, err := t.Execute(commit)
+	if err != nil {
+		s = err.Error()
+	}
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	_, _ = io.WriteString(w, s)
+}
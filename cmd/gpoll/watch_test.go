@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/gpolltest/server"
+	"github.com/eddieowens/gpoll/mocks"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type WatchTest struct {
+	suite.Suite
+
+	poller *mocks.Poller
+	view   *watchView
+}
+
+func (s *WatchTest) SetupTest() {
+	s.poller = new(mocks.Poller)
+	s.view = &watchView{poller: s.poller}
+}
+
+func (s *WatchTest) TestRendersStatusCommitsAndErrors() {
+	// -- Given
+	//
+	s.poller.On("Status").Return(gpoll.Status{
+		Remote:    "https://example.com/repo.git",
+		Branch:    "master",
+		Running:   true,
+		Sequence:  3,
+		LastError: errors.New("poll failed"),
+	})
+	s.view.addCommit(gpoll.CommitDiff{
+		To: gpoll.Commit{
+			Sha:     "0123456789abcdef",
+			Message: "add config\n\nwith details",
+			Author:  gpoll.Author{Name: "Jane"},
+		},
+		Changes: []gpoll.FileChange{{Filepath: "a.yaml", ChangeType: gpoll.ChangeTypeCreate}},
+	})
+	s.view.addError(errors.New("handler failed"))
+
+	// -- When
+	//
+	out := &strings.Builder{}
+	s.view.render(out)
+
+	// -- Then
+	//
+	rendered := out.String()
+	s.Contains(rendered, "https://example.com/repo.git (master)")
+	s.Contains(rendered, "state: running  delivered: 3")
+	s.Contains(rendered, "last poll failed: poll failed")
+	s.Contains(rendered, "0123456 add config")
+	s.NotContains(rendered, "with details")
+	s.Contains(rendered, "a.yaml")
+	s.Contains(rendered, "handler failed")
+}
+
+func (s *WatchTest) TestKeepsMostRecentCommits() {
+	// -- Given
+	//
+	s.poller.On("Status").Return(gpoll.Status{Paused: true})
+	tmpl, err := gpoll.NewCommitTemplate(`{{.To.Sha}}`)
+	s.Require().NoError(err)
+	s.view.template = tmpl
+
+	// -- When
+	//
+	for i := 0; i < watchMaxCommits+1; i++ {
+		s.view.addCommit(gpoll.CommitDiff{To: gpoll.Commit{Sha: fmt.Sprintf("sha-%d", i)}})
+	}
+	out := &strings.Builder{}
+	s.view.render(out)
+
+	// -- Then
+	//
+	rendered := out.String()
+	s.Contains(rendered, "state: paused")
+	s.NotContains(rendered, "sha-0\n")
+	s.Contains(rendered, fmt.Sprintf("sha-%d\n", watchMaxCommits))
+	s.Less(strings.Index(rendered, fmt.Sprintf("sha-%d\n", watchMaxCommits)), strings.Index(rendered, "sha-1\n"))
+}
+
+func (s *WatchTest) TestRendersSectionPerRepo() {
+	// -- Given
+	//
+	infra, apps := new(mocks.Poller), new(mocks.Poller)
+	infra.On("Status").Return(gpoll.Status{Remote: "https://example.com/infra.git", Branch: "main", Running: true})
+	apps.On("Status").Return(gpoll.Status{Remote: "https://example.com/apps.git", Branch: "main"})
+	views := map[string]*watchView{
+		"infra": {name: "infra", poller: infra},
+		"apps":  {name: "apps", poller: apps},
+	}
+	views["apps"].addError(errors.New("clone failed"))
+
+	// -- When
+	//
+	out := &strings.Builder{}
+	renderViews(out, []string{"apps", "infra"}, views)
+
+	// -- Then
+	//
+	rendered := out.String()
+	s.True(strings.HasPrefix(rendered, clearScreen))
+	s.Equal(1, strings.Count(rendered, clearScreen))
+	appsAt := strings.Index(rendered, "== apps: https://example.com/apps.git (main)")
+	infraAt := strings.Index(rendered, "== infra: https://example.com/infra.git (main)")
+	errorAt := strings.Index(rendered, "clone failed")
+	s.True(appsAt >= 0 && infraAt >= 0)
+	s.True(appsAt < errorAt && errorAt < infraAt)
+	s.Contains(rendered[infraAt:], "state: running")
+	s.Contains(rendered[appsAt:infraAt], "state: stopped")
+}
+
+func TestWatch(t *testing.T) {
+	suite.Run(t, new(WatchTest))
+}
+
+type WatchSignalTest struct {
+	suite.Suite
+
+	server *server.Server
+	dir    string
+}
+
+func (s *WatchSignalTest) SetupTest() {
+	srv, err := server.New()
+	s.Require().NoError(err)
+	s.server = srv
+	dir, err := ioutil.TempDir("", "gpoll")
+	s.Require().NoError(err)
+	s.dir = dir
+}
+
+func (s *WatchSignalTest) TearDownTest() {
+	s.server.Close()
+	_ = os.RemoveAll(s.dir)
+}
+
+func (s *WatchSignalTest) TestSigtermStopsAndWritesCheckpoint() {
+	// -- Given
+	//
+	config := s.server.GitConfig()
+	healthcheck := filepath.Join(s.dir, "healthy")
+	checkpoint := filepath.Join(s.dir, "checkpoint")
+	exited := make(chan int, 1)
+	go func() {
+		exited <- watch([]string{
+			"-remote", config.Remote,
+			"-branch", config.Branch,
+			"-username", config.Auth.Username,
+			"-password", config.Auth.Password,
+			"-dir", filepath.Join(s.dir, "clone"),
+			"-interval", "10ms",
+			"-refresh", "1h",
+			"-healthcheck-file", healthcheck,
+			"-checkpoint-file", checkpoint,
+		})
+	}()
+	// The healthcheck is touched once the poller is running, after the signals are registered.
+	s.Eventually(func() bool {
+		_, err := os.Stat(healthcheck)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	sha, err := s.server.Commit("add a", map[string]string{"a.txt": "a"})
+	s.Require().NoError(err)
+	s.Eventually(func() bool {
+		b, err := ioutil.ReadFile(checkpoint)
+		return err == nil && strings.TrimSpace(string(b)) == sha
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// -- When
+	//
+	s.Require().NoError(syscall.Kill(os.Getpid(), syscall.SIGTERM))
+
+	// -- Then
+	//
+	select {
+	case code := <-exited:
+		s.Equal(0, code)
+	case <-time.After(5 * time.Second):
+		s.FailNow("watch did not stop on SIGTERM")
+	}
+	b, err := ioutil.ReadFile(checkpoint)
+	s.NoError(err)
+	s.Equal(sha, strings.TrimSpace(string(b)))
+}
+
+func TestWatchSignal(t *testing.T) {
+	suite.Run(t, new(WatchSignalTest))
+}
@@ -0,0 +1,106 @@
+package gpoll
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const defaultSlowConsumerWindow = 100
+
+type SlowConsumerConfig struct {
+	// The 95th percentile of how long commits wait between being received from the remote and being handled, above
+	// which a SlowConsumer event is emitted. If not set, the wait is still reported through Status but nothing is
+	// emitted.
+	Threshold time.Duration
+
+	// The number of most recently delivered commits the percentile is taken over. Defaults to 100.
+	Window int
+}
+
+// Emitted when the 95th percentile of how long commits wait to be handled rises above the SlowConsumer Threshold, e.g.
+// because a handler or the reader of the channel can't keep up with the repo. Emitted again only once the percentile
+// has dropped back below the threshold in the meantime.
+type SlowConsumer struct {
+	// The 95th percentile of the wait over the Window.
+	P95 time.Duration
+
+	// The threshold that was exceeded.
+	Threshold time.Duration
+
+	// The number of commits waiting to be handled at the time.
+	QueueDepth int
+}
+
+func (s SlowConsumer) EventType() EventType {
+	return EventTypeSlowConsumer
+}
+
+func (s SlowConsumer) String() string {
+	return fmt.Sprintf("p95 wait of %s to handle commits exceeds %s with %d commits queued", s.P95, s.Threshold,
+		s.QueueDepth)
+}
+
+// The most recent waits of delivered commits, oldest overwritten first.
+type waitWindow struct {
+	waits []time.Duration
+	next  int
+	size  int
+}
+
+func newWaitWindow(size int) *waitWindow {
+	return &waitWindow{waits: make([]time.Duration, size)}
+}
+
+func (w *waitWindow) add(wait time.Duration) {
+	w.waits[w.next] = wait
+	w.next = (w.next + 1) % len(w.waits)
+	if w.size < len(w.waits) {
+		w.size++
+	}
+}
+
+// Get the 95th percentile of the waits using the nearest rank. Zero if there are none.
+func (w *waitWindow) p95() time.Duration {
+	if w.size == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, w.size)
+	copy(sorted, w.waits[:w.size])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := (95*w.size + 99) / 100
+	return sorted[rank-1]
+}
+
+// The number of commits waiting to be handled, both those being delivered and those the loop holds back. Must be
+// called while holding the lock.
+func (p *poller) queueDepth() int {
+	return p.queued + p.lag
+}
+
+// Records that the commit was handled and how long it waited, emitting a SlowConsumer event if the percentile crossed
+// the threshold.
+func (p *poller) recordWait(commit CommitDiff) {
+	p.lock.Lock()
+	p.queued--
+	if commit.ReceivedAt.IsZero() {
+		p.lock.Unlock()
+		return
+	}
+	p.waits.add(time.Since(commit.ReceivedAt))
+	p95 := p.waits.p95()
+	threshold := p.config.SlowConsumer.Threshold
+	slow := threshold > 0 && p95 > threshold
+	crossed := slow && !p.slowConsumer
+	p.slowConsumer = slow
+	depth := p.queueDepth()
+	p.lock.Unlock()
+
+	if crossed {
+		p.emit(SlowConsumer{
+			P95:        p95,
+			Threshold:  threshold,
+			QueueDepth: depth,
+		})
+	}
+}
@@ -0,0 +1,211 @@
+// Renders the files of a content directory kept in git and publishes the outputs for every delivered commit, e.g. to
+// build a static site from markdown. Rendering and publishing are pluggable through a Renderer and a Publisher.
+package contentsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eddieowens/gpoll"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The name of the Handler a Sync is installed as by Install.
+const HandlerName = "contentsync"
+
+var ErrNoSnapshot = errors.New("contentsync requires PollConfig.Snapshots to read the files of a commit")
+
+// A rendered file.
+type Output struct {
+	// The slash separated path of the output at the destination.
+	Path string
+
+	// The rendered content.
+	Content []byte
+
+	// The media type of the content e.g. text/html. Only used by publishers that store it, like S3.
+	ContentType string
+}
+
+// Renders a content file into the outputs to publish.
+type Renderer interface {
+	// Render the file at the slash separated path, relative to the content Dir. Returning no outputs publishes nothing
+	// for the file e.g. for partials only included by other files.
+	Render(ctx context.Context, path string, content []byte) ([]Output, error)
+}
+
+// Adapts a function to a Renderer.
+type RenderFunc func(ctx context.Context, path string, content []byte) ([]Output, error)
+
+func (r RenderFunc) Render(ctx context.Context, path string, content []byte) ([]Output, error) {
+	return r(ctx, path, content)
+}
+
+// Publishes outputs to a destination e.g. a directory served by a web server or an S3 bucket.
+type Publisher interface {
+	// Write the outputs, replacing any existing output at the same path.
+	Publish(ctx context.Context, outputs []Output) error
+
+	// Remove the outputs at the slash separated paths. Paths that don't exist are ignored.
+	Remove(ctx context.Context, paths []string) error
+}
+
+type Config struct {
+	// The slash separated path of the content directory, relative to the root of the repo. Defaults to the whole repo.
+	Dir string
+
+	// Renders every created or updated file within the Dir. Required.
+	Renderer Renderer `validate:"required"`
+
+	// Where the outputs are published. Required.
+	Publisher Publisher `validate:"required"`
+
+	// Called when a commit fails to render or publish.
+	OnError func(err error)
+}
+
+// Renders and publishes the content of every delivered commit. Every file is rendered on start, after which only the
+// files changed by each commit are. Outputs of deleted files, and outputs a file no longer renders, are removed from
+// the destination. Which outputs a file rendered is only known since the start, so outputs of files deleted while the
+// poller wasn't running are left behind.
+type Sync struct {
+	config Config
+
+	lock sync.Mutex
+	// The output paths rendered from each file, keyed by its path relative to the Dir.
+	outputs map[string][]string
+}
+
+// Create a Sync from config.
+func New(config Config) *Sync {
+	config.Dir = strings.Trim(config.Dir, "/")
+	return &Sync{
+		config:  config,
+		outputs: make(map[string][]string),
+	}
+}
+
+// Configure the poller to deliver commits to the Sync: snapshots are enabled, paths are made relative to the root of
+// the repo and the Sync is added as a Handler named HandlerName.
+func (s *Sync) Install(config *gpoll.PollConfig) {
+	config.Snapshots = true
+	config.FilepathMode = gpoll.FilepathModeRepoRelative
+	config.FilepathSeparator = gpoll.SeparatorSlash
+	config.Handlers = append(config.Handlers, gpoll.Handler{
+		Name:   HandlerName,
+		Handle: s.HandleCommit,
+	})
+}
+
+// Render the files of the Dir changed by the commit and publish the outputs. The commit must have a Snapshot and
+// repo relative, slash separated paths, see Install.
+func (s *Sync) HandleCommit(ctx context.Context, commit gpoll.CommitDiff) {
+	if err := s.Apply(ctx, commit); err != nil && s.config.OnError != nil {
+		s.config.OnError(err)
+	}
+}
+
+// Render and publish the commit like HandleCommit, returning the error.
+func (s *Sync) Apply(ctx context.Context, commit gpoll.CommitDiff) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	rendered := make(map[string][]string)
+	removed := make([]string, 0)
+	outputs := make([]Output, 0)
+	for _, change := range commit.Changes {
+		rel, ok := s.relative(change.Filepath)
+		if !ok {
+			continue
+		}
+		if change.ChangeType == gpoll.ChangeTypeDelete {
+			removed = append(removed, rel)
+			continue
+		}
+		if commit.Snapshot == nil {
+			return ErrNoSnapshot
+		}
+		content, err := readFile(commit, change.Filepath)
+		if err != nil {
+			return err
+		}
+		out, err := s.config.Renderer.Render(ctx, rel, content)
+		if err != nil {
+			return fmt.Errorf("failed to render %s at %s: %w", rel, commit.To.Sha, err)
+		}
+		paths := make([]string, len(out))
+		for i, o := range out {
+			paths[i] = o.Path
+		}
+		rendered[rel] = paths
+		outputs = append(outputs, out...)
+	}
+
+	if len(outputs) > 0 {
+		if err := s.config.Publisher.Publish(ctx, outputs); err != nil {
+			return fmt.Errorf("failed to publish %s: %w", commit.To.Sha, err)
+		}
+	}
+
+	stale := make([]string, 0)
+	for rel, paths := range rendered {
+		stale = append(stale, s.outputs[rel]...)
+		s.outputs[rel] = paths
+	}
+	for _, rel := range removed {
+		stale = append(stale, s.outputs[rel]...)
+		delete(s.outputs, rel)
+	}
+	stale = s.unowned(stale)
+	if len(stale) == 0 {
+		return nil
+	}
+	if err := s.config.Publisher.Remove(ctx, stale); err != nil {
+		return fmt.Errorf("failed to remove outputs of %s: %w", commit.To.Sha, err)
+	}
+	return nil
+}
+
+// Gets the path relative to the Dir if the repo relative path is within it.
+func (s *Sync) relative(fp string) (string, bool) {
+	if s.config.Dir == "" {
+		return fp, true
+	}
+	if !strings.HasPrefix(fp, s.config.Dir+"/") {
+		return "", false
+	}
+	return strings.TrimPrefix(fp, s.config.Dir+"/"), true
+}
+
+func readFile(commit gpoll.CommitDiff, fp string) ([]byte, error) {
+	f, err := commit.Snapshot.Open(path.Clean(fp))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// Gets the sorted, unique paths that no file renders anymore.
+func (s *Sync) unowned(paths []string) []string {
+	owned := make(map[string]bool)
+	for _, out := range s.outputs {
+		for _, p := range out {
+			owned[p] = true
+		}
+	}
+	res := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if owned[p] {
+			continue
+		}
+		owned[p] = true
+		res = append(res, p)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,103 @@
+package contentsync
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Publishes outputs as files within a directory e.g. the root of a web server.
+type DirPublisher struct {
+	// The directory the outputs are written to. Created if it doesn't exist.
+	Dir string
+}
+
+// Every output is written to a temp file that is then renamed over the output so readers never see a partial file.
+func (d *DirPublisher) Publish(ctx context.Context, outputs []Output) error {
+	for _, o := range outputs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fp := filepath.Join(d.Dir, filepath.FromSlash(path.Clean("/"+o.Path)))
+		if err := os.MkdirAll(filepath.Dir(fp), 0755); err != nil {
+			return err
+		}
+		tmp, err := ioutil.TempFile(filepath.Dir(fp), "."+filepath.Base(fp)+".tmp")
+		if err != nil {
+			return err
+		}
+		_, err = tmp.Write(o.Content)
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Chmod(tmp.Name(), 0644)
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), fp)
+		}
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DirPublisher) Remove(ctx context.Context, paths []string) error {
+	for _, p := range paths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := os.Remove(filepath.Join(d.Dir, filepath.FromSlash(path.Clean("/"+p))))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// The operations of an S3 client an S3Publisher needs, so any SDK can be plugged in, e.g. by wrapping the PutObject
+// and DeleteObjects of the AWS SDK.
+type S3Client interface {
+	// Store the body at the key of the bucket with the content type.
+	PutObject(ctx context.Context, bucket, key string, body []byte, contentType string) error
+
+	// Delete the keys from the bucket. Keys that don't exist are ignored.
+	DeleteObjects(ctx context.Context, bucket string, keys []string) error
+}
+
+// Publishes outputs as objects in an S3 bucket e.g. one serving a static website.
+type S3Publisher struct {
+	// Performs the requests. Required.
+	Client S3Client
+
+	// The bucket the outputs are stored in. Required.
+	Bucket string
+
+	// Prepended to the path of every output to get its key e.g. site/. Defaults to no prefix.
+	Prefix string
+}
+
+func (s *S3Publisher) Publish(ctx context.Context, outputs []Output) error {
+	for _, o := range outputs {
+		if err := s.Client.PutObject(ctx, s.Bucket, s.key(o.Path), o.Content, o.ContentType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *S3Publisher) Remove(ctx context.Context, paths []string) error {
+	keys := make([]string, len(paths))
+	for i, p := range paths {
+		keys[i] = s.key(p)
+	}
+	return s.Client.DeleteObjects(ctx, s.Bucket, keys)
+}
+
+func (s *S3Publisher) key(p string) string {
+	return s.Prefix + path.Clean("/" + p)[1:]
+}
@@ -0,0 +1,80 @@
+package gpoll
+
+import (
+	"expvar"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"runtime"
+	"time"
+)
+
+// Runtime internals of a Poller for debugging.
+type DebugInfo struct {
+	Status
+
+	// When the remote was last cloned.
+	ClonedAt time.Time
+
+	// An estimate of the size in bytes of the git objects held by the clone.
+	StorageBytes int64
+
+	// The number of goroutines in the process.
+	Goroutines int
+
+	// The number of bytes allocated on the heap by the process.
+	HeapBytes uint64
+
+	// When the process last garbage collected. Zero if it never has.
+	LastGC time.Time
+}
+
+func (p *poller) Debug() DebugInfo {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	p.lock.RLock()
+	clonedAt := p.clonedAt
+	p.lock.RUnlock()
+
+	d := DebugInfo{
+		Status:     p.Status(),
+		ClonedAt:   clonedAt,
+		Goroutines: runtime.NumGoroutine(),
+		HeapBytes:  mem.HeapAlloc,
+	}
+	if mem.LastGC > 0 {
+		d.LastGC = time.Unix(0, int64(mem.LastGC))
+	}
+	err := p.WithRepo(func(repo *git.Repository) error {
+		size, err := storageSize(repo.Storer)
+		d.StorageBytes = size
+		return err
+	})
+	if err != nil && err != ErrNotStarted {
+		p.onError(err)
+	}
+	return d
+}
+
+// Sums the size of every object in the storage. Objects are counted uncompressed so this overestimates storage on disk.
+func storageSize(s storer.EncodedObjectStorer) (int64, error) {
+	iter, err := s.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	err = iter.ForEach(func(o plumbing.EncodedObject) error {
+		size += o.Size()
+		return nil
+	})
+	return size, err
+}
+
+// Publish the Poller's DebugInfo as an expvar under the name so it is served by the expvar handler at /debug/vars.
+// Panics if the name is already published.
+func PublishExpvar(name string, p Poller) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return p.Debug()
+	}))
+}
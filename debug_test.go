@@ -0,0 +1,78 @@
+package gpoll_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+	"time"
+)
+
+type DebugTest struct {
+	serverSuite
+}
+
+func (s *DebugTest) TestReportsRuntimeInternals() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{})
+	unstarted := p.Debug()
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	started := p.Debug()
+	s.commit("add a", map[string]string{"a.txt": strings.Repeat("a", 4096)})
+	s.receive(c)
+	grown := p.Debug()
+
+	// -- Then
+	//
+	s.True(unstarted.ClonedAt.IsZero())
+	s.Zero(unstarted.StorageBytes)
+	s.False(unstarted.Running)
+
+	s.True(started.Running)
+	s.False(started.ClonedAt.IsZero())
+	s.True(started.StorageBytes > 0)
+	s.True(started.Goroutines > 0)
+	s.True(started.HeapBytes > 0)
+
+	s.Equal(started.ClonedAt, grown.ClonedAt)
+	s.GreaterOrEqual(grown.StorageBytes, started.StorageBytes+4096)
+}
+
+func (s *DebugTest) TestPublishesExpvar() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{})
+	s.start(p)
+	defer p.StopAndWait()
+	// Published names can't be reused within the process.
+	name := fmt.Sprintf("gpoll-debug-%d", time.Now().UnixNano())
+
+	// -- When
+	//
+	gpoll.PublishExpvar(name, p)
+
+	// -- Then
+	//
+	v := expvar.Get(name)
+	s.Require().NotNil(v)
+	var d gpoll.DebugInfo
+	s.Require().NoError(json.Unmarshal([]byte(v.String()), &d))
+	s.Equal(s.server.GitConfig().Remote, d.Remote)
+	s.False(d.ClonedAt.IsZero())
+	s.True(d.StorageBytes > 0)
+	s.Panics(func() {
+		gpoll.PublishExpvar(name, p)
+	})
+}
+
+func TestDebug(t *testing.T) {
+	suite.Run(t, new(DebugTest))
+}
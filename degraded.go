@@ -0,0 +1,84 @@
+package gpoll
+
+import (
+	"context"
+	"fmt"
+	"gopkg.in/src-d/go-git.v4"
+	"time"
+)
+
+// Emitted after every failed attempt at the initial clone while starting with StartDegraded.
+type Degraded struct {
+	// When the first attempt failed.
+	Since time.Time
+
+	// The number of failed attempts.
+	Attempts int
+
+	// The error from the last attempt.
+	Err error
+}
+
+func (d Degraded) EventType() EventType {
+	return EventTypeDegraded
+}
+
+func (d Degraded) String() string {
+	return fmt.Sprintf("initial clone failed %d times since %s: %s", d.Attempts, d.Since.Format(time.RFC3339),
+		d.Err.Error())
+}
+
+// Emitted once the initial clone succeeds after failing while starting with StartDegraded.
+type Recovered struct {
+	// How long the poller was degraded.
+	Downtime time.Duration
+
+	// The number of failed attempts.
+	Attempts int
+}
+
+func (r Recovered) EventType() EventType {
+	return EventTypeRecovered
+}
+
+func (r Recovered) String() string {
+	return fmt.Sprintf("initial clone succeeded after %d failed attempts over %s", r.Attempts, r.Downtime)
+}
+
+// Retries the initial clone that failed with the error, backing off from 1s up to the Interval, until it succeeds or
+// the context is done.
+func (p *poller) cloneDegraded(ctx context.Context, err error) (*git.Repository, error) {
+	since := time.Now()
+	p.lock.Lock()
+	p.degradedSince = since
+	p.lock.Unlock()
+	defer func() {
+		p.lock.Lock()
+		p.degradedSince = time.Time{}
+		p.lock.Unlock()
+	}()
+
+	backoff := time.Second
+	for attempts := 1; ; attempts++ {
+		p.onError(err)
+		p.emit(Degraded{Since: since, Attempts: attempts, Err: err})
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+		if backoff *= 2; backoff > p.config.Interval {
+			backoff = p.config.Interval
+		}
+
+		var repo *git.Repository
+		repo, err = p.git.Clone(ctx, p.config.Git.Remote, p.config.Git.Branch, p.config.Git.CloneDirectory)
+		if err == nil {
+			p.emit(Recovered{Downtime: time.Since(since), Attempts: attempts})
+			return repo, nil
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package gpoll
+
+import (
+	"context"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"time"
+)
+
+// Clone the remote and diff the head of its branch against an older revision. The revision is either a commit sha or
+// a duration e.g. 24h, in which case the diff is against the last commit made at least that long ago. If every commit
+// is newer than the duration, the diff is against the first commit. The paths of the returned changes are relative to
+// the root of the repo.
+func DiffSince(config GitConfig, since string) (*CommitDiff, error) {
+	g, err := newGit(config)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := g.Clone(context.Background(), config.Remote, config.Branch, config.CloneDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := g.HeadCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := resolveSince(repo, head, since)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.Diff(base, head)
+}
+
+func resolveSince(repo *git.Repository, head *object.Commit, since string) (*object.Commit, error) {
+	d, err := time.ParseDuration(since)
+	if err != nil {
+		h, err := repo.ResolveRevision(plumbing.Revision(since))
+		if err != nil {
+			return nil, err
+		}
+		return repo.CommitObject(*h)
+	}
+
+	cutoff := time.Now().Add(-d)
+	base := head
+	for !base.Committer.When.Before(cutoff) {
+		parent, err := base.Parents().Next()
+		if err != nil {
+			break
+		}
+		base = parent
+	}
+	return base, nil
+}
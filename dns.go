@@ -0,0 +1,174 @@
+package gpoll
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Which IP versions remotes are connected over.
+type IPFamily int
+
+const (
+	// Connect over whichever addresses the resolver returns first.
+	IPFamilyAny IPFamily = iota
+
+	// Try the IPv4 addresses of the remote before its IPv6 ones.
+	IPFamilyPreferIPv4
+
+	// Try the IPv6 addresses of the remote before its IPv4 ones.
+	IPFamilyPreferIPv6
+
+	// Only connect over IPv4 e.g. in containers with broken IPv6.
+	IPFamilyIPv4Only
+
+	// Only connect over IPv6.
+	IPFamilyIPv6Only
+)
+
+const defaultDNSPort = 53
+
+// Whether the transport resolves remotes itself rather than leaving it to go-git.
+func (t TransportConfig) resolves() bool {
+	return t.IPFamily != IPFamilyAny || len(t.DNSServers) > 0 || t.DNSTimeout > 0
+}
+
+// Resolves remotes with the configured DNS servers and connects to their addresses in order of the IPFamily.
+type resolvingDialer struct {
+	dialer  *net.Dialer
+	family  IPFamily
+	servers []string
+	timeout time.Duration
+}
+
+func newResolvingDialer(config TransportConfig) (*resolvingDialer, error) {
+	servers := make([]string, len(config.DNSServers))
+	for i, s := range config.DNSServers {
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			s = net.JoinHostPort(s, strconv.Itoa(defaultDNSPort))
+		}
+		if host, _, _ := net.SplitHostPort(s); net.ParseIP(host) == nil {
+			return nil, errors.New("DNS servers must be IP addresses, not " + host)
+		}
+		servers[i] = s
+	}
+	return &resolvingDialer{
+		dialer: &net.Dialer{
+			Timeout:   config.ConnectTimeout,
+			KeepAlive: config.KeepAlive,
+		},
+		family:  config.IPFamily,
+		servers: servers,
+		timeout: config.DNSTimeout,
+	}, nil
+}
+
+func (r *resolvingDialer) dial(target string) (net.Conn, error) {
+	return r.DialContext(context.Background(), "tcp", target)
+}
+
+func (r *resolvingDialer) Dial(network, addr string) (net.Conn, error) {
+	return r.DialContext(context.Background(), network, addr)
+}
+
+// Connects to each address of the host in turn until one accepts.
+func (r *resolvingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := r.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		var conn net.Conn
+		if conn, err = r.dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port)); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+func (r *resolvingDialer) close() {}
+
+// The addresses of the host in the order they are tried.
+func (r *resolvingDialer) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		var err error
+		if ips, err = r.lookup(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+
+	var v4, v6 []net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	switch r.family {
+	case IPFamilyPreferIPv4:
+		ips = append(v4, v6...)
+	case IPFamilyPreferIPv6:
+		ips = append(v6, v4...)
+	case IPFamilyIPv4Only:
+		ips = v4
+	case IPFamilyIPv6Only:
+		ips = v6
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no addresses of the allowed IP family", Name: host, IsNotFound: true}
+	}
+	return ips, nil
+}
+
+// Looks the host up with each DNS server in turn, giving each attempt the DNSTimeout.
+func (r *resolvingDialer) lookup(ctx context.Context, host string) ([]net.IP, error) {
+	network := "ip"
+	switch r.family {
+	case IPFamilyIPv4Only:
+		network = "ip4"
+	case IPFamilyIPv6Only:
+		network = "ip6"
+	}
+	if len(r.servers) == 0 {
+		return r.lookupWith(ctx, net.DefaultResolver, network, host)
+	}
+
+	var err error
+	for _, server := range r.servers {
+		server := server
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+		var ips []net.IP
+		if ips, err = r.lookupWith(ctx, resolver, network, host); err == nil {
+			return ips, nil
+		}
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+func (r *resolvingDialer) lookupWith(ctx context.Context, resolver *net.Resolver, network, host string) ([]net.IP, error) {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	return resolver.LookupIP(ctx, network, host)
+}
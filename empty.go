@@ -0,0 +1,70 @@
+package gpoll
+
+import (
+	"context"
+	"errors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"time"
+)
+
+// Whether the initial clone failed because the remote doesn't have any commits yet.
+func isEmptyRemote(err error) bool {
+	return errors.Is(err, transport.ErrEmptyRemoteRepository)
+}
+
+// Whether a failed initial clone is retried in the background rather than failing the start.
+func (p *poller) retriesClone(err error) bool {
+	return err != nil && (p.config.StartDegraded || isEmptyRemote(err))
+}
+
+// Retries the initial clone until it succeeds, waiting for the first commit if the remote is empty and for the remote
+// to recover with StartDegraded.
+func (p *poller) retryClone(ctx context.Context, err error) (*git.Repository, error) {
+	if isEmptyRemote(err) {
+		var repo *git.Repository
+		if repo, err = p.awaitFirstCommit(ctx); err == nil || !p.config.StartDegraded {
+			return repo, err
+		}
+	}
+	return p.cloneDegraded(ctx, err)
+}
+
+// Clones the remote every Interval until it has a commit, which is then delivered as the initial state as if the
+// remote always had it.
+func (p *poller) awaitFirstCommit(ctx context.Context) (*git.Repository, error) {
+	p.lock.Lock()
+	p.awaitingFirstCommit = true
+	p.lock.Unlock()
+	defer func() {
+		p.lock.Lock()
+		p.awaitingFirstCommit = false
+		p.lock.Unlock()
+	}()
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+		repo, err := p.git.Clone(ctx, p.config.Git.Remote, p.config.Git.Branch, p.config.Git.CloneDirectory)
+		if err == nil || !isEmptyRemote(err) {
+			return repo, err
+		}
+	}
+}
+
+// Replaces the error of a failed clone with transport.ErrEmptyRemoteRepository if the remote has no refs. go-git only
+// reports empty remotes that advertise nothing at all, while git hosts advertise their capabilities without any refs.
+func (g *gitImpl) emptyRemoteError(remote string, err error) error {
+	if err == nil || isEmptyRemote(err) {
+		return err
+	}
+	if s, serr := g.snapshotRemote(remote); serr == nil && len(s.Refs) == 0 {
+		return transport.ErrEmptyRemoteRepository
+	}
+	return err
+}
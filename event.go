@@ -0,0 +1,155 @@
+package gpoll
+
+import "reflect"
+
+// An Event is emitted by the Poller for anything notable that happens outside of normal commit delivery e.g. a commit
+// that violates a configured policy.
+type Event interface {
+	// The type of the event.
+	EventType() EventType
+}
+
+type EventType int
+
+const (
+	// A commit violated one of the configured policies. The event is a PolicyViolation.
+	EventTypePolicyViolation EventType = iota
+
+	// A potential secret was found within a commit. The event is a SecurityFinding.
+	EventTypeSecurityFinding
+
+	// A changed path would be unsafe to write to disk. The event is a PathWarning.
+	EventTypePathWarning
+
+	// A poll failed because the remote can't be reached. The event is an Unreachable.
+	EventTypeUnreachable
+
+	// The remote can be reached again after being unreachable. The event is a Reachable.
+	EventTypeReachable
+
+	// A ref on the remote changed in Mirror mode. The event is a RefChange.
+	EventTypeRefChange
+
+	// A commit's diff exceeded the configured size thresholds. The event is a LargeChange.
+	EventTypeLargeChange
+
+	// A commit failed validation. The event is a Quarantined.
+	EventTypeQuarantined
+
+	// The checkpoint was inconsistent with the local clone on start. The event is a Reconciliation.
+	EventTypeReconciliation
+
+	// The initial clone failed while starting with StartDegraded. The event is a Degraded.
+	EventTypeDegraded
+
+	// The initial clone succeeded after failing while starting with StartDegraded. The event is a Recovered.
+	EventTypeRecovered
+
+	// The standby clone replaced the clone being polled. The event is a Failover.
+	EventTypeFailover
+
+	// Commits wait too long to be handled. The event is a SlowConsumer.
+	EventTypeSlowConsumer
+
+	// The clone doesn't fit within the storage Quota. The event is a QuotaExceeded.
+	EventTypeQuotaExceeded
+
+	// Polls exceeded the resource Budget of the repo. The event is a BudgetExceeded.
+	EventTypeBudgetExceeded
+
+	// The branch was deleted on the remote. The event is a BranchMissing.
+	EventTypeBranchMissing
+
+	// The history of the branch was replaced by an unrelated one. The event is a HistoryReplaced.
+	EventTypeHistoryReplaced
+
+	// The checksums of the files at a delivered commit were computed. The event is a *Manifest.
+	EventTypeManifest
+)
+
+// The name of the event type e.g. policy-violation.
+func (t EventType) String() string {
+	switch t {
+	case EventTypePolicyViolation:
+		return "policy-violation"
+	case EventTypeSecurityFinding:
+		return "security-finding"
+	case EventTypePathWarning:
+		return "path-warning"
+	case EventTypeUnreachable:
+		return "unreachable"
+	case EventTypeReachable:
+		return "reachable"
+	case EventTypeRefChange:
+		return "ref-change"
+	case EventTypeLargeChange:
+		return "large-change"
+	case EventTypeQuarantined:
+		return "quarantined"
+	case EventTypeReconciliation:
+		return "reconciliation"
+	case EventTypeDegraded:
+		return "degraded"
+	case EventTypeRecovered:
+		return "recovered"
+	case EventTypeFailover:
+		return "failover"
+	case EventTypeSlowConsumer:
+		return "slow-consumer"
+	case EventTypeQuotaExceeded:
+		return "quota-exceeded"
+	case EventTypeBudgetExceeded:
+		return "budget-exceeded"
+	case EventTypeBranchMissing:
+		return "branch-missing"
+	case EventTypeHistoryReplaced:
+		return "history-replaced"
+	case EventTypeManifest:
+		return "manifest"
+	default:
+		return "unknown"
+	}
+}
+
+type HandleEventFunc func(event Event)
+
+// Get what is encoded as the JSON of the event. The errors within the event are replaced by their messages as errors
+// otherwise encode as empty objects.
+func eventData(event Event) interface{} {
+	v := reflect.ValueOf(event)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return event
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return event
+	}
+
+	errType := reflect.TypeOf((*error)(nil)).Elem()
+	data := make(map[string]interface{}, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		if f.Type == errType {
+			if fv.IsNil() {
+				data[f.Name] = nil
+			} else {
+				data[f.Name] = fv.Interface().(error).Error()
+			}
+			continue
+		}
+		data[f.Name] = fv.Interface()
+	}
+	return data
+}
+
+func (p *poller) emit(event Event) {
+	if p.config.HandleEvent != nil {
+		p.config.HandleEvent(p.redactEvent(event))
+	}
+}
@@ -0,0 +1,67 @@
+package gpoll
+
+import (
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	gitssh "gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+	"io"
+	"path/filepath"
+)
+
+// Replace the GitService used by a Poller created through NewPoller.
+func SetGitService(p Poller, g GitService) {
+	p.(*poller).git = g
+}
+
+// Point a provider created through NewGitHubProvenanceProvider at another API.
+func SetProvenanceBaseUrl(p ProvenanceProvider, u string) {
+	p.(*gitHubProvenance).baseUrl = u
+}
+
+// Expand a leading ~ of the path as done for the paths within a GitConfig.
+var ExpandHome = expandHome
+
+// Check the repo relative paths changed by the commit for anything that would be unsafe to write to disk.
+func CheckPaths(commit CommitDiff) []PathWarning {
+	return checkPaths(commit, func(fp string) string {
+		return fp
+	})
+}
+
+// Open the file, relative to the .git directory of the clone in the directory, as done with StorageConfig.MemoryMap.
+func OpenMemoryMapped(directory, filename string) (billy.File, error) {
+	root := filepath.Join(directory, ".git")
+	return (&mmapFs{Filesystem: osfs.New(root), root: root}).Open(filename)
+}
+
+// Whether the file was memory-mapped.
+func IsMemoryMapped(f billy.File) bool {
+	_, ok := f.(*mappedFile)
+	return ok
+}
+
+// Create the writer the progress sent by the remote is written to while cloning and fetching.
+func NewProgressWriter(progress ProgressFunc) io.Writer {
+	return &progressWriter{progress: progress}
+}
+
+// Get the SSH keys offered to the SSH remote of the config, in order, when it has more than one candidate.
+func SshKeyCandidates(config GitConfig) ([]ssh.Signer, error) {
+	g, err := newGit(config)
+	if err != nil {
+		return nil, err
+	}
+	return g.(*gitImpl).authMethod.(*gitssh.PublicKeysCallback).Callback()
+}
+
+// Get the SSH keys a MultiPoller created with the keys offers to a repo of the remote without any auth configured.
+func SelectSshKeys(keys []RemoteSshKey, remote string) []string {
+	return selectSshKeys(keys, remote)
+}
+
+// Get every commit reachable from to but not from, in the order they would be delivered under the CommitOrder.
+func OrderedCommits(from, to *object.Commit, order CommitOrder) ([]*object.Commit, error) {
+	return orderedCommits(from, to, order)
+}
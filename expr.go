@@ -0,0 +1,581 @@
+package gpoll
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A boolean expression over a FileChange and the commit it belongs to, used to filter and route changes through
+// config rather than code e.g.
+//
+//	change.path.matches("k8s/**") && commit.author.email.endsWith("@corp.com")
+//
+// The fields are change.path, the path relative to the root of the repo, change.type, one of create, update, delete
+// or init, change.size, commit.sha, commit.message, commit.author.name, commit.author.email, commit.committer.name and
+// commit.committer.email. Strings have the methods matches, against a path.Match pattern where ** matches any number
+// of directories, startsWith, endsWith, contains and lower. Expressions combine comparisons through ==, !=, <, <=, >,
+// >=, &&, || and ! and are type checked when parsed. Unmarshals from the source text in YAML and JSON config.
+type Expression struct {
+	source string
+	root   exprNode
+}
+
+// Parse and type check the source of an Expression.
+func ParseExpression(source string) (*Expression, error) {
+	p := &exprParser{source: source}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != exprTokenEOF {
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	}
+	if root.typ() != exprBool {
+		return nil, fmt.Errorf("expression %q is a %s rather than a bool", source, root.typ())
+	}
+	return &Expression{source: source, root: root}, nil
+}
+
+// Whether the change within the commit satisfies the expression. The Filepath of the change must be relative to the
+// root of the repo.
+func (e *Expression) MatchChange(commit CommitDiff, change FileChange) bool {
+	return e.root.eval(&exprEnv{commit: &commit, change: &change}).(bool)
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+func (e *Expression) MarshalText() ([]byte, error) {
+	return []byte(e.source), nil
+}
+
+func (e *Expression) UnmarshalText(text []byte) error {
+	parsed, err := ParseExpression(string(text))
+	if err != nil {
+		return err
+	}
+	*e = *parsed
+	return nil
+}
+
+// Narrows the commit down to the changes satisfying the expression, with paths as per the FilepathMode.
+func (p *poller) filterByExpression(e *Expression, commit CommitDiff) CommitDiff {
+	changes := make([]FileChange, 0, len(commit.Changes))
+	for _, c := range commit.Changes {
+		rel := c
+		rel.Filepath = p.relativePath(c.Filepath)
+		if e.MatchChange(commit, rel) {
+			changes = append(changes, c)
+		}
+	}
+	commit.Changes = changes
+	return commit
+}
+
+type exprType int
+
+const (
+	exprBool exprType = iota
+	exprString
+	exprInt
+)
+
+func (t exprType) String() string {
+	switch t {
+	case exprString:
+		return "string"
+	case exprInt:
+		return "int"
+	default:
+		return "bool"
+	}
+}
+
+// What an Expression is evaluated against.
+type exprEnv struct {
+	commit *CommitDiff
+	change *FileChange
+}
+
+type exprField struct {
+	typ exprType
+	get func(env *exprEnv) interface{}
+}
+
+var exprFields = map[string]exprField{
+	"change.path": {exprString, func(env *exprEnv) interface{} {
+		return env.change.Filepath
+	}},
+	"change.type": {exprString, func(env *exprEnv) interface{} {
+		return env.change.ChangeType.String()
+	}},
+	"change.size": {exprInt, func(env *exprEnv) interface{} {
+		return env.change.Size
+	}},
+	"commit.sha": {exprString, func(env *exprEnv) interface{} {
+		return env.commit.To.Sha
+	}},
+	"commit.message": {exprString, func(env *exprEnv) interface{} {
+		return env.commit.To.Message
+	}},
+	"commit.author.name": {exprString, func(env *exprEnv) interface{} {
+		return env.commit.To.Author.Name
+	}},
+	"commit.author.email": {exprString, func(env *exprEnv) interface{} {
+		return env.commit.To.Author.Email
+	}},
+	"commit.committer.name": {exprString, func(env *exprEnv) interface{} {
+		return env.commit.To.Committer.Name
+	}},
+	"commit.committer.email": {exprString, func(env *exprEnv) interface{} {
+		return env.commit.To.Committer.Email
+	}},
+}
+
+type exprMethod struct {
+	args []exprType
+	typ  exprType
+	call func(s string, args []interface{}) interface{}
+}
+
+var exprMethods = map[string]exprMethod{
+	"matches": {[]exprType{exprString}, exprBool, func(s string, args []interface{}) interface{} {
+		return globMatch(args[0].(string), s)
+	}},
+	"startsWith": {[]exprType{exprString}, exprBool, func(s string, args []interface{}) interface{} {
+		return strings.HasPrefix(s, args[0].(string))
+	}},
+	"endsWith": {[]exprType{exprString}, exprBool, func(s string, args []interface{}) interface{} {
+		return strings.HasSuffix(s, args[0].(string))
+	}},
+	"contains": {[]exprType{exprString}, exprBool, func(s string, args []interface{}) interface{} {
+		return strings.Contains(s, args[0].(string))
+	}},
+	"lower": {nil, exprString, func(s string, _ []interface{}) interface{} {
+		return strings.ToLower(s)
+	}},
+}
+
+// Matches the slash separated name against the pattern segment by segment as per path.Match, except that a ** segment
+// matches any number of segments.
+func globMatch(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(name); i >= 0; i-- {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+type exprNode interface {
+	typ() exprType
+	eval(env *exprEnv) interface{}
+}
+
+type exprLiteral struct {
+	t     exprType
+	value interface{}
+}
+
+func (l *exprLiteral) typ() exprType {
+	return l.t
+}
+
+func (l *exprLiteral) eval(*exprEnv) interface{} {
+	return l.value
+}
+
+type exprFieldNode struct {
+	field exprField
+}
+
+func (f *exprFieldNode) typ() exprType {
+	return f.field.typ
+}
+
+func (f *exprFieldNode) eval(env *exprEnv) interface{} {
+	return f.field.get(env)
+}
+
+type exprCall struct {
+	method   exprMethod
+	receiver exprNode
+	args     []exprNode
+}
+
+func (c *exprCall) typ() exprType {
+	return c.method.typ
+}
+
+func (c *exprCall) eval(env *exprEnv) interface{} {
+	args := make([]interface{}, len(c.args))
+	for i, a := range c.args {
+		args[i] = a.eval(env)
+	}
+	return c.method.call(c.receiver.eval(env).(string), args)
+}
+
+type exprNot struct {
+	operand exprNode
+}
+
+func (n *exprNot) typ() exprType {
+	return exprBool
+}
+
+func (n *exprNot) eval(env *exprEnv) interface{} {
+	return !n.operand.eval(env).(bool)
+}
+
+type exprBinary struct {
+	op          string
+	left, right exprNode
+}
+
+func (b *exprBinary) typ() exprType {
+	return exprBool
+}
+
+func (b *exprBinary) eval(env *exprEnv) interface{} {
+	switch b.op {
+	case "&&":
+		return b.left.eval(env).(bool) && b.right.eval(env).(bool)
+	case "||":
+		return b.left.eval(env).(bool) || b.right.eval(env).(bool)
+	}
+
+	l, r := b.left.eval(env), b.right.eval(env)
+	switch b.op {
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	}
+	var cmp int
+	if b.left.typ() == exprInt {
+		li, ri := l.(int64), r.(int64)
+		if li < ri {
+			cmp = -1
+		} else if li > ri {
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(l.(string), r.(string))
+	}
+	switch b.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+type exprTokenKind int
+
+const (
+	exprTokenEOF exprTokenKind = iota
+	exprTokenIdent
+	exprTokenString
+	exprTokenInt
+	exprTokenOp
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	pos  int
+}
+
+type exprParser struct {
+	source string
+	tokens []exprToken
+	next   int
+}
+
+// The operators, longest first so that e.g. <= isn't lexed as <.
+var exprOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", ".", ","}
+
+func (p *exprParser) lex() error {
+	s := p.source
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(s) && (s[i] == '_' || unicode.IsLetter(rune(s[i])) || unicode.IsDigit(rune(s[i]))) {
+				i++
+			}
+			p.tokens = append(p.tokens, exprToken{kind: exprTokenIdent, text: s[start:i], pos: start})
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(s) && unicode.IsDigit(rune(s[i])) {
+				i++
+			}
+			p.tokens = append(p.tokens, exprToken{kind: exprTokenInt, text: s[start:i], pos: start})
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(s) && s[end] != s[i] {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return fmt.Errorf("expression %q has an unterminated string at %d", p.source, i)
+			}
+			text := s[i+1 : end]
+			if c == '"' {
+				unquoted, err := strconv.Unquote(s[i : end+1])
+				if err != nil {
+					return fmt.Errorf("expression %q has an invalid string at %d", p.source, i)
+				}
+				text = unquoted
+			}
+			p.tokens = append(p.tokens, exprToken{kind: exprTokenString, text: text, pos: i})
+			i = end + 1
+		default:
+			op := ""
+			for _, o := range exprOperators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("expression %q has an unexpected %q at %d", p.source, c, i)
+			}
+			p.tokens = append(p.tokens, exprToken{kind: exprTokenOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	p.tokens = append(p.tokens, exprToken{kind: exprTokenEOF, pos: len(s)})
+	return nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.next]
+}
+
+func (p *exprParser) peekAt(offset int) exprToken {
+	if p.next+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.next+offset]
+}
+
+func (p *exprParser) take() exprToken {
+	t := p.tokens[p.next]
+	if t.kind != exprTokenEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *exprParser) isOp(text string) bool {
+	t := p.peek()
+	return t.kind == exprTokenOp && t.text == text
+}
+
+func (p *exprParser) expect(text string) error {
+	if t := p.take(); t.kind != exprTokenOp || t.text != text {
+		return p.errorf(t, "expected %q", text)
+	}
+	return nil
+}
+
+func (p *exprParser) errorf(t exprToken, format string, args ...interface{}) error {
+	return fmt.Errorf("expression %q: %s at %d", p.source, fmt.Sprintf(format, args...), t.pos)
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	return p.parseLogical("||", p.parseAnd)
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	return p.parseLogical("&&", p.parseUnary)
+}
+
+func (p *exprParser) parseLogical(op string, operand func() (exprNode, error)) (exprNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(op) {
+		t := p.take()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ() != exprBool || right.typ() != exprBool {
+			return nil, p.errorf(t, "%s requires bools", op)
+		}
+		left = &exprBinary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.isOp("!") {
+		t := p.take()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if operand.typ() != exprBool {
+			return nil, p.errorf(t, "! requires a bool")
+		}
+		return &exprNot{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != exprTokenOp {
+		return left, nil
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return left, nil
+	}
+	p.take()
+	right, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	if left.typ() != right.typ() {
+		return nil, p.errorf(t, "cannot compare %s to %s", left.typ(), right.typ())
+	}
+	if t.text != "==" && t.text != "!=" && left.typ() == exprBool {
+		return nil, p.errorf(t, "cannot order bools")
+	}
+	return &exprBinary{op: t.text, left: left, right: right}, nil
+}
+
+// Parses a primary followed by any method calls on it.
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(".") {
+		p.take()
+		name := p.take()
+		if name.kind != exprTokenIdent {
+			return nil, p.errorf(name, "expected a method name")
+		}
+		method, ok := exprMethods[name.text]
+		if !ok {
+			return nil, p.errorf(name, "unknown method %s", name.text)
+		}
+		if node.typ() != exprString {
+			return nil, p.errorf(name, "%s is a method of strings", name.text)
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		args := make([]exprNode, 0, len(method.args))
+		for !p.isOp(")") {
+			if len(args) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		p.take()
+		if len(args) != len(method.args) {
+			return nil, p.errorf(name, "%s takes %d arguments", name.text, len(method.args))
+		}
+		for i, a := range args {
+			if a.typ() != method.args[i] {
+				return nil, p.errorf(name, "%s takes a %s", name.text, method.args[i])
+			}
+		}
+		node = &exprCall{method: method, receiver: node, args: args}
+	}
+	return node, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.take()
+	switch t.kind {
+	case exprTokenString:
+		return &exprLiteral{t: exprString, value: t.text}, nil
+	case exprTokenInt:
+		i, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid int %s", t.text)
+		}
+		return &exprLiteral{t: exprInt, value: i}, nil
+	case exprTokenIdent:
+		switch t.text {
+		case "true":
+			return &exprLiteral{t: exprBool, value: true}, nil
+		case "false":
+			return &exprLiteral{t: exprBool, value: false}, nil
+		}
+		// The field is every dotted name up to a method call.
+		name := t.text
+		for p.isOp(".") && p.peekAt(1).kind == exprTokenIdent && !(p.peekAt(2).kind == exprTokenOp &&
+			p.peekAt(2).text == "(") {
+			p.take()
+			name += "." + p.take().text
+		}
+		field, ok := exprFields[name]
+		if !ok {
+			return nil, p.errorf(t, "unknown field %s", name)
+		}
+		return &exprFieldNode{field: field}, nil
+	case exprTokenOp:
+		if t.text == "(" {
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+		}
+	}
+	if t.kind == exprTokenEOF {
+		return nil, p.errorf(t, "unexpected end")
+	}
+	return nil, p.errorf(t, "unexpected %q", t.text)
+}
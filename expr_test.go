@@ -0,0 +1,141 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type ExprTest struct {
+	suite.Suite
+}
+
+func (s *ExprTest) TestMatchChange() {
+	commit := gpoll.CommitDiff{To: gpoll.Commit{
+		Sha:     "abc",
+		Message: "Fix \"quoted\" bug",
+		Author:  gpoll.Author{Name: "Jane", Email: "jane@corp.com"},
+	}}
+	change := gpoll.FileChange{Filepath: "k8s/prod/app.yaml", ChangeType: gpoll.ChangeTypeUpdate, Size: 100}
+
+	tests := []struct {
+		source   string
+		expected bool
+	}{
+		// Precedence: ! binds tighter than &&, which binds tighter than ||.
+		{`true || false && false`, true},
+		{`(true || false) && false`, false},
+		{`!false && false`, false},
+		{`!(false && false)`, true},
+		{`false || !true || true`, true},
+		{`change.size > 50 && change.size <= 100`, true},
+		{`(change.size == 100) == true`, true},
+
+		// Strings and their methods.
+		{`change.path.matches("k8s/**")`, true},
+		{`change.path.matches("k8s/*.yaml")`, false},
+		{`change.path.matches("**/app.yaml")`, true},
+		{`commit.author.email.endsWith("@corp.com")`, true},
+		{`commit.author.name.lower() == "jane"`, true},
+		{`change.type == "update"`, true},
+		{`"a" < "b"`, true},
+
+		// String escapes.
+		{`commit.message.contains("\"quoted\"")`, true},
+		{`commit.message.contains('"quoted"')`, true},
+		{`"a\tb" == "a	b"`, true},
+		{`"é" == "é"`, true},
+	}
+	for _, test := range tests {
+		s.Run(test.source, func() {
+			// -- When
+			//
+			e, err := gpoll.ParseExpression(test.source)
+
+			// -- Then
+			//
+			s.Require().NoError(err)
+			s.Equal(test.expected, e.MatchChange(commit, change))
+		})
+	}
+}
+
+func (s *ExprTest) TestParseErrors() {
+	tests := []string{
+		// Unknown identifiers.
+		`change.name == "a"`,
+		`commit`,
+		`foo`,
+		`change.path.glob("*")`,
+		`change.path.lower`,
+
+		// Type errors.
+		`change.path`,
+		`change.size == "1"`,
+		`true < false`,
+		`!change.path`,
+		`change.size.lower() == ""`,
+		`change.path.matches(1)`,
+		`change.path.matches()`,
+		`change.path.matches("a", "b")`,
+		`"a" && true`,
+
+		// Malformed input.
+		``,
+		`   `,
+		`(`,
+		`)`,
+		`(true`,
+		`true)`,
+		`true &&`,
+		`|| true`,
+		`true & false`,
+		`true ! false`,
+		`"unterminated`,
+		`'unterminated`,
+		`"trailing escape\`,
+		`"\q" == ""`,
+		`change.`,
+		`change.path.`,
+		`change.path.matches(`,
+		`change.path.matches("a"`,
+		`change.path.matches("a",)`,
+		`99999999999999999999 > change.size`,
+		`change.size > -1`,
+		`#`,
+		`é == true`,
+		`true == true == true`,
+	}
+	for _, source := range tests {
+		s.Run(source, func() {
+			// -- When
+			//
+			var err error
+			s.NotPanics(func() {
+				_, err = gpoll.ParseExpression(source)
+			})
+
+			// -- Then
+			//
+			s.Error(err)
+		})
+	}
+}
+
+func (s *ExprTest) TestTruncatedInputDoesNotPanic() {
+	// -- Given
+	//
+	source := `!(change.path.lower().matches("k8s/**") || commit.message.contains('a\'b')) && change.size >= 10`
+
+	for i := range source {
+		// -- When
+		//
+		s.NotPanics(func() {
+			_, _ = gpoll.ParseExpression(source[:i])
+		}, source[:i])
+	}
+}
+
+func TestExpr(t *testing.T) {
+	suite.Run(t, new(ExprTest))
+}
@@ -0,0 +1,110 @@
+package gpoll
+
+import (
+	"context"
+	"errors"
+	"gopkg.in/src-d/go-git.v4"
+	"sync"
+	"time"
+)
+
+// Injects faults into polling so that error handling can be exercised in integration tests. Only meant for tests and
+// should never be set in production.
+type FaultInjector interface {
+	// Called before every fetch from the remote. A non-nil error fails the poll as if the fetch itself had failed.
+	FetchError() error
+
+	// How long to wait before every fetch, simulating a slow remote.
+	FetchDelay() time.Duration
+
+	// Called with the commits found by every successful poll. The returned commits are used in their place e.g. return
+	// fewer to simulate a partial diff.
+	Diff(commits []CommitDiff) []CommitDiff
+}
+
+var ErrInjectedFault = errors.New("injected fault")
+
+// A FaultInjector configured through its fields. Safe to change through its methods while the poller is running.
+type Faults struct {
+	lock sync.Mutex
+
+	// The number of upcoming fetches that should fail.
+	FailFetches int
+
+	// The error returned by a failed fetch. Defaults to ErrInjectedFault.
+	Err error
+
+	// How long to wait before every fetch.
+	Delay time.Duration
+
+	// If greater than 0, only the first MaxCommits commits of every poll are returned.
+	MaxCommits int
+}
+
+// Fail the next n fetches with the error.
+func (f *Faults) FailNext(n int, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.FailFetches = n
+	f.Err = err
+}
+
+// Wait for the duration before every upcoming fetch, e.g. to simulate a fetch hung on the network.
+func (f *Faults) SetDelay(d time.Duration) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.Delay = d
+}
+
+func (f *Faults) FetchError() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.FailFetches <= 0 {
+		return nil
+	}
+	f.FailFetches--
+	if f.Err == nil {
+		return ErrInjectedFault
+	}
+	return f.Err
+}
+
+func (f *Faults) FetchDelay() time.Duration {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.Delay
+}
+
+func (f *Faults) Diff(commits []CommitDiff) []CommitDiff {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.MaxCommits > 0 && len(commits) > f.MaxCommits {
+		return commits[:f.MaxCommits]
+	}
+	return commits
+}
+
+// Wraps a GitService, injecting faults into every diff against the remote.
+type faultyGit struct {
+	GitService
+	faults FaultInjector
+}
+
+func (f *faultyGit) DiffRemote(ctx context.Context, repo *git.Repository, branch string) ([]CommitDiff, error) {
+	if d := f.faults.FetchDelay(); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if err := f.faults.FetchError(); err != nil {
+		return nil, err
+	}
+
+	commits, err := f.GitService.DiffRemote(ctx, repo, branch)
+	if err != nil {
+		return nil, err
+	}
+	return f.faults.Diff(commits), nil
+}
@@ -0,0 +1,10 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build aix darwin dragonfly freebsd linux netbsd openbsd
+
+package gpoll
+
+import "syscall"
+
+func mkfifo(fp string) error {
+	return syscall.Mkfifo(fp, 0600)
+}
@@ -0,0 +1,46 @@
+package gpoll
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Writes to a temporary file that replaces the file at fp once synced to disk, so a crash mid-write never leaves it
+// corrupted or empty.
+func writeFileAtomic(fp string, b []byte, perm os.FileMode) error {
+	tmp := fp + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, fp); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(fp))
+}
+
+// Syncs the directory so the files created or renamed within it survive a crash.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		// Directories can't be synced on windows, where renames are durable once they return.
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,147 @@
+package gpoll
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Decides whether a commit may be delivered, blocking until a decision is made. The context is cancelled once the
+// GateConfig's Timeout is exceeded.
+type GateFunc func(ctx context.Context, commit CommitDiff) (bool, error)
+
+type GateConfig struct {
+	// Function that must approve every commit before it is delivered e.g. the Gate of an ApprovalGate. Polling waits
+	// while a commit awaits approval. Stopping the poller cancels the wait, and the commit awaits approval again once
+	// polling resumes. If not set, every commit is delivered.
+	Gate GateFunc
+
+	// The maximum amount of time to wait for approval. Defaults to waiting indefinitely.
+	Timeout time.Duration
+
+	// Whether a commit is approved once the Timeout is exceeded. Defaults to rejecting it.
+	ApproveOnTimeout bool
+
+	// What to do with a rejected commit. Defaults to PolicyActionHalt.
+	RejectAction PolicyAction
+}
+
+const PolicyGate = "gate"
+
+// Checks the commit against the configured gate. A rejection is returned as a PolicyViolation. Returns false if the
+// context is cancelled, e.g. because the poller is stopping, before the commit is decided.
+func (p *poller) checkGate(parent context.Context, commit CommitDiff) (*PolicyViolation, bool) {
+	config := p.config.Gate
+	if config.Gate == nil {
+		return nil, true
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if config.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, config.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	defer cancel()
+
+	approved, err := config.Gate(ctx, commit)
+	if parent.Err() != nil {
+		return nil, false
+	}
+	reason := "commit was rejected"
+	if ctx.Err() == context.DeadlineExceeded {
+		if config.ApproveOnTimeout {
+			return nil, true
+		}
+		reason = "approval timed out"
+	} else if err != nil {
+		p.onError(err)
+		reason = err.Error()
+	} else if approved {
+		return nil, true
+	}
+
+	return &PolicyViolation{
+		Policy: PolicyGate,
+		Commit: commit.To,
+		Reason: reason,
+		Action: config.RejectAction,
+	}, true
+}
+
+// A gate that waits for each commit to be approved or rejected through its methods e.g. from a chat command or an
+// admin endpoint. Use its Gate as the GateConfig's Gate.
+type ApprovalGate struct {
+	lock    sync.Mutex
+	pending map[string]*approval
+}
+
+type approval struct {
+	commit   Commit
+	decision chan bool
+}
+
+func NewApprovalGate() *ApprovalGate {
+	return &ApprovalGate{
+		pending: make(map[string]*approval),
+	}
+}
+
+func (a *ApprovalGate) Gate(ctx context.Context, commit CommitDiff) (bool, error) {
+	ap := &approval{
+		commit:   commit.To,
+		decision: make(chan bool, 1),
+	}
+	a.lock.Lock()
+	a.pending[commit.To.Sha] = ap
+	a.lock.Unlock()
+
+	defer func() {
+		a.lock.Lock()
+		delete(a.pending, commit.To.Sha)
+		a.lock.Unlock()
+	}()
+
+	select {
+	case approved := <-ap.decision:
+		return approved, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// Approve delivery of the pending commit. Returns false if no commit with the sha is awaiting approval.
+func (a *ApprovalGate) Approve(sha string) bool {
+	return a.decide(sha, true)
+}
+
+// Reject delivery of the pending commit. Returns false if no commit with the sha is awaiting approval.
+func (a *ApprovalGate) Reject(sha string) bool {
+	return a.decide(sha, false)
+}
+
+// Get the commits awaiting approval.
+func (a *ApprovalGate) Pending() []Commit {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	commits := make([]Commit, 0, len(a.pending))
+	for _, ap := range a.pending {
+		commits = append(commits, ap.commit)
+	}
+	return commits
+}
+
+func (a *ApprovalGate) decide(sha string, approved bool) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	ap, ok := a.pending[sha]
+	if !ok {
+		return false
+	}
+	select {
+	case ap.decision <- approved:
+	default:
+	}
+	return true
+}
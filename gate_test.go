@@ -0,0 +1,246 @@
+package gpoll_test
+
+import (
+	"context"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"sync"
+	"testing"
+	"time"
+)
+
+type GateTest struct {
+	serverSuite
+}
+
+// Waits for the commit to await approval at the gate.
+func (s *GateTest) awaitPending(gate *gpoll.ApprovalGate, sha string) {
+	s.Eventually(func() bool {
+		for _, c := range gate.Pending() {
+			if c.Sha == sha {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *GateTest) TestApprovedCommitIsDelivered() {
+	// -- Given
+	//
+	gate := gpoll.NewApprovalGate()
+	p := s.newPoller(gpoll.PollConfig{
+		Gate: gpoll.GateConfig{Gate: gate.Gate},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	sha := s.commit("add a", map[string]string{"a.txt": "a"})
+	s.awaitPending(gate, sha)
+	s.receiveNone(c, 100*time.Millisecond)
+
+	// -- Then
+	//
+	s.True(gate.Approve(sha))
+	s.Equal(sha, s.receive(c).To.Sha)
+	s.Empty(gate.Pending())
+	s.False(gate.Approve(sha))
+}
+
+func (s *GateTest) TestRejectedCommitIsHeld() {
+	// -- Given
+	//
+	gate := gpoll.NewApprovalGate()
+	p := s.newPoller(gpoll.PollConfig{
+		Gate: gpoll.GateConfig{Gate: gate.Gate},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	sha := s.commit("add a", map[string]string{"a.txt": "a"})
+	s.awaitPending(gate, sha)
+	s.True(gate.Reject(sha))
+
+	// -- Then
+	//
+	s.Eventually(func() bool {
+		return len(p.Quarantine()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	held := p.Quarantine()[0]
+	s.Equal(sha, held.Commit.To.Sha)
+	s.Equal("commit was rejected", held.Reason)
+	s.receiveNone(c, 100*time.Millisecond)
+	s.False(gate.Reject(sha))
+}
+
+func (s *GateTest) TestSkipRejectActionDropsCommit() {
+	// -- Given
+	//
+	var lock sync.Mutex
+	violations := make([]gpoll.PolicyViolation, 0)
+	gate := gpoll.NewApprovalGate()
+	p := s.newPoller(gpoll.PollConfig{
+		Gate: gpoll.GateConfig{Gate: gate.Gate, RejectAction: gpoll.PolicyActionSkip},
+		HandleEvent: func(event gpoll.Event) {
+			if v, ok := event.(gpoll.PolicyViolation); ok {
+				lock.Lock()
+				defer lock.Unlock()
+				violations = append(violations, v)
+			}
+		},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	rejected := s.commit("add a", map[string]string{"a.txt": "a"})
+	s.awaitPending(gate, rejected)
+	s.True(gate.Reject(rejected))
+	approved := s.commit("add b", map[string]string{"b.txt": "b"})
+	s.awaitPending(gate, approved)
+	s.True(gate.Approve(approved))
+
+	// -- Then
+	//
+	s.Equal(approved, s.receive(c).To.Sha)
+	s.Empty(p.Quarantine())
+	lock.Lock()
+	defer lock.Unlock()
+	if s.Len(violations, 1) {
+		s.Equal(gpoll.PolicyGate, violations[0].Policy)
+		s.Equal(rejected, violations[0].Commit.Sha)
+		s.Equal(gpoll.PolicyActionSkip, violations[0].Action)
+	}
+}
+
+func (s *GateTest) TestTimeout() {
+	cases := []struct {
+		name             string
+		approveOnTimeout bool
+	}{
+		{name: "rejects", approveOnTimeout: false},
+		{name: "approves", approveOnTimeout: true},
+	}
+	for _, tc := range cases {
+		s.Run(tc.name, func() {
+			// -- Given
+			//
+			gate := gpoll.NewApprovalGate()
+			p := s.newPoller(gpoll.PollConfig{
+				Gate: gpoll.GateConfig{
+					Gate:             gate.Gate,
+					Timeout:          50 * time.Millisecond,
+					ApproveOnTimeout: tc.approveOnTimeout,
+				},
+			})
+			c := s.start(p)
+			defer p.StopAndWait()
+
+			// -- When
+			//
+			sha := s.commit("add "+tc.name, map[string]string{tc.name + ".txt": tc.name})
+
+			// -- Then
+			//
+			if tc.approveOnTimeout {
+				s.Equal(sha, s.receive(c).To.Sha)
+				return
+			}
+			s.Eventually(func() bool {
+				return len(p.Quarantine()) == 1
+			}, 5*time.Second, 10*time.Millisecond)
+			s.Equal("approval timed out", p.Quarantine()[0].Reason)
+			s.receiveNone(c, 100*time.Millisecond)
+		})
+	}
+}
+
+func (s *GateTest) TestStopInterruptsApprovalUntilRestart() {
+	// -- Given
+	//
+	gate := gpoll.NewApprovalGate()
+	p := s.newPoller(gpoll.PollConfig{
+		Gate: gpoll.GateConfig{Gate: gate.Gate},
+	})
+	s.start(p)
+	sha := s.commit("add a", map[string]string{"a.txt": "a"})
+	s.awaitPending(gate, sha)
+
+	// -- When
+	//
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		p.StopAndWait()
+	}()
+
+	// -- Then
+	//
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		s.FailNow("stopping waited for the approval")
+	}
+	s.Empty(gate.Pending())
+
+	c := s.start(p)
+	defer p.StopAndWait()
+	s.awaitPending(gate, sha)
+	s.True(gate.Approve(sha))
+	s.Equal(sha, s.receive(c).To.Sha)
+}
+
+func (s *GateTest) TestStopDoesNotRejectCommitAwaitingApproval() {
+	// -- Given
+	//
+	var lock sync.Mutex
+	violations := 0
+	entered := make(chan struct{}, 1)
+	p := s.newPoller(gpoll.PollConfig{
+		Gate: gpoll.GateConfig{
+			Gate: func(ctx context.Context, commit gpoll.CommitDiff) (bool, error) {
+				entered <- struct{}{}
+				<-ctx.Done()
+				return false, ctx.Err()
+			},
+		},
+		HandleEvent: func(event gpoll.Event) {
+			if _, ok := event.(gpoll.PolicyViolation); ok {
+				lock.Lock()
+				defer lock.Unlock()
+				violations++
+			}
+		},
+	})
+	s.start(p)
+	sha := s.commit("add a", map[string]string{"a.txt": "a"})
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for the gate")
+	}
+
+	// -- When
+	//
+	p.StopAndWait()
+
+	// -- Then
+	//
+	held := p.Quarantine()
+	if s.Len(held, 1) {
+		s.Equal(sha, held[0].Commit.To.Sha)
+		s.Equal("interrupted before a decision was made", held[0].Reason)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	s.Zero(violations)
+}
+
+func TestGate(t *testing.T) {
+	suite.Run(t, new(GateTest))
+}
@@ -1,15 +1,22 @@
 package gpoll
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4"
 	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/sideband"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/storage"
 	"gopkg.in/src-d/go-git.v4/storage/memory"
 	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+	"net/http"
+	"sync"
 	"time"
 )
 
@@ -20,6 +27,12 @@ type FileChange struct {
 
 	// The type of change that occurred e.g. added, created, deleted the file.
 	ChangeType ChangeType
+
+	// The size of the file in bytes after the change. Always 0 for deleted files.
+	Size int64
+
+	// The target of the file if it is a symbolic link. Empty otherwise.
+	Symlink string
 }
 
 // Represents a batch of changes to files between two commits in a Git repo.
@@ -32,6 +45,70 @@ type CommitDiff struct {
 
 	// The result of the file changes.
 	To Commit
+
+	// How the To commit arrived on the branch. Only set if a ProvenanceProvider is configured.
+	Provenance *Provenance
+
+	// The results of the configured AnnotateFuncs keyed by their name.
+	Annotations map[string]string
+
+	// When the poller received the commit from the remote. Carries a monotonic clock reading so time.Since(ReceivedAt)
+	// accurately measures how long the commit has been in flight within this process.
+	ReceivedAt time.Time
+
+	// When the poller received the commit in the Location of the handler or sink it was delivered to, for display. Zero
+	// unless a Location is configured.
+	LocalReceivedAt time.Time
+
+	// The position of the commit in the order of delivery, starting at 1. Only set on delivered commits.
+	Sequence uint64
+
+	// Whether every change in the commit only converts line endings or changes .gitattributes. Only set if detected
+	// through the NormalizationConfig.
+	Normalization bool
+
+	// Whether the diff was created through RollbackTo rather than polled from the remote. The From commit is the last
+	// delivered commit and the To commit is the older commit being rolled back to.
+	Rollback bool
+
+	// Whether the commit was replayed to the handlers through Backfill rather than polled from the remote. Only the
+	// changes within the backfilled path are included.
+	Backfill bool
+
+	// Whether the To commit shares no history with the From commit, e.g. the branch was replaced by an orphan branch.
+	// The changes are squashed into a single diff between the full trees of both commits.
+	HistoryReplaced bool
+
+	// Identifies the poll that found the commit, shared by every commit it found. See Receipt.
+	PollID string
+
+	// Uniquely identifies the delivery of the commit across remotes and branches. Derived from the remote, branch, sha
+	// and Sequence through EventID so downstream systems can use it to deduplicate. Only set on delivered commits.
+	ID string
+
+	// A read-only view of every file in the repo at the To commit, unaffected by later polls. Paths are relative to the
+	// root of the repo. Once released, every read fails with ErrSnapshotReleased. Only set on delivered commits, and the
+	// list of every file handed to the handlers on start, if PollConfig.Snapshots is enabled. The latter is never
+	// released.
+	Snapshot billy.Filesystem `json:"-"`
+
+	// The checksums of every watched file at the To commit. Only set on delivered commits, and the list of every file
+	// handed to the handlers on start, if PollConfig.Manifests is enabled.
+	Manifest *Manifest
+}
+
+// Get a copy of the commit whose LocalWhen and LocalReceivedAt are in the location, keeping the raw times as they are.
+// The copy is returned unchanged if the location is nil.
+func (c CommitDiff) In(loc *time.Location) CommitDiff {
+	if loc == nil {
+		return c
+	}
+	c.From.LocalWhen = c.From.When.In(loc)
+	c.To.LocalWhen = c.To.When.In(loc)
+	if !c.ReceivedAt.IsZero() {
+		c.LocalReceivedAt = c.ReceivedAt.In(loc)
+	}
+	return c
 }
 
 type Commit struct {
@@ -41,17 +118,35 @@ type Commit struct {
 	// When the commit occurred in UTC.
 	When time.Time
 
+	// When the commit occurred in the Location of the handler or sink it was delivered to, for display. Zero unless a
+	// Location is configured.
+	LocalWhen time.Time
+
 	// The author of the commit.
 	Author Author
 
+	// Who committed the commit. Differs from the Author when e.g. a patch was applied by someone else.
+	Committer Author
+
 	// The message made by the author.
 	Message string
+
+	// The ASCII armored PGP signature of the commit. Empty if the commit is not signed.
+	Signature string
+
+	// How far the author or committer time is from when the commit was received, if outside the tolerances of the
+	// ClockSkewConfig. Positive if the commit is dated in the future. Zero if its clock looked right. Only set on the To
+	// commit of polled commits.
+	ClockSkew time.Duration
 }
 
 type Author struct {
 	Name string
 
 	Email string
+
+	// When the author made or committed the change, in the timezone recorded in the commit.
+	When time.Time
 }
 
 type ChangeType int
@@ -70,15 +165,67 @@ const (
 	ChangeTypeInit
 )
 
+func (c ChangeType) String() string {
+	switch c {
+	case ChangeTypeCreate:
+		return "create"
+	case ChangeTypeDelete:
+		return "delete"
+	case ChangeTypeInit:
+		return "init"
+	default:
+		return "update"
+	}
+}
+
 const remoteName = "origin"
 
+var (
+	ErrUnsignedCommit = errors.New("commit is not signed")
+	ErrNoTrustedKeys  = errors.New("no trusted keys to verify the signature with")
+)
+
 func newGit(config GitConfig) (GitService, error) {
-	auth, err := toAuthMethod(&config.Auth)
+	if err := config.Transport.SshCrypto.validate(); err != nil {
+		return nil, err
+	}
+	auth, err := toAuthMethod(&config.Auth, config.Transport.SshCrypto)
 	if err != nil {
 		return nil, err
 	}
+	ep, err := transport.NewEndpoint(config.Remote)
+	if err != nil {
+		return nil, err
+	}
+	var hostKeyAddr string
+	var dialer contextDialer
+	if config.Transport.Bastion.Host != "" || config.Transport.Proxy.Address != "" || config.Transport.resolves() ||
+		(ep.Protocol == "ssh" && config.Transport.KeepAlive > 0) {
+		if hostKeyAddr, dialer, err = useTunnel(config.Remote, config.Transport); err != nil {
+			return nil, err
+		}
+	}
+	var httpClient *http.Client
+	if ep.Protocol == "http" || ep.Protocol == "https" {
+		installHttpTransport()
+		httpClient = newHttpClient(config.Transport, dialer)
+	}
+	var progress sideband.Progress
+	if config.Progress != nil {
+		progress = &progressWriter{progress: config.Progress}
+	}
 	return &gitImpl{
-		authMethod: auth,
+		progress:        progress,
+		authMethod:      applyTransport(config.Transport, auth, hostKeyAddr, httpClient),
+		hostKeyAddr:     hostKeyAddr,
+		httpClient:      httpClient,
+		refresh:         config.AuthRefresh,
+		refreshInterval: config.AuthRefreshInterval,
+		refreshedAt:     time.Now(),
+		transport:       config.Transport,
+		noCheckout:      config.NoCheckout || config.Mirror,
+		storage:         config.Storage,
+		order:           config.Order,
 	}, nil
 }
 
@@ -94,12 +241,51 @@ type GitConfig struct {
 
 	// The directory that the git repository will be cloned into. Defaults to the current directory.
 	CloneDirectory string
+
+	// Timeouts and keepalives for the connection to the remote.
+	Transport TransportConfig
+
+	// Clone without a worktree and never check out or pull files, working purely with the git objects. Use this when
+	// only the diffs are needed to avoid the cost of checking out every change.
+	NoCheckout bool
+
+	// Keep every branch and tag of the remote, emitting a RefChange whenever any of them is created, updated or
+	// deleted. The refs advertised by the remote are compared on every poll and objects are only fetched when they
+	// changed. Branches are kept as remote tracking branches. Commits to the Branch are delivered as usual. Implies
+	// NoCheckout.
+	Mirror bool
+
+	// Where the clone is kept. Defaults to memory.
+	Storage StorageConfig
+
+	// The order in which new commits are delivered when the branch has merges. Defaults to CommitOrderFirstParent.
+	Order CommitOrder
+
+	// Called for fresh credentials whenever the remote rejects the current ones, after which the operation is retried
+	// once. Use this with short-lived credentials e.g. tokens minted through OIDC or workload identity.
+	AuthRefresh AuthRefreshFunc
+
+	// How often the AuthRefresh is called ahead of the credentials being rejected. Defaults to only calling it once they
+	// are rejected.
+	AuthRefreshInterval time.Duration
+
+	// Called with the progress reported by the remote while cloning and fetching. Use this to show the status of the
+	// initial clone of a large repo.
+	Progress ProgressFunc
 }
 
 type GitAuthConfig struct {
 	// The filepath to the SSH key. Required if the Username and Password are not set.
 	SshKey string `validation:"required_without=Username Password"`
 
+	// Filepaths to further SSH keys. Every key, starting with the SshKey, is offered to the remote in order until one is
+	// accepted e.g. when a deploy key per repo is configured.
+	SshKeys []string
+
+	// A directory of SSH keys e.g. ~/.ssh. Every private key in the directory that can be parsed is offered to the
+	// remote, in order of filename, after the SshKey and SshKeys.
+	SshKeyDir string
+
 	// The username for the git repo. Required if the SshKey is not set or if the Password is set.
 	Username string `validation:"required_without=SshKey,required_with=Password"`
 
@@ -108,28 +294,117 @@ type GitAuthConfig struct {
 }
 
 type GitService interface {
-	Clone(remote, branch, directory string) (*git.Repository, error)
-	DiffRemote(repo *git.Repository, branch string) ([]CommitDiff, error)
+	Clone(ctx context.Context, remote, branch, directory string) (*git.Repository, error)
+	DiffRemote(ctx context.Context, repo *git.Repository, branch string) ([]CommitDiff, error)
+	Fetch(repo *git.Repository) error
 	FetchLatestRemoteCommit(repo *git.Repository, branch string) (*object.Commit, error)
 	HeadCommit(repo *git.Repository) (*object.Commit, error)
+	Reset(repo *git.Repository, branch, sha string) error
 	Diff(from *object.Commit, to *object.Commit) (*CommitDiff, error)
+	DiffCommits(repo *git.Repository, from, to string) ([]CommitDiff, error)
 	ToInternal(c *object.Commit) *Commit
+	VerifySignature(repo *git.Repository, sha string, armoredKeyRing string) error
+	ReadFile(repo *git.Repository, sha string, fp string) ([]byte, error)
+	CheckRemote(remote, branch string) error
+	Compact(repo *git.Repository) error
+	DefaultBranch(repo *git.Repository) (string, error)
+	ListFiles(c *object.Commit) ([]FileChange, error)
+	ResolveRevision(repo *git.Repository, revision string) (*object.Commit, error)
+	RemoteRefs(repo *git.Repository) (map[string]string, error)
+	FetchMirror(repo *git.Repository) error
+	History(repo *git.Repository, fp string, limit int) ([]*object.Commit, error)
+	Tag(repo *git.Repository, name, sha string) (*Tag, error)
+}
+
+// Check that the remote is reachable with the configured auth and that the branch exists, without cloning anything.
+func Preflight(config GitConfig) error {
+	g, err := newGit(config)
+	if err != nil {
+		return err
+	}
+	return g.CheckRemote(config.Remote, config.Branch)
 }
 
 type gitImpl struct {
-	authMethod transport.AuthMethod
+	authLock        sync.RWMutex
+	authMethod      transport.AuthMethod
+	refresh         AuthRefreshFunc
+	refreshInterval time.Duration
+	refreshedAt     time.Time
+	// The host:port host keys of the remote are verified against when it is reached through a bastion. Empty otherwise.
+	hostKeyAddr string
+	// The client HTTP(S) remotes are connected with. Nil for other remotes.
+	httpClient *http.Client
+	// Called after every operation presenting the credentials to the remote. Set before the poller starts.
+	onAuth func(auth transport.AuthMethod, err error)
+	// The goroutines of the poller, which listings of the remote run on. Set before the poller starts.
+	goroutines *goroutines
+
+	transport  TransportConfig
+	noCheckout bool
+	storage    StorageConfig
+	progress   sideband.Progress
+	order      CommitOrder
 }
 
 func (g *gitImpl) ToInternal(c *object.Commit) *Commit {
+	return toCommit(c)
+}
+
+func toCommit(c *object.Commit) *Commit {
 	return &Commit{
 		Sha:  c.Hash.String(),
 		When: c.Author.When.UTC(),
 		Author: Author{
 			Name:  c.Author.Name,
 			Email: c.Author.Email,
+			When:  c.Author.When,
 		},
-		Message: c.Message,
+		Committer: Author{
+			Name:  c.Committer.Name,
+			Email: c.Committer.Email,
+			When:  c.Committer.When,
+		},
+		Message:   c.Message,
+		Signature: c.PGPSignature,
+	}
+}
+
+func (g *gitImpl) VerifySignature(repo *git.Repository, sha string, armoredKeyRing string) error {
+	c, err := repo.CommitObject(plumbing.NewHash(sha))
+	if err != nil {
+		return err
+	}
+
+	if c.PGPSignature == "" {
+		return ErrUnsignedCommit
+	}
+
+	// Otherwise a commit signed by anyone would be accepted, e.g. when validation of the config is skipped.
+	if armoredKeyRing == "" {
+		return ErrNoTrustedKeys
 	}
+
+	_, err = c.Verify(armoredKeyRing)
+	return err
+}
+
+func (g *gitImpl) ReadFile(repo *git.Repository, sha string, fp string) ([]byte, error) {
+	c, err := repo.CommitObject(plumbing.NewHash(sha))
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := c.File(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := f.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
 }
 
 func (g *gitImpl) Diff(from *object.Commit, to *object.Commit) (*CommitDiff, error) {
@@ -168,6 +443,20 @@ func (g *gitImpl) Diff(from *object.Commit, to *object.Commit) (*CommitDiff, err
 			gitChange.Filepath = d.From.Name
 		} else {
 			gitChange.Filepath = d.To.Name
+			_, f, err := d.Files()
+			if err != nil {
+				return nil, err
+			}
+			if f != nil {
+				gitChange.Size = f.Size
+				if f.Mode == filemode.Symlink {
+					target, err := f.Contents()
+					if err != nil {
+						return nil, err
+					}
+					gitChange.Symlink = target
+				}
+			}
 		}
 
 		changes = append(changes, gitChange)
@@ -180,6 +469,35 @@ func (g *gitImpl) Diff(from *object.Commit, to *object.Commit) (*CommitDiff, err
 	}, nil
 }
 
+func (g *gitImpl) ListFiles(c *object.Commit) ([]FileChange, error) {
+	files, err := c.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]FileChange, 0)
+	err = files.ForEach(func(f *object.File) error {
+		changes = append(changes, FileChange{
+			Filepath:   f.Name,
+			ChangeType: ChangeTypeInit,
+			Size:       f.Size,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (g *gitImpl) ResolveRevision(repo *git.Repository, revision string) (*object.Commit, error) {
+	h, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, err
+	}
+	return repo.CommitObject(*h)
+}
+
 func (g *gitImpl) HeadCommit(repo *git.Repository) (*object.Commit, error) {
 	h, err := repo.Head()
 	if err != nil {
@@ -188,14 +506,42 @@ func (g *gitImpl) HeadCommit(repo *git.Repository) (*object.Commit, error) {
 	return repo.CommitObject(h.Hash())
 }
 
-func (g *gitImpl) DiffRemote(repo *git.Repository, branch string) ([]CommitDiff, error) {
-	err := repo.Fetch(&git.FetchOptions{
-		Auth: g.authMethod,
-	})
+// Moves the branch to the commit, resetting the worktree to it unless NoCheckout is set.
+func (g *gitImpl) Reset(repo *git.Repository, branch, sha string) error {
+	h := plumbing.NewHash(sha)
+	if g.noCheckout {
+		return repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), h))
+	}
+
+	wt, err := repo.Worktree()
 	if err != nil {
-		if err != git.NoErrAlreadyUpToDate {
-			return nil, err
-		}
+		return err
+	}
+	return wt.Reset(&git.ResetOptions{Commit: h, Mode: git.HardReset})
+}
+
+func (g *gitImpl) Fetch(repo *git.Repository) error {
+	return g.fetch(context.Background(), repo)
+}
+
+func (g *gitImpl) fetch(ctx context.Context, repo *git.Repository) error {
+	err := g.withAuth(func(auth transport.AuthMethod) error {
+		ctx, cancel := g.operationContextFrom(ctx)
+		defer cancel()
+		return repo.FetchContext(ctx, &git.FetchOptions{
+			Auth:     authContext(ctx, auth),
+			Progress: g.progress,
+		})
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
+}
+
+func (g *gitImpl) DiffRemote(ctx context.Context, repo *git.Repository, branch string) ([]CommitDiff, error) {
+	if err := g.fetch(ctx, repo); err != nil {
+		return nil, err
 	}
 
 	h, err := repo.Head()
@@ -203,7 +549,7 @@ func (g *gitImpl) DiffRemote(repo *git.Repository, branch string) ([]CommitDiff,
 		return nil, err
 	}
 
-	remCommit, err := g.FetchLatestRemoteCommit(repo, branch)
+	remCommit, err := g.latestRemoteCommit(ctx, repo, branch)
 	if err != nil {
 		return nil, err
 	}
@@ -213,21 +559,17 @@ func (g *gitImpl) DiffRemote(repo *git.Repository, branch string) ([]CommitDiff,
 		return nil, err
 	}
 
-	commits, err := g.listCommits(currentCommit, remCommit)
+	diffs, err := g.diffCommits(currentCommit, remCommit)
 	if err != nil {
 		return nil, err
 	}
 
-	from := currentCommit
-	diffs := make([]CommitDiff, len(commits)-1)
-	for i := 1; i < len(commits); i++ {
-		to := commits[i]
-		diff, err := g.Diff(from, to)
-		if err != nil {
+	if g.noCheckout {
+		ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), remCommit.Hash)
+		if err := repo.Storer.SetReference(ref); err != nil {
 			return nil, err
 		}
-		diffs[i-1] = *diff
-		from = to
+		return diffs, nil
 	}
 
 	wt, err := repo.Worktree()
@@ -235,36 +577,190 @@ func (g *gitImpl) DiffRemote(repo *git.Repository, branch string) ([]CommitDiff,
 		return nil, err
 	}
 
-	err = wt.Pull(&git.PullOptions{
-		SingleBranch:  true,
-		ReferenceName: plumbing.NewBranchReferenceName(branch),
-		Auth:          g.authMethod,
-	})
-
-	if err != nil {
+	// Check out exactly the diffed commit rather than pulling, which would skip any commit pushed since the fetch.
+	if err := wt.Reset(&git.ResetOptions{Commit: remCommit.Hash, Mode: git.HardReset}); err != nil {
 		return nil, err
 	}
-
 	return diffs, nil
 }
 
-func (g *gitImpl) Clone(remote, branch, directory string) (*git.Repository, error) {
-	repo, err := git.Clone(memory.NewStorage(), memfs.New(), &git.CloneOptions{
-		URL:           remote,
-		RemoteName:    remoteName,
-		ReferenceName: plumbing.NewBranchReferenceName(branch),
-		Auth:          g.authMethod,
+func (g *gitImpl) Clone(ctx context.Context, remote, branch, directory string) (*git.Repository, error) {
+	ctx, cancel := g.operationContextFrom(ctx)
+	defer cancel()
+	var repo *git.Repository
+	var s storage.Storer
+	var wt billy.Filesystem
+	err := g.withAuth(func(auth transport.AuthMethod) error {
+		var err error
+		s, wt = newStorage(g.storage, directory, g.noCheckout)
+		repo, err = git.CloneContext(ctx, s, wt, &git.CloneOptions{
+			URL:           remote,
+			RemoteName:    remoteName,
+			ReferenceName: plumbing.NewBranchReferenceName(branch),
+			Auth:          authContext(ctx, auth),
+			Progress:      g.progress,
+		})
+		return err
 	})
 
 	if err == git.ErrRepositoryAlreadyExists {
+		if g.storage.Type == StorageTypeFilesystem {
+			repo, err = g.resumeClone(ctx, s, wt, branch)
+			return repo, g.emptyRemoteError(remote, err)
+		}
 		return git.PlainOpen(directory)
 	} else if err != nil {
+		return nil, g.emptyRemoteError(remote, err)
+	}
+
+	return repo, nil
+}
+
+// Opens a clone kept on the filesystem. If a previous clone was interrupted before the branch was checked out, the
+// clone is completed by fetching the branch. Objects that were already stored are not fetched again.
+func (g *gitImpl) resumeClone(ctx context.Context, s storage.Storer, wt billy.Filesystem, branch string) (*git.Repository, error) {
+	repo, err := git.Open(s, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	if _, err := repo.Reference(branchRef, false); err == nil {
+		return repo, nil
+	} else if err != plumbing.ErrReferenceNotFound {
 		return nil, err
 	}
 
+	remoteRef := plumbing.NewRemoteReferenceName(remoteName, branch)
+	err = g.withAuth(func(auth transport.AuthMethod) error {
+		return repo.FetchContext(ctx, &git.FetchOptions{
+			RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", branchRef, remoteRef))},
+			Auth:     authContext(ctx, auth),
+			Progress: g.progress,
+		})
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, err
+	}
+
+	ref, err := repo.Reference(remoteRef, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(branchRef, ref.Hash())); err != nil {
+		return nil, err
+	}
+	if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branchRef)); err != nil {
+		return nil, err
+	}
+
+	if wt != nil {
+		w, err := repo.Worktree()
+		if err != nil {
+			return nil, err
+		}
+		if err := w.Checkout(&git.CheckoutOptions{Branch: branchRef, Force: true}); err != nil {
+			return nil, err
+		}
+	}
 	return repo, nil
 }
 
+func (g *gitImpl) CheckRemote(remote, branch string) error {
+	rem := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: remoteName,
+		URLs: []string{remote},
+	})
+
+	rfs, err := g.listRemote(rem)
+	if err != nil {
+		return err
+	}
+
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	for _, r := range rfs {
+		if r.Name() == branchRef {
+			return nil
+		}
+	}
+	return fmt.Errorf("branch %s does not exist on %s", branch, remote)
+}
+
+// Diffs every commit between the from and to commits in the configured order.
+func (g *gitImpl) DiffCommits(repo *git.Repository, from, to string) ([]CommitDiff, error) {
+	fromCommit, err := repo.CommitObject(plumbing.NewHash(from))
+	if err != nil {
+		return nil, err
+	}
+	toCommit, err := repo.CommitObject(plumbing.NewHash(to))
+	if err != nil {
+		return nil, err
+	}
+	return g.diffCommits(fromCommit, toCommit)
+}
+
+func (g *gitImpl) diffCommits(from, to *object.Commit) ([]CommitDiff, error) {
+	if from.Hash == to.Hash {
+		return []CommitDiff{}, nil
+	}
+	base, err := mergeBase(from, to)
+	if err != nil {
+		return nil, err
+	} else if base == nil {
+		return g.diffUnrelated(from, to)
+	}
+
+	// When the branch was force pushed, the first commit after the common ancestor is diffed against the from commit
+	// instead so the changes of the commits that were dropped are undone.
+	if g.order != CommitOrderFirstParent {
+		commits, err := orderedCommits(from, to, g.order)
+		if err != nil {
+			return nil, err
+		}
+		if len(commits) == 0 {
+			commits = []*object.Commit{to}
+		}
+		diffs := make([]CommitDiff, len(commits))
+		for i, c := range commits {
+			// Each commit is diffed against its first parent since the commit before it may be on another branch.
+			parent, err := c.Parents().Next()
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 && base.Hash != from.Hash {
+				parent = from
+			}
+			diff, err := g.Diff(parent, c)
+			if err != nil {
+				return nil, err
+			}
+			diffs[i] = *diff
+		}
+		return diffs, nil
+	}
+
+	commits, err := g.listCommits(base, to)
+	if err != nil {
+		return nil, err
+	}
+	commits[0] = from
+	if len(commits) == 1 {
+		// The branch was reset to an ancestor of the from commit.
+		commits = append(commits, to)
+	}
+
+	diffs := make([]CommitDiff, len(commits)-1)
+	for i := 1; i < len(commits); i++ {
+		diff, err := g.Diff(from, commits[i])
+		if err != nil {
+			return nil, err
+		}
+		diffs[i-1] = *diff
+		from = commits[i]
+	}
+	return diffs, nil
+}
+
 func (g *gitImpl) listCommits(from *object.Commit, to *object.Commit) ([]*object.Commit, error) {
 	var err error
 	parent := to
@@ -290,14 +786,16 @@ func (g *gitImpl) listCommits(from *object.Commit, to *object.Commit) ([]*object
 }
 
 func (g *gitImpl) FetchLatestRemoteCommit(repo *git.Repository, branch string) (*object.Commit, error) {
+	return g.latestRemoteCommit(context.Background(), repo, branch)
+}
+
+func (g *gitImpl) latestRemoteCommit(ctx context.Context, repo *git.Repository, branch string) (*object.Commit, error) {
 	rem, err := repo.Remote(remoteName)
 	if err != nil {
 		return nil, err
 	}
 
-	rfs, err := rem.List(&git.ListOptions{
-		Auth: g.authMethod,
-	})
+	rfs, err := g.listRemoteContext(ctx, rem)
 	if err != nil {
 		return nil, err
 	}
@@ -312,5 +810,5 @@ func (g *gitImpl) FetchLatestRemoteCommit(repo *git.Repository, branch string) (
 			return c, nil
 		}
 	}
-	return nil, errors.New("commit for ref could not be found")
+	return nil, &BranchMissingError{Remote: rem.Config().URLs[0], Branch: branch}
 }
@@ -0,0 +1,87 @@
+package gpoll
+
+import (
+	"context"
+	"runtime/pprof"
+	"sync"
+)
+
+// The name of the goroutines running commit handlers. A handler that ignores the cancellation of its context after the
+// HandlerTimeout keeps running in the background, so these aren't waited for when stopping.
+const handlerGoroutine = "handler"
+
+// The name of the goroutines listing the refs of the remote. go-git can't cancel a listing over SSH, so one that's
+// abandoned keeps running in the background until the connection gives up, and these aren't waited for either.
+const listRemoteGoroutine = "list-remote"
+
+// Tracks the goroutines spawned by a poller so they can be counted and waited for. Each runs with the pprof labels
+// gpoll, naming the poller, and gpoll.goroutine, naming what it does, so they can be told apart in profiles and
+// goroutine dumps.
+type goroutines struct {
+	label string
+
+	lock    sync.Mutex
+	exited  *sync.Cond
+	running map[string]int
+}
+
+func newGoroutines(label string) *goroutines {
+	g := &goroutines{
+		label:   label,
+		running: make(map[string]int),
+	}
+	g.exited = sync.NewCond(&g.lock)
+	return g
+}
+
+// Run f in a new goroutine under the name.
+func (g *goroutines) Go(name string, f func()) {
+	g.lock.Lock()
+	g.running[name]++
+	g.lock.Unlock()
+
+	labels := pprof.Labels("gpoll", g.label, "gpoll.goroutine", name)
+	go pprof.Do(context.Background(), labels, func(context.Context) {
+		defer g.exit(name)
+		f()
+	})
+}
+
+func (g *goroutines) exit(name string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.running[name]--
+	if g.running[name] == 0 {
+		delete(g.running, name)
+	}
+	g.exited.Broadcast()
+}
+
+// Get the number of running goroutines keyed by name.
+func (g *goroutines) counts() map[string]int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	counts := make(map[string]int, len(g.running))
+	for name, n := range g.running {
+		counts[name] = n
+	}
+	return counts
+}
+
+// Block until every goroutine other than those running handlers or listing the remote has exited.
+func (g *goroutines) wait() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	for g.waitable() {
+		g.exited.Wait()
+	}
+}
+
+func (g *goroutines) waitable() bool {
+	for name := range g.running {
+		if name != handlerGoroutine && name != listRemoteGoroutine {
+			return true
+		}
+	}
+	return false
+}
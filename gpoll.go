@@ -1,33 +1,177 @@
 // A library for polling a Git repository for changes.
 package gpoll
 
+//go:generate mockery --output mocks --outpkg mocks --dir . --all --case snake
+
 import (
+	"context"
+	"errors"
 	"gopkg.in/go-playground/validator.v9"
 	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
 	"os"
-	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Every method of a Poller is safe to call concurrently. A Poller can be started again after it stops, but only one
+// Start may be in progress or running at a time; the others return ErrAlreadyStarted.
 type Poller interface {
 	// Start polling your git repo without blocking. The poller will diff the remote against the local clone directory at
-	// the specified interval and return all changes through the configured callback and the returned channel.
+	// the specified interval and return all changes through the configured callback and the returned channel. If the
+	// remote has no commits yet, polling begins in the background once it has one.
 	StartAsync() (chan CommitDiff, error)
 
 	// Start polling your git repo blocking whatever thread it is run on. The poller will diff the remote against the
 	// local clone directory at the specified interval and return all changes through the configured callback.
 	Start() error
 
-	// Stop all polling.
+	// Like StartAsync but the initial clone is bounded by the context. Polling stops once the context is done.
+	StartAsyncContext(ctx context.Context) (chan CommitDiff, error)
+
+	// Like Start but the initial clone is bounded by the context. Polling stops, and the call returns, once the context
+	// is done.
+	StartContext(ctx context.Context) error
+
+	// Stop all polling. Cancels the initial clone if the poller is still starting, and the poll in flight if it is
+	// running. Has no effect if the poller isn't running.
 	Stop()
 
-	// Diff the remote and the local and return all differences.
+	// Stop all polling and block until the commit currently being delivered, if any, has been handled and every
+	// goroutine spawned by the poller has exited, other than handlers that ignored the cancellation of their context
+	// after the HandlerTimeout and listings of the remote that go-git couldn't cancel. Returns once those goroutines have
+	// exited if the poller is not running.
+	StopAndWait()
+
+	// Like StopAndWait but gives up waiting once the context is done, returning its error. The poller still stops.
+	StopContext(ctx context.Context) error
+
+	// Diff the remote and the local and return all differences. Safe to call while the poller is running: the commits
+	// returned are still delivered, and calls within the PollCacheTTL of the last poll share its result rather than
+	// fetching again. Returns ErrNotStarted if the poller hasn't been started.
 	Poll() ([]CommitDiff, error)
+
+	// Like Poll but the fetch is bounded by the context, e.g. to cancel a fetch hung on the network or bound it with a
+	// deadline.
+	PollContext(ctx context.Context) ([]CommitDiff, error)
+
+	// Get the recently delivered commits with a Sequence greater than since, oldest first. Lets a late subscriber catch
+	// up on what it missed without polling the remote. Only available when the Replay buffer is configured.
+	Events(since uint64) []CommitDiff
+
+	// Get a snapshot of the poller's current state.
+	Status() Status
+
+	// Get the receipt tracing the delivered commit with the ID, a CommitDiff.ID, back to the poll that found it and on
+	// to every handler and sink it was delivered to. Only the most recent 1000 receipts are kept.
+	Receipt(eventID string) (Receipt, bool)
+
+	// Get the channel every error passed to OnError is also sent to, e.g. failed polls, to log, alert or shut down on
+	// repeated failures. Holds up to the last 16 errors that haven't been received. Older ones are dropped and counted
+	// in the DroppedErrors of the Status.
+	Errors() <-chan error
+
+	// Pause polling until Resume is called. Commits pushed in the meantime are delivered once polling resumes.
+	Pause()
+
+	// Resume polling after a call to Pause.
+	Resume()
+
+	// Poll immediately rather than waiting for the next interval. Does nothing if the poller is not running.
+	Trigger()
+
+	// Report the outcome of applying a delivered commit downstream, identified by the ID of its CommitDiff. The result
+	// is reflected in Status and, if configured, reported through the CommitStatus. Returns ErrUnknownEvent if no
+	// delivered commit with the ID is awaiting a result.
+	ReportResult(eventID string, outcome Outcome, message string) error
+
+	// Freeze delivery at a known-good revision, either a sha or a tag, e.g. during an incident. Commits up to and
+	// including the revision are delivered. The remote is still polled while pinned so Status reports the lag, but
+	// nothing after the revision is delivered until Unpin is called. Returns ErrNotStarted if the poller hasn't been
+	// started.
+	PinTo(revision string) error
+
+	// Resume delivery after a call to PinTo.
+	Unpin()
+
+	// Get every ref currently advertised by the remote. Compare snapshots with DiffRefs. Returns ErrNotStarted if the
+	// poller hasn't been started.
+	RefSnapshot() (RefSnapshot, error)
+
+	// Get the runtime internals of the poller for debugging e.g. through PublishExpvar.
+	Debug() DebugInfo
+
+	// Add a named handler that is called with every delivered commit alongside any other handlers. If the handler has a
+	// Baseline, it is backfilled from there before receiving new commits. Added handlers are backfilled once the poller
+	// is started if it isn't already.
+	AddHandler(h Handler) error
+
+	// Remove a handler added through AddHandler or the PollConfig.
+	RemoveHandler(name string)
+
+	// Deliver a CommitDiff that reverses the changes from the last delivered commit back to an older revision, either a
+	// sha or a tag.
+	RollbackTo(revision string) error
+
+	// Get the last polled commit that changed the path, formatted as per the FilepathMode. Only paths included by the
+	// FileChangeFilter in commits polled since the poller started are known.
+	LastChange(path string) (PathChange, bool)
+
+	// Get up to limit of the most recent commits that changed the path, formatted as per the FilepathMode, newest first.
+	// The history is read from the local clone. A limit of 0 or less returns every commit.
+	History(path string, limit int) ([]Commit, error)
+
+	// Replay every commit made to the path, formatted as per the FilepathMode, since the time to the handlers, oldest
+	// first, e.g. to hydrate a handler or virtual repo added for the path without replaying the whole history. Each
+	// commit is diffed against its first parent from the local clone and only includes the changes within the path. The
+	// commits are marked as Backfill and are neither sent to the channel nor recorded as delivered. Returns
+	// ErrNotStarted if the poller hasn't been started.
+	Backfill(path string, since time.Time) error
+
+	// Get the commits withheld from delivery, oldest first. The oldest violated a policy or failed validation. The rest
+	// are held behind it so that commits are never delivered out of order.
+	Quarantine() []QuarantinedCommit
+
+	// Deliver the oldest quarantined commit regardless of why it was quarantined. The commits held behind it are then
+	// checked again, in order. Returns ErrNotQuarantined if the sha isn't quarantined or ErrNotOldestQuarantined if
+	// older commits are still quarantined.
+	Release(sha string) error
+
+	// Drop a quarantined commit without delivering it. If it was the oldest, the commits held behind it are then checked
+	// again, in order. The next delivered CommitDiff will be relative to the dropped commit. Returns ErrNotQuarantined if
+	// the sha isn't quarantined.
+	Discard(sha string) error
+
+	// Get the underlying go-git repository of the local clone. nil until the poller is started. The poller fetches into
+	// the repository while polling, so use WithRepo to safely run anything other than reads of immutable objects.
+	Repository() *git.Repository
+
+	// Get the log of every head observed on the remote and every commit delivered, oldest first, to reconstruct when the
+	// remote changed versus when the change was delivered. Returns ErrNoHeadLog unless the Checkpoint Store is a
+	// HeadLogStore, like NewFileCheckpointStore.
+	HeadLog() ([]HeadLogEntry, error)
+
+	// Replace the clone being polled with the Standby clone, e.g. after its files were tampered with. Polling continues
+	// from the commit the standby was last brought to. Returns ErrNoStandby if no standby is configured or it hasn't
+	// been cloned yet.
+	PromoteStandby() error
+
+	// Call the function with the underlying go-git repository while holding the lock the poller takes whenever it fetches
+	// into or reads from the repository, so custom go-git operations never interleave with its own. The function must not
+	// call back into the Poller. Returns ErrNotStarted if the poller hasn't been started, otherwise the function's error.
+	WithRepo(f func(repo *git.Repository) error) error
 }
 
 type HandleCommitFunc func(commit CommitDiff)
 
+type HandleCommitContextFunc func(ctx context.Context, commit CommitDiff)
+
+type HandleErrorFunc func(err error)
+
 type FileChangeFilterFunc func(change FileChange) bool
 
 type PollConfig struct {
@@ -37,12 +181,194 @@ type PollConfig struct {
 	// included in the commit passed into the HandleCommit calls. If false is returned, the file will always be ignored.
 	FileChangeFilter FileChangeFilterFunc
 
+	// An Expression every FileChange must satisfy to be included in the commit, e.g. parsed from YAML so the filter can
+	// change without recompiling. Applied after the FileChangeFilter and the Mailmap.
+	Filter *Expression
+
 	// Function that is called when a commit is made to the Git repo. This function maintains chronological order of
 	// commits and is called synchronously.
 	HandleCommit HandleCommitFunc
 
+	// Context-aware alternative to HandleCommit. The context is cancelled when the HandlerTimeout is exceeded. If both
+	// are set, HandleCommitContext is used.
+	HandleCommitContext HandleCommitContextFunc
+
+	// Named handlers that are called with every delivered commit alongside HandleCommit. Each handler can be backfilled
+	// from its own Baseline.
+	Handlers []Handler
+
+	// Promotes every delivered commit through the environments of the chain, alongside HandleCommit. Commits are only
+	// applied while the poller runs, and StopAndWait waits for the environments to stop.
+	Promotion *PromotionChain
+
+	// Subsets of the repo's paths that are delivered to their own handlers as if they were separate repos. Each is added
+	// as a named Handler.
+	VirtualRepos []VirtualRepo `validate:"dive"`
+
+	// The maximum amount of time a single call to the commit handler may take. When exceeded, the handler's context is
+	// cancelled, a HandlerTimeoutError is passed to OnError, and delivery continues with the next commit without waiting
+	// for the handler to return. A handler that ignores the cancellation, like every handler set via HandleCommit, keeps
+	// running in the background and may overlap with its calls for the next commits, so it must be safe to call
+	// concurrently. Defaults to no timeout.
+	HandlerTimeout time.Duration
+
+	// Function that is called when the poller encounters an error that cannot be returned to the caller, including every
+	// failed poll. The errors are also sent to the channel of Errors.
+	OnError HandleErrorFunc
+
+	// Function that is called after every poll with the error the poll failed with, nil if it succeeded e.g. to touch
+	// a healthcheck file.
+	OnPoll HandleErrorFunc
+
+	// Function that is called for every Event emitted by the poller e.g. policy violations.
+	HandleEvent HandleEventFunc
+
+	// Policies that commits must satisfy before they are delivered.
+	Policies PolicyConfig
+
+	// Validation that commits must pass before they are delivered. Commits failing validation are quarantined and
+	// emitted as Quarantined events.
+	Validation ValidationConfig
+
+	// Approval that commits must receive before they are delivered e.g. for manual approval of deployments. Commits are
+	// only gated once they satisfy the Policies and pass Validation.
+	Gate GateConfig
+
+	// Scanning of commit content for secrets. Findings are emitted as SecurityFinding events.
+	SecretScanning SecretScanConfig
+
+	// Thresholds over which a commit is a large change, emitted as a LargeChange event.
+	LargeChanges LargeChangeConfig
+
+	// Buffer of recently delivered commits that can be retrieved through Events.
+	Replay ReplayConfig
+
+	// Detection of handlers, or readers of the channel, that can't keep up with the commits being delivered.
+	SlowConsumer SlowConsumerConfig
+
+	// Redacts secrets only known to the application from errors, events, Status and audit records, on top of the
+	// password, passwords embedded in URLs and bearer tokens that are always redacted.
+	Redactor Redactor
+
+	// Where records of who configured what, when the credentials were used and where commits were delivered are
+	// written for compliance.
+	Audit AuditConfig
+
+	// Limits on the time and memory polls of the repo may use, throttling polling once exceeded.
+	Budget BudgetConfig
+
+	// How long state that would otherwise accumulate in long running pollers is kept.
+	Retention RetentionConfig
+
+	// Provider used to enrich every commit with its Provenance e.g. NewGitHubProvenanceProvider. If not set, commits
+	// are not enriched.
+	Provenance ProvenanceProvider
+
+	// Reports results passed to ReportResult back to the git provider e.g. NewGitHubCommitStatusReporter. If not set,
+	// results are only reflected in Status.
+	CommitStatus CommitStatusReporter
+
+	// Functions computing annotations for every commit e.g. through expensive lookups, memoized so each is computed
+	// once per commit.
+	Annotations AnnotationConfig
+
+	// The full sha of a commit the branch is known to contain, e.g. pinned in a bootstrap script. Every time the poller
+	// starts, the head of the clone must be the commit or a descendant of it, otherwise starting fails with an
+	// InitialShaMismatchError before anything is delivered. Protects against cloning a mirror whose history was
+	// tampered with.
+	ExpectedInitialSha string `validate:"omitempty,len=40,hexadecimal"`
+
+	// Where the last delivered commit is kept so delivery resumes from it after a restart, and how the local clone is
+	// reconciled with it on start.
+	Checkpoint CheckpointConfig
+
+	// Durable queue that delivered commits pass through on their way to a downstream system, so they survive crashes
+	// and are retried until acknowledged without holding up polling.
+	Outbox OutboxConfig
+
+	// Which tags RefChanges are emitted for in Mirror mode.
+	Tags TagConfig
+
+	// A second clone kept up to date in the background that replaces the clone being polled if it becomes corrupted.
+	Standby StandbyConfig
+
 	// The polling interval. Defaults to 30 seconds.
 	Interval time.Duration
+
+	// Start even if the initial clone fails, e.g. when the remote is temporarily down, so services embedding the poller
+	// can start without it. The clone is retried in the background, backing off up to the Interval, emitting a Degraded
+	// event for every failed attempt and a Recovered event once it succeeds. Start blocks until then, while StartAsync
+	// returns immediately. A remote without any commits, e.g. a freshly created repo, doesn't fail the start either way;
+	// the poller waits for its first commit, cloning it every Interval, and delivers it as the initial state.
+	StartDegraded bool
+
+	// How long the result of a poll is shared with calls to Poll rather than fetching again, e.g. when many consumers
+	// in one process call Poll. Defaults to 0 which fetches on every call.
+	PollCacheTTL time.Duration
+
+	// How long to hold polled commits before delivering them. Commits are batched until the Debounce duration has
+	// passed without any new commits being seen, at which point the whole batch is delivered in order. Since this is
+	// checked once per poll, the effective debounce is rounded up to the Interval. Defaults to 0 which delivers commits
+	// as soon as they are polled.
+	Debounce time.Duration
+
+	// Path patterns that mark a commit as high priority. A commit touching any matching path bypasses the Debounce and
+	// is delivered immediately, along with any commits batched before it so ordering is preserved. Patterns use
+	// path.Match syntax against the path relative to the root of the repo, and a pattern naming a directory matches
+	// everything beneath it.
+	PriorityPaths []string
+
+	// Mappings of authors and committers to their canonical identities, applied to every polled commit.
+	Mailmap MailmapConfig
+
+	// File extensions e.g. .json whose files are only included in a commit if they changed in more than the whitespace
+	// ignored by the WhitespaceMode, e.g. to ignore reformatting of config files. A commit where every file only changed
+	// in ignored whitespace is still delivered, without any changes.
+	IgnoreWhitespace map[string]WhitespaceMode
+
+	// Detection of commits that only normalize line endings.
+	Normalization NormalizationConfig
+
+	// Tolerances for commits dated too far from when they were received.
+	ClockSkew ClockSkewConfig
+
+	// What to do once the Branch is deleted on the remote. Defaults to BranchMissingWait.
+	BranchMissing BranchMissingPolicy
+
+	// How FileChange.Filepath is presented. Defaults to FilepathModeCloneAbsolute.
+	FilepathMode FilepathMode
+
+	// The separator used in FileChange.Filepath. Defaults to SeparatorNative.
+	FilepathSeparator FilepathSeparator
+
+	// Injects faults such as failed fetches, delays and partial diffs. For testing only.
+	FaultInjector FaultInjector
+
+	// The validator used to validate the config in NewPoller. Share an application wide validator or register custom
+	// rules on it e.g. a struct level validation for GitConfig restricting which remotes may be polled. Defaults to a
+	// new validator.
+	Validator *validator.Validate `validate:"-"`
+
+	// The value of the gpoll pprof label of every goroutine spawned by the poller, telling pollers apart in profiles and
+	// goroutine dumps. Defaults to the Remote.
+	GoroutineLabel string
+
+	// Remove the CloneDirectory, and the Standby Directory, once the poller stops so nothing is left behind, e.g. in CI
+	// jobs and other short-lived workloads. Only applies to StorageTypeFilesystem, and requires the CloneDirectory to be
+	// set rather than defaulting to the working directory. Starting again clones from scratch.
+	CleanupOnStop bool
+
+	// Attach a Snapshot to every delivered CommitDiff so handlers can read any file at the commit, not just the ones
+	// that changed. Each snapshot stays readable until a result is reported for the commit through ReportResult, or
+	// the commit is forgotten as per the Retention.
+	Snapshots bool
+
+	// Compute a Manifest of the SHA-256 of every file included by the FileChangeFilter at every delivered commit. It is
+	// set as the Manifest of the CommitDiff before the commit is handled, and emitted as an event.
+	Manifests bool
+
+	// Skip validation of the config in NewPoller.
+	SkipValidation bool
 }
 
 // Create a new Poller from config. Will return an error for misconfiguration.
@@ -50,7 +376,31 @@ func NewPoller(config PollConfig) (Poller, error) {
 	if config.Interval == 0 {
 		config.Interval = 30 * time.Second
 	}
+	if config.Retention.Interval == 0 {
+		config.Retention.Interval = defaultRetentionInterval
+	}
+	if config.Retention.MaxAwaitingResults == 0 {
+		config.Retention.MaxAwaitingResults = defaultMaxAwaitingResults
+	}
+	if config.Outbox.RetryInterval == 0 {
+		config.Outbox.RetryInterval = defaultOutboxRetryInterval
+	}
+	if config.SlowConsumer.Window <= 0 {
+		config.SlowConsumer.Window = defaultSlowConsumerWindow
+	}
+	if config.Budget.Window == 0 {
+		config.Budget.Window = defaultBudgetWindow
+	}
+	if config.ClockSkew.Future == 0 {
+		config.ClockSkew.Future = defaultFutureSkew
+	}
+	if config.Standby.Interval == 0 {
+		config.Standby.Interval = defaultStandbyInterval
+	}
 
+	if config.CleanupOnStop && config.Git.CloneDirectory == "" {
+		return nil, errors.New("cleaning up on stop requires a clone directory")
+	}
 	if config.Git.CloneDirectory == "" {
 		wd, err := os.Getwd()
 		if err != nil {
@@ -58,39 +408,255 @@ func NewPoller(config PollConfig) (Poller, error) {
 		}
 		config.Git.CloneDirectory = wd
 	}
-	v := validator.New()
-	if err := v.Struct(config); err != nil {
-		return nil, err
+	if config.Standby.Directory != "" && filepath.Clean(config.Standby.Directory) == filepath.Clean(config.Git.CloneDirectory) {
+		return nil, errors.New("the standby directory must differ from the clone directory")
+	}
+	if !config.SkipValidation {
+		v := config.Validator
+		if v == nil {
+			v = validator.New()
+		}
+		if err := v.Struct(config); err != nil {
+			return nil, err
+		}
 	}
 
 	g, err := newGit(config.Git)
 	if err != nil {
 		return nil, err
 	}
+	impl, _ := g.(*gitImpl)
+	if config.FaultInjector != nil {
+		g = &faultyGit{GitService: g, faults: config.FaultInjector}
+	}
+
+	label := config.GoroutineLabel
+	if label == "" {
+		label = config.Git.Remote
+	}
+
+	secretRules, err := compileSecretRules(config.SecretScanning.Rules)
+	if err != nil {
+		return nil, err
+	}
 
 	closer := make(chan bool, 1)
 	onChangeChan := make(chan CommitDiff, 1)
 
 	poller := &poller{
-		c:      onChangeChan,
-		config: &config,
-		closer: closer,
-		git:    g,
+		c:             onChangeChan,
+		errs:          make(chan error, errorBuffer),
+		receipts:      newReceiptLog(),
+		config:        &config,
+		closer:        closer,
+		trigger:       make(chan struct{}, 1),
+		git:           g,
+		secretRules:   secretRules,
+		replay:        newReplayBuffer(config.Replay),
+		results:       newResultTracker(config.Retention),
+		checkpoints:   make(map[string]string),
+		lastChanges:   make(map[string]PathChange),
+		annotations:   config.Annotations.Cache,
+		outboxSignal:  make(chan struct{}, 1),
+		outboxHandled: make(map[string]bool),
+		goroutines:    newGoroutines(label),
+		waits:         newWaitWindow(config.SlowConsumer.Window),
+
+		standbyDirectory: config.Standby.Directory,
+	}
+	poller.directory.Store(config.Git.CloneDirectory)
+	if config.Audit.Sink != nil {
+		poller.auditActor = config.Audit.Actor
+		if poller.auditActor == "" {
+			poller.auditActor = defaultAuditActor()
+		}
+		if impl != nil {
+			impl.onAuth = poller.auditCredentialUse
+		}
+	}
+	if impl != nil {
+		impl.goroutines = poller.goroutines
+	}
+	poller.credentials = impl
+	if poller.annotations == nil {
+		poller.annotations = NewMemoryAnnotationCache(defaultAnnotationCacheSize)
+	}
+	for _, h := range config.Handlers {
+		if err := poller.AddHandler(h); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range config.VirtualRepos {
+		if err := poller.AddHandler(poller.virtualRepoHandler(v)); err != nil {
+			return nil, err
+		}
 	}
 
 	return poller, nil
 }
 
 type poller struct {
+	// The number of errors dropped from errs because it was full. Accessed atomically, so it's kept first to be 64-bit
+	// aligned on 32-bit platforms.
+	droppedErrors uint64
+
 	c      chan CommitDiff
 	config *PollConfig
 	closer chan bool
-	git    GitService
-	repo   *git.Repository
+	// Every error passed to onError. See Errors.
+	errs chan error
+	// The receipts of the delivered commits. See Receipt.
+	receipts *receiptLog
+	// The checksums of the blobs of the last Manifest. Guarded by the repoLock.
+	manifestSums map[plumbing.Hash]string
+	// Signals the loop to poll before the next tick.
+	trigger chan struct{}
+	// Whether polls are only triggered, by the scheduler of a PollerGroup, rather than on the Interval.
+	scheduled bool
+	// Receives everything sent on the channel until the poller stops when set, by a MultiPoller or PollerGroup, rather
+	// than it being read by the user.
+	forwardCommit func(commit CommitDiff)
+	// Receives the errors of the poller until it stops when set, by a PollerGroup.
+	forwardError func(err error)
+	git          GitService
+	// Set once the poller is started. Read it through repository() unless holding the repoLock.
+	repo *git.Repository
+
+	// Commits that are withheld from delivery because of a policy violation or failed validation, oldest first.
+	held []QuarantinedCommit
+	// Whether the oldest held commit was released or discarded, so the held commits are to be checked again.
+	heldDecided bool
+
+	// Guards the repo against concurrent fetches, reads and custom operations through WithRepo.
+	repoLock sync.Mutex
+
+	// Serializes polls from the loop and through Poll, and guards the result of the last poll.
+	pollLock     sync.Mutex
+	pollCachedAt time.Time
+	pollCache    []CommitDiff
+
+	// Serializes delivery from the loop and from RollbackTo.
+	deliverLock sync.Mutex
+
+	// The git service presenting the credentials, used to redact them. nil if the service was replaced.
+	credentials *gitImpl
+
+	// Serializes writes to the audit Sink.
+	auditLock  sync.Mutex
+	auditActor string
+
+	handlers []Handler
+	// Handlers added before the poller was started that are waiting to be backfilled. Guarded by the lock.
+	backfills []Handler
+
+	secretRules map[string]*regexp.Regexp
+
+	// Guards the state below that is read through Status.
+	lock          sync.RWMutex
+	running       bool
+	paused        bool
+	lastPoll      time.Time
+	lastError     error
+	lastDelivered Commit
+	// The number of polls in a row that failed.
+	consecutiveFailures int
+	// When the remote became unreachable. Zero if it is reachable.
+	unreachableSince time.Time
+	// When the branch was found deleted on the remote. Zero if it exists.
+	branchMissingSince time.Time
+	// The size of the clone on disk as of the last poll. Only measured under a storage Quota.
+	diskUsage int64
+	// When the standby was last brought up to date. Zero if there is no standby yet.
+	standbyUpdatedAt time.Time
+	// The sha delivery is pinned to. Empty if not pinned.
+	pinnedTo string
+	// The number of commits seen but not yet delivered.
+	lag int
+	// The number of commits passed to deliver that have yet to be handled.
+	queued int
+	// How long recently delivered commits waited to be handled.
+	waits *waitWindow
+	// Whether the percentile of the waits is above the SlowConsumer Threshold.
+	slowConsumer bool
+	// The usage of the Budget since the start of the current window, and when polling resumes once it was exceeded.
+	budgetWindowStart time.Time
+	cpuUsed           time.Duration
+	memoryUsed        int64
+	throttledUntil    time.Time
+	// The refs on the remote as of the last poll in Mirror mode, keyed by name.
+	refs map[string]string
+	// The sha of the remote head when last observed by a poll.
+	observedHead string
+	// The newest version of the tags admitted in Mirror mode. nil until one is admitted.
+	newestTag *version
+	// When the remote was last cloned.
+	clonedAt time.Time
+	// Cancels the initial clone while the poller is starting. nil otherwise.
+	cancelStart context.CancelFunc
+	// Cancels the poll in flight in the loop along with any delivery waiting on the channel. nil unless the loop is
+	// running.
+	cancelPoll context.CancelFunc
+	// The context cancelled by cancelPoll. nil unless the loop is running.
+	pollCtx context.Context
+	// When the initial clone first failed while starting with StartDegraded. Zero unless it is being retried.
+	degradedSince time.Time
+	// Whether the remote was empty when starting and the first commit is being waited for.
+	awaitingFirstCommit bool
+	// Closed once the loop exits.
+	done chan struct{}
+	// The Sequence of the last delivered commit.
+	sequence uint64
+	results  *resultTracker
+	// The sha of the last commit handled by each named handler.
+	checkpoints map[string]string
+	// The last polled change to each path.
+	lastChanges map[string]PathChange
+	// Commits found through Poll while running that the loop has yet to deliver.
+	unclaimed []CommitDiff
+
+	replay *replayBuffer
+
+	annotations AnnotationCache
+
+	// Wakes the drain of the outbox when a commit is added to it.
+	outboxSignal chan struct{}
+	// The IDs of the commits in the outbox whose handlers were called but which weren't acknowledged. Only used by the
+	// drain of the outbox.
+	outboxHandled map[string]bool
+
+	goroutines *goroutines
+
+	// The directory of the clone being polled. Holds a string.
+	directory atomic.Value
+
+	// Guards the standby clone and its directory, which is swapped with the directory of the clone being polled on
+	// promotion.
+	standbyLock      sync.Mutex
+	standby          *git.Repository
+	standbyDirectory string
+	// Stops the goroutine keeping the standby up to date, which closes standbyExited once it returns. Only used by the
+	// loop.
+	standbyStop   chan struct{}
+	standbyExited chan struct{}
+}
+
+var ErrNotStarted = errors.New("the poller has not been started")
+
+var ErrAlreadyStarted = errors.New("the poller has already been started")
+
+// Get the repo, or nil if the poller hasn't been started, without waiting for the repo to be free.
+func (p *poller) repository() *git.Repository {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.repo
 }
 
 func (p *poller) Start() error {
-	ticker, err := p.setup()
+	return p.StartContext(context.Background())
+}
+
+func (p *poller) StartContext(ctx context.Context) error {
+	ticker, err := p.setup(ctx)
 	if err != nil {
 		return err
 	}
@@ -100,118 +666,625 @@ func (p *poller) Start() error {
 }
 
 func (p *poller) StartAsync() (chan CommitDiff, error) {
-	ticker, err := p.setup()
+	return p.StartAsyncContext(context.Background())
+}
+
+func (p *poller) StartAsyncContext(ctx context.Context) (chan CommitDiff, error) {
+	startCtx, cancel, err := p.beginStart(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	go p.loop(ticker)
+	repo, err := p.git.Clone(startCtx, p.config.Git.Remote, p.config.Git.Branch, p.config.Git.CloneDirectory)
+	if p.retriesClone(err) {
+		p.goroutines.Go("start", func() {
+			defer p.endStart(cancel)
+			repo, err := p.retryClone(startCtx, err)
+			if err != nil {
+				if startCtx.Err() == nil {
+					p.onError(err)
+				}
+				return
+			}
+			ticker, err := p.finishStart(ctx, repo)
+			if err != nil {
+				p.onError(err)
+				return
+			}
+			p.goroutines.Go("loop", func() { p.loop(ticker) })
+			// Stop may have been called after the clone succeeded but before the poller was running.
+			if startCtx.Err() != nil {
+				p.Stop()
+			}
+		})
+		return p.c, nil
+	}
+	defer p.endStart(cancel)
+	if err != nil {
+		return nil, p.redact(err)
+	}
+
+	ticker, err := p.finishStart(ctx, repo)
+	if err != nil {
+		return nil, p.redact(err)
+	}
+
+	p.goroutines.Go("loop", func() { p.loop(ticker) })
 
 	return p.c, nil
 }
 
 func (p *poller) Poll() ([]CommitDiff, error) {
-	changes, err := p.git.DiffRemote(p.repo, p.config.Git.Branch)
+	return p.PollContext(context.Background())
+}
+
+func (p *poller) PollContext(ctx context.Context) ([]CommitDiff, error) {
+	p.pollLock.Lock()
+	defer p.pollLock.Unlock()
+	if ttl := p.config.PollCacheTTL; ttl > 0 && time.Since(p.pollCachedAt) < ttl {
+		return append([]CommitDiff(nil), p.pollCache...), nil
+	}
+
+	changes, err := p.poll(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(changes) > 0 {
-		for _, change := range changes {
-			for i, c := range change.Changes {
-				if p.config.FileChangeFilter != nil {
-					filteredChanges := make([]FileChange, 0)
-					if p.config.FileChangeFilter(c) {
-						filteredChanges = append(filteredChanges, c)
-					}
-					change.Changes = filteredChanges
-				}
-				change.Changes[i].Filepath = path.Join(p.config.Git.CloneDirectory, c.Filepath)
+	// Commits are only seen by the first poll after they are pushed, so those found here are passed on to the loop.
+	p.lock.Lock()
+	if p.running {
+		p.unclaimed = append(p.unclaimed, changes...)
+	}
+	p.lock.Unlock()
+	return append([]CommitDiff(nil), changes...), nil
+}
+
+// Polls for the loop, including the commits found through calls to Poll since the last time the loop polled.
+func (p *poller) pollLoop(ctx context.Context) ([]CommitDiff, error) {
+	p.pollLock.Lock()
+	defer p.pollLock.Unlock()
+	changes, err := p.poll(ctx)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	unclaimed := p.unclaimed
+	p.unclaimed = nil
+	return append(unclaimed, changes...), err
+}
+
+func (p *poller) diffRemote(ctx context.Context) ([]CommitDiff, error) {
+	p.repoLock.Lock()
+	defer p.repoLock.Unlock()
+	if p.repo == nil {
+		return nil, ErrNotStarted
+	}
+	return p.git.DiffRemote(ctx, p.repo, p.config.Git.Branch)
+}
+
+// Fetches and diffs the remote. Must be called while holding the pollLock.
+func (p *poller) poll(ctx context.Context) ([]CommitDiff, error) {
+	start := time.Now()
+	changes, err := p.diffRemote(ctx)
+	if err == nil {
+		changes, err = p.replaceHeld(changes)
+	}
+	if err != nil {
+		// Fetching costs the same whether or not anything was found.
+		p.charge(time.Since(start), nil)
+		return nil, err
+	}
+
+	// The diffs are changed below so they're copied to leave whatever the GitService returned untouched.
+	changes = append(make([]CommitDiff, 0, len(changes)), changes...)
+	receivedAt := time.Now()
+	pollID := newDeliveryID()
+	for i, change := range changes {
+		filtered := make([]FileChange, 0, len(change.Changes))
+		for _, c := range change.Changes {
+			if p.config.FileChangeFilter != nil && !p.config.FileChangeFilter(c) {
+				continue
+			}
+			if ws, err := p.isWhitespaceChange(change.From.Sha, change.To.Sha, c); err != nil {
+				p.onError(err)
+			} else if ws {
+				continue
 			}
+			filtered = append(filtered, c)
+		}
+		changes[i].Changes = filtered
+		p.detectNormalization(&changes[i])
+		for j := range filtered {
+			filtered[j].Filepath = p.formatPath(filtered[j].Filepath)
+		}
+		changes[i].ReceivedAt = receivedAt
+		changes[i].PollID = pollID
+	}
+	if len(changes) > 0 {
+		p.observeHead(changes[len(changes)-1].To.Sha, receivedAt)
+	}
+	p.detectClockSkew(changes, receivedAt)
+	p.detectHistoryReplaced(changes)
+	p.applyMailmap(changes)
+	if p.config.Filter != nil {
+		for i := range changes {
+			changes[i] = p.filterByExpression(p.config.Filter, changes[i])
 		}
 	}
+	p.indexChanges(changes)
+	p.charge(time.Since(start), changes)
+	p.pollCachedAt = time.Now()
+	p.pollCache = changes
 	return changes, nil
 }
 
+func (p *poller) Trigger() {
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
 func (p *poller) Stop() {
-	p.closer <- true
+	// Signalled while holding the lock so the signal can't outlive the loop it was meant for. See stopped.
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.cancelStart != nil {
+		p.cancelStart()
+	}
+	if p.cancelPoll != nil {
+		p.cancelPoll()
+	}
+	if !p.running {
+		return
+	}
+	select {
+	case p.closer <- true:
+	default:
+	}
 }
 
-func (p *poller) onStart() error {
-	if p.config.HandleCommit == nil {
+// Forwards everything sent on the channel, and the errors if they're forwarded, until polling stops after which nothing
+// more is sent.
+func (p *poller) forward(done chan struct{}) {
+	var errs <-chan error
+	if p.forwardError != nil {
+		errs = p.errs
+	}
+	for {
+		select {
+		case c := <-p.c:
+			p.forwardCommit(c)
+		case err := <-errs:
+			p.forwardError(err)
+		case <-done:
+			return
+		}
+	}
+}
+
+// Stops polling once the context is done unless polling already stopped.
+func (p *poller) stopOnDone(ctx context.Context, done chan struct{}) {
+	select {
+	case <-ctx.Done():
+		p.Stop()
+	case <-done:
+	}
+}
+
+func (p *poller) StopAndWait() {
+	p.lock.RLock()
+	running, done := p.running, p.done
+	p.lock.RUnlock()
+	p.Stop()
+	if running {
+		<-done
+	}
+	p.goroutines.wait()
+}
+
+// The wait is abandoned rather than stopped once the context is done.
+func (p *poller) StopContext(ctx context.Context) error {
+	waited := make(chan struct{})
+	go func() {
+		defer close(waited)
+		p.StopAndWait()
+	}()
+	select {
+	case <-waited:
 		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
+
+func (p *poller) onStart() error {
 	commit, err := p.git.HeadCommit(p.repo)
 	if err != nil {
 		return err
 	}
-	gitDir := path.Join("*", ".git")
-	changes := make([]FileChange, 0)
-	err = filepath.Walk(p.config.Git.CloneDirectory, func(fp string, _ os.FileInfo, err error) error {
-		if err != nil {
-			return filepath.SkipDir
-		}
-		isInGitDir, _ := filepath.Match(path.Join(gitDir, "*"), fp)
-		isGitDir, _ := filepath.Match(gitDir, fp)
-		if isInGitDir || isGitDir {
-			return filepath.SkipDir
-		}
-
-		changes = append(changes, FileChange{
-			Filepath:   fp,
-			ChangeType: ChangeTypeInit,
-		})
+	base := p.git.ToInternal(commit)
 
+	// Consumers have the head of the clone until something is delivered, so rollbacks and backfills are diffed from it.
+	p.lock.Lock()
+	p.lastDelivered = *base
+	backfills := p.backfills
+	p.backfills = nil
+	p.lock.Unlock()
+	if !p.hasHandler() && len(backfills) == 0 {
 		return nil
-	})
+	}
+	changes, err := p.git.ListFiles(commit)
 	if err != nil {
 		return err
 	}
+	for i := range changes {
+		changes[i].Filepath = p.formatPath(changes[i].Filepath)
+	}
 
-	base := p.git.ToInternal(commit)
-
-	p.config.HandleCommit(CommitDiff{
+	initial := CommitDiff{
 		Changes: changes,
 		From:    *base,
 		To:      *base,
-	})
+	}
+	if p.config.Snapshots {
+		initial.Snapshot = p.newSnapshot(*base)
+	}
+	p.attachManifest(&initial)
+	p.handleCommit(initial)
+
+	for _, h := range backfills {
+		if err := p.AddHandler(h); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (p *poller) setup() (*time.Ticker, error) {
-	repo, err := p.git.Clone(p.config.Git.Remote, p.config.Git.Branch, p.config.Git.CloneDirectory)
+func (p *poller) setup(ctx context.Context) (*time.Ticker, error) {
+	startCtx, cancel, err := p.beginStart(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.endStart(cancel)
+
+	repo, err := p.git.Clone(startCtx, p.config.Git.Remote, p.config.Git.Branch, p.config.Git.CloneDirectory)
+	if p.retriesClone(err) {
+		repo, err = p.retryClone(startCtx, err)
+	}
 	if err != nil {
 		return nil, err
 	}
+	return p.finishStart(ctx, repo)
+}
+
+// Marks the poller as starting. The returned context bounds the initial clone and is cancelled through Stop, and the
+// returned function must be passed to endStart.
+func (p *poller) beginStart(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.running || p.cancelStart != nil {
+		return nil, nil, ErrAlreadyStarted
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	p.cancelStart = cancel
+	return ctx, cancel, nil
+}
+
+func (p *poller) endStart(cancel context.CancelFunc) {
+	cancel()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.cancelStart = nil
+}
+
+// Completes the start with the cloned repo, after which the loop is to be run.
+func (p *poller) finishStart(ctx context.Context, repo *git.Repository) (*time.Ticker, error) {
+	if err := p.verifyInitialSha(repo); err != nil {
+		return nil, err
+	}
 
+	// The repo is only ever replaced here, while holding both locks, so it can be read while holding either.
+	p.repoLock.Lock()
+	p.lock.Lock()
 	p.repo = repo
+	p.lock.Unlock()
+	p.repoLock.Unlock()
 
-	err = p.onStart()
-	if err != nil {
+	if err := p.reconcile(); err != nil {
 		return nil, err
 	}
 
-	return time.NewTicker(p.config.Interval), nil
+	if err := p.onStart(); err != nil {
+		return nil, err
+	}
+
+	p.auditStart()
+	p.lock.Lock()
+	p.clonedAt = time.Now()
+	p.running = true
+	p.done = make(chan struct{})
+	p.lock.Unlock()
+	done := p.done
+	p.goroutines.Go("janitor", func() { p.janitor(done) })
+	if p.config.Outbox.Store != nil {
+		p.goroutines.Go("outbox", func() { p.drainOutbox(done) })
+	}
+	if p.config.Promotion != nil {
+		p.config.Promotion.start(p, done)
+	}
+	if p.config.Standby.Directory != "" {
+		stop, exited := make(chan struct{}), make(chan struct{})
+		p.standbyStop, p.standbyExited = stop, exited
+		p.goroutines.Go("standby", func() {
+			defer close(exited)
+			p.maintainStandby(stop)
+		})
+	}
+	if ctx.Done() != nil {
+		p.goroutines.Go("stop-on-done", func() { p.stopOnDone(ctx, done) })
+	}
+	if p.forwardCommit != nil {
+		p.goroutines.Go("forward", func() { p.forward(done) })
+	}
+	ticker := time.NewTicker(p.config.Interval)
+	if p.scheduled {
+		// A stopped ticker never fires, leaving the loop to wait for triggers.
+		ticker.Stop()
+	}
+	return ticker, nil
 }
 
 func (p *poller) loop(ticker *time.Ticker) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.lock.Lock()
+	p.cancelPoll, p.pollCtx = cancel, ctx
+	p.lock.Unlock()
+	// The clones are cleaned up before the poller is marked as stopped so that a new start can't race the cleanup.
+	defer func() {
+		p.lock.Lock()
+		p.cancelPoll, p.pollCtx = nil, nil
+		p.lock.Unlock()
+		cancel()
+		p.haltStandby()
+		p.cleanup()
+		p.stopped()
+	}()
+	pending := make([]CommitDiff, 0)
+	var lastSeen time.Time
 	for {
-		changes, err := p.Poll()
-		if err != nil {
-			continue
+		if p.isPaused() || p.isThrottled() {
+			select {
+			case <-ticker.C:
+				continue
+			case <-p.trigger:
+				continue
+			case <-p.closer:
+				ticker.Stop()
+				return
+			}
+		}
+		changes, err := p.pollLoop(ctx)
+		if err != nil && ctx.Err() != nil {
+			// Stopped mid poll.
+			ticker.Stop()
+			return
+		}
+		if err == nil && p.config.Git.Mirror {
+			err = p.pollRefs()
+		}
+		p.recordPoll(err)
+		p.onPoll(err)
+		if p.handleMissingBranch(err) {
+			ticker.Stop()
+			return
+		}
+		if err != nil && err != git.NoErrAlreadyUpToDate {
+			p.onError(err)
+		}
+		p.trackReachability(err)
+		p.failover(err)
+		if err == nil || err == git.NoErrAlreadyUpToDate {
+			p.enforceQuota()
+		}
+		released, readmit := p.takeHeld()
+		if len(released) > 0 {
+			pending = append(pending, released...)
+			lastSeen = time.Now()
+		}
+		for _, c := range readmit {
+			if p.admit(ctx, c) {
+				pending = append(pending, c)
+				lastSeen = time.Now()
+			}
 		}
 		for _, c := range changes {
-			if p.config.HandleCommit != nil {
-				p.config.HandleCommit(c)
+			p.enrich(ctx, &c)
+			p.annotate(&c)
+			if !p.admit(ctx, c) {
+				continue
+			}
+			pending = append(pending, c)
+			lastSeen = time.Now()
+			if p.isPriority(c) {
+				pending = p.deliverPending(ctx, pending)
 			}
-			p.c <- c
 		}
+		if len(pending) > 0 && time.Since(lastSeen) >= p.config.Debounce {
+			pending = p.deliverPending(ctx, pending)
+		}
+		p.recordLag(len(pending))
 		select {
 		case <-ticker.C:
 			continue
+		case <-p.trigger:
+			continue
 		case <-p.closer:
 			ticker.Stop()
 			return
 		}
 	}
 }
+
+// Checks whether the commit can be delivered. Once a commit halts delivery, it and every commit after it are held.
+func (p *poller) admit(ctx context.Context, commit CommitDiff) bool {
+	if p.isHolding() {
+		p.hold(commit, "")
+		return false
+	}
+	if commit.Normalization && p.config.Normalization.Suppress {
+		return false
+	}
+
+	admitted, halted := true, false
+	reasons := make([]string, 0)
+	for _, v := range p.checkPolicies(commit) {
+		p.emit(v)
+		switch v.Action {
+		case PolicyActionHalt:
+			halted = true
+			reasons = append(reasons, v.Reason)
+		case PolicyActionSkip:
+			admitted = false
+		}
+	}
+
+	for _, w := range checkPaths(commit, p.relativePath) {
+		p.emit(w)
+	}
+
+	blocked, err := p.scanSecrets(commit)
+	if err != nil {
+		p.onError(err)
+		// A commit that couldn't be scanned may contain secrets, so it's held rather than let through.
+		if p.config.SecretScanning.Block {
+			blocked = true
+			reasons = append(reasons, "failed to scan for secrets: "+err.Error())
+		}
+	} else if blocked {
+		reasons = append(reasons, "potential secret found")
+	}
+	if halted || blocked {
+		p.hold(commit, strings.Join(reasons, "; "))
+		return false
+	}
+	if !admitted {
+		return false
+	}
+
+	if l := p.checkLargeChange(commit); l != nil {
+		p.emit(*l)
+		if p.config.LargeChanges.SkipDelivery {
+			return false
+		}
+	}
+
+	q, decided := p.validate(ctx, commit)
+	if !decided {
+		p.holdUndecided(commit)
+		return false
+	}
+	if q != nil {
+		p.emit(*q)
+		switch q.Action {
+		case PolicyActionHalt:
+			p.hold(commit, q.Err.Error())
+			return false
+		case PolicyActionSkip:
+			return false
+		}
+	}
+
+	v, decided := p.checkGate(ctx, commit)
+	if !decided {
+		p.holdUndecided(commit)
+		return false
+	}
+	if v != nil {
+		p.emit(*v)
+		switch v.Action {
+		case PolicyActionHalt:
+			p.hold(commit, v.Reason)
+			return false
+		case PolicyActionSkip:
+			return false
+		}
+	}
+	return true
+}
+
+func (p *poller) hold(commit CommitDiff, reason string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.held = append(p.held, QuarantinedCommit{Commit: commit, Reason: reason})
+}
+
+func (p *poller) isHolding() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return len(p.held) > 0
+}
+
+func (p *poller) Events(since uint64) []CommitDiff {
+	return p.replay.since(since)
+}
+
+// Returns ErrNotStarted if the context is done, i.e. the poller is stopping, before every commit is sent on the channel.
+// Commits after the one that couldn't be sent aren't delivered.
+func (p *poller) deliver(ctx context.Context, commits []CommitDiff) error {
+	p.deliverLock.Lock()
+	defer p.deliverLock.Unlock()
+	p.lock.Lock()
+	p.queued = len(commits)
+	p.lock.Unlock()
+	for _, c := range commits {
+		var s *snapshot
+		if p.config.Snapshots {
+			s = p.newSnapshot(c.To)
+			c.Snapshot = s
+		}
+		p.attachManifest(&c)
+		p.lock.Lock()
+		p.sequence++
+		c.Sequence = p.sequence
+		c.ID = EventID(p.config.Git.Remote, p.config.Git.Branch, c.To.Sha, c.Sequence)
+		p.lastDelivered = c.To
+		p.results.delivered(c.ID, c.To, s)
+		p.lock.Unlock()
+		p.replay.add(c)
+		p.receipts.add(Receipt{
+			PollID:      c.PollID,
+			CommitID:    c.ID,
+			Sha:         c.To.Sha,
+			Sequence:    c.Sequence,
+			ReceivedAt:  c.ReceivedAt,
+			DeliveredAt: time.Now(),
+		})
+		// The handlers of commits in the outbox are called by its drain, which also audits their delivery.
+		outboxed := p.putOutbox(c)
+		if !outboxed {
+			p.receipts.handled(c.ID, p.handleCommit(c))
+		}
+		p.saveCheckpoint(c.To.Sha)
+		p.logHead(HeadLogEntry{Kind: HeadLogKindDelivered, Sha: c.To.Sha, At: time.Now()})
+		select {
+		case p.c <- c:
+		case <-ctx.Done():
+			return ErrNotStarted
+		}
+		p.receipts.deliveredTo(c.ID, "channel", nil)
+		if !outboxed {
+			p.auditDelivery(c)
+		}
+		p.recordWait(c)
+	}
+	return nil
+}
+
+func (p *poller) isPriority(commit CommitDiff) bool {
+	for _, c := range commit.Changes {
+		if matchesAny(p.config.PriorityPaths, p.relativePath(c.Filepath)) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,10 +1,13 @@
-package gpoll
+package gpoll_test
 
 import (
 	"github.com/bxcodec/faker/v3"
+	"github.com/eddieowens/gpoll"
 	"github.com/eddieowens/gpoll/mocks"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"testing"
 )
 
@@ -12,41 +15,48 @@ type GpollTest struct {
 	suite.Suite
 
 	gitMock *mocks.GitService
-	p       *poller
+	config  gpoll.PollConfig
+	p       gpoll.Poller
 }
 
 func (g *GpollTest) SetupTest() {
 	g.gitMock = new(mocks.GitService)
-	p, err := NewPoller(PollConfig{
-		Git: GitConfig{
-			Auth: GitAuthConfig{
+	g.config = gpoll.PollConfig{
+		Git: gpoll.GitConfig{
+			Auth: gpoll.GitAuthConfig{
 				Username: faker.Username(),
 				Password: faker.Username(),
 			},
-			Remote: faker.Username(),
+			Remote:         faker.Username(),
+			CloneDirectory: "/" + faker.Username(),
 		},
-		Interval: 1,
-	})
+		Interval:     1,
+		FilepathMode: gpoll.FilepathModeRepoRelative,
+	}
+	p, err := gpoll.NewPoller(g.config)
 	if !g.NoError(err) {
 		g.FailNow(err.Error())
 	}
 
-	g.p = p.(*poller)
-	g.p.git = g.gitMock
+	g.p = p
+	gpoll.SetGitService(g.p, g.gitMock)
 }
 
 func (g *GpollTest) TestStart() {
 	// -- Given
-	//repo *git.Repository, branch string
-	remote := g.p.config.Git.Remote
-	branch := g.p.config.Git.Branch
-	directory := g.p.config.Git.CloneDirectory
+	//
+	remote := g.config.Git.Remote
+	branch := g.config.Git.Branch
+	directory := g.config.Git.CloneDirectory
 	repo := new(git.Repository)
 
-	changes := FakeGitChanges()
+	changes := FakeCommitDiffs()
 
-	g.gitMock.On("Clone", remote, branch, directory).Return(repo, nil)
-	g.gitMock.On("DiffRemote", repo, branch).Return(changes, nil)
+	g.gitMock.On("Clone", mock.Anything, remote, branch, directory).Return(repo, nil)
+	g.gitMock.On("HeadCommit", repo).Return(new(object.Commit), nil)
+	g.gitMock.On("ToInternal", mock.Anything).Return(&gpoll.Commit{Sha: faker.Username()})
+	g.gitMock.On("DiffRemote", mock.Anything, repo, branch).Return(changes, nil).Once()
+	g.gitMock.On("DiffRemote", mock.Anything, repo, branch).Return([]gpoll.CommitDiff{}, nil)
 
 	// -- When
 	//
@@ -55,9 +65,11 @@ func (g *GpollTest) TestStart() {
 	// -- Then
 	//
 	if g.NoError(err) {
-		n := len(changes)
-		for i := 0; i < n; i++ {
-			g.Contains(changes, <-c)
+		defer g.p.Stop()
+		for _, expected := range changes {
+			actual := <-c
+			g.Equal(expected.To, actual.To)
+			g.Equal(expected.Changes, actual.Changes)
 		}
 	}
 }
@@ -67,15 +79,28 @@ func RandInt(l, u int) int {
 	return is[0]
 }
 
-func FakeGitChanges() []FileChange {
+func FakeCommitDiffs() []gpoll.CommitDiff {
+	n := RandInt(1, 5)
+	diffs := make([]gpoll.CommitDiff, n)
+	for i := range diffs {
+		diffs[i] = gpoll.CommitDiff{
+			Changes: FakeGitChanges(),
+			To: gpoll.Commit{
+				Sha: faker.Username(),
+			},
+		}
+	}
+	return diffs
+}
+
+func FakeGitChanges() []gpoll.FileChange {
 	c := RandInt(0, 3)
 	n := RandInt(1, 10)
-	cs := make([]FileChange, n)
+	cs := make([]gpoll.FileChange, n)
 	for i := range cs {
-		cs[i] = FileChange{
+		cs[i] = gpoll.FileChange{
 			Filepath:   faker.Username(),
-			ChangeType: ChangeType(c),
-			Sha:        faker.Username(),
+			ChangeType: gpoll.ChangeType(c),
 		}
 	}
 	return cs
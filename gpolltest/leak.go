@@ -0,0 +1,33 @@
+// Helpers for testing code that uses gpoll.
+package gpolltest
+
+import (
+	"fmt"
+	"github.com/eddieowens/gpoll"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stop the poller, waiting for it to stop, and return an error naming every goroutine spawned by the poller that is
+// still running once the grace period has passed, e.g. a handler that ignores the cancellation of its context. Call it
+// at the end of a test to catch leaks.
+func VerifyNoLeaks(p gpoll.Poller, grace time.Duration) error {
+	p.StopAndWait()
+	deadline := time.Now().Add(grace)
+	active := p.Status().ActiveGoroutines
+	for len(active) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		active = p.Status().ActiveGoroutines
+	}
+	if len(active) == 0 {
+		return nil
+	}
+
+	leaked := make([]string, 0, len(active))
+	for name, n := range active {
+		leaked = append(leaked, fmt.Sprintf("%s (%d)", name, n))
+	}
+	sort.Strings(leaked)
+	return fmt.Errorf("goroutines still running after stopping: %s", strings.Join(leaked, ", "))
+}
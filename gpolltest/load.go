@@ -0,0 +1,263 @@
+package gpolltest
+
+import (
+	"fmt"
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/gpolltest/server"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Tracks the commits a poller delivers against the commits pushed to its remote, catching any that are lost,
+// duplicated or delivered out of order, and any gap or repeat in their Sequence.
+type SequenceTracker struct {
+	lock       sync.Mutex
+	pushed     []string
+	delivered  []string
+	checked    int
+	sequence   uint64
+	violations []string
+	changed    chan struct{}
+}
+
+func NewSequenceTracker() *SequenceTracker {
+	return &SequenceTracker{changed: make(chan struct{}, 1)}
+}
+
+// Record that the commit with the sha was pushed to the remote. Commits must be recorded in the order they were pushed.
+func (t *SequenceTracker) Pushed(sha string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.pushed = append(t.pushed, sha)
+	t.check()
+}
+
+// Record the delivery of the commit. Commits without a Sequence, like the initial delivery to handlers, are ignored.
+func (t *SequenceTracker) Delivered(commit gpoll.CommitDiff) {
+	if commit.Sequence == 0 {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if commit.Sequence != t.sequence+1 {
+		t.violate("sequence %d delivered after %d", commit.Sequence, t.sequence)
+	}
+	t.sequence = commit.Sequence
+	t.delivered = append(t.delivered, commit.To.Sha)
+	t.check()
+
+	select {
+	case t.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Wait until every pushed commit was delivered, returning an error describing every violation so far, or how many
+// commits are still missing once the timeout passes.
+func (t *SequenceTracker) Wait(timeout time.Duration) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		t.lock.Lock()
+		delivered, pushed := len(t.delivered), len(t.pushed)
+		t.lock.Unlock()
+		if delivered >= pushed {
+			return t.Verify()
+		}
+		select {
+		case <-t.changed:
+		case <-deadline.C:
+			if err := t.Verify(); err != nil {
+				return err
+			}
+			return fmt.Errorf("%d of %d pushed commits weren't delivered within %s", pushed-delivered, pushed, timeout)
+		}
+	}
+}
+
+// Get an error describing every commit lost, duplicated or reordered so far. Nil if there were none.
+func (t *SequenceTracker) Verify() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	violations := append([]string(nil), t.violations...)
+	for _, sha := range t.delivered[t.checked:] {
+		violations = append(violations, fmt.Sprintf("%s delivered but never pushed, or delivered again", sha))
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return &SequenceError{Violations: violations}
+}
+
+// Compares the commits delivered with those pushed in the same position. A commit may be delivered before its push is
+// recorded, so positions are compared once both are known.
+func (t *SequenceTracker) check() {
+	for ; t.checked < len(t.pushed) && t.checked < len(t.delivered); t.checked++ {
+		if pushed, delivered := t.pushed[t.checked], t.delivered[t.checked]; pushed != delivered {
+			t.violate("%s delivered where %s was expected", delivered, pushed)
+		}
+	}
+}
+
+func (t *SequenceTracker) violate(format string, args ...interface{}) {
+	t.violations = append(t.violations, fmt.Sprintf(format, args...))
+}
+
+// Returned when a poller lost, duplicated or reordered commits.
+type SequenceError struct {
+	Violations []string
+}
+
+func (s *SequenceError) Error() string {
+	msg := fmt.Sprintf("%d delivery violations", len(s.Violations))
+	if len(s.Violations) > 0 {
+		msg += ", first: " + s.Violations[0]
+	}
+	return msg
+}
+
+type LoadConfig struct {
+	// How many commits to push. Defaults to 1000.
+	Commits int
+
+	// How many commits are pushed at once, between which the Pause passes. Defaults to 10.
+	BatchSize int
+
+	// How long to wait between batches, giving the poller a chance to poll part way. Defaults to no wait.
+	Pause time.Duration
+
+	// The most heap the process may have allocated at any point during the run, in bytes. Defaults to no ceiling.
+	MaxHeap uint64
+
+	// How long the poller may take to deliver every commit after the last was pushed. Defaults to a minute.
+	Timeout time.Duration
+}
+
+// What a load run measured.
+type LoadReport struct {
+	// How many commits were pushed and delivered.
+	Commits int
+
+	// How long it took from pushing the first commit to delivering the last.
+	Duration time.Duration
+
+	// Delivered commits per second.
+	Throughput float64
+
+	// The most heap the process had allocated at any point during the run, in bytes.
+	MaxHeap uint64
+}
+
+// Run a poller against the server while pushing commits to it, verifying every commit is delivered exactly once and in
+// the order it was pushed, and that the heap stays within the MaxHeap. The poller is configured by the config with its
+// Git defaulting to the server's, and is stopped once the run is over. Commits are read off the channel of the poller
+// so any handlers in the config are left as they are.
+func RunLoad(srv *server.Server, config gpoll.PollConfig, load LoadConfig) (LoadReport, error) {
+	if load.Commits <= 0 {
+		load.Commits = 1000
+	}
+	if load.BatchSize <= 0 {
+		load.BatchSize = 10
+	}
+	if load.Timeout <= 0 {
+		load.Timeout = time.Minute
+	}
+	if config.Git.Remote == "" {
+		config.Git = srv.GitConfig()
+	}
+
+	p, err := gpoll.NewPoller(config)
+	if err != nil {
+		return LoadReport{}, err
+	}
+	c, err := p.StartAsync()
+	if err != nil {
+		return LoadReport{}, err
+	}
+	defer p.StopAndWait()
+
+	tracker := NewSequenceTracker()
+	go func() {
+		for commit := range c {
+			tracker.Delivered(commit)
+		}
+	}()
+	heap := newHeapSampler()
+	defer heap.stop()
+
+	start := time.Now()
+	for i := 0; i < load.Commits; i++ {
+		sha, err := srv.Commit(fmt.Sprintf("load %d", i), map[string]string{"load.yaml": fmt.Sprintf("commit: %d\n", i)})
+		if err != nil {
+			return LoadReport{}, err
+		}
+		tracker.Pushed(sha)
+		if load.Pause > 0 && (i+1)%load.BatchSize == 0 {
+			time.Sleep(load.Pause)
+		}
+	}
+	if err := tracker.Wait(load.Timeout); err != nil {
+		return LoadReport{}, err
+	}
+
+	duration := time.Since(start)
+	report := LoadReport{
+		Commits:    load.Commits,
+		Duration:   duration,
+		Throughput: float64(load.Commits) / duration.Seconds(),
+		MaxHeap:    heap.stop(),
+	}
+	if load.MaxHeap > 0 && report.MaxHeap > load.MaxHeap {
+		return report, fmt.Errorf("heap reached %d bytes, above the ceiling of %d", report.MaxHeap, load.MaxHeap)
+	}
+	return report, nil
+}
+
+// Samples the allocated heap in the background, keeping the maximum.
+type heapSampler struct {
+	done chan struct{}
+	once sync.Once
+	max  uint64
+	wg   sync.WaitGroup
+}
+
+const heapSampleInterval = 50 * time.Millisecond
+
+func newHeapSampler() *heapSampler {
+	h := &heapSampler{done: make(chan struct{})}
+	h.sample()
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		ticker := time.NewTicker(heapSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.sample()
+			case <-h.done:
+				return
+			}
+		}
+	}()
+	return h
+}
+
+func (h *heapSampler) sample() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	if stats.HeapAlloc > h.max {
+		h.max = stats.HeapAlloc
+	}
+}
+
+// Stop sampling and get the maximum sampled.
+func (h *heapSampler) stop() uint64 {
+	h.once.Do(func() {
+		close(h.done)
+		h.wg.Wait()
+		h.sample()
+	})
+	return h.max
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+)
+
+// A DNS server answering A and AAAA queries from a fixed set of records, e.g. to point a made up host at a Server.
+type DNS struct {
+	// The address of the server as ip:port.
+	Addr string
+
+	records map[string][]net.IP
+	conn    net.PacketConn
+
+	lock    sync.Mutex
+	queries int
+}
+
+// Start a new DNS server on the loopback interface resolving each host to its IPs. Close must be called once the server
+// is no longer needed.
+func NewDNS(records map[string][]net.IP) (*DNS, error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	d := &DNS{
+		Addr:    conn.LocalAddr().String(),
+		records: make(map[string][]net.IP, len(records)),
+		conn:    conn,
+	}
+	for host, ips := range records {
+		d.records[strings.ToLower(strings.TrimSuffix(host, "."))] = ips
+	}
+	go d.serve()
+	return d, nil
+}
+
+// The number of queries answered so far.
+func (d *DNS) Queries() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.queries
+}
+
+// Stop the server.
+func (d *DNS) Close() {
+	_ = d.conn.Close()
+}
+
+func (d *DNS) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := d.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if resp := d.answer(buf[:n]); resp != nil {
+			_, _ = d.conn.WriteTo(resp, addr)
+		}
+	}
+}
+
+const (
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+)
+
+// Answers a query of a single question, as sent by resolvers.
+func (d *DNS) answer(query []byte) []byte {
+	if len(query) < 12 || binary.BigEndian.Uint16(query[4:]) != 1 {
+		return nil
+	}
+	var labels []string
+	i := 12
+	for i < len(query) && query[i] != 0 {
+		l := int(query[i])
+		if i+1+l > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[i+1:i+1+l]))
+		i += 1 + l
+	}
+	// The terminating zero, then the type and class.
+	if i+5 > len(query) {
+		return nil
+	}
+	question := query[12 : i+5]
+	qtype := binary.BigEndian.Uint16(query[i+1:])
+
+	d.lock.Lock()
+	d.queries++
+	d.lock.Unlock()
+
+	ips, found := d.records[strings.ToLower(strings.Join(labels, "."))]
+	var answers [][]byte
+	for _, ip := range ips {
+		data := ip.To4()
+		if qtype == dnsTypeAAAA {
+			if data != nil {
+				continue
+			}
+			data = ip.To16()
+		} else if qtype != dnsTypeA || data == nil {
+			continue
+		}
+		rr := []byte{0xc0, 12}
+		rr = appendUint16(rr, qtype)
+		rr = append(rr, 0, 1, 0, 0, 0, 60)
+		rr = appendUint16(rr, uint16(len(data)))
+		answers = append(answers, append(rr, data...))
+	}
+
+	// A response to a recursive query, authoritative for the records and NXDOMAIN for everything else.
+	flags := uint16(0x8580)
+	if !found {
+		flags |= 3
+	}
+	resp := append([]byte{}, query[:2]...)
+	resp = appendUint16(resp, flags)
+	resp = append(resp, 0, 1)
+	resp = appendUint16(resp, uint16(len(answers)))
+	resp = append(resp, 0, 0, 0, 0)
+	resp = append(resp, question...)
+	for _, a := range answers {
+		resp = append(resp, a...)
+	}
+	return resp
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
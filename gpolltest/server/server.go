@@ -0,0 +1,418 @@
+// Package server provides an in-process git server for end-to-end tests of gpoll without any external network or
+// credentials.
+package server
+
+import (
+	"fmt"
+	"github.com/eddieowens/gpoll"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pktline"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	gitserver "gopkg.in/src-d/go-git.v4/plumbing/transport/server"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// The branch commits are made to.
+	Branch = "master"
+
+	Username = "gpolltest"
+	Password = "gpolltest-secret"
+)
+
+// A git server speaking the smart HTTP protocol, backed by a repo in a temp directory. Clients can fetch from it but
+// not push. Commits are made directly to the backing repo through Commit and Remove instead, which is equivalent to a
+// push from the point of view of a poller.
+type Server struct {
+	// The URL of the repo e.g. to use as the Remote of a GitConfig.
+	URL string
+
+	// The URL of the repo over SSH. Empty until StartSsh is called.
+	SshURL string
+
+	// The filepath to the key clients log in with over SSH.
+	SshKey string
+
+	// The filepath to the known_hosts file clients verify the server with over SSH.
+	KnownHosts string
+
+	lock sync.Mutex
+	dir  string
+	repo *git.Repository
+	http *httptest.Server
+
+	sshKeys     *sshKeys
+	sshListener net.Listener
+}
+
+// Start a new Server backed by a fresh repo containing a single commit with a README.md. Close must be called once the
+// Server is no longer needed.
+func New() (*Server, error) {
+	s, err := NewEmpty()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Commit("initial commit", map[string]string{"README.md": "# gpolltest\n"}); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Start a new Server backed by a fresh repo without any commits, as if just created on a git host. Close must be called
+// once the Server is no longer needed.
+func NewEmpty() (*Server, error) {
+	dir, err := ioutil.TempDir("", "gpolltest")
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	s := &Server{
+		dir:  dir,
+		repo: repo,
+	}
+	s.http = httptest.NewServer(s)
+	s.URL = s.http.URL + "/repo.git"
+	return s, nil
+}
+
+// A GitConfig for polling the Server's Branch.
+func (s *Server) GitConfig() gpoll.GitConfig {
+	return gpoll.GitConfig{
+		Auth: gpoll.GitAuthConfig{
+			Username: Username,
+			Password: Password,
+		},
+		Remote: s.URL,
+		Branch: Branch,
+	}
+}
+
+// Write the files, keyed by their slash separated path, and commit them. Returns the sha of the commit.
+func (s *Server) Commit(message string, files map[string]string) (string, error) {
+	return s.CommitAt(time.Now(), message, files)
+}
+
+// Like Commit, but dated at the time, e.g. as if made in another timezone or on a machine with a wrong clock.
+func (s *Server) CommitAt(when time.Time, message string, files map[string]string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	wt, err := s.repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	for fp, content := range files {
+		full := filepath.Join(s.dir, filepath.FromSlash(fp))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(full, []byte(content), 0644); err != nil {
+			return "", err
+		}
+		if _, err := wt.Add(fp); err != nil {
+			return "", err
+		}
+	}
+	return s.commitAt(wt, message, when)
+}
+
+// Replace the Branch with an orphan branch whose only commit contains just the files, as if force pushed with unrelated
+// history. Returns the sha of the commit.
+func (s *Server) CommitOrphan(message string, files map[string]string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	wt, err := s.repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	h, err := s.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	head, err := s.repo.CommitObject(h.Hash())
+	if err != nil {
+		return "", err
+	}
+	tracked, err := head.Files()
+	if err != nil {
+		return "", err
+	}
+	err = tracked.ForEach(func(f *object.File) error {
+		_, err := wt.Remove(f.Name)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for fp, content := range files {
+		full := filepath.Join(s.dir, filepath.FromSlash(fp))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(full, []byte(content), 0644); err != nil {
+			return "", err
+		}
+		if _, err := wt.Add(fp); err != nil {
+			return "", err
+		}
+	}
+
+	// Without the branch the commit has no parent, and the branch is recreated at it.
+	if err := s.repo.Storer.RemoveReference(h.Name()); err != nil {
+		return "", err
+	}
+	return s.commit(wt, message)
+}
+
+// Reset the Branch to the commit, as if force pushed to it, e.g. to rewrite the commits after it. Later commits are made
+// on top of it.
+func (s *Server) Reset(sha string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	wt, err := s.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return wt.Reset(&git.ResetOptions{Commit: plumbing.NewHash(sha), Mode: git.HardReset})
+}
+
+// Delete the files at the slash separated paths and commit. Returns the sha of the commit.
+func (s *Server) Remove(message string, paths ...string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	wt, err := s.repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	for _, fp := range paths {
+		if _, err := wt.Remove(fp); err != nil {
+			return "", err
+		}
+	}
+	return s.commit(wt, message)
+}
+
+// Get the sha of the head of the Branch.
+func (s *Server) Head() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	h, err := s.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return h.Hash().String(), nil
+}
+
+// Tag the head of the Branch. The tag is annotated with the message, or lightweight if the message is empty. Returns the
+// sha the tag points to, which is the tag object if annotated.
+func (s *Server) Tag(name, message string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	h, err := s.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	var opts *git.CreateTagOptions
+	if message != "" {
+		opts = &git.CreateTagOptions{
+			Tagger:  s.signature(),
+			Message: message,
+		}
+	}
+	ref, err := s.repo.CreateTag(name, h.Hash(), opts)
+	if err != nil {
+		return "", err
+	}
+	return ref.Hash().String(), nil
+}
+
+// Create a branch at the head of the Branch. Commits are still made to the Branch.
+func (s *Server) CreateBranch(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	h, err := s.repo.Head()
+	if err != nil {
+		return err
+	}
+	return s.repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), h.Hash()))
+}
+
+// Delete a branch created through CreateBranch.
+func (s *Server) DeleteBranch(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.repo.Storer.RemoveReference(plumbing.NewBranchReferenceName(name))
+}
+
+// Delete the tag.
+func (s *Server) DeleteTag(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.repo.DeleteTag(name)
+}
+
+// Stop the Server and delete its repo.
+func (s *Server) Close() {
+	s.http.Close()
+	s.closeSsh()
+	_ = os.RemoveAll(s.dir)
+}
+
+func (s *Server) commit(wt *git.Worktree, message string) (string, error) {
+	return s.commitAt(wt, message, time.Now())
+}
+
+func (s *Server) commitAt(wt *git.Worktree, message string, when time.Time) (string, error) {
+	author := s.signature()
+	author.When = when
+	h, err := wt.Commit(message, &git.CommitOptions{
+		Author: author,
+	})
+	if err != nil {
+		return "", err
+	}
+	return h.String(), nil
+}
+
+func (s *Server) signature() *object.Signature {
+	return &object.Signature{
+		Name:  Username,
+		Email: Username + "@example.com",
+		When:  time.Now(),
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if u, p, ok := r.BasicAuth(); !ok || u != Username || p != Password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/info/refs"):
+		s.advertiseRefs(w, r)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/"+transport.UploadPackServiceName):
+		s.uploadPack(w, r)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *Server) advertiseRefs(w http.ResponseWriter, r *http.Request) {
+	service := r.URL.Query().Get("service")
+	if service != transport.UploadPackServiceName {
+		http.Error(w, fmt.Sprintf("service %s is not supported", service), http.StatusForbidden)
+		return
+	}
+
+	sess, err := s.session()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ar, err := sess.AdvertisedReferences()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ar.Prefix = [][]byte{
+		[]byte("# service=" + transport.UploadPackServiceName),
+		pktline.Flush,
+	}
+
+	w.Header().Set("Content-Type", fmt.Sprintf("application/x-%s-advertisement", service))
+	_ = ar.Encode(w)
+}
+
+func (s *Server) uploadPack(w http.ResponseWriter, r *http.Request) {
+	req, err := readUploadPackRequest(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sess, err := s.session()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := sess.UploadPack(r.Context(), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", fmt.Sprintf("application/x-%s-result", transport.UploadPackServiceName))
+	_ = resp.Encode(w)
+}
+
+func readUploadPackRequest(r io.Reader) (*packp.UploadPackRequest, error) {
+	req := packp.NewUploadPackRequest()
+	if err := req.UploadRequest.Decode(r); err != nil {
+		return nil, err
+	}
+
+	// The wants are followed by the haves and a final done.
+	scanner := pktline.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(string(scanner.Bytes()))
+		if line == "done" {
+			break
+		}
+		if strings.HasPrefix(line, "have ") {
+			req.Haves = append(req.Haves, plumbing.NewHash(strings.TrimPrefix(line, "have ")))
+		}
+	}
+	return req, scanner.Err()
+}
+
+func (s *Server) session() (transport.UploadPackSession, error) {
+	ep, err := transport.NewEndpoint(s.URL)
+	if err != nil {
+		return nil, err
+	}
+	return gitserver.NewServer(&loader{storer: s.repo.Storer}).NewUploadPackSession(ep, nil)
+}
+
+// Serves the same repo for every endpoint.
+type loader struct {
+	storer storer.Storer
+}
+
+func (l *loader) Load(*transport.Endpoint) (storer.Storer, error) {
+	return l.storer, nil
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// A SOCKS5 proxy requiring a username and password, as described in RFC 1928 and RFC 1929. Only supports CONNECT.
+type Socks struct {
+	// The address of the proxy as host:port.
+	Addr string
+
+	// The credentials clients must authenticate with.
+	Username string
+	Password string
+
+	listener net.Listener
+
+	lock      sync.Mutex
+	connected int
+}
+
+// Start a new Socks proxy on the loopback interface. Close must be called once the proxy is no longer needed.
+func NewSocks(username, password string) (*Socks, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Socks{
+		Addr:     l.Addr().String(),
+		Username: username,
+		Password: password,
+		listener: l,
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s, nil
+}
+
+// The number of connections made through the proxy so far.
+func (s *Socks) Connected() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.connected
+}
+
+// Stop the proxy.
+func (s *Socks) Close() {
+	_ = s.listener.Close()
+}
+
+func (s *Socks) serve(conn net.Conn) {
+	defer conn.Close()
+	target, err := s.handshake(conn)
+	if err != nil {
+		return
+	}
+	remote, err := net.Dial("tcp", target)
+	if err != nil {
+		// General failure.
+		_, _ = conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer remote.Close()
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+
+	s.lock.Lock()
+	s.connected++
+	s.lock.Unlock()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(remote, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(conn, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// Negotiates username/password auth and reads the host:port of the CONNECT request.
+func (s *Socks) handshake(conn net.Conn) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+	offered := false
+	for _, m := range methods {
+		offered = offered || m == 2
+	}
+	if !offered {
+		_, _ = conn.Write([]byte{5, 0xff})
+		return "", errors.New("username/password auth wasn't offered")
+	}
+	if _, err := conn.Write([]byte{5, 2}); err != nil {
+		return "", err
+	}
+
+	username, password, err := readSocksCredentials(conn)
+	if err != nil {
+		return "", err
+	}
+	if username != s.Username || password != s.Password {
+		_, _ = conn.Write([]byte{1, 1})
+		return "", errors.New("invalid credentials")
+	}
+	if _, err := conn.Write([]byte{1, 0}); err != nil {
+		return "", err
+	}
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return "", err
+	}
+	if req[1] != 1 {
+		_, _ = conn.Write([]byte{5, 7, 0, 1, 0, 0, 0, 0, 0, 0})
+		return "", errors.New("only CONNECT is supported")
+	}
+	var host string
+	switch req[3] {
+	case 1, 4:
+		ip := make([]byte, map[byte]int{1: net.IPv4len, 4: net.IPv6len}[req[3]])
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case 3:
+		name, err := readSocksString(conn)
+		if err != nil {
+			return "", err
+		}
+		host = name
+	default:
+		return "", errors.New("unsupported address type")
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+func readSocksCredentials(r io.Reader) (string, string, error) {
+	version := make([]byte, 1)
+	if _, err := io.ReadFull(r, version); err != nil {
+		return "", "", err
+	}
+	username, err := readSocksString(r)
+	if err != nil {
+		return "", "", err
+	}
+	password, err := readSocksString(r)
+	return username, password, err
+}
+
+// Reads a string prefixed by its length in a single byte.
+func readSocksString(r io.Reader) (string, error) {
+	length := make([]byte, 1)
+	if _, err := io.ReadFull(r, length); err != nil {
+		return "", err
+	}
+	b := make([]byte, length[0])
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
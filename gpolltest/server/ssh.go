@@ -0,0 +1,327 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/eddieowens/gpoll"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The user clients log in as over SSH.
+const SshUser = "git"
+
+// Serve the repo over SSH as well, on the loopback interface. Sets the SshURL, and the SshKey and KnownHosts clients
+// log in and verify the server with.
+func (s *Server) StartSsh() error {
+	keys, err := newSshKeys("gpolltest-ssh")
+	if err != nil {
+		return err
+	}
+	l, err := keys.listen(SshUser, s.handleSshChannel)
+	if err != nil {
+		keys.remove()
+		return err
+	}
+
+	s.sshKeys = keys
+	s.sshListener = l
+	s.SshKey = keys.clientKey
+	s.KnownHosts = keys.knownHosts
+	s.SshURL = fmt.Sprintf("ssh://%s@%s/repo.git", SshUser, l.Addr().String())
+	return nil
+}
+
+// A GitConfig for polling the Server's Branch over SSH. Requires StartSsh to have been called. The KnownHosts file
+// must be in the SSH_KNOWN_HOSTS environment variable for the poller to trust the server.
+func (s *Server) SshGitConfig() gpoll.GitConfig {
+	return gpoll.GitConfig{
+		Auth:   gpoll.GitAuthConfig{SshKey: s.SshKey},
+		Remote: s.SshURL,
+		Branch: Branch,
+	}
+}
+
+func (s *Server) closeSsh() {
+	if s.sshListener != nil {
+		_ = s.sshListener.Close()
+		s.sshKeys.remove()
+	}
+}
+
+// Serves git-upload-pack over an SSH session.
+func (s *Server) handleSshChannel(newCh ssh.NewChannel) {
+	if newCh.ChannelType() != "session" {
+		_ = newCh.Reject(ssh.UnknownChannelType, "only sessions are supported")
+		return
+	}
+	ch, reqs, err := newCh.Accept()
+	if err != nil {
+		return
+	}
+	defer ch.Close()
+
+	for req := range reqs {
+		if req.Type != "exec" {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		var exec struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &exec); err != nil ||
+			!strings.HasPrefix(exec.Command, "git-upload-pack ") {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		_ = req.Reply(true, nil)
+
+		status := uint32(0)
+		if err := s.serveUploadPack(ch); err != nil {
+			_, _ = fmt.Fprintln(ch.Stderr(), err.Error())
+			status = 1
+		}
+		_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+		return
+	}
+}
+
+// Advertises the refs and serves the upload request that follows, if any.
+func (s *Server) serveUploadPack(rw io.ReadWriter) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sess, err := s.session()
+	if err != nil {
+		return err
+	}
+	ar, err := sess.AdvertisedReferences()
+	if err != nil {
+		return err
+	}
+	if err := ar.Encode(rw); err != nil {
+		return err
+	}
+
+	// Clients that are up to date hang up without a request.
+	req, err := readUploadPackRequest(rw)
+	if err != nil {
+		return nil
+	}
+	resp, err := sess.UploadPack(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	return resp.Encode(rw)
+}
+
+// An SSH jump host, like a bastion in front of a private network, that forwards connections to any address.
+type Bastion struct {
+	// The address of the jump host as host:port.
+	Addr string
+
+	// The user clients log in as.
+	User string
+
+	// The filepath to the key clients log in with.
+	SshKey string
+
+	// The filepath to the known_hosts file clients verify the jump host with.
+	KnownHosts string
+
+	keys     *sshKeys
+	listener net.Listener
+
+	lock      sync.Mutex
+	forwarded int
+}
+
+// Start a new Bastion on the loopback interface. Close must be called once the Bastion is no longer needed.
+func NewBastion() (*Bastion, error) {
+	keys, err := newSshKeys("gpolltest-bastion")
+	if err != nil {
+		return nil, err
+	}
+	b := &Bastion{
+		User:   "jump",
+		SshKey: keys.clientKey,
+		keys:   keys,
+	}
+	l, err := keys.listen(b.User, b.handleChannel)
+	if err != nil {
+		keys.remove()
+		return nil, err
+	}
+	b.listener = l
+	b.Addr = l.Addr().String()
+	b.KnownHosts = keys.knownHosts
+	return b, nil
+}
+
+// The number of connections forwarded so far.
+func (b *Bastion) Forwarded() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.forwarded
+}
+
+// Stop the Bastion.
+func (b *Bastion) Close() {
+	_ = b.listener.Close()
+	b.keys.remove()
+}
+
+func (b *Bastion) handleChannel(newCh ssh.NewChannel) {
+	if newCh.ChannelType() != "direct-tcpip" {
+		_ = newCh.Reject(ssh.UnknownChannelType, "only forwarding is supported")
+		return
+	}
+	var target struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newCh.ExtraData(), &target); err != nil {
+		_ = newCh.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+	if err != nil {
+		_ = newCh.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	defer conn.Close()
+	ch, reqs, err := newCh.Accept()
+	if err != nil {
+		return
+	}
+	defer ch.Close()
+	go ssh.DiscardRequests(reqs)
+
+	b.lock.Lock()
+	b.forwarded++
+	b.lock.Unlock()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(conn, ch)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(ch, conn)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// The keys of an SSH server and the single client it accepts, kept in a temp directory.
+type sshKeys struct {
+	dir        string
+	host       ssh.Signer
+	client     ssh.PublicKey
+	clientKey  string
+	knownHosts string
+}
+
+func newSshKeys(prefix string) (*sshKeys, error) {
+	dir, err := ioutil.TempDir("", prefix)
+	if err != nil {
+		return nil, err
+	}
+	k := &sshKeys{dir: dir}
+	if err := k.generate(); err != nil {
+		k.remove()
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *sshKeys) generate() error {
+	hostKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	if k.host, err = ssh.NewSignerFromKey(hostKey); err != nil {
+		return err
+	}
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		return err
+	}
+	if k.client, err = ssh.NewPublicKey(&clientKey.PublicKey); err != nil {
+		return err
+	}
+	k.clientKey = filepath.Join(k.dir, "id_ecdsa")
+	return ioutil.WriteFile(k.clientKey, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+}
+
+// Listens on the loopback interface for the user with the client key, and writes the known_hosts file for the address.
+func (k *sshKeys) listen(user string, handle func(ssh.NewChannel)) (net.Listener, error) {
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if meta.User() == user && bytes.Equal(key.Marshal(), k.client.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	config.AddHostKey(k.host)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	k.knownHosts = filepath.Join(k.dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(l.Addr().String())}, k.host.PublicKey())
+	if err := ioutil.WriteFile(k.knownHosts, []byte(line+"\n"), 0600); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSshConn(conn, config, handle)
+		}
+	}()
+	return l, nil
+}
+
+func (k *sshKeys) remove() {
+	_ = os.RemoveAll(k.dir)
+}
+
+func serveSshConn(conn net.Conn, config *ssh.ServerConfig, handle func(ssh.NewChannel)) {
+	defer conn.Close()
+	sc, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer sc.Close()
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		go handle(newCh)
+	}
+}
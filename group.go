@@ -0,0 +1,244 @@
+package gpoll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrGroupStarted = errors.New("poller group has already been started")
+	ErrGroupStopped = errors.New("poller group has been stopped")
+)
+
+// A delivered commit, emitted Event or error of one of the repos of a PollerGroup. Exactly one of Commit, Event and Err
+// is set.
+type GroupEvent struct {
+	// The ID of the repo in the PollerGroup.
+	Repo string
+
+	// A commit delivered by the poller of the repo.
+	Commit *CommitDiff
+
+	// An Event emitted by the poller of the repo.
+	Event Event
+
+	// An error encountered by the poller of the repo, including failed polls.
+	Err error
+}
+
+func (e GroupEvent) String() string {
+	switch {
+	case e.Commit != nil:
+		return fmt.Sprintf("%s: commit %s", e.Repo, e.Commit.To.Sha)
+	case e.Event != nil:
+		return fmt.Sprintf("%s: %s", e.Repo, e.Event.EventType())
+	default:
+		return fmt.Sprintf("%s: %v", e.Repo, e.Err)
+	}
+}
+
+// Runs the pollers of many repos, each identified by a unique ID, on a single shared scheduler and multiplexes their
+// commits, events and errors into one channel tagged with the repo. Every repo is still polled on the Interval of its
+// PollConfig, but the polls are triggered by the scheduler rather than a ticker per repo. The repos are managed by a
+// MultiPoller. Stopping the group stops every poller and waits for them before closing the channel. A PollerGroup can
+// only be started once.
+type PollerGroup struct {
+	multi *multiPoller
+	out   chan GroupEvent
+
+	startLock sync.Mutex
+	started   bool
+
+	lock   sync.RWMutex
+	closed bool
+	// What's sent while the pollers are starting, before the channel is returned, and while it's being flushed. Nil
+	// once flushed, after which everything is sent directly.
+	pending []GroupEvent
+
+	// Closed once the group is stopped, after which anything sent by the pollers is dropped.
+	done chan struct{}
+	// Closed once the channel is closed.
+	finished chan struct{}
+	// The goroutine flushing what was sent while starting.
+	flushing sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// Create a new PollerGroup from a config per repo ID. Will return an error for misconfiguration of any repo. The
+// HandleEvent of each config is still called, alongside the event being sent on the channel of the group.
+func NewPollerGroup(configs map[string]PollConfig) (*PollerGroup, error) {
+	g := &PollerGroup{
+		multi:    newMultiPoller(nil),
+		out:      make(chan GroupEvent),
+		pending:  make([]GroupEvent, 0),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	g.multi.scheduled = true
+	g.multi.reschedule = make(chan struct{}, 1)
+	g.multi.configure = g.configure
+	g.multi.forward = g.forward
+	for id, config := range configs {
+		if err := g.multi.AddRepo(id, config); err != nil {
+			return nil, fmt.Errorf("invalid config for repo %s: %s", id, err.Error())
+		}
+	}
+	return g, nil
+}
+
+// Start every poller and the scheduler, returning the channel everything the pollers deliver, emit and encounter is
+// sent on. The channel must be read until it's closed by Stop, otherwise the pollers block on delivering commits. If a
+// poller fails to start, the pollers already started are stopped and the error is returned.
+func (g *PollerGroup) Start() (<-chan GroupEvent, error) {
+	return g.StartContext(context.Background())
+}
+
+// Start like Start. The pollers stop once the context is done, though the channel is only closed by Stop.
+func (g *PollerGroup) StartContext(ctx context.Context) (<-chan GroupEvent, error) {
+	g.startLock.Lock()
+	defer g.startLock.Unlock()
+	if g.started {
+		return nil, ErrGroupStarted
+	}
+
+	if err := g.multi.start(ctx); err != nil {
+		// What the pollers that were stopped sent while starting is never read.
+		g.lock.Lock()
+		g.pending = make([]GroupEvent, 0)
+		g.lock.Unlock()
+		return nil, err
+	}
+
+	g.started = true
+	g.flushing.Add(1)
+	go g.flush()
+	return g.out, nil
+}
+
+// Stop every poller, wait for them to stop and close the channel. Commits delivered while stopping are dropped.
+func (g *PollerGroup) Stop() {
+	_ = g.StopContext(context.Background())
+}
+
+// Stop like Stop, abandoning the wait once the context is done. The channel is closed once every poller has stopped
+// regardless.
+func (g *PollerGroup) StopContext(ctx context.Context) error {
+	g.stopOnce.Do(func() {
+		g.startLock.Lock()
+		g.started = true
+		g.startLock.Unlock()
+		close(g.done)
+		go func() {
+			g.multi.stopAndWait()
+			g.flushing.Wait()
+
+			g.lock.Lock()
+			g.closed = true
+			close(g.out)
+			g.lock.Unlock()
+			close(g.finished)
+		}()
+	})
+	select {
+	case <-g.finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Add a repo under the ID. If the group is running, the repo is started immediately and its commits, events and errors
+// are sent on the channel of the group. Will return an error for misconfiguration of the repo, if it fails to start or
+// if the group is stopped.
+func (g *PollerGroup) AddRepo(id string, config PollConfig) error {
+	select {
+	case <-g.done:
+		return ErrGroupStopped
+	default:
+	}
+	return g.multi.AddRepo(id, config)
+}
+
+// Stop polling the repo and remove it. Will return ErrRepoNotFound if the repo isn't in the group.
+func (g *PollerGroup) RemoveRepo(id string) error {
+	return g.multi.RemoveRepo(id)
+}
+
+// The IDs of every repo, sorted.
+func (g *PollerGroup) Repos() []string {
+	return g.multi.ListRepos()
+}
+
+// Get the Poller of the repo e.g. to check its Status or Trigger a poll. Will return ErrRepoNotFound if the repo isn't
+// in the group.
+func (g *PollerGroup) Poller(id string) (Poller, error) {
+	return g.multi.get(id)
+}
+
+// Sends the events of the repo on the channel alongside calling its HandleEvent.
+func (g *PollerGroup) configure(id string, config PollConfig) PollConfig {
+	handle := config.HandleEvent
+	config.HandleEvent = func(event Event) {
+		if handle != nil {
+			handle(event)
+		}
+		g.send(GroupEvent{Repo: id, Event: event})
+	}
+	return config
+}
+
+// Sends the commits and errors of the repo on the channel.
+func (g *PollerGroup) forward(id string, p *poller) {
+	p.forwardCommit = func(commit CommitDiff) {
+		g.send(GroupEvent{Repo: id, Commit: &commit})
+	}
+	p.forwardError = func(err error) {
+		g.send(GroupEvent{Repo: id, Err: err})
+	}
+}
+
+// Sends the event unless the group is stopped, in which case it's dropped. Blocks until the event is read, unless it's
+// queued to be flushed.
+func (g *PollerGroup) send(e GroupEvent) {
+	g.lock.Lock()
+	if g.pending != nil {
+		g.pending = append(g.pending, e)
+		g.lock.Unlock()
+		return
+	}
+	g.lock.Unlock()
+	g.sendNow(e)
+}
+
+func (g *PollerGroup) sendNow(e GroupEvent) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	if g.closed {
+		return
+	}
+	select {
+	case g.out <- e:
+	case <-g.done:
+	}
+}
+
+// Sends what was queued while starting, in order, until nothing is left to send directly instead.
+func (g *PollerGroup) flush() {
+	defer g.flushing.Done()
+	for {
+		g.lock.Lock()
+		batch := g.pending
+		if len(batch) == 0 {
+			g.pending = nil
+			g.lock.Unlock()
+			return
+		}
+		g.pending = make([]GroupEvent, 0)
+		g.lock.Unlock()
+		for _, e := range batch {
+			g.sendNow(e)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package gpoll_test
+
+import (
+	"context"
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/gpolltest/server"
+	"github.com/stretchr/testify/suite"
+	"net"
+	"testing"
+	"time"
+)
+
+type GroupTest struct {
+	serverSuite
+}
+
+func (s *GroupTest) TestSchedulesReposFairly() {
+	// -- Given
+	//
+	fronts := make(map[string]*front)
+	configs := make(map[string]gpoll.PollConfig)
+	for _, id := range []string{"a", "b", "c", "hung"} {
+		srv, err := server.New()
+		s.Require().NoError(err)
+		defer srv.Close()
+		f := s.newFront(srv)
+		defer f.Close()
+		fronts[id] = f
+		configs[id] = gpoll.PollConfig{Git: f.config, Interval: 20 * time.Millisecond}
+	}
+	g, err := gpoll.NewPollerGroup(configs)
+	s.Require().NoError(err)
+	c, err := g.Start()
+	s.Require().NoError(err)
+	go func() {
+		for range c {
+		}
+	}()
+	defer g.Stop()
+
+	// -- When
+	//
+	fronts["hung"].hang()
+	before := make(map[string]int)
+	for id, f := range fronts {
+		before[id] = f.refsRequested()
+	}
+	time.Sleep(time.Second)
+
+	// -- Then
+	//
+	polls := make(map[string]int)
+	for _, id := range []string{"a", "b", "c"} {
+		polls[id] = fronts[id].refsRequested() - before[id]
+		s.Greater(polls[id], 10, id)
+	}
+	for _, id := range []string{"b", "c"} {
+		s.InDelta(polls["a"], polls[id], float64(polls["a"])/2, id)
+	}
+}
+
+func (s *GroupTest) TestFailedStartStopsStartedRepos() {
+	// -- Given
+	//
+	g, err := gpoll.NewPollerGroup(map[string]gpoll.PollConfig{
+		"a": {Git: s.server.GitConfig(), Interval: 10 * time.Millisecond},
+		"b": {Git: s.unreachable(), Interval: 10 * time.Millisecond},
+	})
+	s.Require().NoError(err)
+
+	// -- When
+	//
+	_, err = g.Start()
+
+	// -- Then
+	//
+	s.Error(err)
+	p, err := g.Poller("a")
+	s.Require().NoError(err)
+	s.Eventually(func() bool {
+		return !p.Status().Running && !forwarding()
+	}, 5*time.Second, 10*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.NoError(g.StopContext(ctx))
+}
+
+func (s *GroupTest) TestAddRepoFailure() {
+	// -- Given
+	//
+	g, err := gpoll.NewPollerGroup(map[string]gpoll.PollConfig{
+		"a": {Git: s.server.GitConfig(), Interval: 10 * time.Millisecond},
+	})
+	s.Require().NoError(err)
+	c, err := g.Start()
+	s.Require().NoError(err)
+	go func() {
+		for range c {
+		}
+	}()
+
+	// -- When
+	//
+	unreachable := g.AddRepo("b", gpoll.PollConfig{Git: s.unreachable()})
+	duplicate := g.AddRepo("a", gpoll.PollConfig{Git: s.server.GitConfig()})
+	invalid := g.AddRepo("c", gpoll.PollConfig{})
+
+	// -- Then
+	//
+	s.Error(unreachable)
+	s.Error(duplicate)
+	s.Error(invalid)
+	s.Equal([]string{"a"}, g.Repos())
+	_, err = g.Poller("b")
+	s.Equal(gpoll.ErrRepoNotFound, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.NoError(g.StopContext(ctx))
+	s.False(forwarding())
+	s.Equal(gpoll.ErrGroupStopped, g.AddRepo("b", gpoll.PollConfig{Git: s.server.GitConfig()}))
+}
+
+func (s *GroupTest) TestAddRepoWhileRunning() {
+	// -- Given
+	//
+	g, err := gpoll.NewPollerGroup(map[string]gpoll.PollConfig{})
+	s.Require().NoError(err)
+	c, err := g.Start()
+	s.Require().NoError(err)
+	defer g.Stop()
+
+	// -- When
+	//
+	s.Require().NoError(g.AddRepo("a", gpoll.PollConfig{Git: s.server.GitConfig(), Interval: 10 * time.Millisecond}))
+	sha := s.commit("add a", map[string]string{"a.txt": "a"})
+
+	// -- Then
+	//
+	for {
+		select {
+		case e := <-c:
+			if e.Commit != nil {
+				s.Equal("a", e.Repo)
+				s.Equal(sha, e.Commit.To.Sha)
+				return
+			}
+		case <-time.After(5 * time.Second):
+			s.FailNow("timed out waiting for the commit")
+		}
+	}
+}
+
+// A config of a remote that refuses connections.
+func (s *GroupTest) unreachable() gpoll.GitConfig {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	addr := l.Addr().String()
+	s.Require().NoError(l.Close())
+	config := s.server.GitConfig()
+	config.Remote = "http://" + addr + "/repo.git"
+	return config
+}
+
+func TestGroup(t *testing.T) {
+	suite.Run(t, new(GroupTest))
+}
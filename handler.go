@@ -0,0 +1,236 @@
+package gpoll
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Returned through OnError when a commit handler runs longer than the configured HandlerTimeout.
+type HandlerTimeoutError struct {
+	// The commit that was being handled.
+	Commit Commit
+
+	// The timeout that was exceeded.
+	Timeout time.Duration
+}
+
+func (h *HandlerTimeoutError) Error() string {
+	return fmt.Sprintf("handler for commit %s exceeded timeout of %s", h.Commit.Sha, h.Timeout)
+}
+
+// A named commit handler with its own position in the history of the branch.
+type Handler struct {
+	// Uniquely identifies the handler. Required.
+	Name string
+
+	// Function that is called with every delivered commit.
+	Handle HandleCommitContextFunc
+
+	// A sha or tag to backfill the handler from. When the handler is added, it is first called with a single CommitDiff
+	// containing every change from the Baseline up to the last delivered commit, after which it receives commits
+	// alongside every other handler. If not set, the handler starts from the last delivered commit, or from the list of
+	// every file in the repo if added through the PollConfig.
+	Baseline string
+
+	// The time zone that the LocalWhen and LocalReceivedAt of every commit delivered to the handler are in e.g. for
+	// notifications showing local times. Defaults to leaving them unset.
+	Location *time.Location
+
+	// Routes commits to the handler through an Expression. The handler is only called with the changes satisfying it,
+	// and not at all for commits without any. Defaults to every change.
+	Filter *Expression
+}
+
+func (p *poller) AddHandler(h Handler) error {
+	if h.Name == "" || h.Handle == nil {
+		return fmt.Errorf("handler requires a name and a handle function")
+	}
+
+	// Hold delivery so that nothing is delivered between the backfill and the handler being registered.
+	p.deliverLock.Lock()
+	defer p.deliverLock.Unlock()
+
+	p.lock.Lock()
+	_, exists := p.checkpoints[h.Name]
+	current := p.lastDelivered.Sha
+	if exists {
+		p.lock.Unlock()
+		return fmt.Errorf("handler %s already exists", h.Name)
+	}
+
+	// Backfilled once the poller is started. The repo is set while holding the lock before the backfills are taken, so
+	// the handler is never missed.
+	if h.Baseline != "" && p.repo == nil {
+		defer p.lock.Unlock()
+		for _, b := range p.backfills {
+			if b.Name == h.Name {
+				return fmt.Errorf("handler %s already exists", h.Name)
+			}
+		}
+		p.backfills = append(p.backfills, h)
+		return nil
+	}
+	p.lock.Unlock()
+
+	if h.Baseline != "" {
+		if err := p.backfill(h, current); err != nil {
+			return err
+		}
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.handlers = append(p.handlers, h)
+	p.checkpoints[h.Name] = current
+	return nil
+}
+
+func (p *poller) RemoveHandler(name string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for i, h := range p.handlers {
+		if h.Name == name {
+			p.handlers = append(p.handlers[:i:i], p.handlers[i+1:]...)
+			break
+		}
+	}
+	delete(p.checkpoints, name)
+}
+
+// Calls the handler with the changes from its Baseline up to the sha, or the head of the clone if the sha is empty.
+func (p *poller) backfill(h Handler, sha string) error {
+	diff, err := p.baselineDiff(h.Baseline, sha)
+	if err != nil {
+		return err
+	}
+	for i := range diff.Changes {
+		diff.Changes[i].Filepath = p.formatPath(diff.Changes[i].Filepath)
+	}
+	p.runHandler("handler:"+h.Name, diff.In(h.Location), h.Handle)
+	return nil
+}
+
+func (p *poller) baselineDiff(baseline, sha string) (*CommitDiff, error) {
+	p.repoLock.Lock()
+	defer p.repoLock.Unlock()
+	if p.repo == nil {
+		return nil, ErrNotStarted
+	}
+	from, err := p.git.ResolveRevision(p.repo, baseline)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := p.git.HeadCommit(p.repo)
+	if sha != "" {
+		to, err = p.git.ResolveRevision(p.repo, sha)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.git.Diff(from, to)
+}
+
+func (p *poller) hasHandler() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.config.HandleCommit != nil || p.config.HandleCommitContext != nil || p.config.Promotion != nil ||
+		len(p.handlers) > 0
+}
+
+// Returns how each handler that was called went.
+func (p *poller) handleCommit(commit CommitDiff) []HandlerInvocation {
+	invocations := make([]HandlerInvocation, 0)
+	if p.config.HandleCommitContext != nil {
+		invocations = append(invocations, p.runHandler("handler", commit, p.config.HandleCommitContext))
+	} else if p.config.HandleCommit != nil {
+		invocations = append(invocations, p.runHandler("handler", commit, func(_ context.Context, commit CommitDiff) {
+			p.config.HandleCommit(commit)
+		}))
+	}
+
+	if p.config.Promotion != nil {
+		invocations = append(invocations, p.runHandler("promotion", commit, p.config.Promotion.HandleCommit))
+	}
+
+	p.lock.RLock()
+	handlers := p.handlers
+	p.lock.RUnlock()
+	for _, h := range handlers {
+		routed := commit
+		if h.Filter != nil {
+			routed = p.filterByExpression(h.Filter, commit)
+		}
+		if h.Filter == nil || len(routed.Changes) > 0 {
+			invocations = append(invocations, p.runHandler("handler:"+h.Name, routed.In(h.Location), h.Handle))
+		}
+		if commit.Backfill {
+			continue
+		}
+		p.lock.Lock()
+		if _, ok := p.checkpoints[h.Name]; ok {
+			p.checkpoints[h.Name] = commit.To.Sha
+		}
+		p.lock.Unlock()
+	}
+	return invocations
+}
+
+func (p *poller) runHandler(target string, commit CommitDiff, handle HandleCommitContextFunc) HandlerInvocation {
+	invocation := HandlerInvocation{Target: target, Started: time.Now()}
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if p.config.HandlerTimeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), p.config.HandlerTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+
+	done := make(chan struct{})
+	p.goroutines.Go(handlerGoroutine, func() {
+		defer close(done)
+		handle(ctx, commit)
+	})
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		invocation.TimedOut = true
+		p.onError(&HandlerTimeoutError{
+			Commit:  commit.To,
+			Timeout: p.config.HandlerTimeout,
+		})
+	}
+	invocation.Duration = time.Since(invocation.Started)
+	return invocation
+}
+
+// The number of errors the channel of Errors holds.
+const errorBuffer = 16
+
+func (p *poller) onError(err error) {
+	err = p.redact(err)
+	if p.config.OnError != nil {
+		p.config.OnError(err)
+	}
+	// The oldest error is dropped for the newest once the buffer is full so the poller never blocks on the channel.
+	for {
+		select {
+		case p.errs <- err:
+			return
+		default:
+		}
+		select {
+		case <-p.errs:
+			atomic.AddUint64(&p.droppedErrors, 1)
+		default:
+		}
+	}
+}
+
+func (p *poller) Errors() <-chan error {
+	return p.errs
+}
@@ -0,0 +1,140 @@
+package gpoll_test
+
+import (
+	"context"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type HandlerTest struct {
+	serverSuite
+}
+
+func (s *HandlerTest) TestHandlerTimeoutCancelsHandler() {
+	// -- Given
+	//
+	cancelled := make(chan string, 10)
+	timeouts := make(chan *gpoll.HandlerTimeoutError, 10)
+	p := s.newPoller(gpoll.PollConfig{
+		HandlerTimeout: 50 * time.Millisecond,
+		HandleCommitContext: func(ctx context.Context, commit gpoll.CommitDiff) {
+			<-ctx.Done()
+			cancelled <- commit.To.Sha
+		},
+		OnError: func(err error) {
+			if t, ok := err.(*gpoll.HandlerTimeoutError); ok {
+				timeouts <- t
+			}
+		},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+	go func() {
+		for range c {
+		}
+	}()
+
+	// -- When
+	//
+	first := s.commit("add a", map[string]string{"a.txt": "a"})
+	second := s.commit("add b", map[string]string{"b.txt": "b"})
+
+	// -- Then
+	//
+	shas := make(map[string]bool)
+	for !shas[first] || !shas[second] {
+		select {
+		case sha := <-cancelled:
+			shas[sha] = true
+		case <-time.After(5 * time.Second):
+			s.FailNow("timed out waiting for the handlers to be cancelled")
+		}
+	}
+	select {
+	case t := <-timeouts:
+		s.Equal(50*time.Millisecond, t.Timeout)
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for a HandlerTimeoutError")
+	}
+}
+
+func (s *HandlerTest) TestTimedOutHandlerKeepsRunningAlongsideNextCall() {
+	// -- Given
+	//
+	release := make(chan struct{})
+	defer close(release)
+	called := make(chan string, 10)
+	// Only the first call after the initial delivery blocks.
+	block := make(chan struct{}, 1)
+	block <- struct{}{}
+	p := s.newPoller(gpoll.PollConfig{
+		HandlerTimeout: 50 * time.Millisecond,
+		HandleCommit: func(commit gpoll.CommitDiff) {
+			if len(commit.Changes) > 0 && commit.Changes[0].ChangeType == gpoll.ChangeTypeInit {
+				return
+			}
+			called <- commit.To.Sha
+			select {
+			case <-block:
+				<-release
+			default:
+			}
+		},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+	go func() {
+		for range c {
+		}
+	}()
+
+	// -- When
+	//
+	a := s.commit("add a", map[string]string{"a.txt": "a"})
+	s.Equal(a, s.receiveSha(called))
+	b := s.commit("add b", map[string]string{"b.txt": "b"})
+
+	// -- Then
+	//
+	s.Equal(b, s.receiveSha(called))
+	s.Eventually(func() bool {
+		return p.Status().ActiveGoroutines["handler"] == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *HandlerTest) TestCountsDroppedErrors() {
+	// -- Given
+	//
+	f := s.newFront(s.server)
+	p := s.newPoller(gpoll.PollConfig{Git: f.config})
+	s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	// Every poll fails once the remote is gone.
+	f.Close()
+
+	// -- Then
+	//
+	s.Eventually(func() bool {
+		return p.Status().DroppedErrors > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Len(p.Errors(), 16)
+}
+
+func (s *HandlerTest) receiveSha(c chan string) string {
+	select {
+	case sha := <-c:
+		return sha
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for the handler")
+	}
+	return ""
+}
+
+func TestHandler(t *testing.T) {
+	suite.Run(t, new(HandlerTest))
+}
@@ -0,0 +1,169 @@
+package gpoll
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+var ErrNoHeadLog = errors.New("the checkpoint store doesn't keep a head log")
+
+// What a HeadLogEntry records.
+type HeadLogKind int
+
+const (
+	// The remote head was seen at the sha.
+	HeadLogKindObserved HeadLogKind = iota
+
+	// The commit with the sha was delivered.
+	HeadLogKindDelivered
+)
+
+func (h HeadLogKind) String() string {
+	if h == HeadLogKindDelivered {
+		return "delivered"
+	}
+	return "observed"
+}
+
+// An entry in the log of remote heads kept by a HeadLogStore.
+type HeadLogEntry struct {
+	Kind HeadLogKind
+
+	// The sha of the commit.
+	Sha string
+
+	// When the head was observed or the commit delivered.
+	At time.Time
+}
+
+// A CheckpointStore that also keeps an append-only log of every head observed on the remote and every commit
+// delivered, so operators can reconstruct when the remote changed versus when the change was delivered. Used by the
+// poller if the Checkpoint Store implements it.
+type HeadLogStore interface {
+	CheckpointStore
+
+	// Add the entry to the end of the log.
+	AppendHead(entry HeadLogEntry) error
+
+	// Get every entry in the log, oldest first.
+	Heads() ([]HeadLogEntry, error)
+}
+
+// A HeadLogStore whose log can be pruned as per the Retention. Used by the poller if the Checkpoint Store implements it.
+type PrunableHeadLogStore interface {
+	HeadLogStore
+
+	// Remove the entries from before the time, and then the oldest entries beyond the max. A zero time or max doesn't
+	// remove by it.
+	PruneHeads(before time.Time, max int) error
+}
+
+// The log is kept next to the checkpoint as one JSON entry per line, only ever appended to.
+func (f *fileCheckpointStore) logPath() string {
+	return f.fp + ".heads"
+}
+
+func (f *fileCheckpointStore) AppendHead(entry HeadLogEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	file, err := os.OpenFile(f.logPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(b, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (f *fileCheckpointStore) Heads() ([]HeadLogEntry, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.heads()
+}
+
+// Must be called while holding the lock.
+func (f *fileCheckpointStore) heads() ([]HeadLogEntry, error) {
+	entries := make([]HeadLogEntry, 0)
+	file, err := os.Open(f.logPath())
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry HeadLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Rewrites the log without the pruned entries.
+func (f *fileCheckpointStore) PruneHeads(before time.Time, max int) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	entries, err := f.heads()
+	if err != nil {
+		return err
+	}
+	from := retainFrom(len(entries), func(i int) time.Time {
+		return entries[i].At
+	}, before, max)
+	if from == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, entry := range entries[from:] {
+		b, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+	return writeFileAtomic(f.logPath(), buf.Bytes(), 0600)
+}
+
+func (p *poller) HeadLog() ([]HeadLogEntry, error) {
+	store, ok := p.config.Checkpoint.Store.(HeadLogStore)
+	if !ok {
+		return nil, ErrNoHeadLog
+	}
+	return store.Heads()
+}
+
+// Logs that the remote head was seen at the sha unless it was already at the sha when last observed.
+func (p *poller) observeHead(sha string, at time.Time) {
+	p.lock.Lock()
+	changed := p.observedHead != sha
+	p.observedHead = sha
+	p.lock.Unlock()
+	if changed {
+		p.logHead(HeadLogEntry{Kind: HeadLogKindObserved, Sha: sha, At: at})
+	}
+}
+
+func (p *poller) logHead(entry HeadLogEntry) {
+	store, ok := p.config.Checkpoint.Store.(HeadLogStore)
+	if !ok {
+		return
+	}
+	if err := store.AppendHead(entry); err != nil {
+		p.onError(err)
+	}
+}
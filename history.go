@@ -0,0 +1,61 @@
+package gpoll
+
+import (
+	"fmt"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// Emitted when a poll finds the history of the branch replaced by an unrelated one, e.g. by an orphan branch. The
+// change is delivered as a single CommitDiff flagged with HistoryReplaced.
+type HistoryReplaced struct {
+	// The last commit of the replaced history.
+	From Commit
+
+	// The head of the new history.
+	To Commit
+}
+
+func (h HistoryReplaced) EventType() EventType {
+	return EventTypeHistoryReplaced
+}
+
+func (h HistoryReplaced) String() string {
+	return fmt.Sprintf("history replaced from %s to %s", h.From.Sha, h.To.Sha)
+}
+
+// Gets the best common ancestor of the commits, or nil if they have none, e.g. when the branch was replaced by an orphan
+// branch. Cheap when from is an ancestor of to, which is the common case.
+func mergeBase(from, to *object.Commit) (*object.Commit, error) {
+	if from.Hash == to.Hash {
+		return from, nil
+	}
+	if ok, err := from.IsAncestor(to); err != nil {
+		return nil, err
+	} else if ok {
+		return from, nil
+	}
+	bases, err := from.MergeBase(to)
+	if err != nil || len(bases) == 0 {
+		return nil, err
+	}
+	return bases[0], nil
+}
+
+// Squashes everything between unrelated commits into a single diff of their full trees, since there aren't any commits
+// leading from one to the other.
+func (g *gitImpl) diffUnrelated(from, to *object.Commit) ([]CommitDiff, error) {
+	diff, err := g.Diff(from, to)
+	if err != nil {
+		return nil, err
+	}
+	diff.HistoryReplaced = true
+	return []CommitDiff{*diff}, nil
+}
+
+func (p *poller) detectHistoryReplaced(changes []CommitDiff) {
+	for _, c := range changes {
+		if c.HistoryReplaced {
+			p.emit(HistoryReplaced{From: c.From, To: c.To})
+		}
+	}
+}
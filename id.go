@@ -0,0 +1,18 @@
+package gpoll
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// Create the ID of the delivery of a commit. The ID is deterministic so the same commit delivered at the same Sequence
+// from the same remote and branch always has the same ID, making it suitable as an idempotency key downstream.
+func EventID(remote, branch, sha string, sequence uint64) string {
+	h := sha256.New()
+	for _, s := range []string{remote, branch, sha, strconv.FormatUint(sequence, 10)} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,60 @@
+package gpoll
+
+import (
+	"fmt"
+)
+
+type LargeChangeConfig struct {
+	// A commit changing more files than this is a large change. Defaults to no limit.
+	MaxFiles int
+
+	// A commit whose changed files add up to more bytes than this is a large change. Deleted files don't count towards
+	// the size. Defaults to no limit.
+	MaxBytes int64
+
+	// Only emit the LargeChange for a large change rather than also delivering it, e.g. when large changes are
+	// processed separately through HandleEvent. Defaults to delivering large changes as usual.
+	SkipDelivery bool
+}
+
+// Emitted for every commit whose diff exceeds the configured LargeChangeConfig thresholds e.g. a vendored dependency
+// bump, letting it be routed to a slower processing path.
+type LargeChange struct {
+	// The large commit.
+	Commit CommitDiff
+
+	// The number of changed files.
+	Files int
+
+	// The total size in bytes of the changed files.
+	Bytes int64
+}
+
+func (l LargeChange) EventType() EventType {
+	return EventTypeLargeChange
+}
+
+func (l LargeChange) String() string {
+	return fmt.Sprintf("large change in commit %s: %d files, %d bytes", l.Commit.To.Sha, l.Files, l.Bytes)
+}
+
+// Checks the commit against the configured thresholds. nil if it isn't a large change.
+func (p *poller) checkLargeChange(commit CommitDiff) *LargeChange {
+	config := p.config.LargeChanges
+	if config.MaxFiles <= 0 && config.MaxBytes <= 0 {
+		return nil
+	}
+
+	var size int64
+	for _, c := range commit.Changes {
+		size += c.Size
+	}
+	if (config.MaxFiles > 0 && len(commit.Changes) > config.MaxFiles) || (config.MaxBytes > 0 && size > config.MaxBytes) {
+		return &LargeChange{
+			Commit: commit,
+			Files:  len(commit.Changes),
+			Bytes:  size,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+	"time"
+)
+
+type LargeTest struct {
+	serverSuite
+}
+
+// Create a poller with the thresholds, sending every LargeChange it emits on the channel returned.
+func (s *LargeTest) newLargePoller(config gpoll.LargeChangeConfig) (gpoll.Poller, chan gpoll.LargeChange) {
+	large := make(chan gpoll.LargeChange, 10)
+	p := s.newPoller(gpoll.PollConfig{
+		LargeChanges: config,
+		HandleEvent: func(event gpoll.Event) {
+			if l, ok := event.(gpoll.LargeChange); ok {
+				large <- l
+			}
+		},
+	})
+	return p, large
+}
+
+// Receive LargeChanges until the one for the commit, failing the test if it isn't received in time.
+func (s *LargeTest) receiveLarge(large chan gpoll.LargeChange, sha string) gpoll.LargeChange {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case l := <-large:
+			if l.Commit.To.Sha == sha {
+				return l
+			}
+		case <-timeout:
+			s.FailNow("timed out waiting for a LargeChange")
+			return gpoll.LargeChange{}
+		}
+	}
+}
+
+func (s *LargeTest) TestEmitsCommitsOverMaxFiles() {
+	// -- Given
+	//
+	p, large := s.newLargePoller(gpoll.LargeChangeConfig{MaxFiles: 2})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	small := s.commit("add a", map[string]string{"a.txt": "a", "b.txt": "b"})
+	s.Equal(small, s.receive(c).To.Sha)
+	// Emitted before the commit is delivered, so it would have been by now.
+	for len(large) > 0 {
+		s.NotEqual(small, (<-large).Commit.To.Sha)
+	}
+	sha := s.commit("add vendor", map[string]string{"vendor/a.go": "a", "vendor/b.go": "b", "vendor/c.go": "c"})
+
+	// -- Then
+	//
+	s.Equal(sha, s.receive(c).To.Sha)
+	l := s.receiveLarge(large, sha)
+	s.Equal(3, l.Files)
+	s.Equal(int64(3), l.Bytes)
+}
+
+func (s *LargeTest) TestEmitsCommitsOverMaxBytes() {
+	// -- Given
+	//
+	p, large := s.newLargePoller(gpoll.LargeChangeConfig{MaxBytes: 100})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	s.commit("add a", map[string]string{"a.txt": strings.Repeat("a", 100)})
+	s.receive(c)
+	sha := s.commit("add b", map[string]string{"b.txt": strings.Repeat("b", 101)})
+
+	// -- Then
+	//
+	s.Equal(sha, s.receive(c).To.Sha)
+	l := s.receiveLarge(large, sha)
+	s.Equal(1, l.Files)
+	s.Equal(int64(101), l.Bytes)
+}
+
+func (s *LargeTest) TestSkipDeliveryOnlyEmits() {
+	// -- Given
+	//
+	p, large := s.newLargePoller(gpoll.LargeChangeConfig{MaxFiles: 1, SkipDelivery: true})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	skipped := s.commit("add vendor", map[string]string{"vendor/a.go": "a", "vendor/b.go": "b"})
+	s.receiveLarge(large, skipped)
+	delivered := s.commit("add a", map[string]string{"a.txt": "a"})
+
+	// -- Then
+	//
+	s.Equal(delivered, s.receive(c).To.Sha)
+	s.receiveNone(c, 100*time.Millisecond)
+}
+
+func TestLarge(t *testing.T) {
+	suite.Run(t, new(LargeTest))
+}
@@ -0,0 +1,129 @@
+package gpoll
+
+import (
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// The last commit that changed a path.
+type PathChange struct {
+	// The commit that changed the path.
+	Commit Commit
+
+	// How the path was changed.
+	ChangeType ChangeType
+}
+
+func (p *poller) LastChange(path string) (PathChange, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	c, ok := p.lastChanges[path]
+	return c, ok
+}
+
+// Records the commits, oldest first, as the last change to each of their paths.
+func (p *poller) indexChanges(commits []CommitDiff) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, commit := range commits {
+		for _, c := range commit.Changes {
+			p.lastChanges[c.Filepath] = PathChange{
+				Commit:     commit.To,
+				ChangeType: c.ChangeType,
+			}
+		}
+	}
+}
+
+func (p *poller) History(path string, limit int) ([]Commit, error) {
+	p.repoLock.Lock()
+	if p.repo == nil {
+		p.repoLock.Unlock()
+		return nil, ErrNotStarted
+	}
+	commits, err := p.git.History(p.repo, p.relativePath(path), limit)
+	p.repoLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	history := make([]Commit, len(commits))
+	for i, c := range commits {
+		history[i] = *p.git.ToInternal(c)
+	}
+	return history, nil
+}
+
+// Walks back from the head of the clone collecting the commits that changed the slash separated path. Like git log, a
+// merge commit is only included if the path differs from every one of its parents.
+func (g *gitImpl) History(repo *git.Repository, fp string, limit int) ([]*object.Commit, error) {
+	h, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	iter, err := repo.Log(&git.LogOptions{From: h.Hash()})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	commits := make([]*object.Commit, 0)
+	err = iter.ForEach(func(c *object.Commit) error {
+		if limit > 0 && len(commits) >= limit {
+			return storer.ErrStop
+		}
+		changed, err := changedPath(c, fp)
+		if err != nil {
+			return err
+		}
+		if changed {
+			commits = append(commits, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return commits, nil
+}
+
+// Whether the path within the commit differs from every one of its parents.
+func changedPath(c *object.Commit, fp string) (bool, error) {
+	h, err := pathHash(c, fp)
+	if err != nil {
+		return false, err
+	}
+	if c.NumParents() == 0 {
+		return !h.IsZero(), nil
+	}
+
+	changed := true
+	err = c.Parents().ForEach(func(parent *object.Commit) error {
+		ph, err := pathHash(parent, fp)
+		if err != nil {
+			return err
+		}
+		if ph == h {
+			changed = false
+			return storer.ErrStop
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// The hash of the path within the commit's tree. Zero if the path doesn't exist.
+func pathHash(c *object.Commit, fp string) (plumbing.Hash, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	e, err := tree.FindEntry(fp)
+	if err == object.ErrEntryNotFound || err == object.ErrDirectoryNotFound {
+		return plumbing.ZeroHash, nil
+	} else if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return e.Hash, nil
+}
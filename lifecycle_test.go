@@ -0,0 +1,109 @@
+package gpoll_test
+
+import (
+	"context"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/src-d/go-git.v4"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+type LifecycleTest struct {
+	serverSuite
+}
+
+func (s *LifecycleTest) TestUnstartedPollerIsSafeToCall() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{})
+
+	// -- When
+	//
+	s.callEverything(p)
+
+	// -- Then
+	//
+	s.start(p)
+	p.StopAndWait()
+}
+
+func (s *LifecycleTest) TestStoppedPollerIsSafeToCall() {
+	// -- Given
+	//
+	dir, err := ioutil.TempDir("", "gpoll")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	config := gpoll.PollConfig{Git: s.server.GitConfig(), CleanupOnStop: true}
+	config.Git.CloneDirectory = dir
+	p := s.newPoller(config)
+	s.start(p)
+	p.StopAndWait()
+
+	// -- When
+	//
+	s.callEverything(p)
+
+	// -- Then
+	//
+	s.start(p)
+	p.StopAndWait()
+}
+
+// Calls every method of the poller that doesn't start it, expecting those requiring a clone to return ErrNotStarted.
+// Every call must return within the timeout, so no lock is left held.
+func (s *LifecycleTest) callEverything(p gpoll.Poller) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := p.Poll()
+		s.Equal(gpoll.ErrNotStarted, err)
+		_, err = p.PollContext(context.Background())
+		s.Equal(gpoll.ErrNotStarted, err)
+		s.Equal(gpoll.ErrNotStarted, p.PinTo("HEAD"))
+		p.Unpin()
+		s.Equal(gpoll.ErrNotStarted, p.RollbackTo("HEAD"))
+		_, err = p.RefSnapshot()
+		s.Equal(gpoll.ErrNotStarted, err)
+		_, err = p.History("a.txt", 1)
+		s.Equal(gpoll.ErrNotStarted, err)
+		s.Equal(gpoll.ErrNotStarted, p.Backfill("a.txt", time.Time{}))
+		s.Equal(gpoll.ErrNotStarted, p.WithRepo(func(repo *git.Repository) error { return nil }))
+		s.Nil(p.Repository())
+		_, ok := p.LastChange("a.txt")
+		s.False(ok)
+		s.Empty(p.Events(0))
+		_, ok = p.Receipt("unknown")
+		s.False(ok)
+		s.Empty(p.Quarantine())
+		s.Equal(gpoll.ErrNotQuarantined, p.Release("unknown"))
+		s.Equal(gpoll.ErrNotQuarantined, p.Discard("unknown"))
+		s.Equal(gpoll.ErrUnknownEvent, p.ReportResult("unknown", gpoll.OutcomeSuccess, ""))
+		s.Equal(gpoll.ErrNoStandby, p.PromoteStandby())
+		_, err = p.HeadLog()
+		s.Equal(gpoll.ErrNoHeadLog, err)
+		s.NoError(p.AddHandler(gpoll.Handler{Name: "h", Handle: func(context.Context, gpoll.CommitDiff) {}}))
+		p.RemoveHandler("h")
+		s.False(p.Status().Running)
+		p.Debug()
+		p.Pause()
+		p.Resume()
+		p.Trigger()
+		p.Stop()
+		p.StopAndWait()
+		s.NoError(p.StopContext(context.Background()))
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out calling the poller")
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	suite.Run(t, new(LifecycleTest))
+}
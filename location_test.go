@@ -0,0 +1,146 @@
+package gpoll_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type LocationTest struct {
+	serverSuite
+
+	tokyo *time.Location
+}
+
+func (s *LocationTest) SetupTest() {
+	s.serverSuite.SetupTest()
+	s.tokyo = time.FixedZone("JST", 9*60*60)
+}
+
+// Get the offset from UTC of the time's zone in seconds.
+func zoneOffset(t time.Time) int {
+	_, o := t.Zone()
+	return o
+}
+
+func (s *LocationTest) TestInKeepsRawTimes() {
+	// -- Given
+	//
+	when := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	commit := gpoll.CommitDiff{
+		From:       gpoll.Commit{When: when.Add(-time.Hour)},
+		To:         gpoll.Commit{When: when},
+		ReceivedAt: when.Add(time.Minute),
+	}
+
+	// -- When
+	//
+	local := commit.In(s.tokyo)
+	unlocalized := commit.In(nil)
+
+	// -- Then
+	//
+	s.True(local.To.LocalWhen.Equal(when))
+	s.Equal(9*60*60, zoneOffset(local.To.LocalWhen))
+	s.Equal(21, local.To.LocalWhen.Hour())
+	s.True(local.From.LocalWhen.Equal(when.Add(-time.Hour)))
+	s.True(local.LocalReceivedAt.Equal(when.Add(time.Minute)))
+	s.Equal(when, local.To.When)
+	s.Equal(time.UTC, local.To.When.Location())
+	s.Equal(commit, unlocalized)
+	s.True(gpoll.CommitDiff{}.In(s.tokyo).LocalReceivedAt.IsZero())
+}
+
+func (s *LocationTest) TestLocalizesPerHandler() {
+	// -- Given
+	//
+	local, raw := make(chan gpoll.CommitDiff, 10), make(chan gpoll.CommitDiff, 10)
+	p := s.newPoller(gpoll.PollConfig{
+		Handlers: []gpoll.Handler{
+			{
+				Name:     "local",
+				Location: s.tokyo,
+				Handle: func(ctx context.Context, commit gpoll.CommitDiff) {
+					local <- commit
+				},
+			},
+			{
+				Name: "raw",
+				Handle: func(ctx context.Context, commit gpoll.CommitDiff) {
+					raw <- commit
+				},
+			},
+		},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	sha := s.commit("add a", map[string]string{"a.txt": "a"})
+	delivered := s.receive(c)
+
+	// -- Then
+	//
+	s.Equal(sha, delivered.To.Sha)
+	s.True(delivered.To.LocalWhen.IsZero())
+	commit := s.receiveCommit(local, sha)
+	s.Equal(9*60*60, zoneOffset(commit.To.LocalWhen))
+	s.True(commit.To.LocalWhen.Equal(commit.To.When))
+	s.Equal(9*60*60, zoneOffset(commit.LocalReceivedAt))
+	commit = s.receiveCommit(raw, sha)
+	s.True(commit.To.LocalWhen.IsZero())
+	s.True(commit.LocalReceivedAt.IsZero())
+}
+
+func (s *LocationTest) TestLocalizesWebhookBody() {
+	// -- Given
+	//
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer srv.Close()
+	w := gpoll.NewWebhook(gpoll.WebhookConfig{Url: srv.URL, Location: s.tokyo})
+	when := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// -- When
+	//
+	err := w.Deliver(context.Background(), gpoll.CommitDiff{ID: "a", To: gpoll.Commit{When: when}})
+
+	// -- Then
+	//
+	s.Require().NoError(err)
+	var commit gpoll.CommitDiff
+	s.Require().NoError(json.Unmarshal(<-bodies, &commit))
+	s.Equal(0, zoneOffset(commit.To.When))
+	s.Equal(9*60*60, zoneOffset(commit.To.LocalWhen))
+	s.True(commit.To.LocalWhen.Equal(when))
+}
+
+// Receive commits until the one with the sha, failing the test if it isn't received in time.
+func (s *LocationTest) receiveCommit(c chan gpoll.CommitDiff, sha string) gpoll.CommitDiff {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case commit := <-c:
+			if commit.To.Sha == sha {
+				return commit
+			}
+		case <-timeout:
+			s.FailNow("timed out waiting for " + sha)
+			return gpoll.CommitDiff{}
+		}
+	}
+}
+
+func TestLocation(t *testing.T) {
+	suite.Run(t, new(LocationTest))
+}
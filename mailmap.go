@@ -0,0 +1,122 @@
+package gpoll
+
+import (
+	"bufio"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"strings"
+)
+
+type MailmapConfig struct {
+	// Mappings of the names and emails of authors and committers to their canonical identities, in the format of a
+	// .mailmap file e.g. "Jane Doe <jane@example.com> <jane@old.example.com>". Takes precedence over the .mailmap of the
+	// repo.
+	Mailmap string
+
+	// Also apply the .mailmap at the root of the repo as of the latest polled commit.
+	UseRepoMailmap bool
+}
+
+type mailmapEntry struct {
+	properName  string
+	properEmail string
+	commitName  string
+	commitEmail string
+}
+
+// Parses the lines of a .mailmap file. Lines that can't be parsed are ignored.
+func parseMailmap(s string) []mailmapEntry {
+	entries := make([]mailmapEntry, 0)
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		// Each line is made up of names followed by emails in angle brackets, of which there are one or two.
+		names := make([]string, 0, 2)
+		emails := make([]string, 0, 2)
+		for len(emails) < 2 {
+			open := strings.Index(line, "<")
+			end := strings.Index(line, ">")
+			if open < 0 || end < open {
+				break
+			}
+			names = append(names, strings.TrimSpace(line[:open]))
+			emails = append(emails, strings.TrimSpace(line[open+1:end]))
+			line = line[end+1:]
+		}
+
+		switch len(emails) {
+		case 1:
+			if names[0] != "" {
+				entries = append(entries, mailmapEntry{properName: names[0], commitEmail: emails[0]})
+			}
+		case 2:
+			entries = append(entries, mailmapEntry{
+				properName:  names[0],
+				properEmail: emails[0],
+				commitName:  names[1],
+				commitEmail: emails[1],
+			})
+		}
+	}
+	return entries
+}
+
+// Maps the author to its canonical identity through the first matching entry. Entries matching both the name and email
+// take precedence over those only matching the email, as they do in git.
+func mapIdentity(entries []mailmapEntry, a Author) Author {
+	var match *mailmapEntry
+	for i, e := range entries {
+		if !strings.EqualFold(e.commitEmail, a.Email) {
+			continue
+		}
+		if e.commitName != "" {
+			if strings.EqualFold(e.commitName, a.Name) {
+				match = &entries[i]
+				break
+			}
+		} else if match == nil {
+			match = &entries[i]
+		}
+	}
+	if match == nil {
+		return a
+	}
+
+	if match.properName != "" {
+		a.Name = match.properName
+	}
+	if match.properEmail != "" {
+		a.Email = match.properEmail
+	}
+	return a
+}
+
+// Maps the authors and committers of the commits to their canonical identities.
+func (p *poller) applyMailmap(commits []CommitDiff) {
+	config := p.config.Mailmap
+	if len(commits) == 0 || (config.Mailmap == "" && !config.UseRepoMailmap) {
+		return
+	}
+
+	entries := parseMailmap(config.Mailmap)
+	if config.UseRepoMailmap {
+		p.repoLock.Lock()
+		content, err := p.git.ReadFile(p.repo, commits[len(commits)-1].To.Sha, ".mailmap")
+		p.repoLock.Unlock()
+		if err == nil {
+			entries = append(entries, parseMailmap(string(content))...)
+		} else if err != object.ErrFileNotFound {
+			p.onError(err)
+		}
+	}
+
+	for i := range commits {
+		for _, c := range []*Commit{&commits[i].From, &commits[i].To} {
+			c.Author = mapIdentity(entries, c.Author)
+			c.Committer = mapIdentity(entries, c.Committer)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package gpoll
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The SHA-256 of every watched file in the repo at a commit, so downstream systems can verify they applied exactly the
+// delivered state. Emitted as an event for every delivered commit and set as its Manifest if PollConfig.Manifests is
+// enabled.
+type Manifest struct {
+	// The commit the files are at.
+	Sha string
+
+	// The hex encoded SHA-256 of the content of every file included by the FileChangeFilter, keyed by its slash
+	// separated path relative to the root of the repo.
+	Files map[string]string
+
+	// The hex encoded SHA-256 of the manifest as formatted by Bytes. Equal for equal sets of files.
+	Digest string
+}
+
+func (m *Manifest) EventType() EventType {
+	return EventTypeManifest
+}
+
+func (m *Manifest) String() string {
+	return fmt.Sprintf("manifest of %d files at %s: %s", len(m.Files), m.Sha, m.Digest)
+}
+
+// Format the manifest like the output of sha256sum, one line per file sorted by path, e.g. to write it next to the
+// applied files and check it with sha256sum -c.
+func (m *Manifest) Bytes() []byte {
+	paths := make([]string, 0, len(m.Files))
+	for fp := range m.Files {
+		paths = append(paths, fp)
+	}
+	sort.Strings(paths)
+	var b bytes.Buffer
+	for _, fp := range paths {
+		b.WriteString(m.Files[fp])
+		b.WriteString("  ")
+		b.WriteString(fp)
+		b.WriteString("\n")
+	}
+	return b.Bytes()
+}
+
+// Returned by Manifest.Verify when the files in a directory don't match the manifest.
+type ManifestMismatchError struct {
+	// The paths of the files whose content differs.
+	Changed []string
+
+	// The paths of the files in the manifest that don't exist.
+	Missing []string
+}
+
+func (m *ManifestMismatchError) Error() string {
+	return fmt.Sprintf("files don't match the manifest: %d changed, %d missing: %s", len(m.Changed), len(m.Missing),
+		strings.Join(append(append([]string(nil), m.Changed...), m.Missing...), ", "))
+}
+
+// Check that every file in the manifest exists within the directory with the same content. Files in the directory that
+// aren't in the manifest are ignored. Returns a ManifestMismatchError if any file doesn't match.
+func (m *Manifest) Verify(dir string) error {
+	mismatch := &ManifestMismatchError{}
+	for fp, sum := range m.Files {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(fp)))
+		if os.IsNotExist(err) {
+			mismatch.Missing = append(mismatch.Missing, fp)
+			continue
+		} else if err != nil {
+			return err
+		}
+		if checksum(b) != sum {
+			mismatch.Changed = append(mismatch.Changed, fp)
+		}
+	}
+	if len(mismatch.Changed) == 0 && len(mismatch.Missing) == 0 {
+		return nil
+	}
+	sort.Strings(mismatch.Changed)
+	sort.Strings(mismatch.Missing)
+	return mismatch
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Computes the manifest of the commit, emitting it and setting it on the commit, if Manifests are enabled.
+func (p *poller) attachManifest(commit *CommitDiff) {
+	if !p.config.Manifests {
+		return
+	}
+	m, err := p.manifest(commit.To.Sha)
+	if err != nil {
+		p.onError(err)
+		return
+	}
+	commit.Manifest = m
+	p.emit(m)
+}
+
+// Checksums are kept per blob between manifests so only the blobs changed since the last manifest are read.
+func (p *poller) manifest(sha string) (*Manifest, error) {
+	p.repoLock.Lock()
+	defer p.repoLock.Unlock()
+	c, err := p.repo.CommitObject(plumbing.NewHash(sha))
+	if err != nil {
+		return nil, err
+	}
+	files, err := c.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{Sha: sha, Files: make(map[string]string)}
+	sums := make(map[plumbing.Hash]string)
+	err = files.ForEach(func(f *object.File) error {
+		change := FileChange{Filepath: p.formatPath(f.Name), ChangeType: ChangeTypeInit, Size: f.Size}
+		if p.config.FileChangeFilter != nil && !p.config.FileChangeFilter(change) {
+			return nil
+		}
+		sum, ok := p.manifestSums[f.Hash]
+		if !ok {
+			if sum, err = blobChecksum(f); err != nil {
+				return err
+			}
+		}
+		sums[f.Hash] = sum
+		m.Files[f.Name] = sum
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.manifestSums = sums
+	m.Digest = checksum(m.Bytes())
+	return m, nil
+}
+
+func blobChecksum(f *object.File) (string, error) {
+	r, err := f.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,77 @@
+package gpoll
+
+import (
+	"errors"
+	"io"
+)
+
+var errReadOnly = errors.New("memory-mapped file is read only")
+
+// A read only file backed by memory-mapped data.
+type mappedFile struct {
+	name   string
+	data   []byte
+	offset int64
+	// Releases the data.
+	unmap func(data []byte) error
+}
+
+func (m *mappedFile) Name() string {
+	return m.name
+}
+
+func (m *mappedFile) Read(p []byte) (int, error) {
+	n, err := m.ReadAt(p, m.offset)
+	m.offset += int64(n)
+	return n, err
+}
+
+func (m *mappedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *mappedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += m.offset
+	case io.SeekEnd:
+		offset += int64(len(m.data))
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	m.offset = offset
+	return offset, nil
+}
+
+func (m *mappedFile) Close() error {
+	data := m.data
+	m.data = nil
+	if data == nil {
+		return nil
+	}
+	return m.unmap(data)
+}
+
+func (m *mappedFile) Write([]byte) (int, error) {
+	return 0, errReadOnly
+}
+
+func (m *mappedFile) Truncate(int64) error {
+	return errReadOnly
+}
+
+func (m *mappedFile) Lock() error {
+	return nil
+}
+
+func (m *mappedFile) Unlock() error {
+	return nil
+}
@@ -0,0 +1,36 @@
+package gpoll
+
+import (
+	"path"
+	"strings"
+)
+
+// Checks whether the slash separated path fp matches any of the patterns. Patterns use path.Match syntax and a pattern
+// naming a directory matches everything beneath it.
+func matchesAny(patterns []string, fp string) bool {
+	for _, pattern := range patterns {
+		if matches(pattern, fp) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(pattern, fp string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	for p := fp; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Create a FileChangeFilterFunc that only includes files matching at least one of the patterns. Patterns use
+// path.Match syntax against the path relative to the root of the repo, and a pattern naming a directory matches
+// everything beneath it.
+func IncludePaths(patterns ...string) FileChangeFilterFunc {
+	return func(change FileChange) bool {
+		return matchesAny(patterns, change.Filepath)
+	}
+}
@@ -0,0 +1,127 @@
+package gpoll
+
+import (
+	"fmt"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+// Emitted in Mirror mode when any ref on the remote is created, updated or deleted.
+type RefChange struct {
+	// The full name of the ref e.g. refs/tags/v1.0.0.
+	Ref string
+
+	// The sha the ref pointed to. Empty if the ref was created.
+	From string
+
+	// The sha the ref points to. Empty if the ref was deleted.
+	To string
+
+	// The tag if the ref is one, described from the sha it points to or, if deleted, pointed to. nil for branches.
+	Tag *Tag
+}
+
+func (r RefChange) EventType() EventType {
+	return EventTypeRefChange
+}
+
+func (r RefChange) String() string {
+	switch {
+	case r.From == "":
+		return fmt.Sprintf("%s created at %s", r.Ref, r.To)
+	case r.To == "":
+		return fmt.Sprintf("%s deleted from %s", r.Ref, r.From)
+	default:
+		return fmt.Sprintf("%s updated from %s to %s", r.Ref, r.From, r.To)
+	}
+}
+
+// The refspecs fetched in Mirror mode.
+var mirrorRefSpecs = []config.RefSpec{
+	"+refs/heads/*:refs/remotes/origin/*",
+	"+refs/tags/*:refs/tags/*",
+}
+
+func (g *gitImpl) RemoteRefs(repo *git.Repository) (map[string]string, error) {
+	rem, err := repo.Remote(remoteName)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := g.snapshot(rem)
+	if err != nil {
+		return nil, err
+	}
+	return s.Refs, nil
+}
+
+func (g *gitImpl) FetchMirror(repo *git.Repository) error {
+	err := g.withAuth(func(auth transport.AuthMethod) error {
+		ctx, cancel := g.operationContext()
+		defer cancel()
+		return repo.FetchContext(ctx, &git.FetchOptions{
+			RefSpecs: mirrorRefSpecs,
+			Auth:     authContext(ctx, auth),
+			Progress: g.progress,
+		})
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
+}
+
+// Compares the refs on the remote to those of the last poll and emits a RefChange for every difference.
+func (p *poller) pollRefs() error {
+	refs, err := p.git.RemoteRefs(p.repo)
+	if err != nil {
+		return err
+	}
+
+	previous := p.refs
+	if previous != nil && sameRefs(previous, refs) {
+		return nil
+	}
+	p.repoLock.Lock()
+	err = p.git.FetchMirror(p.repo)
+	p.repoLock.Unlock()
+	if err != nil {
+		return err
+	}
+	p.refs = refs
+	// The first poll only records the refs, and the newest version of the existing tags for the Monotonic policy.
+	if previous == nil && !p.config.Tags.Monotonic {
+		return nil
+	}
+
+	changes := DiffRefs(RefSnapshot{Refs: previous}, RefSnapshot{Refs: refs})
+	admitted := make([]RefChange, 0, len(changes))
+	for _, c := range changes {
+		emit, err := p.describeTag(&c)
+		if err != nil {
+			p.onError(err)
+		} else if emit {
+			admitted = append(admitted, c)
+		}
+	}
+	p.orderRefChanges(admitted)
+	for _, c := range admitted {
+		if p.admitTag(c) && previous != nil {
+			p.emit(c)
+		}
+	}
+	return nil
+}
+
+func sameRefs(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, sha := range a {
+		if b[name] != sha {
+			return false
+		}
+	}
+	return true
+}
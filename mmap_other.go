@@ -0,0 +1,14 @@
+//go:build !(aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris)
+// +build !aix,!darwin,!dragonfly,!freebsd,!illumos,!linux,!netbsd,!openbsd,!solaris
+
+package gpoll
+
+import (
+	"errors"
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+// Memory-mapping is not supported so the file is read through its descriptor as usual instead.
+func mmapFile(fp, name string) (billy.File, error) {
+	return nil, errors.New("memory-mapping is not supported on this platform")
+}
@@ -0,0 +1,34 @@
+//go:build aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd illumos linux netbsd openbsd solaris
+
+package gpoll
+
+import (
+	"errors"
+	"gopkg.in/src-d/go-billy.v4"
+	"os"
+	"syscall"
+)
+
+// Maps the file at the filepath fp into memory, named as name. The mapping outlives the descriptor it was made through,
+// which is closed before returning.
+func mmapFile(fp, name string) (billy.File, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return nil, errors.New("empty files can't be memory-mapped")
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	return &mappedFile{name: name, data: data, unmap: syscall.Munmap}, nil
+}
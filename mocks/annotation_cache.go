@@ -0,0 +1,45 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+
+// AnnotationCache is an autogenerated mock type for the AnnotationCache type
+type AnnotationCache struct {
+	mock.Mock
+}
+
+// Get provides a mock function with given fields: sha, name
+func (_m *AnnotationCache) Get(sha string, name string) (string, bool) {
+	ret := _m.Called(sha, name)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string, string) string); ok {
+		r0 = rf(sha, name)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(string, string) bool); ok {
+		r1 = rf(sha, name)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// Set provides a mock function with given fields: sha, name, value
+func (_m *AnnotationCache) Set(sha string, name string, value string) error {
+	ret := _m.Called(sha, name, value)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string) error); ok {
+		r0 = rf(sha, name, value)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,25 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+
+// CacheClient is an autogenerated mock type for the CacheClient type
+type CacheClient struct {
+	mock.Mock
+}
+
+// Invalidate provides a mock function with given fields: ctx, keys
+func (_m *CacheClient) Invalidate(ctx context.Context, keys []string) error {
+	ret := _m.Called(ctx, keys)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string) error); ok {
+		r0 = rf(ctx, keys)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,45 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+
+// CheckpointStore is an autogenerated mock type for the CheckpointStore type
+type CheckpointStore struct {
+	mock.Mock
+}
+
+// Load provides a mock function with given fields:
+func (_m *CheckpointStore) Load() (string, error) {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Save provides a mock function with given fields: sha
+func (_m *CheckpointStore) Save(sha string) error {
+	ret := _m.Called(sha)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(sha)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,25 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import gpoll "github.com/eddieowens/gpoll"
+import mock "github.com/stretchr/testify/mock"
+
+// CommitStatusReporter is an autogenerated mock type for the CommitStatusReporter type
+type CommitStatusReporter struct {
+	mock.Mock
+}
+
+// ReportStatus provides a mock function with given fields: branch, result
+func (_m *CommitStatusReporter) ReportStatus(branch string, result gpoll.Result) error {
+	ret := _m.Called(branch, result)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, gpoll.Result) error); ok {
+		r0 = rf(branch, result)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,24 @@
+// Package mocks contains mocks of every gpoll interface for use in tests. The mocks are generated by mockery through
+// go generate and must not be edited by hand.
+package mocks
+
+import gpoll "github.com/eddieowens/gpoll"
+
+// Fail the build if a mock falls out of sync with its interface.
+var (
+	_ gpoll.AnnotationCache      = (*AnnotationCache)(nil)
+	_ gpoll.CacheClient          = (*CacheClient)(nil)
+	_ gpoll.CheckpointStore      = (*CheckpointStore)(nil)
+	_ gpoll.CommitStatusReporter = (*CommitStatusReporter)(nil)
+	_ gpoll.Event                = (*Event)(nil)
+	_ gpoll.FaultInjector        = (*FaultInjector)(nil)
+	_ gpoll.GitService           = (*GitService)(nil)
+	_ gpoll.HeadLogStore         = (*HeadLogStore)(nil)
+	_ gpoll.MultiPoller          = (*MultiPoller)(nil)
+	_ gpoll.OutboxStore          = (*OutboxStore)(nil)
+	_ gpoll.Poller               = (*Poller)(nil)
+	_ gpoll.PrunableAuditSink    = (*PrunableAuditSink)(nil)
+	_ gpoll.PrunableHeadLogStore = (*PrunableHeadLogStore)(nil)
+	_ gpoll.ProvenanceProvider   = (*ProvenanceProvider)(nil)
+	_ gpoll.Redactor             = (*Redactor)(nil)
+)
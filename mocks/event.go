@@ -0,0 +1,25 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import gpoll "github.com/eddieowens/gpoll"
+import mock "github.com/stretchr/testify/mock"
+
+// Event is an autogenerated mock type for the Event type
+type Event struct {
+	mock.Mock
+}
+
+// EventType provides a mock function with given fields:
+func (_m *Event) EventType() gpoll.EventType {
+	ret := _m.Called()
+
+	var r0 gpoll.EventType
+	if rf, ok := ret.Get(0).(func() gpoll.EventType); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(gpoll.EventType)
+	}
+
+	return r0
+}
@@ -0,0 +1,56 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import gpoll "github.com/eddieowens/gpoll"
+import mock "github.com/stretchr/testify/mock"
+import time "time"
+
+// FaultInjector is an autogenerated mock type for the FaultInjector type
+type FaultInjector struct {
+	mock.Mock
+}
+
+// Diff provides a mock function with given fields: commits
+func (_m *FaultInjector) Diff(commits []gpoll.CommitDiff) []gpoll.CommitDiff {
+	ret := _m.Called(commits)
+
+	var r0 []gpoll.CommitDiff
+	if rf, ok := ret.Get(0).(func([]gpoll.CommitDiff) []gpoll.CommitDiff); ok {
+		r0 = rf(commits)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]gpoll.CommitDiff)
+		}
+	}
+
+	return r0
+}
+
+// FetchDelay provides a mock function with given fields:
+func (_m *FaultInjector) FetchDelay() time.Duration {
+	ret := _m.Called()
+
+	var r0 time.Duration
+	if rf, ok := ret.Get(0).(func() time.Duration); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(time.Duration)
+	}
+
+	return r0
+}
+
+// FetchError provides a mock function with given fields:
+func (_m *FaultInjector) FetchError() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
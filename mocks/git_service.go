@@ -2,6 +2,7 @@
 
 package mocks
 
+import context "context"
 import git "gopkg.in/src-d/go-git.v4"
 import gpoll "github.com/eddieowens/gpoll"
 import mock "github.com/stretchr/testify/mock"
@@ -12,13 +13,27 @@ type GitService struct {
 	mock.Mock
 }
 
-// Clone provides a mock function with given fields: remote, branch, directory
-func (_m *GitService) Clone(remote string, branch string, directory string) (*git.Repository, error) {
-	ret := _m.Called(remote, branch, directory)
+// CheckRemote provides a mock function with given fields: remote, branch
+func (_m *GitService) CheckRemote(remote string, branch string) error {
+	ret := _m.Called(remote, branch)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(remote, branch)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Clone provides a mock function with given fields: ctx, remote, branch, directory
+func (_m *GitService) Clone(ctx context.Context, remote string, branch string, directory string) (*git.Repository, error) {
+	ret := _m.Called(ctx, remote, branch, directory)
 
 	var r0 *git.Repository
-	if rf, ok := ret.Get(0).(func(string, string, string) *git.Repository); ok {
-		r0 = rf(remote, branch, directory)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) *git.Repository); ok {
+		r0 = rf(ctx, remote, branch, directory)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*git.Repository)
@@ -26,8 +41,43 @@ func (_m *GitService) Clone(remote string, branch string, directory string) (*gi
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, string, string) error); ok {
-		r1 = rf(remote, branch, directory)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string) error); ok {
+		r1 = rf(ctx, remote, branch, directory)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Compact provides a mock function with given fields: repo
+func (_m *GitService) Compact(repo *git.Repository) error {
+	ret := _m.Called(repo)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*git.Repository) error); ok {
+		r0 = rf(repo)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DefaultBranch provides a mock function with given fields: repo
+func (_m *GitService) DefaultBranch(repo *git.Repository) (string, error) {
+	ret := _m.Called(repo)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(*git.Repository) string); ok {
+		r0 = rf(repo)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*git.Repository) error); ok {
+		r1 = rf(repo)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -58,13 +108,13 @@ func (_m *GitService) Diff(from *object.Commit, to *object.Commit) (*gpoll.Commi
 	return r0, r1
 }
 
-// DiffRemote provides a mock function with given fields: repo, branch
-func (_m *GitService) DiffRemote(repo *git.Repository, branch string) ([]gpoll.CommitDiff, error) {
-	ret := _m.Called(repo, branch)
+// DiffCommits provides a mock function with given fields: repo, from, to
+func (_m *GitService) DiffCommits(repo *git.Repository, from string, to string) ([]gpoll.CommitDiff, error) {
+	ret := _m.Called(repo, from, to)
 
 	var r0 []gpoll.CommitDiff
-	if rf, ok := ret.Get(0).(func(*git.Repository, string) []gpoll.CommitDiff); ok {
-		r0 = rf(repo, branch)
+	if rf, ok := ret.Get(0).(func(*git.Repository, string, string) []gpoll.CommitDiff); ok {
+		r0 = rf(repo, from, to)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]gpoll.CommitDiff)
@@ -72,8 +122,8 @@ func (_m *GitService) DiffRemote(repo *git.Repository, branch string) ([]gpoll.C
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(*git.Repository, string) error); ok {
-		r1 = rf(repo, branch)
+	if rf, ok := ret.Get(1).(func(*git.Repository, string, string) error); ok {
+		r1 = rf(repo, from, to)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -81,6 +131,43 @@ func (_m *GitService) DiffRemote(repo *git.Repository, branch string) ([]gpoll.C
 	return r0, r1
 }
 
+// DiffRemote provides a mock function with given fields: ctx, repo, branch
+func (_m *GitService) DiffRemote(ctx context.Context, repo *git.Repository, branch string) ([]gpoll.CommitDiff, error) {
+	ret := _m.Called(ctx, repo, branch)
+
+	var r0 []gpoll.CommitDiff
+	if rf, ok := ret.Get(0).(func(context.Context, *git.Repository, string) []gpoll.CommitDiff); ok {
+		r0 = rf(ctx, repo, branch)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]gpoll.CommitDiff)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *git.Repository, string) error); ok {
+		r1 = rf(ctx, repo, branch)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Fetch provides a mock function with given fields: repo
+func (_m *GitService) Fetch(repo *git.Repository) error {
+	ret := _m.Called(repo)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*git.Repository) error); ok {
+		r0 = rf(repo)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // FetchLatestRemoteCommit provides a mock function with given fields: repo, branch
 func (_m *GitService) FetchLatestRemoteCommit(repo *git.Repository, branch string) (*object.Commit, error) {
 	ret := _m.Called(repo, branch)
@@ -104,6 +191,20 @@ func (_m *GitService) FetchLatestRemoteCommit(repo *git.Repository, branch strin
 	return r0, r1
 }
 
+// FetchMirror provides a mock function with given fields: repo
+func (_m *GitService) FetchMirror(repo *git.Repository) error {
+	ret := _m.Called(repo)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*git.Repository) error); ok {
+		r0 = rf(repo)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // HeadCommit provides a mock function with given fields: repo
 func (_m *GitService) HeadCommit(repo *git.Repository) (*object.Commit, error) {
 	ret := _m.Called(repo)
@@ -127,6 +228,158 @@ func (_m *GitService) HeadCommit(repo *git.Repository) (*object.Commit, error) {
 	return r0, r1
 }
 
+// History provides a mock function with given fields: repo, fp, limit
+func (_m *GitService) History(repo *git.Repository, fp string, limit int) ([]*object.Commit, error) {
+	ret := _m.Called(repo, fp, limit)
+
+	var r0 []*object.Commit
+	if rf, ok := ret.Get(0).(func(*git.Repository, string, int) []*object.Commit); ok {
+		r0 = rf(repo, fp, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*object.Commit)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*git.Repository, string, int) error); ok {
+		r1 = rf(repo, fp, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListFiles provides a mock function with given fields: c
+func (_m *GitService) ListFiles(c *object.Commit) ([]gpoll.FileChange, error) {
+	ret := _m.Called(c)
+
+	var r0 []gpoll.FileChange
+	if rf, ok := ret.Get(0).(func(*object.Commit) []gpoll.FileChange); ok {
+		r0 = rf(c)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]gpoll.FileChange)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*object.Commit) error); ok {
+		r1 = rf(c)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReadFile provides a mock function with given fields: repo, sha, fp
+func (_m *GitService) ReadFile(repo *git.Repository, sha string, fp string) ([]byte, error) {
+	ret := _m.Called(repo, sha, fp)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func(*git.Repository, string, string) []byte); ok {
+		r0 = rf(repo, sha, fp)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*git.Repository, string, string) error); ok {
+		r1 = rf(repo, sha, fp)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RemoteRefs provides a mock function with given fields: repo
+func (_m *GitService) RemoteRefs(repo *git.Repository) (map[string]string, error) {
+	ret := _m.Called(repo)
+
+	var r0 map[string]string
+	if rf, ok := ret.Get(0).(func(*git.Repository) map[string]string); ok {
+		r0 = rf(repo)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*git.Repository) error); ok {
+		r1 = rf(repo)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Reset provides a mock function with given fields: repo, branch, sha
+func (_m *GitService) Reset(repo *git.Repository, branch string, sha string) error {
+	ret := _m.Called(repo, branch, sha)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*git.Repository, string, string) error); ok {
+		r0 = rf(repo, branch, sha)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResolveRevision provides a mock function with given fields: repo, revision
+func (_m *GitService) ResolveRevision(repo *git.Repository, revision string) (*object.Commit, error) {
+	ret := _m.Called(repo, revision)
+
+	var r0 *object.Commit
+	if rf, ok := ret.Get(0).(func(*git.Repository, string) *object.Commit); ok {
+		r0 = rf(repo, revision)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*object.Commit)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*git.Repository, string) error); ok {
+		r1 = rf(repo, revision)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Tag provides a mock function with given fields: repo, name, sha
+func (_m *GitService) Tag(repo *git.Repository, name string, sha string) (*gpoll.Tag, error) {
+	ret := _m.Called(repo, name, sha)
+
+	var r0 *gpoll.Tag
+	if rf, ok := ret.Get(0).(func(*git.Repository, string, string) *gpoll.Tag); ok {
+		r0 = rf(repo, name, sha)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*gpoll.Tag)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*git.Repository, string, string) error); ok {
+		r1 = rf(repo, name, sha)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ToInternal provides a mock function with given fields: c
 func (_m *GitService) ToInternal(c *object.Commit) *gpoll.Commit {
 	ret := _m.Called(c)
@@ -142,3 +395,17 @@ func (_m *GitService) ToInternal(c *object.Commit) *gpoll.Commit {
 
 	return r0
 }
+
+// VerifySignature provides a mock function with given fields: repo, sha, armoredKeyRing
+func (_m *GitService) VerifySignature(repo *git.Repository, sha string, armoredKeyRing string) error {
+	ret := _m.Called(repo, sha, armoredKeyRing)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*git.Repository, string, string) error); ok {
+		r0 = rf(repo, sha, armoredKeyRing)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
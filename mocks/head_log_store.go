@@ -0,0 +1,83 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import gpoll "github.com/eddieowens/gpoll"
+import mock "github.com/stretchr/testify/mock"
+
+// HeadLogStore is an autogenerated mock type for the HeadLogStore type
+type HeadLogStore struct {
+	mock.Mock
+}
+
+// AppendHead provides a mock function with given fields: entry
+func (_m *HeadLogStore) AppendHead(entry gpoll.HeadLogEntry) error {
+	ret := _m.Called(entry)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(gpoll.HeadLogEntry) error); ok {
+		r0 = rf(entry)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Heads provides a mock function with given fields:
+func (_m *HeadLogStore) Heads() ([]gpoll.HeadLogEntry, error) {
+	ret := _m.Called()
+
+	var r0 []gpoll.HeadLogEntry
+	if rf, ok := ret.Get(0).(func() []gpoll.HeadLogEntry); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]gpoll.HeadLogEntry)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Load provides a mock function with given fields:
+func (_m *HeadLogStore) Load() (string, error) {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Save provides a mock function with given fields: sha
+func (_m *HeadLogStore) Save(sha string) error {
+	ret := _m.Called(sha)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(sha)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
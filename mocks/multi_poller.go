@@ -0,0 +1,188 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import gpoll "github.com/eddieowens/gpoll"
+import mock "github.com/stretchr/testify/mock"
+
+// MultiPoller is an autogenerated mock type for the MultiPoller type
+type MultiPoller struct {
+	mock.Mock
+}
+
+// AddRepo provides a mock function with given fields: id, config
+func (_m *MultiPoller) AddRepo(id string, config gpoll.PollConfig) error {
+	ret := _m.Called(id, config)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, gpoll.PollConfig) error); ok {
+		r0 = rf(id, config)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AddTenant provides a mock function with given fields: tenant
+func (_m *MultiPoller) AddTenant(tenant gpoll.Tenant) error {
+	ret := _m.Called(tenant)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(gpoll.Tenant) error); ok {
+		r0 = rf(tenant)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AddTenantRepo provides a mock function with given fields: tenant, id, config
+func (_m *MultiPoller) AddTenantRepo(tenant string, id string, config gpoll.PollConfig) error {
+	ret := _m.Called(tenant, id, config)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, gpoll.PollConfig) error); ok {
+		r0 = rf(tenant, id, config)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Health provides a mock function with given fields:
+func (_m *MultiPoller) Health() gpoll.Health {
+	ret := _m.Called()
+
+	var r0 gpoll.Health
+	if rf, ok := ret.Get(0).(func() gpoll.Health); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(gpoll.Health)
+	}
+
+	return r0
+}
+
+// ListRepos provides a mock function with given fields:
+func (_m *MultiPoller) ListRepos() []string {
+	ret := _m.Called()
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func() []string); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	return r0
+}
+
+// ListTenantRepos provides a mock function with given fields: tenant
+func (_m *MultiPoller) ListTenantRepos(tenant string) ([]string, error) {
+	ret := _m.Called(tenant)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(string) []string); ok {
+		r0 = rf(tenant)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(tenant)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PauseRepo provides a mock function with given fields: id
+func (_m *MultiPoller) PauseRepo(id string) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RemoveRepo provides a mock function with given fields: id
+func (_m *MultiPoller) RemoveRepo(id string) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResumeRepo provides a mock function with given fields: id
+func (_m *MultiPoller) ResumeRepo(id string) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Start provides a mock function with given fields:
+func (_m *MultiPoller) Start() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Status provides a mock function with given fields: id
+func (_m *MultiPoller) Status(id string) (gpoll.Status, error) {
+	ret := _m.Called(id)
+
+	var r0 gpoll.Status
+	if rf, ok := ret.Get(0).(func(string) gpoll.Status); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Get(0).(gpoll.Status)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Stop provides a mock function with given fields:
+func (_m *MultiPoller) Stop() {
+	_m.Called()
+}
@@ -0,0 +1,62 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import gpoll "github.com/eddieowens/gpoll"
+import mock "github.com/stretchr/testify/mock"
+
+// OutboxStore is an autogenerated mock type for the OutboxStore type
+type OutboxStore struct {
+	mock.Mock
+}
+
+// Ack provides a mock function with given fields: id
+func (_m *OutboxStore) Ack(id string) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Pending provides a mock function with given fields:
+func (_m *OutboxStore) Pending() ([]gpoll.CommitDiff, error) {
+	ret := _m.Called()
+
+	var r0 []gpoll.CommitDiff
+	if rf, ok := ret.Get(0).(func() []gpoll.CommitDiff); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]gpoll.CommitDiff)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Put provides a mock function with given fields: commit
+func (_m *OutboxStore) Put(commit gpoll.CommitDiff) error {
+	ret := _m.Called(commit)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(gpoll.CommitDiff) error); ok {
+		r0 = rf(commit)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
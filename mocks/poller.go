@@ -2,14 +2,191 @@
 
 package mocks
 
+import context "context"
+import git "gopkg.in/src-d/go-git.v4"
 import gpoll "github.com/eddieowens/gpoll"
 import mock "github.com/stretchr/testify/mock"
+import time "time"
 
 // Poller is an autogenerated mock type for the Poller type
 type Poller struct {
 	mock.Mock
 }
 
+// AddHandler provides a mock function with given fields: h
+func (_m *Poller) AddHandler(h gpoll.Handler) error {
+	ret := _m.Called(h)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(gpoll.Handler) error); ok {
+		r0 = rf(h)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Backfill provides a mock function with given fields: path, since
+func (_m *Poller) Backfill(path string, since time.Time) error {
+	ret := _m.Called(path, since)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, time.Time) error); ok {
+		r0 = rf(path, since)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Debug provides a mock function with given fields:
+func (_m *Poller) Debug() gpoll.DebugInfo {
+	ret := _m.Called()
+
+	var r0 gpoll.DebugInfo
+	if rf, ok := ret.Get(0).(func() gpoll.DebugInfo); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(gpoll.DebugInfo)
+	}
+
+	return r0
+}
+
+// Discard provides a mock function with given fields: sha
+func (_m *Poller) Discard(sha string) error {
+	ret := _m.Called(sha)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(sha)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Errors provides a mock function with given fields:
+func (_m *Poller) Errors() <-chan error {
+	ret := _m.Called()
+
+	var r0 <-chan error
+	if rf, ok := ret.Get(0).(func() <-chan error); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan error)
+		}
+	}
+
+	return r0
+}
+
+// Events provides a mock function with given fields: since
+func (_m *Poller) Events(since uint64) []gpoll.CommitDiff {
+	ret := _m.Called(since)
+
+	var r0 []gpoll.CommitDiff
+	if rf, ok := ret.Get(0).(func(uint64) []gpoll.CommitDiff); ok {
+		r0 = rf(since)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]gpoll.CommitDiff)
+		}
+	}
+
+	return r0
+}
+
+// HeadLog provides a mock function with given fields:
+func (_m *Poller) HeadLog() ([]gpoll.HeadLogEntry, error) {
+	ret := _m.Called()
+
+	var r0 []gpoll.HeadLogEntry
+	if rf, ok := ret.Get(0).(func() []gpoll.HeadLogEntry); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]gpoll.HeadLogEntry)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// History provides a mock function with given fields: path, limit
+func (_m *Poller) History(path string, limit int) ([]gpoll.Commit, error) {
+	ret := _m.Called(path, limit)
+
+	var r0 []gpoll.Commit
+	if rf, ok := ret.Get(0).(func(string, int) []gpoll.Commit); ok {
+		r0 = rf(path, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]gpoll.Commit)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, int) error); ok {
+		r1 = rf(path, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LastChange provides a mock function with given fields: path
+func (_m *Poller) LastChange(path string) (gpoll.PathChange, bool) {
+	ret := _m.Called(path)
+
+	var r0 gpoll.PathChange
+	if rf, ok := ret.Get(0).(func(string) gpoll.PathChange); ok {
+		r0 = rf(path)
+	} else {
+		r0 = ret.Get(0).(gpoll.PathChange)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(path)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// Pause provides a mock function with given fields:
+func (_m *Poller) Pause() {
+	_m.Called()
+}
+
+// PinTo provides a mock function with given fields: revision
+func (_m *Poller) PinTo(revision string) error {
+	ret := _m.Called(revision)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(revision)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Poll provides a mock function with given fields:
 func (_m *Poller) Poll() ([]gpoll.CommitDiff, error) {
 	ret := _m.Called()
@@ -33,6 +210,169 @@ func (_m *Poller) Poll() ([]gpoll.CommitDiff, error) {
 	return r0, r1
 }
 
+// PollContext provides a mock function with given fields: ctx
+func (_m *Poller) PollContext(ctx context.Context) ([]gpoll.CommitDiff, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []gpoll.CommitDiff
+	if rf, ok := ret.Get(0).(func(context.Context) []gpoll.CommitDiff); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]gpoll.CommitDiff)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PromoteStandby provides a mock function with given fields:
+func (_m *Poller) PromoteStandby() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Quarantine provides a mock function with given fields:
+func (_m *Poller) Quarantine() []gpoll.QuarantinedCommit {
+	ret := _m.Called()
+
+	var r0 []gpoll.QuarantinedCommit
+	if rf, ok := ret.Get(0).(func() []gpoll.QuarantinedCommit); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]gpoll.QuarantinedCommit)
+		}
+	}
+
+	return r0
+}
+
+// RefSnapshot provides a mock function with given fields:
+func (_m *Poller) RefSnapshot() (gpoll.RefSnapshot, error) {
+	ret := _m.Called()
+
+	var r0 gpoll.RefSnapshot
+	if rf, ok := ret.Get(0).(func() gpoll.RefSnapshot); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(gpoll.RefSnapshot)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Receipt provides a mock function with given fields: eventID
+func (_m *Poller) Receipt(eventID string) (gpoll.Receipt, bool) {
+	ret := _m.Called(eventID)
+
+	var r0 gpoll.Receipt
+	if rf, ok := ret.Get(0).(func(string) gpoll.Receipt); ok {
+		r0 = rf(eventID)
+	} else {
+		r0 = ret.Get(0).(gpoll.Receipt)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(eventID)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// Release provides a mock function with given fields: sha
+func (_m *Poller) Release(sha string) error {
+	ret := _m.Called(sha)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(sha)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RemoveHandler provides a mock function with given fields: name
+func (_m *Poller) RemoveHandler(name string) {
+	_m.Called(name)
+}
+
+// ReportResult provides a mock function with given fields: eventID, outcome, message
+func (_m *Poller) ReportResult(eventID string, outcome gpoll.Outcome, message string) error {
+	ret := _m.Called(eventID, outcome, message)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, gpoll.Outcome, string) error); ok {
+		r0 = rf(eventID, outcome, message)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Repository provides a mock function with given fields:
+func (_m *Poller) Repository() *git.Repository {
+	ret := _m.Called()
+
+	var r0 *git.Repository
+	if rf, ok := ret.Get(0).(func() *git.Repository); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*git.Repository)
+		}
+	}
+
+	return r0
+}
+
+// Resume provides a mock function with given fields:
+func (_m *Poller) Resume() {
+	_m.Called()
+}
+
+// RollbackTo provides a mock function with given fields: revision
+func (_m *Poller) RollbackTo(revision string) error {
+	ret := _m.Called(revision)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(revision)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Start provides a mock function with given fields:
 func (_m *Poller) Start() error {
 	ret := _m.Called()
@@ -70,7 +410,101 @@ func (_m *Poller) StartAsync() (chan gpoll.CommitDiff, error) {
 	return r0, r1
 }
 
+// StartAsyncContext provides a mock function with given fields: ctx
+func (_m *Poller) StartAsyncContext(ctx context.Context) (chan gpoll.CommitDiff, error) {
+	ret := _m.Called(ctx)
+
+	var r0 chan gpoll.CommitDiff
+	if rf, ok := ret.Get(0).(func(context.Context) chan gpoll.CommitDiff); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(chan gpoll.CommitDiff)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StartContext provides a mock function with given fields: ctx
+func (_m *Poller) StartContext(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Status provides a mock function with given fields:
+func (_m *Poller) Status() gpoll.Status {
+	ret := _m.Called()
+
+	var r0 gpoll.Status
+	if rf, ok := ret.Get(0).(func() gpoll.Status); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(gpoll.Status)
+	}
+
+	return r0
+}
+
 // Stop provides a mock function with given fields:
 func (_m *Poller) Stop() {
 	_m.Called()
 }
+
+// StopAndWait provides a mock function with given fields:
+func (_m *Poller) StopAndWait() {
+	_m.Called()
+}
+
+// StopContext provides a mock function with given fields: ctx
+func (_m *Poller) StopContext(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Trigger provides a mock function with given fields:
+func (_m *Poller) Trigger() {
+	_m.Called()
+}
+
+// Unpin provides a mock function with given fields:
+func (_m *Poller) Unpin() {
+	_m.Called()
+}
+
+// WithRepo provides a mock function with given fields: f
+func (_m *Poller) WithRepo(f func(*git.Repository) error) error {
+	ret := _m.Called(f)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(func(*git.Repository) error) error); ok {
+		r0 = rf(f)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,35 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import context "context"
+import gpoll "github.com/eddieowens/gpoll"
+import mock "github.com/stretchr/testify/mock"
+
+// ProvenanceProvider is an autogenerated mock type for the ProvenanceProvider type
+type ProvenanceProvider struct {
+	mock.Mock
+}
+
+// Provenance provides a mock function with given fields: ctx, branch, commit
+func (_m *ProvenanceProvider) Provenance(ctx context.Context, branch string, commit gpoll.Commit) (*gpoll.Provenance, error) {
+	ret := _m.Called(ctx, branch, commit)
+
+	var r0 *gpoll.Provenance
+	if rf, ok := ret.Get(0).(func(context.Context, string, gpoll.Commit) *gpoll.Provenance); ok {
+		r0 = rf(ctx, branch, commit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*gpoll.Provenance)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, gpoll.Commit) error); ok {
+		r1 = rf(ctx, branch, commit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,46 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+import time "time"
+
+// PrunableAuditSink is an autogenerated mock type for the PrunableAuditSink type
+type PrunableAuditSink struct {
+	mock.Mock
+}
+
+// PruneAudit provides a mock function with given fields: before, max
+func (_m *PrunableAuditSink) PruneAudit(before time.Time, max int) error {
+	ret := _m.Called(before, max)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(time.Time, int) error); ok {
+		r0 = rf(before, max)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Write provides a mock function with given fields: p
+func (_m *PrunableAuditSink) Write(p []byte) (int, error) {
+	ret := _m.Called(p)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func([]byte) int); ok {
+		r0 = rf(p)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func([]byte) error); ok {
+		r1 = rf(p)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,98 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import gpoll "github.com/eddieowens/gpoll"
+import mock "github.com/stretchr/testify/mock"
+import time "time"
+
+// PrunableHeadLogStore is an autogenerated mock type for the PrunableHeadLogStore type
+type PrunableHeadLogStore struct {
+	mock.Mock
+}
+
+// AppendHead provides a mock function with given fields: entry
+func (_m *PrunableHeadLogStore) AppendHead(entry gpoll.HeadLogEntry) error {
+	ret := _m.Called(entry)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(gpoll.HeadLogEntry) error); ok {
+		r0 = rf(entry)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Heads provides a mock function with given fields:
+func (_m *PrunableHeadLogStore) Heads() ([]gpoll.HeadLogEntry, error) {
+	ret := _m.Called()
+
+	var r0 []gpoll.HeadLogEntry
+	if rf, ok := ret.Get(0).(func() []gpoll.HeadLogEntry); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]gpoll.HeadLogEntry)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Load provides a mock function with given fields:
+func (_m *PrunableHeadLogStore) Load() (string, error) {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PruneHeads provides a mock function with given fields: before, max
+func (_m *PrunableHeadLogStore) PruneHeads(before time.Time, max int) error {
+	ret := _m.Called(before, max)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(time.Time, int) error); ok {
+		r0 = rf(before, max)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Save provides a mock function with given fields: sha
+func (_m *PrunableHeadLogStore) Save(sha string) error {
+	ret := _m.Called(sha)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(sha)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,24 @@
+// Code generated by mockery v1.0.0. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+
+// Redactor is an autogenerated mock type for the Redactor type
+type Redactor struct {
+	mock.Mock
+}
+
+// Redact provides a mock function with given fields: s
+func (_m *Redactor) Redact(s string) string {
+	ret := _m.Called(s)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string) string); ok {
+		r0 = rf(s)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
@@ -0,0 +1,491 @@
+package gpoll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Manages many Pollers, each identified by a unique ID, through a single surface.
+type MultiPoller interface {
+	// Start polling every repo without blocking. Commits are delivered through each repo's configured HandleCommit.
+	Start() error
+
+	// Stop polling every repo.
+	Stop()
+
+	// Add a repo to be polled under the given ID. If the MultiPoller is running, the repo is started immediately. The ID
+	// may not contain a slash, which is reserved for the IDs of the repos of tenants.
+	AddRepo(id string, config PollConfig) error
+
+	// Stop polling the repo and remove it.
+	RemoveRepo(id string) error
+
+	// Get the IDs of every repo, sorted.
+	ListRepos() []string
+
+	// Get the Status of a single repo.
+	Status(id string) (Status, error)
+
+	// Pause polling of a single repo.
+	PauseRepo(id string) error
+
+	// Resume polling of a single repo.
+	ResumeRepo(id string) error
+
+	// Get a summary of the health of every repo.
+	Health() Health
+
+	// Add a tenant whose repos are isolated from those of other tenants.
+	AddTenant(tenant Tenant) error
+
+	// Add a repo of the tenant under the ID TenantRepoID(tenant, id), enforcing the tenant's limits. The repo is cloned
+	// and checkpointed within the tenant's Directory unless configured otherwise. The id may not contain a slash or "..".
+	AddTenantRepo(tenant, id string, config PollConfig) error
+
+	// Get the IDs of every repo of the tenant without the tenant's prefix, sorted.
+	ListTenantRepos(tenant string) ([]string, error)
+}
+
+// An aggregate summary of the repos in a MultiPoller.
+type Health struct {
+	// The number of repos.
+	Total int
+
+	// The number of repos that are running and not paused.
+	Running int
+
+	// The number of paused repos.
+	Paused int
+
+	// The IDs of repos whose last poll failed, sorted.
+	Failing []string
+
+	// The IDs of repos that are holding commits because of policy violations, sorted.
+	Holding []string
+}
+
+// Whether every repo is running and none are failing or holding.
+func (h Health) Healthy() bool {
+	return h.Running+h.Paused == h.Total && len(h.Failing) == 0 && len(h.Holding) == 0
+}
+
+var ErrRepoNotFound = errors.New("repo not found")
+
+// Selects the SSH key for repos whose remote matches a pattern, similar to an IdentityFile within a Host of an SSH
+// config.
+type RemoteSshKey struct {
+	// Matched against both the host of the remote, e.g. github.com, and its host and path without the .git suffix, e.g.
+	// github.com/acme/*, using the syntax of path.Match.
+	Remote string
+
+	// The filepath to the SSH key.
+	SshKey string
+}
+
+// Create a new MultiPoller from a config per repo ID. Will return an error for misconfiguration of any repo. Repos that
+// are forks or branches of the same upstream can share an ObjectPool through their StorageConfig.
+func NewMultiPoller(configs map[string]PollConfig) (MultiPoller, error) {
+	return NewMultiPollerWithKeys(configs, nil)
+}
+
+// Create a new MultiPoller that selects the SSH keys of repos by their remote. A repo without any auth configured is
+// offered the key of every RemoteSshKey matching its remote, in order, until one is accepted. Will return an error for
+// misconfiguration of any repo.
+func NewMultiPollerWithKeys(configs map[string]PollConfig, keys []RemoteSshKey) (MultiPoller, error) {
+	m := newMultiPoller(keys)
+	for id, config := range configs {
+		if err := m.AddRepo(id, config); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+type multiPoller struct {
+	lock    sync.RWMutex
+	pollers map[string]Poller
+	tenants map[string]*tenant
+	// The name of the tenant owning each repo keyed by the repo's ID. Repos added through AddRepo aren't kept.
+	owners  map[string]string
+	running bool
+	keys    []RemoteSshKey
+	// Whether Start is starting the repos. Stop unsets it so Start stops them again.
+	starting bool
+
+	// Whether the repos are polled by a single shared scheduler rather than a ticker each.
+	scheduled bool
+	// Signals the scheduler that a repo was added. Nil unless scheduled.
+	reschedule chan struct{}
+	// Closed to stop the scheduler, which is then waited for through the scheduler group.
+	stopScheduler chan struct{}
+	scheduler     sync.WaitGroup
+
+	// Adapts the config of a repo before its poller is created. Set by a PollerGroup.
+	configure func(id string, config PollConfig) PollConfig
+	// Forwards what the poller of a repo sends on its channel. Defaults to discarding it, so delivery relies solely on
+	// HandleCommit. Set by a PollerGroup.
+	forward func(id string, p *poller)
+}
+
+func newMultiPoller(keys []RemoteSshKey) *multiPoller {
+	return &multiPoller{
+		pollers: make(map[string]Poller),
+		tenants: make(map[string]*tenant),
+		owners:  make(map[string]string),
+		keys:    keys,
+	}
+}
+
+func (m *multiPoller) Start() error {
+	return m.start(context.Background())
+}
+
+// Starts every repo, stopping those already started if any fails. The repos are started without holding the lock, since
+// starting clones them, and repos added meanwhile are started before the MultiPoller is marked as running.
+func (m *multiPoller) start(ctx context.Context) error {
+	m.lock.Lock()
+	if m.running || m.starting {
+		m.lock.Unlock()
+		return ErrAlreadyStarted
+	}
+	m.starting = true
+	m.lock.Unlock()
+
+	started := make(map[string]Poller)
+	for {
+		m.lock.Lock()
+		if !m.starting {
+			m.lock.Unlock()
+			for _, p := range started {
+				p.Stop()
+			}
+			return errors.New("the repos were stopped while starting")
+		}
+		for id, p := range started {
+			// Removed while starting.
+			if m.pollers[id] != p {
+				p.Stop()
+				delete(started, id)
+			}
+		}
+		pending := make([]string, 0)
+		for _, id := range m.sortedIDs() {
+			if started[id] != m.pollers[id] {
+				pending = append(pending, id)
+			}
+		}
+		if len(pending) == 0 {
+			m.starting = false
+			m.running = true
+			if m.scheduled {
+				m.stopScheduler = make(chan struct{})
+				m.scheduler.Add(1)
+				go m.schedule(m.stopScheduler)
+			}
+			m.lock.Unlock()
+			return nil
+		}
+		pollers := make([]Poller, len(pending))
+		for i, id := range pending {
+			pollers[i] = m.pollers[id]
+		}
+		m.lock.Unlock()
+
+		for i, id := range pending {
+			if _, err := pollers[i].StartAsyncContext(ctx); err != nil {
+				m.lock.Lock()
+				m.starting = false
+				m.lock.Unlock()
+				for _, p := range started {
+					p.Stop()
+				}
+				return fmt.Errorf("failed to start repo %s: %s", id, err.Error())
+			}
+			started[id] = pollers[i]
+		}
+	}
+}
+
+func (m *multiPoller) Stop() {
+	m.stop()
+}
+
+// Stops every repo and the scheduler, returning the pollers that were stopped.
+func (m *multiPoller) stop() []Poller {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	// Stopping the repos cancels any being started.
+	m.starting = false
+	stopped := make([]Poller, 0, len(m.pollers))
+	for _, p := range m.pollers {
+		p.Stop()
+		stopped = append(stopped, p)
+	}
+	if m.stopScheduler != nil {
+		close(m.stopScheduler)
+		m.stopScheduler = nil
+	}
+	m.running = false
+	return stopped
+}
+
+// Stops every repo and the scheduler and waits for them.
+func (m *multiPoller) stopAndWait() {
+	var wg sync.WaitGroup
+	for _, p := range m.stop() {
+		p := p
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.StopAndWait()
+		}()
+	}
+	wg.Wait()
+	m.scheduler.Wait()
+}
+
+func (m *multiPoller) AddRepo(id string, config PollConfig) error {
+	// Otherwise the repo could pass as a tenant's, bypassing its limits.
+	if strings.Contains(id, "/") {
+		return fmt.Errorf("repo %s may not contain a slash, repos of tenants must be added through AddTenantRepo", id)
+	}
+	return m.addRepo(id, config, func() error { return nil })
+}
+
+// Adds the repo if the check, which is called while holding the lock, passes.
+func (m *multiPoller) addRepo(id string, config PollConfig, check func() error) error {
+	if !hasAuth(&config.Git.Auth) {
+		config.Git.Auth.SshKeys = selectSshKeys(m.keys, config.Git.Remote)
+	}
+	if m.configure != nil {
+		config = m.configure(id, config)
+	}
+	p, err := NewPoller(config)
+	if err != nil {
+		return err
+	}
+	pp := p.(*poller)
+	pp.scheduled = m.scheduled
+	pp.forwardCommit = func(CommitDiff) {}
+	if m.forward != nil {
+		m.forward(id, pp)
+	}
+
+	m.lock.Lock()
+	if _, ok := m.pollers[id]; ok {
+		m.lock.Unlock()
+		return fmt.Errorf("repo %s already exists", id)
+	}
+	if err := check(); err != nil {
+		m.lock.Unlock()
+		return err
+	}
+	m.pollers[id] = p
+	running := m.running
+	m.lock.Unlock()
+
+	// Started without holding the lock since starting clones the repo.
+	if running {
+		if _, err := p.StartAsync(); err != nil {
+			m.lock.Lock()
+			if m.pollers[id] == p {
+				delete(m.pollers, id)
+				delete(m.owners, id)
+			}
+			m.lock.Unlock()
+			return err
+		}
+		// The repo may have been removed or the MultiPoller stopped while starting.
+		m.lock.Lock()
+		if m.pollers[id] != p || !m.running {
+			p.Stop()
+		}
+		m.lock.Unlock()
+	}
+	if m.reschedule != nil {
+		select {
+		case m.reschedule <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (m *multiPoller) RemoveRepo(id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	p, ok := m.pollers[id]
+	if !ok {
+		return ErrRepoNotFound
+	}
+
+	// Also cancels the repo's start should the MultiPoller be starting.
+	p.Stop()
+	delete(m.pollers, id)
+	delete(m.owners, id)
+	return nil
+}
+
+func (m *multiPoller) ListRepos() []string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.sortedIDs()
+}
+
+// Must be called while holding the lock.
+func (m *multiPoller) sortedIDs() []string {
+	ids := make([]string, 0, len(m.pollers))
+	for id := range m.pollers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (m *multiPoller) Status(id string) (Status, error) {
+	p, err := m.get(id)
+	if err != nil {
+		return Status{}, err
+	}
+	return p.Status(), nil
+}
+
+func (m *multiPoller) PauseRepo(id string) error {
+	p, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	p.Pause()
+	return nil
+}
+
+func (m *multiPoller) ResumeRepo(id string) error {
+	p, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	p.Resume()
+	return nil
+}
+
+func (m *multiPoller) Health() Health {
+	h := Health{
+		Failing: make([]string, 0),
+		Holding: make([]string, 0),
+	}
+	for _, id := range m.ListRepos() {
+		s, err := m.Status(id)
+		if err != nil {
+			continue
+		}
+		h.Total++
+		if s.Paused {
+			h.Paused++
+		} else if s.Running {
+			h.Running++
+		}
+		if s.LastError != nil {
+			h.Failing = append(h.Failing, id)
+		}
+		if s.Held > 0 {
+			h.Holding = append(h.Holding, id)
+		}
+	}
+	return h
+}
+
+func (m *multiPoller) get(id string) (Poller, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	p, ok := m.pollers[id]
+	if !ok {
+		return nil, ErrRepoNotFound
+	}
+	return p, nil
+}
+
+// Triggers a poll of every repo once its Interval has passed since it was last triggered. The pollers poll on their
+// own when they start, so the first trigger is an Interval after the repo is first seen by the scheduler. Every due
+// repo is triggered without waiting on any poll, so a slow repo never delays the others.
+func (m *multiPoller) schedule(stop chan struct{}) {
+	defer m.scheduler.Done()
+	next := make(map[string]time.Time)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		now := time.Now()
+		due := make([]*poller, 0)
+		var earliest time.Time
+		m.lock.RLock()
+		for id := range next {
+			if _, ok := m.pollers[id]; !ok {
+				delete(next, id)
+			}
+		}
+		for id, p := range m.pollers {
+			pp := p.(*poller)
+			t, ok := next[id]
+			if !ok {
+				t = now.Add(pp.config.Interval)
+			} else if !t.After(now) {
+				due = append(due, pp)
+				t = now.Add(pp.config.Interval)
+			}
+			next[id] = t
+			if earliest.IsZero() || t.Before(earliest) {
+				earliest = t
+			}
+		}
+		m.lock.RUnlock()
+		for _, p := range due {
+			p.Trigger()
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		wait := time.Hour
+		if !earliest.IsZero() {
+			wait = time.Until(earliest)
+		}
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-m.reschedule:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Get the keys of every RemoteSshKey matching the remote, in order.
+func selectSshKeys(keys []RemoteSshKey, remote string) []string {
+	if len(keys) == 0 {
+		return nil
+	}
+	ep, err := transport.NewEndpoint(remote)
+	if err != nil {
+		return nil
+	}
+	repo := path.Join(ep.Host, strings.TrimSuffix(strings.TrimPrefix(ep.Path, "/"), ".git"))
+
+	selected := make([]string, 0)
+	for _, k := range keys {
+		hostMatch, _ := path.Match(k.Remote, ep.Host)
+		repoMatch, _ := path.Match(k.Remote, repo)
+		if hostMatch || repoMatch {
+			selected = append(selected, k.SshKey)
+		}
+	}
+	return selected
+}
@@ -0,0 +1,208 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MultiTest struct {
+	serverSuite
+}
+
+func (s *MultiTest) TestStopEndsForwarding() {
+	// -- Given
+	//
+	m, err := gpoll.NewMultiPoller(map[string]gpoll.PollConfig{
+		"a": {Git: s.server.GitConfig(), Interval: 10 * time.Millisecond},
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(m.Start())
+	s.Eventually(forwarding, 5*time.Second, 10*time.Millisecond)
+
+	// -- When
+	//
+	m.Stop()
+
+	// -- Then
+	//
+	s.Eventually(func() bool {
+		return !forwarding()
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *MultiTest) TestStartFailureStopsStartedRepos() {
+	// -- Given
+	//
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	closed := l.Addr().String()
+	s.Require().NoError(l.Close())
+
+	unreachable := s.server.GitConfig()
+	unreachable.Remote = "http://" + closed + "/repo.git"
+	m, err := gpoll.NewMultiPoller(map[string]gpoll.PollConfig{
+		"a": {Git: s.server.GitConfig(), Interval: 10 * time.Millisecond},
+		"b": {Git: unreachable, Interval: 10 * time.Millisecond},
+	})
+	s.Require().NoError(err)
+
+	// -- When
+	//
+	err = m.Start()
+
+	// -- Then
+	//
+	s.Error(err)
+	s.Eventually(func() bool {
+		status, err := m.Status("a")
+		return err == nil && !status.Running
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Eventually(func() bool {
+		return !forwarding()
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *MultiTest) TestPausesAndResumesRepos() {
+	// -- Given
+	//
+	m, err := gpoll.NewMultiPoller(map[string]gpoll.PollConfig{
+		"a": {Git: s.server.GitConfig(), Interval: 10 * time.Millisecond},
+		"b": {Git: s.server.GitConfig(), Interval: 10 * time.Millisecond},
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(m.Start())
+	defer m.Stop()
+
+	// -- When
+	//
+	s.NoError(m.PauseRepo("a"))
+
+	// -- Then
+	//
+	status, err := m.Status("a")
+	s.NoError(err)
+	s.True(status.Paused)
+	health := m.Health()
+	s.Equal(2, health.Total)
+	s.Equal(1, health.Running)
+	s.Equal(1, health.Paused)
+	s.True(health.Healthy())
+
+	s.NoError(m.ResumeRepo("a"))
+	status, err = m.Status("a")
+	s.NoError(err)
+	s.False(status.Paused)
+	health = m.Health()
+	s.Equal(2, health.Running)
+	s.Equal(0, health.Paused)
+}
+
+func (s *MultiTest) TestHealthReportsFailingRepos() {
+	// -- Given
+	//
+	f := s.newFront(s.server)
+	defer f.Close()
+	failing := f.config
+	failing.Transport.OperationTimeout = 100 * time.Millisecond
+	m, err := gpoll.NewMultiPoller(map[string]gpoll.PollConfig{
+		"a": {Git: s.server.GitConfig(), Interval: 10 * time.Millisecond},
+		"b": {Git: failing, Interval: 10 * time.Millisecond},
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(m.Start())
+	defer m.Stop()
+
+	// -- When
+	//
+	f.hang()
+
+	// -- Then
+	//
+	s.Eventually(func() bool {
+		return len(m.Health().Failing) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	health := m.Health()
+	s.Equal([]string{"b"}, health.Failing)
+	s.False(health.Healthy())
+}
+
+func (s *MultiTest) TestUnknownRepoIsNotFound() {
+	// -- Given
+	//
+	m, err := gpoll.NewMultiPoller(map[string]gpoll.PollConfig{})
+	s.Require().NoError(err)
+
+	// -- When
+	//
+	_, statusErr := m.Status("missing")
+
+	// -- Then
+	//
+	s.Equal(gpoll.ErrRepoNotFound, statusErr)
+	s.Equal(gpoll.ErrRepoNotFound, m.PauseRepo("missing"))
+	s.Equal(gpoll.ErrRepoNotFound, m.ResumeRepo("missing"))
+	s.Equal(gpoll.ErrRepoNotFound, m.RemoveRepo("missing"))
+}
+
+func (s *MultiTest) TestManagesReposWhileCloning() {
+	// -- Given
+	//
+	f := s.newFront(s.server)
+	defer f.Close()
+	slow := f.config
+	slow.Transport.OperationTimeout = time.Second
+	m, err := gpoll.NewMultiPoller(map[string]gpoll.PollConfig{
+		"a": {Git: s.server.GitConfig(), Interval: 10 * time.Millisecond},
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(m.Start())
+	defer m.Stop()
+	f.hang()
+	added := make(chan error, 1)
+	go func() {
+		added <- m.AddRepo("b", gpoll.PollConfig{Git: slow, Interval: 10 * time.Millisecond})
+	}()
+	s.Eventually(func() bool {
+		return len(m.ListRepos()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// -- When
+	//
+	listed := make(chan []string, 1)
+	go func() {
+		_ = m.PauseRepo("a")
+		_ = m.Health()
+		listed <- m.ListRepos()
+	}()
+
+	// -- Then
+	//
+	select {
+	case ids := <-listed:
+		s.Equal([]string{"a", "b"}, ids)
+	case <-time.After(500 * time.Millisecond):
+		s.FailNow("blocked while the repo was cloning")
+	}
+	select {
+	case err := <-added:
+		s.Error(err)
+	case <-time.After(5 * time.Second):
+		s.FailNow("the clone did not time out")
+	}
+	s.Equal([]string{"a"}, m.ListRepos())
+}
+
+// Whether any poller is forwarding what it sends on its channel.
+func forwarding() bool {
+	buf := make([]byte, 1<<20)
+	return strings.Contains(string(buf[:runtime.Stack(buf, true)]), "(*poller).forward(")
+}
+
+func TestMulti(t *testing.T) {
+	suite.Run(t, new(MultiTest))
+}
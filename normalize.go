@@ -0,0 +1,63 @@
+package gpoll
+
+import (
+	"bytes"
+	"path"
+)
+
+type NormalizationConfig struct {
+	// Detect commits whose only changes normalize line endings or change .gitattributes, e.g. after a repo is
+	// renormalized, and mark them through CommitDiff.Normalization.
+	Detect bool
+
+	// Don't deliver commits whose only changes normalize line endings or change .gitattributes, since they rarely
+	// warrant any work downstream. Implies Detect.
+	Suppress bool
+}
+
+// Marks the commit if every change either only converts line endings between CRLF and LF or changes a .gitattributes
+// file.
+func (p *poller) detectNormalization(commit *CommitDiff) {
+	config := p.config.Normalization
+	if (!config.Detect && !config.Suppress) || len(commit.Changes) == 0 {
+		return
+	}
+
+	for _, c := range commit.Changes {
+		if path.Base(c.Filepath) == ".gitattributes" {
+			continue
+		}
+		if c.ChangeType != ChangeTypeUpdate {
+			return
+		}
+		same, err := p.sameContent(commit.From.Sha, commit.To.Sha, c.Filepath, normalizeLineEndings)
+		if err != nil {
+			p.onError(err)
+			return
+		}
+		if !same {
+			return
+		}
+	}
+	commit.Normalization = true
+}
+
+// Whether the file at the slash separated path relative to the root of the repo is the same in both commits once both
+// are normalized.
+func (p *poller) sameContent(from, to, fp string, normalize func(b []byte) []byte) (bool, error) {
+	p.repoLock.Lock()
+	defer p.repoLock.Unlock()
+	before, err := p.git.ReadFile(p.repo, from, fp)
+	if err != nil {
+		return false, err
+	}
+	after, err := p.git.ReadFile(p.repo, to, fp)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(normalize(before), normalize(after)), nil
+}
+
+func normalizeLineEndings(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+}
@@ -0,0 +1,88 @@
+package gpoll
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Emitted after every poll that fails because the remote can't be reached. Delivered state, Status and the replay
+// buffer keep being served from the local clone in the meantime, and diffing resumes once the remote is back.
+type Unreachable struct {
+	// When the remote first became unreachable.
+	Since time.Time
+
+	// How long the remote has been unreachable.
+	Downtime time.Duration
+
+	// The error from the last poll.
+	Err error
+}
+
+func (u Unreachable) EventType() EventType {
+	return EventTypeUnreachable
+}
+
+func (u Unreachable) String() string {
+	return fmt.Sprintf("remote unreachable for %s: %s", u.Downtime, u.Err.Error())
+}
+
+// Emitted on the first successful poll after the remote was unreachable.
+type Reachable struct {
+	// How long the remote was unreachable.
+	Downtime time.Duration
+}
+
+func (r Reachable) EventType() EventType {
+	return EventTypeReachable
+}
+
+func (r Reachable) String() string {
+	return fmt.Sprintf("remote reachable again after %s", r.Downtime)
+}
+
+// Checks whether the error was caused by not being able to reach the remote rather than e.g. bad auth.
+func isUnreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == context.DeadlineExceeded {
+		return true
+	}
+	switch e := err.(type) {
+	case net.Error:
+		return true
+	case *url.Error:
+		return isUnreachable(e.Err)
+	}
+	return false
+}
+
+// Tracks whether the remote is reachable based on the result of a poll, emitting Unreachable and Reachable events.
+func (p *poller) trackReachability(err error) {
+	p.lock.Lock()
+	since := p.unreachableSince
+	if isUnreachable(err) {
+		if since.IsZero() {
+			since = time.Now()
+			p.unreachableSince = since
+		}
+		p.lock.Unlock()
+		p.emit(Unreachable{
+			Since:    since,
+			Downtime: time.Since(since),
+			Err:      err,
+		})
+		return
+	}
+
+	p.unreachableSince = time.Time{}
+	p.lock.Unlock()
+	if !since.IsZero() && err == nil {
+		p.emit(Reachable{
+			Downtime: time.Since(since),
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type OfflineTest struct {
+	serverSuite
+}
+
+func (s *OfflineTest) TestReportsUnreachableRemote() {
+	// -- Given
+	//
+	f := s.newFront(s.server)
+	defer f.Close()
+	config := f.config
+	config.Transport.OperationTimeout = 100 * time.Millisecond
+	events := make(chan gpoll.Event, 100)
+	p := s.newPoller(gpoll.PollConfig{
+		Git: config,
+		HandleEvent: func(event gpoll.Event) {
+			select {
+			case events <- event:
+			default:
+			}
+		},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	f.hang()
+	unreachable := s.receiveEvent(events, gpoll.EventTypeUnreachable).(gpoll.Unreachable)
+	sha := s.commit("add a", map[string]string{"a.txt": "a"})
+	f.resume()
+
+	// -- Then
+	//
+	s.Error(unreachable.Err)
+	s.False(unreachable.Since.IsZero())
+	s.True(p.Status().Running)
+	reachable := s.receiveEvent(events, gpoll.EventTypeReachable).(gpoll.Reachable)
+	s.True(reachable.Downtime >= unreachable.Downtime)
+	s.Equal(sha, s.receive(c).To.Sha)
+}
+
+// Receive events until one of the type, failing the test if none is received in time.
+func (s *OfflineTest) receiveEvent(events chan gpoll.Event, t gpoll.EventType) gpoll.Event {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.EventType() == t {
+				return e
+			}
+		case <-timeout:
+			s.FailNow("timed out waiting for " + t.String())
+			return nil
+		}
+	}
+}
+
+func TestOffline(t *testing.T) {
+	suite.Run(t, new(OfflineTest))
+}
@@ -0,0 +1,79 @@
+package gpoll
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"sort"
+	"time"
+)
+
+// The order in which the commits of a poll are delivered.
+type CommitOrder int
+
+const (
+	// Deliver the commits along the first parent of the branch, oldest first. A merge commit is delivered as a single
+	// commit containing every change it merged, and the commits on the merged branch aren't delivered.
+	CommitOrderFirstParent CommitOrder = iota
+
+	// Deliver every new commit, including those on merged branches, with parents before their children.
+	CommitOrderTopological
+
+	// Deliver every new commit ordered by when it was committed, oldest first. Commits with the same time are in
+	// topological order.
+	CommitOrderCommitTime
+
+	// Deliver every new commit ordered by when it was authored, oldest first, e.g. to preserve the original order of
+	// rebased commits. Commits with the same time are in topological order.
+	CommitOrderAuthorTime
+)
+
+// Get every commit reachable from the to commit but not from the from commit, ordered by the order. Only used for
+// orders other than CommitOrderFirstParent.
+func orderedCommits(from, to *object.Commit, order CommitOrder) ([]*object.Commit, error) {
+	seen := make(map[plumbing.Hash]bool)
+	err := object.NewCommitPreorderIter(from, nil, nil).ForEach(func(c *object.Commit) error {
+		seen[c.Hash] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	commits, err := topologicalCommits(to, seen)
+	if err != nil {
+		return nil, err
+	}
+
+	var when func(c *object.Commit) time.Time
+	switch order {
+	case CommitOrderCommitTime:
+		when = func(c *object.Commit) time.Time { return c.Committer.When }
+	case CommitOrderAuthorTime:
+		when = func(c *object.Commit) time.Time { return c.Author.When }
+	default:
+		return commits, nil
+	}
+	sort.SliceStable(commits, func(i, j int) bool {
+		return when(commits[i]).Before(when(commits[j]))
+	})
+	return commits, nil
+}
+
+// Gets the commits reachable from c that haven't been seen, with parents before their children.
+func topologicalCommits(c *object.Commit, seen map[plumbing.Hash]bool) ([]*object.Commit, error) {
+	commits := make([]*object.Commit, 0)
+	var visit func(c *object.Commit) error
+	visit = func(c *object.Commit) error {
+		if seen[c.Hash] {
+			return nil
+		}
+		seen[c.Hash] = true
+		err := c.Parents().ForEach(visit)
+		if err != nil {
+			return err
+		}
+		commits = append(commits, c)
+		return nil
+	}
+	return commits, visit(c)
+}
@@ -0,0 +1,119 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+	"testing"
+	"time"
+)
+
+type OrderTest struct {
+	suite.Suite
+
+	storage *memory.Storage
+	tree    plumbing.Hash
+	commits map[string]*object.Commit
+}
+
+// Builds the history
+//
+//	base - main ------ merge
+//	     \            /
+//	      side1 - side2
+//
+// where side1 was authored last but committed first, as if rebased, and side2 was authored first.
+func (s *OrderTest) SetupTest() {
+	s.storage = memory.NewStorage()
+	s.commits = make(map[string]*object.Commit)
+	tree := s.storage.NewEncodedObject()
+	s.Require().NoError((&object.Tree{}).Encode(tree))
+	hash, err := s.storage.SetEncodedObject(tree)
+	s.Require().NoError(err)
+	s.tree = hash
+
+	s.add("base", 0, 0)
+	s.add("main", 3, 3, "base")
+	s.add("side1", 1, 5, "base")
+	s.add("side2", 2, 1, "side1")
+	s.add("merge", 4, 4, "main", "side2")
+}
+
+// Add a commit committed and authored the number of minutes after the epoch.
+func (s *OrderTest) add(name string, committed, authored int, parents ...string) {
+	epoch := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &object.Commit{
+		Author:    object.Signature{Name: "jane", When: epoch.Add(time.Duration(authored) * time.Minute)},
+		Committer: object.Signature{Name: "jane", When: epoch.Add(time.Duration(committed) * time.Minute)},
+		Message:   name,
+		TreeHash:  s.tree,
+	}
+	for _, p := range parents {
+		c.ParentHashes = append(c.ParentHashes, s.commits[p].Hash)
+	}
+	obj := s.storage.NewEncodedObject()
+	s.Require().NoError(c.Encode(obj))
+	hash, err := s.storage.SetEncodedObject(obj)
+	s.Require().NoError(err)
+	commit, err := object.GetCommit(s.storage, hash)
+	s.Require().NoError(err)
+	s.commits[name] = commit
+}
+
+func (s *OrderTest) ordered(from, to string, order gpoll.CommitOrder) []string {
+	commits, err := gpoll.OrderedCommits(s.commits[from], s.commits[to], order)
+	s.Require().NoError(err)
+	names := make([]string, 0, len(commits))
+	for _, c := range commits {
+		names = append(names, c.Message)
+	}
+	return names
+}
+
+func (s *OrderTest) TestOrders() {
+	cases := []struct {
+		name     string
+		order    gpoll.CommitOrder
+		expected []string
+	}{
+		{name: "topological", order: gpoll.CommitOrderTopological, expected: []string{"main", "side1", "side2", "merge"}},
+		{name: "commit time", order: gpoll.CommitOrderCommitTime, expected: []string{"side1", "side2", "main", "merge"}},
+		{name: "author time", order: gpoll.CommitOrderAuthorTime, expected: []string{"side2", "main", "merge", "side1"}},
+	}
+	for _, tc := range cases {
+		s.Run(tc.name, func() {
+			s.Equal(tc.expected, s.ordered("base", "merge", tc.order))
+		})
+	}
+}
+
+func (s *OrderTest) TestExcludesCommitsAlreadyReachable() {
+	// -- When
+	//
+	ordered := s.ordered("side1", "merge", gpoll.CommitOrderTopological)
+
+	// -- Then
+	//
+	s.Equal([]string{"main", "side2", "merge"}, ordered)
+}
+
+func (s *OrderTest) TestKeepsTopologicalOrderForSameTime() {
+	// -- Given
+	//
+	s.add("child", 4, 4, "merge")
+	s.add("grandchild", 4, 4, "child")
+
+	// -- When
+	//
+	ordered := s.ordered("merge", "grandchild", gpoll.CommitOrderCommitTime)
+
+	// -- Then
+	//
+	s.Equal([]string{"child", "grandchild"}, ordered)
+}
+
+func TestOrder(t *testing.T) {
+	suite.Run(t, new(OrderTest))
+}
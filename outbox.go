@@ -0,0 +1,326 @@
+package gpoll
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const defaultOutboxRetryInterval = 5 * time.Second
+
+// Delivers a commit from the outbox downstream. The commit stays in the outbox and is retried until nil is returned.
+type OutboxDeliverFunc func(ctx context.Context, commit CommitDiff) error
+
+type OutboxConfig struct {
+	// Where delivered commits are kept until they are acknowledged e.g. NewFileOutboxStore. The HandleCommit and
+	// Handlers are called for the commits in the outbox rather than as they're delivered, so a commit is handled even
+	// if the process crashes before its handlers are called. If not set, there is no outbox.
+	Store OutboxStore
+
+	// Delivers each commit in the outbox, in order, once its handlers were called. A commit is acknowledged and removed
+	// from the outbox once it returns nil, e.g. Webhook.Deliver. If not set, a commit is acknowledged once its handlers
+	// were called.
+	Deliver OutboxDeliverFunc
+
+	// How long to wait before retrying a commit that failed to deliver. Defaults to 5s.
+	RetryInterval time.Duration
+}
+
+// Durable storage of the commits in the outbox.
+type OutboxStore interface {
+	// Add the commit to the end of the outbox.
+	Put(commit CommitDiff) error
+
+	// Get every commit that hasn't been acknowledged, oldest first.
+	Pending() ([]CommitDiff, error)
+
+	// Remove the commit with the event ID from the outbox.
+	Ack(id string) error
+}
+
+// The number of acknowledged commits kept in the log of a file outbox before it's compacted, unless more commits are
+// pending.
+const outboxCompactAfter = 64
+
+// Create an OutboxStore persisted to the file at fp so commits that weren't acknowledged survive crashes and restarts.
+// Every Put and Ack is appended to the file as a line of JSON and synced to disk before it returns. The file is created
+// if it doesn't exist, and rewritten without the acknowledged commits once they make up most of it.
+//
+// The log is a plain file rather than an embedded database such as BoltDB or SQLite so gpoll takes on no further
+// dependencies, and no cgo in the case of SQLite. An outbox backed by a database can be used by implementing
+// OutboxStore.
+func NewFileOutboxStore(fp string) (OutboxStore, error) {
+	f := &fileOutboxStore{
+		fp:      fp,
+		entries: make([]CommitDiff, 0),
+	}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+type fileOutboxStore struct {
+	lock    sync.Mutex
+	fp      string
+	entries []CommitDiff
+	// The log appended to, and its size after the last complete record.
+	file *os.File
+	size int64
+	// The number of acknowledged commits in the log.
+	acked int
+}
+
+// A line of the log of a file outbox. Exactly one of Put and Ack is set.
+type outboxRecord struct {
+	Put *CommitDiff `json:"put,omitempty"`
+	Ack string      `json:"ack,omitempty"`
+}
+
+// Replays the log. A last line without a newline was torn by a crash mid-write, so it's dropped. Outboxes written as a
+// single JSON array by earlier versions are rewritten as a log.
+func (f *fileOutboxStore) load() error {
+	b, err := ioutil.ReadFile(f.fp)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &f.entries); err != nil {
+			return err
+		}
+		return f.compact()
+	}
+
+	complete := bytes.LastIndexByte(b, '\n') + 1
+	for _, line := range bytes.Split(b[:complete], []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var r outboxRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			return fmt.Errorf("corrupt outbox %s: %s", f.fp, err.Error())
+		}
+		if r.Put != nil {
+			f.entries = append(f.entries, *r.Put)
+		} else if f.remove(r.Ack) {
+			f.acked++
+		}
+	}
+	if complete < len(b) {
+		return os.Truncate(f.fp, int64(complete))
+	}
+	return nil
+}
+
+// Opens the log for appending, creating it if it doesn't exist.
+func (f *fileOutboxStore) open() error {
+	_, err := os.Stat(f.fp)
+	created := os.IsNotExist(err)
+	file, err := os.OpenFile(f.fp, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	if created {
+		if err := syncDir(filepath.Dir(f.fp)); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+func (f *fileOutboxStore) Put(commit CommitDiff) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.append(outboxRecord{Put: &commit}); err != nil {
+		return err
+	}
+	f.entries = append(f.entries, commit)
+	return nil
+}
+
+func (f *fileOutboxStore) Pending() ([]CommitDiff, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	pending := make([]CommitDiff, len(f.entries))
+	copy(pending, f.entries)
+	return pending, nil
+}
+
+func (f *fileOutboxStore) Ack(id string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	found := false
+	for _, c := range f.entries {
+		if c.ID == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+	if err := f.append(outboxRecord{Ack: id}); err != nil {
+		return err
+	}
+	f.remove(id)
+	f.acked++
+	if f.acked > outboxCompactAfter && f.acked > len(f.entries) {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+		cerr := f.compact()
+		if err := f.open(); err != nil {
+			return err
+		}
+		return cerr
+	}
+	return nil
+}
+
+// Removes the commit with the event ID, returning whether it was pending.
+func (f *fileOutboxStore) remove(id string) bool {
+	for i, c := range f.entries {
+		if c.ID == id {
+			f.entries = append(f.entries[:i], f.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Appends the record to the log and syncs it. A record that fails to be written in full is truncated away so the next
+// one starts on a line of its own.
+func (f *fileOutboxStore) append(r outboxRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	n, err := f.file.Write(append(b, '\n'))
+	if err == nil {
+		err = f.file.Sync()
+	}
+	if err != nil {
+		if n > 0 {
+			_ = f.file.Truncate(f.size)
+		}
+		return err
+	}
+	f.size += int64(n)
+	return nil
+}
+
+// Rewrites the log with only the pending commits.
+func (f *fileOutboxStore) compact() error {
+	var buf bytes.Buffer
+	for i := range f.entries {
+		b, err := json.Marshal(outboxRecord{Put: &f.entries[i]})
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+	if err := writeFileAtomic(f.fp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	f.acked = 0
+	return nil
+}
+
+// Adds the delivered commit to the outbox and wakes the drain, returning whether it was added.
+func (p *poller) putOutbox(commit CommitDiff) bool {
+	if p.config.Outbox.Store == nil {
+		return false
+	}
+	if err := p.config.Outbox.Store.Put(commit); err != nil {
+		p.onError(err)
+		return false
+	}
+	select {
+	case p.outboxSignal <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Dispatches the commits in the outbox to the handlers and then the Deliver function, oldest first, until the poller
+// stops. Commits left over from a previous run are dispatched first, so their handlers may be called again. A commit
+// that fails to deliver is retried after the RetryInterval without calling its handlers again, holding back every
+// commit after it.
+func (p *poller) drainOutbox(done chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.goroutines.Go("outbox-cancel", func() {
+		<-done
+		cancel()
+	})
+
+	for {
+		if err := p.drainOutboxOnce(ctx); err != nil {
+			p.onError(err)
+			// Only the timer wakes a failing outbox so new commits don't cause retries in quick succession.
+			t := time.NewTimer(p.config.Outbox.RetryInterval)
+			select {
+			case <-t.C:
+				continue
+			case <-done:
+				t.Stop()
+				return
+			}
+		}
+
+		select {
+		case <-p.outboxSignal:
+		case <-done:
+			return
+		}
+	}
+}
+
+func (p *poller) drainOutboxOnce(ctx context.Context) error {
+	config := p.config.Outbox
+	pending, err := config.Store.Pending()
+	if err != nil {
+		return err
+	}
+	for _, c := range pending {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !p.outboxHandled[c.ID] {
+			p.receipts.handled(c.ID, p.handleCommit(c))
+			p.auditDelivery(c)
+			p.outboxHandled[c.ID] = true
+		}
+		if config.Deliver != nil {
+			if err := config.Deliver(ctx, c); err != nil {
+				p.receipts.deliveredTo(c.ID, "outbox", p.redact(err))
+				return err
+			}
+			p.receipts.deliveredTo(c.ID, "outbox", nil)
+		}
+		if err := config.Store.Ack(c.ID); err != nil {
+			return err
+		}
+		delete(p.outboxHandled, c.ID)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package gpoll_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type OutboxTest struct {
+	serverSuite
+
+	dir string
+	fp  string
+}
+
+func (s *OutboxTest) SetupTest() {
+	s.serverSuite.SetupTest()
+	dir, err := ioutil.TempDir("", "gpoll")
+	s.Require().NoError(err)
+	s.dir = dir
+	s.fp = filepath.Join(dir, "outbox")
+}
+
+func (s *OutboxTest) TearDownTest() {
+	s.serverSuite.TearDownTest()
+	_ = os.RemoveAll(s.dir)
+}
+
+func (s *OutboxTest) TestFileStoreSurvivesReopen() {
+	// -- Given
+	//
+	store := s.open()
+	s.NoError(store.Put(outboxCommit("a")))
+	s.NoError(store.Put(outboxCommit("b")))
+
+	// -- When
+	//
+	s.NoError(store.Ack("a"))
+
+	// -- Then
+	//
+	s.Equal([]string{"b"}, pendingIDs(s.open()))
+}
+
+func (s *OutboxTest) TestFileStoreDropsTornWrite() {
+	// -- Given
+	//
+	store := s.open()
+	s.NoError(store.Put(outboxCommit("a")))
+	f, err := os.OpenFile(s.fp, os.O_WRONLY|os.O_APPEND, 0600)
+	s.Require().NoError(err)
+	_, err = f.WriteString(`{"put":{"ID":"b"`)
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+
+	// -- When
+	//
+	store = s.open()
+	s.NoError(store.Put(outboxCommit("c")))
+
+	// -- Then
+	//
+	s.Equal([]string{"a", "c"}, pendingIDs(s.open()))
+}
+
+func (s *OutboxTest) TestFileStoreDropsTornTrailingLine() {
+	cases := []struct {
+		name    string
+		torn    string
+		pending []string
+	}{
+		{name: "put", torn: `{"put":{"ID":"c","To":{"Sha":"ab`, pending: []string{"a", "b"}},
+		{name: "ack", torn: `{"ack":"a`, pending: []string{"a", "b"}},
+		{name: "brace", torn: `{`, pending: []string{"a", "b"}},
+	}
+	for _, tc := range cases {
+		s.Run(tc.name, func() {
+			// -- Given
+			//
+			s.Require().NoError(os.RemoveAll(s.fp))
+			store := s.open()
+			s.NoError(store.Put(outboxCommit("a")))
+			s.NoError(store.Put(outboxCommit("b")))
+			f, err := os.OpenFile(s.fp, os.O_WRONLY|os.O_APPEND, 0600)
+			s.Require().NoError(err)
+			_, err = f.WriteString(tc.torn)
+			s.Require().NoError(err)
+			s.Require().NoError(f.Close())
+
+			// -- When
+			//
+			store = s.open()
+
+			// -- Then
+			//
+			s.Equal(tc.pending, pendingIDs(store))
+			b, err := ioutil.ReadFile(s.fp)
+			s.Require().NoError(err)
+			s.True(bytes.HasSuffix(b, []byte("\n")))
+			s.NotContains(string(b), tc.torn+"\n")
+			s.NoError(store.Ack("a"))
+			s.Equal([]string{"b"}, pendingIDs(s.open()))
+		})
+	}
+}
+
+func (s *OutboxTest) TestFileStoreDropsTornFirstLine() {
+	// -- Given
+	//
+	s.Require().NoError(ioutil.WriteFile(s.fp, []byte(`{"put":{"ID":"a"`), 0600))
+
+	// -- When
+	//
+	store := s.open()
+	s.NoError(store.Put(outboxCommit("b")))
+
+	// -- Then
+	//
+	s.Equal([]string{"b"}, pendingIDs(s.open()))
+}
+
+func (s *OutboxTest) TestFileStoreCompactsAcknowledgedCommits() {
+	// -- Given
+	//
+	store := s.open()
+	s.NoError(store.Put(outboxCommit("pending")))
+
+	// -- When
+	//
+	for i := 0; i < 200; i++ {
+		id := fmt.Sprintf("acked-%d", i)
+		s.NoError(store.Put(outboxCommit(id)))
+		s.NoError(store.Ack(id))
+	}
+
+	// -- Then
+	//
+	b, err := ioutil.ReadFile(s.fp)
+	s.Require().NoError(err)
+	s.Less(bytes.Count(b, []byte("\n")), 2*65)
+	s.Equal([]string{"pending"}, pendingIDs(s.open()))
+	_, err = os.Stat(s.fp + ".tmp")
+	s.True(os.IsNotExist(err))
+}
+
+func (s *OutboxTest) TestFileStoreReadsArrayOutbox() {
+	// -- Given
+	//
+	s.Require().NoError(ioutil.WriteFile(s.fp, []byte(`[{"ID":"a"},{"ID":"b"}]`), 0600))
+
+	// -- When
+	//
+	store := s.open()
+	s.NoError(store.Ack("a"))
+
+	// -- Then
+	//
+	s.Equal([]string{"b"}, pendingIDs(s.open()))
+}
+
+func (s *OutboxTest) TestDispatchesHandlersFromOutbox() {
+	// -- Given
+	//
+	store := s.open()
+	s.NoError(store.Put(gpoll.CommitDiff{ID: "left-over", To: gpoll.Commit{Sha: "left-over"}}))
+	handled := make(chan string, 10)
+	p := s.newPoller(gpoll.PollConfig{
+		HandleCommit: func(commit gpoll.CommitDiff) {
+			handled <- commit.To.Sha
+		},
+		Outbox: gpoll.OutboxConfig{Store: store},
+	})
+	s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	sha := s.commit("add a", map[string]string{"a.txt": "a"})
+
+	// -- Then
+	//
+	shas := []string{s.receiveHandled(handled)}
+	for shas[len(shas)-1] != sha {
+		shas = append(shas, s.receiveHandled(handled))
+	}
+	s.Contains(shas, "left-over")
+	s.Eventually(func() bool {
+		return len(pendingIDs(store)) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *OutboxTest) open() gpoll.OutboxStore {
+	store, err := gpoll.NewFileOutboxStore(s.fp)
+	s.Require().NoError(err)
+	return store
+}
+
+func (s *OutboxTest) receiveHandled(handled chan string) string {
+	select {
+	case sha := <-handled:
+		return sha
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for a handler")
+	}
+	return ""
+}
+
+func outboxCommit(id string) gpoll.CommitDiff {
+	return gpoll.CommitDiff{ID: id}
+}
+
+func pendingIDs(store gpoll.OutboxStore) []string {
+	pending, _ := store.Pending()
+	ids := make([]string, 0, len(pending))
+	for _, c := range pending {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func TestOutbox(t *testing.T) {
+	suite.Run(t, new(OutboxTest))
+}
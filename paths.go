@@ -0,0 +1,73 @@
+package gpoll
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type FilepathMode int
+
+const (
+	// Paths are absolute, joining the path within the repo onto the CloneDirectory, or the Standby Directory once the
+	// standby is promoted.
+	FilepathModeCloneAbsolute FilepathMode = iota
+
+	// Paths are relative to the root of the repo. Use this when nothing is written to the CloneDirectory e.g. when the
+	// repo is kept in memory.
+	FilepathModeRepoRelative
+)
+
+type FilepathSeparator int
+
+const (
+	// Paths use the separator of the OS the poller is running on e.g. a backslash on Windows.
+	SeparatorNative FilepathSeparator = iota
+
+	// Paths always use a forward slash regardless of the OS, matching how git itself stores paths.
+	SeparatorSlash
+)
+
+// Format the slash separated path relative to the root of the repo for use in a FileChange.
+func (p *poller) formatPath(rel string) string {
+	fp := filepath.FromSlash(rel)
+	if p.config.FilepathMode == FilepathModeCloneAbsolute {
+		fp = filepath.Join(p.cloneDirectory(), fp)
+	}
+	if p.config.FilepathSeparator == SeparatorSlash {
+		return filepath.ToSlash(fp)
+	}
+	return fp
+}
+
+// The inverse of formatPath. Returns the slash separated path relative to the root of the repo.
+func (p *poller) relativePath(fp string) string {
+	if p.config.FilepathMode == FilepathModeRepoRelative {
+		return filepath.ToSlash(fp)
+	}
+	rel, err := filepath.Rel(p.cloneDirectory(), filepath.FromSlash(fp))
+	if err != nil {
+		return filepath.ToSlash(fp)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Expands a leading ~ or %USERPROFILE% to the user's home directory and converts the path to use the OS separator.
+func expandHome(fp string) string {
+	var rest string
+	switch {
+	case fp == "~":
+	case strings.HasPrefix(fp, "~/"), strings.HasPrefix(fp, `~\`):
+		rest = fp[2:]
+	case strings.HasPrefix(strings.ToUpper(fp), "%USERPROFILE%"):
+		rest = strings.TrimLeft(fp[len("%USERPROFILE%"):], `/\`)
+	default:
+		return filepath.FromSlash(fp)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.FromSlash(fp)
+	}
+	return filepath.Join(home, filepath.FromSlash(rest))
+}
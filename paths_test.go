@@ -0,0 +1,109 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type PathsTest struct {
+	serverSuite
+}
+
+func (s *PathsTest) TestSlashSeparator() {
+	// -- Given
+	//
+	dir := filepath.Join(os.TempDir(), "clone")
+	config := s.server.GitConfig()
+	config.CloneDirectory = dir
+	p := s.newPoller(gpoll.PollConfig{Git: config, FilepathSeparator: gpoll.SeparatorSlash})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	s.commit("add a", map[string]string{"dir/a.txt": "a"})
+
+	// -- Then
+	//
+	commit := s.receive(c)
+	s.Require().Len(commit.Changes, 1)
+	s.Equal(filepath.ToSlash(filepath.Join(dir, "dir", "a.txt")), commit.Changes[0].Filepath)
+}
+
+func (s *PathsTest) TestNativeSeparator() {
+	// -- Given
+	//
+	dir := filepath.Join(os.TempDir(), "clone")
+	config := s.server.GitConfig()
+	config.CloneDirectory = dir
+	p := s.newPoller(gpoll.PollConfig{Git: config})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	s.commit("add a", map[string]string{"dir/a.txt": "a"})
+
+	// -- Then
+	//
+	commit := s.receive(c)
+	s.Require().Len(commit.Changes, 1)
+	s.Equal(filepath.Join(dir, "dir", "a.txt"), commit.Changes[0].Filepath)
+}
+
+func (s *PathsTest) TestRepoRelativePaths() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{
+		FilepathMode:      gpoll.FilepathModeRepoRelative,
+		FilepathSeparator: gpoll.SeparatorSlash,
+		PriorityPaths:     []string{"dir"},
+		Debounce:          time.Hour,
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	sha := s.commit("add a", map[string]string{"dir/a.txt": "a"})
+
+	// -- Then
+	//
+	commit := s.receive(c)
+	s.Require().Len(commit.Changes, 1)
+	s.Equal("dir/a.txt", commit.Changes[0].Filepath)
+	change, ok := p.LastChange("dir/a.txt")
+	s.True(ok)
+	s.Equal(sha, change.Commit.Sha)
+}
+
+func (s *PathsTest) TestExpandHome() {
+	// -- Given
+	//
+	home, err := os.UserHomeDir()
+	s.Require().NoError(err)
+
+	// -- When
+	//
+	tilde := gpoll.ExpandHome("~")
+	slash := gpoll.ExpandHome("~/.ssh/id_rsa")
+	backslash := gpoll.ExpandHome(`~\.ssh\id_rsa`)
+	profile := gpoll.ExpandHome(`%userprofile%\.ssh\id_rsa`)
+	other := gpoll.ExpandHome("keys/~/id_rsa")
+
+	// -- Then
+	//
+	s.Equal(home, tilde)
+	s.Equal(filepath.Join(home, ".ssh", "id_rsa"), slash)
+	s.Equal(filepath.Join(home, `.ssh\id_rsa`), backslash)
+	s.Equal(filepath.Join(home, `.ssh\id_rsa`), profile)
+	s.Equal(filepath.FromSlash("keys/~/id_rsa"), other)
+}
+
+func TestPaths(t *testing.T) {
+	suite.Run(t, new(PathsTest))
+}
@@ -0,0 +1,73 @@
+package gpoll
+
+import "context"
+
+// Stop delivery at the revision, which is either a sha or a tag. Commits up to and including the revision are still
+// delivered, after which nothing is delivered until Unpin is called. While pinned the remote is still polled so Status
+// reports how far behind it delivery is. Pinning does not change what has already been delivered, so pinning to a
+// delivered revision holds everything after the last delivered commit. Returns ErrNotStarted if the poller hasn't been
+// started.
+func (p *poller) PinTo(revision string) (err error) {
+	defer func() {
+		p.auditOperation("pin", map[string]string{"revision": revision}, err)
+	}()
+	sha, err := p.resolveRevision(revision)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.pinnedTo = sha
+	return nil
+}
+
+func (p *poller) resolveRevision(revision string) (string, error) {
+	p.repoLock.Lock()
+	defer p.repoLock.Unlock()
+	if p.repo == nil {
+		return "", ErrNotStarted
+	}
+	c, err := p.git.ResolveRevision(p.repo, revision)
+	if err != nil {
+		return "", err
+	}
+	return c.Hash.String(), nil
+}
+
+// Resume delivery after a call to PinTo. Commits seen while pinned are delivered immediately.
+func (p *poller) Unpin() {
+	p.lock.Lock()
+	p.pinnedTo = ""
+	p.lock.Unlock()
+	p.auditOperation("unpin", nil, nil)
+	p.Trigger()
+}
+
+// Delivers the pending commits, only up to and including the pinned commit while pinned, returning the commits left
+// pending.
+func (p *poller) deliverPending(ctx context.Context, pending []CommitDiff) []CommitDiff {
+	n := len(pending)
+	p.lock.RLock()
+	if p.pinnedTo != "" {
+		n = 0
+		for i, c := range pending {
+			if c.To.Sha == p.pinnedTo {
+				n = i + 1
+				break
+			}
+		}
+	}
+	p.lock.RUnlock()
+	if n > 0 {
+		// A commit that couldn't be sent is dropped along with those after it since the poller is stopping.
+		_ = p.deliver(ctx, pending[:n])
+	}
+	return append(pending[:0], pending[n:]...)
+}
+
+func (p *poller) recordLag(lag int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.lag = lag
+}
@@ -0,0 +1,81 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type PinTest struct {
+	serverSuite
+}
+
+func (s *PinTest) TestRequiresStart() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{})
+
+	// -- When
+	//
+	err := p.PinTo("HEAD")
+
+	// -- Then
+	//
+	s.Equal(gpoll.ErrNotStarted, err)
+	p.Unpin()
+	s.Equal(gpoll.ErrNotStarted, p.PinTo("HEAD"))
+}
+
+func (s *PinTest) TestDeliversUpToPinnedRevision() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{
+		Debounce:      time.Hour,
+		PriorityPaths: []string{"urgent/*"},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+	first := s.commit("add a", map[string]string{"a.txt": "a"})
+	s.commit("add b", map[string]string{"b.txt": "b"})
+	s.Eventually(func() bool {
+		return p.Status().Lag == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// -- When
+	//
+	s.NoError(p.PinTo(first))
+	s.commit("add urgent", map[string]string{"urgent/c.txt": "c"})
+
+	// -- Then
+	//
+	commit := s.receive(c)
+	s.Equal(first, commit.To.Sha)
+	s.Equal("a.txt", filepath.Base(commit.Changes[0].Filepath))
+	s.receiveNone(c, 200*time.Millisecond)
+	s.Equal(2, p.Status().Lag)
+}
+
+func (s *PinTest) TestUnpinDeliversHeldCommits() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{})
+	c := s.start(p)
+	defer p.StopAndWait()
+	s.NoError(p.PinTo("HEAD"))
+	sha := s.commit("add a", map[string]string{"a.txt": "a"})
+	s.receiveNone(c, 200*time.Millisecond)
+
+	// -- When
+	//
+	p.Unpin()
+
+	// -- Then
+	//
+	s.Equal(sha, s.receive(c).To.Sha)
+}
+
+func TestPin(t *testing.T) {
+	suite.Run(t, new(PinTest))
+}
@@ -0,0 +1,376 @@
+package gpoll
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// The version of the plugin protocol spoken by this package.
+const PluginProtocolVersion = 1
+
+const (
+	PluginMethodHandle = "handle"
+	PluginMethodFilter = "filter"
+)
+
+var ErrPluginMethodUnsupported = errors.New("the plugin doesn't support the method")
+
+type PluginConfig struct {
+	// The path to the executable followed by its arguments. Required.
+	Command []string
+
+	// Environment variables set for the executable on top of those of the current process, as KEY=value.
+	Env []string
+
+	// How long the executable may take to complete the handshake, read a request or answer it before it is killed.
+	// Defaults to 30s.
+	Timeout time.Duration
+
+	// Where the stderr of the executable is written, e.g. for its logs. Defaults to the stderr of the current process.
+	Stderr io.Writer
+
+	// Called when a commit fails to be handled or a change fails to be filtered.
+	OnError func(err error)
+}
+
+// An external executable extending the poller as a handler or filter, so teams can write extensions in any language.
+//
+// The executable speaks newline delimited JSON. On start it writes a handshake to stdout declaring the protocol version
+// and the methods it supports e.g. {"protocol":1,"methods":["handle","filter"]}. Requests are then written to its
+// stdin one at a time, each with an id and a method. A handle request carries a commit, a JSON encoded CommitDiff, and a
+// filter request a change, a JSON encoded FileChange. The executable answers each request with a single line carrying
+// the same id, whether a filtered change is included and an error message if it failed e.g.
+// {"id":2,"include":true}. The executable is started again on the next request if it exits or times out. Use
+// ServePlugin to write plugins in Go.
+//
+// Plugins run out of process rather than as in-process WASM modules. Sandboxing untrusted WASM would need a WASM
+// runtime, which gpoll doesn't depend on. Limit the resources of a plugin through its Command instead, e.g. by starting
+// it through prlimit or in a container, while every request is bounded by the Timeout.
+type Plugin struct {
+	config PluginConfig
+
+	lock    sync.Mutex
+	process *pluginProcess
+	methods map[string]bool
+	nextID  uint64
+}
+
+// The request written to the stdin of a plugin.
+type PluginRequest struct {
+	ID     uint64      `json:"id"`
+	Method string      `json:"method"`
+	Commit *CommitDiff `json:"commit,omitempty"`
+	Change *FileChange `json:"change,omitempty"`
+}
+
+// The response a plugin writes to its stdout for every request.
+type PluginResponse struct {
+	ID      uint64 `json:"id"`
+	Include bool   `json:"include,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// The first line a plugin writes to its stdout.
+type PluginHandshake struct {
+	Protocol int      `json:"protocol"`
+	Methods  []string `json:"methods"`
+}
+
+type pluginProcess struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan []byte
+	// Closed once stdout is closed e.g. because the executable exited.
+	done chan struct{}
+	// Closed once the executable is being stopped.
+	quit chan struct{}
+}
+
+// Create a Plugin from config, starting the executable and completing the handshake.
+func NewPlugin(config PluginConfig) (*Plugin, error) {
+	if len(config.Command) == 0 {
+		return nil, errors.New("a plugin requires a command")
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 30 * time.Second
+	}
+	if config.Stderr == nil {
+		config.Stderr = os.Stderr
+	}
+	p := &Plugin{config: config}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if err := p.start(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Handle the commit through the plugin. Use as the HandleCommitContext of a PollConfig or the Handle of a Handler.
+func (p *Plugin) HandleCommit(ctx context.Context, commit CommitDiff) {
+	if _, err := p.call(ctx, PluginRequest{Method: PluginMethodHandle, Commit: &commit}); err != nil {
+		p.onError(err)
+	}
+}
+
+// Whether the plugin includes the change. Changes are left out if the plugin fails. Use as the FileChangeFilter of a
+// PollConfig.
+func (p *Plugin) FilterChange(change FileChange) bool {
+	resp, err := p.call(context.Background(), PluginRequest{Method: PluginMethodFilter, Change: &change})
+	if err != nil {
+		p.onError(err)
+		return false
+	}
+	return resp.Include
+}
+
+// Stop the executable.
+func (p *Plugin) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.process == nil {
+		return nil
+	}
+	err := p.process.stop(p.config.Timeout)
+	p.process = nil
+	return err
+}
+
+func (p *Plugin) call(ctx context.Context, req PluginRequest) (*PluginResponse, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.process == nil {
+		if err := p.start(); err != nil {
+			return nil, err
+		}
+	}
+	if !p.methods[req.Method] {
+		return nil, ErrPluginMethodUnsupported
+	}
+
+	p.nextID++
+	req.ID = p.nextID
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.write(ctx, append(b, '\n')); err != nil {
+		return nil, err
+	}
+	line, err := p.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &PluginResponse{}
+	if err := json.Unmarshal(line, resp); err != nil {
+		p.kill()
+		return nil, fmt.Errorf("plugin %s sent an invalid response: %s", p.config.Command[0], err.Error())
+	}
+	if resp.ID != req.ID {
+		p.kill()
+		return nil, fmt.Errorf("plugin %s answered request %d rather than %d", p.config.Command[0], resp.ID, req.ID)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("plugin %s failed to %s: %s", p.config.Command[0], req.Method, resp.Error)
+	}
+	return resp, nil
+}
+
+// Starts the executable and completes the handshake. Must be called while holding the lock.
+func (p *Plugin) start() error {
+	cmd := exec.Command(p.config.Command[0], p.config.Command[1:]...)
+	cmd.Env = append(os.Environ(), p.config.Env...)
+	cmd.Stderr = p.config.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	process := &pluginProcess{
+		cmd:   cmd,
+		stdin: stdin,
+		lines: make(chan []byte),
+		done:  make(chan struct{}),
+		quit:  make(chan struct{}),
+	}
+	go process.scan(stdout)
+	p.process = process
+
+	line, err := p.read(context.Background())
+	if err != nil {
+		return err
+	}
+	handshake := PluginHandshake{}
+	if err := json.Unmarshal(line, &handshake); err != nil || handshake.Protocol != PluginProtocolVersion {
+		p.kill()
+		return fmt.Errorf("plugin %s doesn't speak protocol version %d", p.config.Command[0], PluginProtocolVersion)
+	}
+	p.methods = make(map[string]bool, len(handshake.Methods))
+	for _, m := range handshake.Methods {
+		p.methods[m] = true
+	}
+	return nil
+}
+
+// Writes to the stdin of the executable, killing it if the write fails, times out or the context is done, e.g. because
+// the executable stopped reading and the pipe is full. Must be called while holding the lock.
+func (p *Plugin) write(ctx context.Context, b []byte) error {
+	written := make(chan error, 1)
+	stdin := p.process.stdin
+	go func() {
+		_, err := stdin.Write(b)
+		written <- err
+	}()
+	t := time.NewTimer(p.config.Timeout)
+	defer t.Stop()
+	select {
+	case err := <-written:
+		if err != nil {
+			p.kill()
+		}
+		return err
+	case <-t.C:
+		// Killing the executable fails the write, ending the goroutine.
+		p.kill()
+		return fmt.Errorf("plugin %s timed out after %s", p.config.Command[0], p.config.Timeout)
+	case <-ctx.Done():
+		p.kill()
+		return ctx.Err()
+	}
+}
+
+// Reads the next line written by the executable, killing it if it exits, times out or the context is done. Must be
+// called while holding the lock.
+func (p *Plugin) read(ctx context.Context) ([]byte, error) {
+	t := time.NewTimer(p.config.Timeout)
+	defer t.Stop()
+	select {
+	case line := <-p.process.lines:
+		return line, nil
+	case <-p.process.done:
+		p.kill()
+		return nil, fmt.Errorf("plugin %s exited", p.config.Command[0])
+	case <-t.C:
+		p.kill()
+		return nil, fmt.Errorf("plugin %s timed out after %s", p.config.Command[0], p.config.Timeout)
+	case <-ctx.Done():
+		p.kill()
+		return nil, ctx.Err()
+	}
+}
+
+// Stops the executable so it is started again on the next request. Must be called while holding the lock.
+func (p *Plugin) kill() {
+	_ = p.process.cmd.Process.Kill()
+	_ = p.process.stop(p.config.Timeout)
+	p.process = nil
+}
+
+func (p *Plugin) onError(err error) {
+	if p.config.OnError != nil {
+		p.config.OnError(err)
+	}
+}
+
+func (p *pluginProcess) scan(stdout io.Reader) {
+	defer close(p.done)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := append([]byte(nil), scanner.Bytes()...)
+		select {
+		case p.lines <- line:
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// Closes stdin, which tells the executable to exit, and waits for it to, killing it after the timeout.
+func (p *pluginProcess) stop(timeout time.Duration) error {
+	close(p.quit)
+	_ = p.stdin.Close()
+	exited := make(chan error, 1)
+	go func() {
+		exited <- p.cmd.Wait()
+	}()
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case err := <-exited:
+		return err
+	case <-t.C:
+		_ = p.cmd.Process.Kill()
+		return <-exited
+	}
+}
+
+// What a plugin written in Go does for each method. Methods that aren't set aren't supported.
+type PluginHandlers struct {
+	// Handles a commit. The error is passed back to the poller.
+	HandleCommit func(commit CommitDiff) error
+
+	// Whether the change is included.
+	FilterChange func(change FileChange) (bool, error)
+}
+
+// Serve the plugin protocol over stdin and stdout until stdin is closed. Call from the main function of a plugin
+// executable.
+func ServePlugin(handlers PluginHandlers) error {
+	return servePlugin(handlers, os.Stdin, os.Stdout)
+}
+
+func servePlugin(handlers PluginHandlers, in io.Reader, out io.Writer) error {
+	handshake := PluginHandshake{Protocol: PluginProtocolVersion, Methods: make([]string, 0, 2)}
+	if handlers.HandleCommit != nil {
+		handshake.Methods = append(handshake.Methods, PluginMethodHandle)
+	}
+	if handlers.FilterChange != nil {
+		handshake.Methods = append(handshake.Methods, PluginMethodFilter)
+	}
+	enc := json.NewEncoder(out)
+	if err := enc.Encode(handshake); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		req := PluginRequest{}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return err
+		}
+		resp := PluginResponse{ID: req.ID}
+		var err error
+		switch {
+		case req.Method == PluginMethodHandle && handlers.HandleCommit != nil && req.Commit != nil:
+			err = handlers.HandleCommit(*req.Commit)
+		case req.Method == PluginMethodFilter && handlers.FilterChange != nil && req.Change != nil:
+			resp.Include, err = handlers.FilterChange(*req.Change)
+		default:
+			err = ErrPluginMethodUnsupported
+		}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
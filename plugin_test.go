@@ -0,0 +1,57 @@
+package gpoll_test
+
+import (
+	"context"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+type PluginTest struct {
+	suite.Suite
+}
+
+func (s *PluginTest) TestTimesOutWritingToPluginThatStoppedReading() {
+	// -- Given
+	//
+	if runtime.GOOS == "windows" {
+		s.T().Skip("requires sh")
+	}
+	errs := make(chan error, 1)
+	p, err := gpoll.NewPlugin(gpoll.PluginConfig{
+		Command: []string{"sh", "-c", `echo '{"protocol":1,"methods":["handle"]}'; exec sleep 60`},
+		Timeout: 200 * time.Millisecond,
+		OnError: func(err error) {
+			errs <- err
+		},
+	})
+	s.Require().NoError(err)
+	commit := gpoll.CommitDiff{
+		Changes: []gpoll.FileChange{{Filepath: strings.Repeat("a", 1<<20)}},
+	}
+
+	// -- When
+	//
+	handled := make(chan struct{})
+	go func() {
+		defer close(handled)
+		p.HandleCommit(context.Background(), commit)
+	}()
+
+	// -- Then
+	//
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		s.FailNow("the write to the plugin did not time out")
+	}
+	s.Contains((<-errs).Error(), "timed out")
+	s.NoError(p.Close())
+}
+
+func TestPlugin(t *testing.T) {
+	suite.Run(t, new(PluginTest))
+}
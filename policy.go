@@ -0,0 +1,157 @@
+package gpoll
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+type PolicyConfig struct {
+	// Refuse to deliver commits that are not signed. When a commit without a valid signature is found, a
+	// PolicyViolation is emitted and the poller holds its position at the last compliant commit. Neither the offending
+	// commit nor anything after it is delivered until it's released, or the history is replaced on the remote by one
+	// without the offending commit, e.g. by force pushing a re-signed commit. The held commits are then dropped and the
+	// new history is delivered from the last compliant commit.
+	RequireSignedCommits bool
+
+	// ASCII armored PGP public keys used to verify commit signatures. Required with RequireSignedCommits so a commit
+	// signed by just anyone isn't accepted.
+	TrustedKeys string `validate:"required_with=RequireSignedCommits"`
+
+	// Branch patterns, in path.Match syntax, that RequireSignedCommits applies to. Defaults to all branches.
+	ProtectedBranches []string
+
+	// The emails that commit authors and committers are allowed to use. Entries use path.Match syntax and are case
+	// insensitive e.g. "*@example.com" allows anyone from the example.com domain. If not set, any email is allowed.
+	AllowedAuthors []string
+
+	// What to do with a commit whose author or committer is not in the AllowedAuthors. Defaults to PolicyActionHalt.
+	AllowedAuthorsAction PolicyAction
+
+	// The maximum size in bytes of a file created or updated by a commit. If not set, files of any size are allowed.
+	MaxFileSize int64
+
+	// The maximum number of files a single commit may change. If not set, any number of files is allowed.
+	MaxChangedFiles int
+
+	// What to do with a commit that exceeds the MaxFileSize or MaxChangedFiles. Defaults to PolicyActionHalt.
+	GuardAction PolicyAction
+}
+
+// What the poller does with a commit that violates a policy.
+type PolicyAction int
+
+const (
+	// Hold delivery at the last compliant commit. Neither the offending commit nor anything after it is delivered until
+	// it's released or the history is replaced without it.
+	PolicyActionHalt PolicyAction = iota
+
+	// Don't deliver the offending commit but continue delivering the commits after it. The next delivered CommitDiff
+	// will be relative to the skipped commit.
+	PolicyActionSkip
+
+	// Deliver the offending commit as normal. Only the PolicyViolation is emitted.
+	PolicyActionWarn
+)
+
+const (
+	PolicySignedCommits   = "signed-commits"
+	PolicyAllowedAuthors  = "allowed-authors"
+	PolicyMaxFileSize     = "max-file-size"
+	PolicyMaxChangedFiles = "max-changed-files"
+)
+
+// Emitted when a commit violates one of the configured policies.
+type PolicyViolation struct {
+	// The name of the violated policy.
+	Policy string
+
+	// The offending commit.
+	Commit Commit
+
+	// Why the commit is in violation.
+	Reason string
+
+	// What the poller did with the offending commit.
+	Action PolicyAction
+}
+
+func (p PolicyViolation) EventType() EventType {
+	return EventTypePolicyViolation
+}
+
+func (p PolicyViolation) String() string {
+	return fmt.Sprintf("commit %s violates the %s policy: %s", p.Commit.Sha, p.Policy, p.Reason)
+}
+
+// Checks the commit against all configured policies and returns every violation.
+func (p *poller) checkPolicies(commit CommitDiff) []PolicyViolation {
+	policies := p.config.Policies
+	violations := make([]PolicyViolation, 0)
+	if policies.RequireSignedCommits && p.isProtectedBranch() {
+		p.repoLock.Lock()
+		err := p.git.VerifySignature(p.repo, commit.To.Sha, policies.TrustedKeys)
+		p.repoLock.Unlock()
+		if err != nil {
+			violations = append(violations, PolicyViolation{
+				Policy: PolicySignedCommits,
+				Commit: commit.To,
+				Reason: err.Error(),
+				Action: PolicyActionHalt,
+			})
+		}
+	}
+
+	if len(policies.AllowedAuthors) > 0 {
+		for _, email := range []string{commit.To.Author.Email, commit.To.Committer.Email} {
+			if !isAllowedEmail(policies.AllowedAuthors, email) {
+				violations = append(violations, PolicyViolation{
+					Policy: PolicyAllowedAuthors,
+					Commit: commit.To,
+					Reason: fmt.Sprintf("%s is not an allowed author", email),
+					Action: policies.AllowedAuthorsAction,
+				})
+				break
+			}
+		}
+	}
+	if policies.MaxFileSize > 0 {
+		for _, c := range commit.Changes {
+			if c.ChangeType != ChangeTypeDelete && c.Size > policies.MaxFileSize {
+				violations = append(violations, PolicyViolation{
+					Policy: PolicyMaxFileSize,
+					Commit: commit.To,
+					Reason: fmt.Sprintf("%s is %d bytes which exceeds the max of %d", p.relativePath(c.Filepath), c.Size,
+						policies.MaxFileSize),
+					Action: policies.GuardAction,
+				})
+			}
+		}
+	}
+
+	if policies.MaxChangedFiles > 0 && len(commit.Changes) > policies.MaxChangedFiles {
+		violations = append(violations, PolicyViolation{
+			Policy: PolicyMaxChangedFiles,
+			Commit: commit.To,
+			Reason: fmt.Sprintf("%d files were changed which exceeds the max of %d", len(commit.Changes),
+				policies.MaxChangedFiles),
+			Action: policies.GuardAction,
+		})
+	}
+	return violations
+}
+
+func (p *poller) isProtectedBranch() bool {
+	branches := p.config.Policies.ProtectedBranches
+	return len(branches) == 0 || matchesAny(branches, p.config.Git.Branch)
+}
+
+func isAllowedEmail(allowed []string, email string) bool {
+	email = strings.ToLower(email)
+	for _, a := range allowed {
+		if ok, _ := path.Match(strings.ToLower(a), email); ok {
+			return true
+		}
+	}
+	return false
+}
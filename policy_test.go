@@ -0,0 +1,259 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/gpolltest/server"
+	"github.com/stretchr/testify/suite"
+	"sync"
+	"testing"
+	"time"
+)
+
+type PolicyTest struct {
+	serverSuite
+}
+
+// Trusted keys for tests of unsigned commits, which are rejected before their signature is verified.
+const unusedKeys = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+
+func (s *PolicyTest) TestResumesOnceOffendingHistoryIsReplaced() {
+	// -- Given
+	//
+	var lock sync.Mutex
+	events := make([]gpoll.Event, 0)
+	p := s.newPoller(gpoll.PollConfig{
+		Policies: gpoll.PolicyConfig{MaxChangedFiles: 1},
+		HandleEvent: func(event gpoll.Event) {
+			lock.Lock()
+			defer lock.Unlock()
+			events = append(events, event)
+		},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+	base, err := s.server.Head()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	offending := s.commit("add a and b", map[string]string{"a.txt": "a", "b.txt": "b"})
+	s.commit("add c", map[string]string{"c.txt": "c"})
+	s.Eventually(func() bool {
+		return len(p.Quarantine()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Equal(offending, p.Quarantine()[0].Commit.To.Sha)
+
+	// -- When
+	//
+	s.NoError(s.server.Reset(base))
+	fixed := s.commit("add a", map[string]string{"a.txt": "a"})
+
+	// -- Then
+	//
+	commit := s.receive(c)
+	s.Equal(base, commit.From.Sha)
+	s.Equal(fixed, commit.To.Sha)
+	s.False(commit.HistoryReplaced)
+	s.Empty(p.Quarantine())
+
+	next := s.commit("add b", map[string]string{"b.txt": "b"})
+	s.Equal(next, s.receive(c).To.Sha)
+
+	lock.Lock()
+	defer lock.Unlock()
+	types := make([]gpoll.EventType, len(events))
+	for i, e := range events {
+		types[i] = e.EventType()
+	}
+	s.Equal([]gpoll.EventType{gpoll.EventTypePolicyViolation}, types)
+}
+
+func (s *PolicyTest) TestHaltsOnUnsignedCommits() {
+	// -- Given
+	//
+	violations := make(chan gpoll.PolicyViolation, 10)
+	p := s.newPoller(gpoll.PollConfig{
+		Policies:    gpoll.PolicyConfig{RequireSignedCommits: true, TrustedKeys: unusedKeys},
+		HandleEvent: sendViolations(violations),
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	unsigned := s.commit("add a", map[string]string{"a.txt": "a"})
+
+	// -- Then
+	//
+	v := s.receiveViolation(violations)
+	s.Equal(gpoll.PolicySignedCommits, v.Policy)
+	s.Equal(unsigned, v.Commit.Sha)
+	s.Equal(gpoll.PolicyActionHalt, v.Action)
+	s.receiveNone(c, 200*time.Millisecond)
+}
+
+func (s *PolicyTest) TestSignedCommitsOnlyApplyToProtectedBranches() {
+	// -- Given
+	//
+	violations := make(chan gpoll.PolicyViolation, 10)
+	p := s.newPoller(gpoll.PollConfig{
+		Policies: gpoll.PolicyConfig{
+			RequireSignedCommits: true,
+			TrustedKeys:          unusedKeys,
+			ProtectedBranches:    []string{"release/*"},
+		},
+		HandleEvent: sendViolations(violations),
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	unsigned := s.commit("add a", map[string]string{"a.txt": "a"})
+
+	// -- Then
+	//
+	s.Equal(unsigned, s.receive(c).To.Sha)
+	s.Empty(violations)
+}
+
+func (s *PolicyTest) TestAllowedAuthors() {
+	// -- Given
+	//
+	violations := make(chan gpoll.PolicyViolation, 10)
+	p := s.newPoller(gpoll.PollConfig{
+		Policies: gpoll.PolicyConfig{
+			AllowedAuthors:       []string{"*@corp.com"},
+			AllowedAuthorsAction: gpoll.PolicyActionWarn,
+		},
+		HandleEvent: sendViolations(violations),
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	sha := s.commit("add a", map[string]string{"a.txt": "a"})
+
+	// -- Then
+	//
+	v := s.receiveViolation(violations)
+	s.Equal(gpoll.PolicyAllowedAuthors, v.Policy)
+	s.Equal(sha, v.Commit.Sha)
+	s.Contains(v.Reason, server.Username+"@example.com")
+	s.Equal(sha, s.receive(c).To.Sha)
+}
+
+func (s *PolicyTest) TestAllowedAuthorsAreCaseInsensitive() {
+	// -- Given
+	//
+	violations := make(chan gpoll.PolicyViolation, 10)
+	p := s.newPoller(gpoll.PollConfig{
+		Policies:    gpoll.PolicyConfig{AllowedAuthors: []string{"*@EXAMPLE.com"}},
+		HandleEvent: sendViolations(violations),
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	sha := s.commit("add a", map[string]string{"a.txt": "a"})
+
+	// -- Then
+	//
+	s.Equal(sha, s.receive(c).To.Sha)
+	s.Empty(violations)
+}
+
+func (s *PolicyTest) TestSkipsCommitsExceedingMaxFileSize() {
+	// -- Given
+	//
+	violations := make(chan gpoll.PolicyViolation, 10)
+	p := s.newPoller(gpoll.PollConfig{
+		Policies: gpoll.PolicyConfig{
+			MaxFileSize: 4,
+			GuardAction: gpoll.PolicyActionSkip,
+		},
+		HandleEvent: sendViolations(violations),
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	large := s.commit("add large", map[string]string{"large.txt": "too large"})
+	v := s.receiveViolation(violations)
+	small := s.commit("add small", map[string]string{"small.txt": "ok"})
+
+	// -- Then
+	//
+	s.Equal(gpoll.PolicyMaxFileSize, v.Policy)
+	s.Equal(large, v.Commit.Sha)
+	s.Equal(gpoll.PolicyActionSkip, v.Action)
+	commit := s.receive(c)
+	s.Equal(small, commit.To.Sha)
+	s.Equal(large, commit.From.Sha)
+}
+
+func (s *PolicyTest) TestHaltsOnCommitsExceedingMaxChangedFiles() {
+	// -- Given
+	//
+	violations := make(chan gpoll.PolicyViolation, 10)
+	p := s.newPoller(gpoll.PollConfig{
+		Policies:    gpoll.PolicyConfig{MaxChangedFiles: 1},
+		HandleEvent: sendViolations(violations),
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	sha := s.commit("add a and b", map[string]string{"a.txt": "a", "b.txt": "b"})
+	s.commit("add c", map[string]string{"c.txt": "c"})
+
+	// -- Then
+	//
+	v := s.receiveViolation(violations)
+	s.Equal(gpoll.PolicyMaxChangedFiles, v.Policy)
+	s.Equal(sha, v.Commit.Sha)
+	s.Equal(gpoll.PolicyActionHalt, v.Action)
+	s.receiveNone(c, 200*time.Millisecond)
+}
+
+func (s *PolicyTest) receiveViolation(violations chan gpoll.PolicyViolation) gpoll.PolicyViolation {
+	select {
+	case v := <-violations:
+		return v
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for a policy violation")
+	}
+	return gpoll.PolicyViolation{}
+}
+
+// Send every PolicyViolation to the channel, dropping those that don't fit.
+func sendViolations(violations chan gpoll.PolicyViolation) gpoll.HandleEventFunc {
+	return func(event gpoll.Event) {
+		if v, ok := event.(gpoll.PolicyViolation); ok {
+			select {
+			case violations <- v:
+			default:
+			}
+		}
+	}
+}
+
+func (s *PolicyTest) TestSignedCommitsRequireTrustedKeys() {
+	// -- When
+	//
+	_, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      s.server.GitConfig(),
+		Policies: gpoll.PolicyConfig{RequireSignedCommits: true},
+	})
+
+	// -- Then
+	//
+	s.Error(err)
+}
+
+func TestPolicy(t *testing.T) {
+	suite.Run(t, new(PolicyTest))
+}
@@ -0,0 +1,162 @@
+package gpoll
+
+import (
+	"fmt"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+	"strings"
+	"sync"
+)
+
+// The prefix of the references through which a pooled clone sees the tips of the other clones sharing its pool.
+const poolRefPrefix = "refs/gpoll-pool/"
+
+// A store of git objects shared between the in-memory clones of many repos, similar to git alternates. Share one pool
+// between pollers of forks or branches of the same upstream, e.g. in a MultiPoller, so objects they have in common are
+// only fetched and kept once. When fetching, the tips of every clone sharing the pool are offered to the remote so
+// only objects missing from the pool are sent.
+//
+// Objects are never removed from the pool, even once every clone using them has stopped.
+type ObjectPool struct {
+	lock    sync.RWMutex
+	objects memory.ObjectStorage
+	// The hash of every reference of every clone sharing the pool, keyed by clone and reference name.
+	tips   map[string]plumbing.Hash
+	clones int
+}
+
+// Create an empty ObjectPool. Set it as the Pool of the StorageConfig of every repo sharing it.
+func NewObjectPool() *ObjectPool {
+	return &ObjectPool{
+		objects: memory.NewStorage().ObjectStorage,
+		tips:    make(map[string]plumbing.Hash),
+	}
+}
+
+// Get the number of objects in the pool.
+func (o *ObjectPool) Len() int {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	return len(o.objects.Objects)
+}
+
+// Create the storage of a clone whose objects are kept in the pool. Everything else e.g. references are its own.
+func (o *ObjectPool) newStorage() *pooledStorage {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.clones++
+	s := memory.NewStorage()
+	return &pooledStorage{
+		pool:             o,
+		id:               o.clones,
+		ConfigStorage:    s.ConfigStorage,
+		ShallowStorage:   s.ShallowStorage,
+		IndexStorage:     s.IndexStorage,
+		ReferenceStorage: s.ReferenceStorage,
+		ModuleStorage:    s.ModuleStorage,
+	}
+}
+
+type pooledStorage struct {
+	pool *ObjectPool
+	id   int
+
+	memory.ConfigStorage
+	memory.ShallowStorage
+	memory.IndexStorage
+	memory.ReferenceStorage
+	memory.ModuleStorage
+}
+
+func (p *pooledStorage) NewEncodedObject() plumbing.EncodedObject {
+	return &plumbing.MemoryObject{}
+}
+
+func (p *pooledStorage) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	p.pool.lock.Lock()
+	defer p.pool.lock.Unlock()
+	return p.pool.objects.SetEncodedObject(obj)
+}
+
+func (p *pooledStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	p.pool.lock.RLock()
+	defer p.pool.lock.RUnlock()
+	return p.pool.objects.EncodedObject(t, h)
+}
+
+func (p *pooledStorage) IterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
+	p.pool.lock.RLock()
+	defer p.pool.lock.RUnlock()
+	return p.pool.objects.IterEncodedObjects(t)
+}
+
+func (p *pooledStorage) HasEncodedObject(h plumbing.Hash) error {
+	p.pool.lock.RLock()
+	defer p.pool.lock.RUnlock()
+	return p.pool.objects.HasEncodedObject(h)
+}
+
+func (p *pooledStorage) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	p.pool.lock.RLock()
+	defer p.pool.lock.RUnlock()
+	return p.pool.objects.EncodedObjectSize(h)
+}
+
+func (p *pooledStorage) SetReference(ref *plumbing.Reference) error {
+	if err := p.ReferenceStorage.SetReference(ref); err != nil {
+		return err
+	}
+	p.setTip(ref)
+	return nil
+}
+
+func (p *pooledStorage) CheckAndSetReference(ref, old *plumbing.Reference) error {
+	if err := p.ReferenceStorage.CheckAndSetReference(ref, old); err != nil {
+		return err
+	}
+	p.setTip(ref)
+	return nil
+}
+
+func (p *pooledStorage) RemoveReference(n plumbing.ReferenceName) error {
+	p.pool.lock.Lock()
+	delete(p.pool.tips, p.tipKey(n))
+	p.pool.lock.Unlock()
+	return p.ReferenceStorage.RemoveReference(n)
+}
+
+// Iterates the clone's own references followed by the tips of the other clones sharing the pool, which are offered to
+// the remote as objects the clone already has when fetching.
+func (p *pooledStorage) IterReferences() (storer.ReferenceIter, error) {
+	refs := make([]*plumbing.Reference, 0, len(p.ReferenceStorage))
+	for _, ref := range p.ReferenceStorage {
+		refs = append(refs, ref)
+	}
+
+	own := fmt.Sprintf("%d:", p.id)
+	seen := make(map[plumbing.Hash]bool)
+	p.pool.lock.RLock()
+	for key, h := range p.pool.tips {
+		if seen[h] || strings.HasPrefix(key, own) {
+			continue
+		}
+		seen[h] = true
+		refs = append(refs, plumbing.NewHashReference(plumbing.ReferenceName(poolRefPrefix+h.String()), h))
+	}
+	p.pool.lock.RUnlock()
+	return storer.NewReferenceSliceIter(refs), nil
+}
+
+func (p *pooledStorage) setTip(ref *plumbing.Reference) {
+	if ref.Type() != plumbing.HashReference {
+		return
+	}
+	p.pool.lock.Lock()
+	defer p.pool.lock.Unlock()
+	p.pool.tips[p.tipKey(ref.Name())] = ref.Hash()
+}
+
+func (p *pooledStorage) tipKey(n plumbing.ReferenceName) string {
+	return fmt.Sprintf("%d:%s", p.id, n)
+}
@@ -0,0 +1,57 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type PriorityTest struct {
+	serverSuite
+}
+
+func (s *PriorityTest) TestPriorityPathBypassesDebounce() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{
+		Debounce:      time.Hour,
+		PriorityPaths: []string{"urgent"},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+	batched := s.commit("add a", map[string]string{"a.txt": "a"})
+	s.receiveNone(c, 200*time.Millisecond)
+
+	// -- When
+	//
+	urgent := s.commit("add urgent", map[string]string{"urgent/b.txt": "b"})
+
+	// -- Then
+	//
+	s.Equal(batched, s.receive(c).To.Sha)
+	s.Equal(urgent, s.receive(c).To.Sha)
+}
+
+func (s *PriorityTest) TestDebounceBatchesCommits() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{Debounce: 300 * time.Millisecond})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	first := s.commit("add a", map[string]string{"a.txt": "a"})
+	second := s.commit("add b", map[string]string{"b.txt": "b"})
+
+	// -- Then
+	//
+	s.receiveNone(c, 100*time.Millisecond)
+	s.Equal(first, s.receive(c).To.Sha)
+	s.Equal(second, s.receive(c).To.Sha)
+}
+
+func TestPriority(t *testing.T) {
+	suite.Run(t, new(PriorityTest))
+}
@@ -0,0 +1,95 @@
+package gpoll
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The progress of transferring objects from the remote, as reported by the remote.
+type Progress struct {
+	// The phase of the transfer e.g. "Counting objects", "Compressing objects" or "Receiving objects". Which phases are
+	// reported depends on the remote.
+	Phase string
+
+	// The number of objects processed so far in the phase.
+	Current uint64
+
+	// The total number of objects in the phase. 0 if unknown.
+	Total uint64
+
+	// The number of bytes transferred so far as reported by the remote. 0 if unknown.
+	Bytes uint64
+
+	// Whether the phase has completed.
+	Done bool
+
+	// The message as sent by the remote.
+	Message string
+}
+
+func (p Progress) String() string {
+	if p.Total > 0 {
+		return fmt.Sprintf("%s: %d/%d", p.Phase, p.Current, p.Total)
+	}
+	return p.Message
+}
+
+// Called with the progress of cloning and fetching from the remote.
+type ProgressFunc func(progress Progress)
+
+var (
+	progressCountsRegex = regexp.MustCompile(`\((\d+)/(\d+)\)`)
+	progressBytesRegex  = regexp.MustCompile(`([\d.]+) (bytes|KiB|MiB|GiB)\b`)
+	progressUnits       = map[string]float64{"bytes": 1, "KiB": 1 << 10, "MiB": 1 << 20, "GiB": 1 << 30}
+)
+
+// Parses the progress messages sent by the remote on the sideband, passing each to the ProgressFunc. Messages are
+// terminated by either a carriage return, when the remote updates the same line, or a newline.
+type progressWriter struct {
+	lock     sync.Mutex
+	progress ProgressFunc
+	buf      []byte
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.buf = append(w.buf, b...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		line := strings.TrimSpace(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+		if line != "" {
+			w.progress(parseProgress(line))
+		}
+	}
+	return len(b), nil
+}
+
+func parseProgress(message string) Progress {
+	p := Progress{Message: message}
+	i := strings.Index(message, ":")
+	if i < 0 {
+		p.Phase = message
+		return p
+	}
+	p.Phase = message[:i]
+	p.Done = strings.HasSuffix(message, "done.") || strings.HasSuffix(message, "done")
+
+	if m := progressCountsRegex.FindStringSubmatch(message); m != nil {
+		p.Current, _ = strconv.ParseUint(m[1], 10, 64)
+		p.Total, _ = strconv.ParseUint(m[2], 10, 64)
+	}
+	if m := progressBytesRegex.FindStringSubmatch(message); m != nil {
+		f, _ := strconv.ParseFloat(m[1], 64)
+		p.Bytes = uint64(f * progressUnits[m[2]])
+	}
+	return p
+}
@@ -0,0 +1,85 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type ProgressTest struct {
+	suite.Suite
+
+	reported []gpoll.Progress
+}
+
+func (s *ProgressTest) SetupTest() {
+	s.reported = make([]gpoll.Progress, 0)
+}
+
+func (s *ProgressTest) write(messages ...string) {
+	w := gpoll.NewProgressWriter(func(progress gpoll.Progress) {
+		s.reported = append(s.reported, progress)
+	})
+	for _, m := range messages {
+		_, err := w.Write([]byte(m))
+		s.Require().NoError(err)
+	}
+}
+
+func (s *ProgressTest) TestParsesPhasesCountsAndBytes() {
+	// -- When
+	//
+	s.write(
+		"Counting objects: 100% (12/12), done.\n",
+		"Receiving objects:  50% (6/12), 1.50 MiB | 3.00 MiB/s\r",
+		"Receiving objects: 100% (12/12), 3.00 MiB | 3.00 MiB/s, done.\n",
+	)
+
+	// -- Then
+	//
+	s.Equal([]gpoll.Progress{
+		{
+			Phase:   "Counting objects",
+			Current: 12,
+			Total:   12,
+			Done:    true,
+			Message: "Counting objects: 100% (12/12), done.",
+		},
+		{
+			Phase:   "Receiving objects",
+			Current: 6,
+			Total:   12,
+			Bytes:   3 << 19,
+			Message: "Receiving objects:  50% (6/12), 1.50 MiB | 3.00 MiB/s",
+		},
+		{
+			Phase:   "Receiving objects",
+			Current: 12,
+			Total:   12,
+			Bytes:   3 << 20,
+			Done:    true,
+			Message: "Receiving objects: 100% (12/12), 3.00 MiB | 3.00 MiB/s, done.",
+		},
+	}, s.reported)
+	s.Equal("Receiving objects: 12/12", s.reported[2].String())
+}
+
+func (s *ProgressTest) TestBuffersMessagesSplitAcrossWrites() {
+	// -- When
+	//
+	s.write("Compressing obj", "ects:  10% (1/10)\r\r", "Total 10 (delta 2)", "\n")
+
+	// -- Then
+	//
+	if s.Len(s.reported, 2) {
+		s.Equal("Compressing objects", s.reported[0].Phase)
+		s.Equal(uint64(1), s.reported[0].Current)
+		s.Equal(uint64(10), s.reported[0].Total)
+		s.Equal("Total 10 (delta 2)", s.reported[1].Phase)
+		s.Equal("Total 10 (delta 2)", s.reported[1].String())
+	}
+}
+
+func TestProgress(t *testing.T) {
+	suite.Run(t, new(ProgressTest))
+}
@@ -0,0 +1,277 @@
+package gpoll
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// An environment within a PromotionChain e.g. staging.
+type Environment struct {
+	// The name of the environment.
+	Name string
+
+	// Applies the commit to the environment. Returning nil reports success, promoting the commit to the next
+	// environment. Returning an error halts the environment until Retry is called, so no commit after the failed one is
+	// applied or promoted. The context is cancelled when the poller stops, in which case the commit is applied again
+	// once it restarts.
+	Apply func(ctx context.Context, commit CommitDiff) error
+
+	// How long a commit must have been applied successfully before it is promoted to the next environment.
+	Soak time.Duration
+
+	// Where the sha of the last commit applied successfully is kept across restarts e.g. NewFileCheckpointStore. The
+	// commit is not applied again when it's delivered on start, e.g. as the list of every file in the repo, but is still
+	// promoted. If not set, the position isn't kept.
+	Checkpoint CheckpointStore
+}
+
+// The state of an environment within a PromotionChain.
+type EnvironmentStatus struct {
+	// The name of the environment.
+	Name string
+
+	// The last commit applied successfully. Only the Sha is set if it was loaded from the Checkpoint.
+	Current Commit
+
+	// The error from the last commit that failed to apply. nil if the last commit was applied successfully.
+	LastError error
+
+	// The number of commits waiting to be applied, including a commit that failed to apply.
+	Pending int
+}
+
+var (
+	ErrUnknownEnvironment = errors.New("no environment with that name")
+	ErrNotFailed          = errors.New("the environment has no failed commit")
+)
+
+// Promotes commits through an ordered list of environments e.g. dev, staging then prod. A commit is only applied to an
+// environment once it has been applied successfully to the one before it and soaked there. Commits are applied to
+// each environment in the order they were delivered.
+//
+// Set it as the Promotion of a PollConfig. Commits are applied while the poller runs, and commits still waiting to be
+// applied or promoted when it stops are picked up again once it restarts.
+type PromotionChain struct {
+	envs []*promotionEnv
+
+	// Whether the positions of the environments were loaded from their Checkpoint.
+	loaded bool
+}
+
+type promotionEnv struct {
+	Environment
+	lock   sync.Mutex
+	status EnvironmentStatus
+	// Commits waiting to be applied, oldest first. The first is kept while it's being applied and after it failed to
+	// apply.
+	queue  []CommitDiff
+	failed bool
+	// Commits applied successfully that are waiting to be promoted to the next environment, oldest first.
+	soaking []soakingCommit
+	// Wake the goroutines applying and promoting commits.
+	applyWake   chan struct{}
+	promoteWake chan struct{}
+	next        *promotionEnv
+}
+
+type soakingCommit struct {
+	commit CommitDiff
+	until  time.Time
+}
+
+// Create a PromotionChain of the environments.
+func NewPromotionChain(envs ...Environment) *PromotionChain {
+	c := &PromotionChain{}
+	for _, e := range envs {
+		env := &promotionEnv{
+			Environment: e,
+			status:      EnvironmentStatus{Name: e.Name},
+			applyWake:   make(chan struct{}, 1),
+			promoteWake: make(chan struct{}, 1),
+		}
+		if len(c.envs) > 0 {
+			c.envs[len(c.envs)-1].next = env
+		}
+		c.envs = append(c.envs, env)
+	}
+	return c
+}
+
+// Queue the commit for the first environment.
+func (c *PromotionChain) HandleCommit(_ context.Context, commit CommitDiff) {
+	if len(c.envs) > 0 {
+		c.envs[0].enqueue(commit)
+	}
+}
+
+// Get the state of every environment, in order.
+func (c *PromotionChain) Status() []EnvironmentStatus {
+	statuses := make([]EnvironmentStatus, len(c.envs))
+	for i, env := range c.envs {
+		env.lock.Lock()
+		statuses[i] = env.status
+		statuses[i].Pending = len(env.queue)
+		env.lock.Unlock()
+	}
+	return statuses
+}
+
+// Apply the commit that failed to apply to the named environment again, resuming the environment if it succeeds.
+func (c *PromotionChain) Retry(name string) error {
+	for _, env := range c.envs {
+		if env.Name != name {
+			continue
+		}
+		env.lock.Lock()
+		failed := env.failed
+		env.failed = false
+		env.lock.Unlock()
+		if !failed {
+			return ErrNotFailed
+		}
+		wake(env.applyWake)
+		return nil
+	}
+	return ErrUnknownEnvironment
+}
+
+// Apply and promote commits on the goroutines of the poller until done is closed.
+func (c *PromotionChain) start(p *poller, done chan struct{}) {
+	if !c.loaded {
+		c.loaded = true
+		for _, env := range c.envs {
+			env.load(p.onError)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.goroutines.Go("promotion-cancel", func() {
+		<-done
+		cancel()
+	})
+	for _, env := range c.envs {
+		env := env
+		p.goroutines.Go("promotion:"+env.Name, func() { env.run(ctx, p.onError) })
+		if env.next != nil {
+			p.goroutines.Go("promotion-soak:"+env.Name, func() { env.promote(ctx) })
+		}
+	}
+}
+
+func (e *promotionEnv) load(onError func(err error)) {
+	if e.Checkpoint == nil {
+		return
+	}
+	sha, err := e.Checkpoint.Load()
+	if err != nil {
+		onError(err)
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.status.Current = Commit{Sha: sha}
+}
+
+func (e *promotionEnv) enqueue(commit CommitDiff) {
+	e.lock.Lock()
+	e.queue = append(e.queue, commit)
+	e.lock.Unlock()
+	wake(e.applyWake)
+}
+
+// Applies the queued commits, in order, until the context is done.
+func (e *promotionEnv) run(ctx context.Context, onError func(err error)) {
+	for {
+		e.lock.Lock()
+		ready := len(e.queue) > 0 && !e.failed
+		var commit CommitDiff
+		var applied bool
+		if ready {
+			commit = e.queue[0]
+			applied = commit.To.Sha != "" && commit.To.Sha == e.status.Current.Sha
+		}
+		e.lock.Unlock()
+		if !ready {
+			select {
+			case <-e.applyWake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		var err error
+		if !applied {
+			err = e.Apply(ctx, commit)
+		}
+		if ctx.Err() != nil {
+			// Stopped mid apply. The commit is still queued so it's applied again on restart.
+			return
+		}
+
+		e.lock.Lock()
+		e.status.LastError = err
+		if err != nil {
+			e.failed = true
+			e.lock.Unlock()
+			continue
+		}
+		e.status.Current = commit.To
+		e.queue = e.queue[1:]
+		if e.next != nil {
+			e.soaking = append(e.soaking, soakingCommit{commit: commit, until: time.Now().Add(e.Soak)})
+		}
+		e.lock.Unlock()
+
+		if e.next != nil {
+			wake(e.promoteWake)
+		}
+		if e.Checkpoint != nil && !applied {
+			if err := e.Checkpoint.Save(commit.To.Sha); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Promotes commits to the next environment, in order, once they have soaked.
+func (e *promotionEnv) promote(ctx context.Context) {
+	for {
+		e.lock.Lock()
+		ready := len(e.soaking) > 0
+		var s soakingCommit
+		if ready {
+			s = e.soaking[0]
+		}
+		e.lock.Unlock()
+		if !ready {
+			select {
+			case <-e.promoteWake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		t := time.NewTimer(time.Until(s.until))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		e.lock.Lock()
+		e.soaking = e.soaking[1:]
+		e.lock.Unlock()
+		e.next.enqueue(s.commit)
+	}
+}
+
+func wake(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,241 @@
+package gpoll_test
+
+import (
+	"context"
+	"errors"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type PromotionTest struct {
+	serverSuite
+
+	dir string
+}
+
+func (s *PromotionTest) SetupTest() {
+	s.serverSuite.SetupTest()
+	dir, err := ioutil.TempDir("", "gpoll")
+	s.Require().NoError(err)
+	s.dir = dir
+}
+
+func (s *PromotionTest) TearDownTest() {
+	s.serverSuite.TearDownTest()
+	_ = os.RemoveAll(s.dir)
+}
+
+// Start the poller, discarding the commits sent on its channel since the environments are what's tested.
+func (s *PromotionTest) startDraining(p gpoll.Poller) {
+	c := s.start(p)
+	go func() {
+		for range c {
+		}
+	}()
+}
+
+// An environment recording the sha of every commit applied to it. Commits are failed while fail returns an error.
+type recordingEnv struct {
+	lock    sync.Mutex
+	applied []string
+	fail    func(commit gpoll.CommitDiff) error
+}
+
+func (r *recordingEnv) Apply(ctx context.Context, commit gpoll.CommitDiff) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.fail != nil {
+		if err := r.fail(commit); err != nil {
+			return err
+		}
+	}
+	r.applied = append(r.applied, commit.To.Sha)
+	return nil
+}
+
+func (r *recordingEnv) shas() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string{}, r.applied...)
+}
+
+func (r *recordingEnv) setFail(fail func(commit gpoll.CommitDiff) error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.fail = fail
+}
+
+func (s *PromotionTest) TestPromotesThroughEnvironmentsInOrder() {
+	// -- Given
+	//
+	dev, prod := &recordingEnv{}, &recordingEnv{}
+	chain := gpoll.NewPromotionChain(
+		gpoll.Environment{Name: "dev", Apply: dev.Apply, Soak: 50 * time.Millisecond},
+		gpoll.Environment{Name: "prod", Apply: prod.Apply},
+	)
+	p := s.newPoller(gpoll.PollConfig{Promotion: chain})
+	s.startDraining(p)
+	defer p.StopAndWait()
+	head, err := s.server.Head()
+	s.Require().NoError(err)
+
+	// -- When
+	//
+	a := s.commit("add a", map[string]string{"a.txt": "a"})
+	b := s.commit("add b", map[string]string{"b.txt": "b"})
+
+	// -- Then
+	//
+	s.Eventually(func() bool {
+		return len(prod.shas()) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Equal([]string{head, a, b}, dev.shas())
+	s.Equal([]string{head, a, b}, prod.shas())
+	for _, status := range chain.Status() {
+		s.Equal(b, status.Current.Sha)
+		s.NoError(status.LastError)
+		s.Zero(status.Pending)
+	}
+}
+
+func (s *PromotionTest) TestFailedCommitHaltsEnvironmentUntilRetried() {
+	// -- Given
+	//
+	dev, prod := &recordingEnv{}, &recordingEnv{}
+	chain := gpoll.NewPromotionChain(
+		gpoll.Environment{Name: "dev", Apply: dev.Apply},
+		gpoll.Environment{Name: "prod", Apply: prod.Apply},
+	)
+	p := s.newPoller(gpoll.PollConfig{Promotion: chain})
+	s.startDraining(p)
+	defer p.StopAndWait()
+	s.Eventually(func() bool {
+		return len(prod.shas()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// -- When
+	//
+	dev.setFail(func(commit gpoll.CommitDiff) error {
+		return errors.New("apply failed")
+	})
+	a := s.commit("add a", map[string]string{"a.txt": "a"})
+	b := s.commit("add b", map[string]string{"b.txt": "b"})
+
+	// -- Then
+	//
+	s.Eventually(func() bool {
+		status := chain.Status()[0]
+		return status.LastError != nil && status.Pending == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Len(prod.shas(), 1)
+	s.Equal(gpoll.ErrNotFailed, chain.Retry("prod"))
+	s.Equal(gpoll.ErrUnknownEnvironment, chain.Retry("qa"))
+
+	dev.setFail(nil)
+	s.NoError(chain.Retry("dev"))
+	s.Eventually(func() bool {
+		return len(prod.shas()) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Equal([]string{a, b}, prod.shas()[1:])
+	s.NoError(chain.Status()[0].LastError)
+}
+
+func (s *PromotionTest) TestStopWaitsForEnvironmentsAndResumesOnRestart() {
+	// -- Given
+	//
+	var lock sync.Mutex
+	applied := make([]string, 0)
+	interrupted := make(chan struct{}, 1)
+	chain := gpoll.NewPromotionChain(gpoll.Environment{
+		Name: "dev",
+		Apply: func(ctx context.Context, commit gpoll.CommitDiff) error {
+			if commit.From.Sha != commit.To.Sha {
+				// Only the first commit after the initial delivery blocks.
+				select {
+				case interrupted <- struct{}{}:
+					<-ctx.Done()
+					return ctx.Err()
+				default:
+				}
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			applied = append(applied, commit.To.Sha)
+			return nil
+		},
+	})
+	p := s.newPoller(gpoll.PollConfig{Promotion: chain})
+	s.startDraining(p)
+	a := s.commit("add a", map[string]string{"a.txt": "a"})
+	s.Eventually(func() bool {
+		return len(interrupted) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// -- When
+	//
+	p.StopAndWait()
+
+	// -- Then
+	//
+	s.Empty(p.Status().ActiveGoroutines)
+	s.Equal(1, chain.Status()[0].Pending)
+
+	s.startDraining(p)
+	defer p.StopAndWait()
+	s.Eventually(func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, sha := range applied {
+			if sha == a {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *PromotionTest) TestCheckpointKeepsPositionAcrossRestarts() {
+	// -- Given
+	//
+	store := gpoll.NewFileCheckpointStore(filepath.Join(s.dir, "dev"))
+	dev := &recordingEnv{}
+	p := s.newPoller(gpoll.PollConfig{
+		Promotion: gpoll.NewPromotionChain(gpoll.Environment{Name: "dev", Apply: dev.Apply, Checkpoint: store}),
+	})
+	s.startDraining(p)
+	a := s.commit("add a", map[string]string{"a.txt": "a"})
+	s.Eventually(func() bool {
+		sha, err := store.Load()
+		return err == nil && sha == a
+	}, 5*time.Second, 10*time.Millisecond)
+	p.StopAndWait()
+
+	// -- When
+	//
+	restarted := &recordingEnv{}
+	chain := gpoll.NewPromotionChain(gpoll.Environment{Name: "dev", Apply: restarted.Apply, Checkpoint: store})
+	p = s.newPoller(gpoll.PollConfig{Promotion: chain})
+	s.startDraining(p)
+	defer p.StopAndWait()
+	b := s.commit("add b", map[string]string{"b.txt": "b"})
+
+	// -- Then
+	//
+	s.Eventually(func() bool {
+		return len(restarted.shas()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Equal([]string{b}, restarted.shas())
+	sha, err := store.Load()
+	s.NoError(err)
+	s.Equal(b, sha)
+}
+
+func TestPromotion(t *testing.T) {
+	suite.Run(t, new(PromotionTest))
+}
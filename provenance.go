@@ -0,0 +1,164 @@
+package gpoll
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// How long a provider may take to look up the provenance of a commit.
+const provenanceTimeout = 10 * time.Second
+
+// Where a commit came from.
+type ProvenanceSource int
+
+const (
+	// The provider does not know how the commit arrived e.g. it was part of a push but not its head.
+	ProvenanceSourceUnknown ProvenanceSource = iota
+
+	// The commit was pushed directly to the branch.
+	ProvenanceSourcePush
+
+	// The commit was force pushed to the branch, rewriting its history.
+	ProvenanceSourceForcePush
+
+	// The commit was the result of merging a pull request.
+	ProvenanceSourcePullRequest
+)
+
+// Describes how a commit arrived on the polled branch.
+type Provenance struct {
+	// How the commit arrived.
+	Source ProvenanceSource
+
+	// The user that pushed or merged the commit.
+	Pusher string
+}
+
+// Looks up the provenance of commits through the API of the git provider hosting the remote.
+type ProvenanceProvider interface {
+	// Get the provenance of the commit on the branch. The ctx is cancelled if the poller stops.
+	Provenance(ctx context.Context, branch string, commit Commit) (*Provenance, error)
+}
+
+// Create a ProvenanceProvider backed by the GitHub repository activity API. The token requires read access to the
+// repository's metadata.
+func NewGitHubProvenanceProvider(owner, repo, token string) ProvenanceProvider {
+	return &gitHubProvenance{
+		baseUrl: "https://api.github.com",
+		owner:   owner,
+		repo:    repo,
+		token:   token,
+		client:  &http.Client{Timeout: provenanceTimeout},
+	}
+}
+
+type gitHubProvenance struct {
+	baseUrl string
+	owner   string
+	repo    string
+	token   string
+	client  *http.Client
+}
+
+type gitHubActivity struct {
+	After        string `json:"after"`
+	ActivityType string `json:"activity_type"`
+	Actor        struct {
+		Login string `json:"login"`
+	} `json:"actor"`
+}
+
+// Pages through the activity of the branch, newest first, until the push of the commit is found.
+func (g *gitHubProvenance) Provenance(ctx context.Context, branch string, commit Commit) (*Provenance, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/activity?%s", g.baseUrl, url.PathEscape(g.owner), url.PathEscape(g.repo),
+		url.Values{"ref": {branch}, "per_page": {"100"}}.Encode())
+	for u != "" {
+		activities, next, err := g.activity(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range activities {
+			if !strings.EqualFold(a.After, commit.Sha) {
+				continue
+			}
+			p := &Provenance{
+				Pusher: a.Actor.Login,
+			}
+			switch a.ActivityType {
+			case "push":
+				p.Source = ProvenanceSourcePush
+			case "force_push":
+				p.Source = ProvenanceSourceForcePush
+			case "pr_merge", "merge_queue_merge":
+				p.Source = ProvenanceSourcePullRequest
+			}
+			return p, nil
+		}
+		u = next
+	}
+
+	return &Provenance{}, nil
+}
+
+// Gets a page of activity and the URL of the next page, which is empty on the last page.
+func (g *gitHubProvenance) activity(ctx context.Context, u string) ([]gitHubActivity, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("github activity request failed with status %d", resp.StatusCode)
+	}
+
+	activities := make([]gitHubActivity, 0)
+	if err := json.NewDecoder(resp.Body).Decode(&activities); err != nil {
+		return nil, "", err
+	}
+	return activities, nextLink(resp.Header.Get("Link")), nil
+}
+
+// Gets the URL of the rel="next" link of a Link header, or empty if there is none.
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		target := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if strings.ReplaceAll(strings.TrimSpace(param), " ", "") == `rel="next"` {
+				return target[1 : len(target)-1]
+			}
+		}
+	}
+	return ""
+}
+
+func (p *poller) enrich(ctx context.Context, commit *CommitDiff) {
+	if p.config.Provenance == nil {
+		return
+	}
+
+	prov, err := p.config.Provenance.Provenance(ctx, p.config.Git.Branch, commit.To)
+	if err != nil {
+		p.onError(err)
+		return
+	}
+	commit.Provenance = prov
+}
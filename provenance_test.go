@@ -0,0 +1,120 @@
+package gpoll_test
+
+import (
+	"context"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type ProvenanceTest struct {
+	suite.Suite
+}
+
+func (s *ProvenanceTest) TestEscapesBranch() {
+	// -- Given
+	//
+	refs := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refs <- r.URL.Query().Get("ref")
+		_, _ = w.Write([]byte(`[{"after":"abc","activity_type":"force_push","actor":{"login":"octocat"}}]`))
+	}))
+	defer srv.Close()
+	p := gpoll.NewGitHubProvenanceProvider("owner", "repo", "")
+	gpoll.SetProvenanceBaseUrl(p, srv.URL)
+
+	// -- When
+	//
+	prov, err := p.Provenance(context.Background(), "feature/a&b=c", gpoll.Commit{Sha: "abc"})
+
+	// -- Then
+	//
+	s.Require().NoError(err)
+	s.Equal("feature/a&b=c", <-refs)
+	s.Equal(gpoll.ProvenanceSourceForcePush, prov.Source)
+	s.Equal("octocat", prov.Pusher)
+}
+
+func (s *ProvenanceTest) TestFollowsPages() {
+	// -- Given
+	//
+	pages := make(chan string, 2)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages <- r.URL.Query().Get("page")
+		if r.URL.Query().Get("page") == "" {
+			s.Equal("100", r.URL.Query().Get("per_page"))
+			w.Header().Set("Link", `<`+srv.URL+r.URL.Path+`?page=2>; rel="next", <`+srv.URL+r.URL.Path+`?page=2>; rel="last"`)
+			_, _ = w.Write([]byte(`[{"after":"def","activity_type":"push","actor":{"login":"hubot"}}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"after":"abc","activity_type":"pr_merge","actor":{"login":"octocat"}}]`))
+	}))
+	defer srv.Close()
+	p := gpoll.NewGitHubProvenanceProvider("owner", "repo", "")
+	gpoll.SetProvenanceBaseUrl(p, srv.URL)
+
+	// -- When
+	//
+	prov, err := p.Provenance(context.Background(), "master", gpoll.Commit{Sha: "abc"})
+
+	// -- Then
+	//
+	s.Require().NoError(err)
+	s.Equal("", <-pages)
+	s.Equal("2", <-pages)
+	s.Equal(gpoll.ProvenanceSourcePullRequest, prov.Source)
+	s.Equal("octocat", prov.Pusher)
+}
+
+func (s *ProvenanceTest) TestUnknownOnceOutOfPages() {
+	// -- Given
+	//
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"after":"def","activity_type":"push","actor":{"login":"hubot"}}]`))
+	}))
+	defer srv.Close()
+	p := gpoll.NewGitHubProvenanceProvider("owner", "repo", "")
+	gpoll.SetProvenanceBaseUrl(p, srv.URL)
+
+	// -- When
+	//
+	prov, err := p.Provenance(context.Background(), "master", gpoll.Commit{Sha: "abc"})
+
+	// -- Then
+	//
+	s.Require().NoError(err)
+	s.Equal(gpoll.ProvenanceSourceUnknown, prov.Source)
+}
+
+func (s *ProvenanceTest) TestCancelled() {
+	// -- Given
+	//
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+	p := gpoll.NewGitHubProvenanceProvider("owner", "repo", "")
+	gpoll.SetProvenanceBaseUrl(p, srv.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// -- When
+	//
+	_, err := p.Provenance(ctx, "master", gpoll.Commit{Sha: "abc"})
+
+	// -- Then
+	//
+	s.Error(err)
+}
+
+func TestProvenance(t *testing.T) {
+	suite.Run(t, new(ProvenanceTest))
+}
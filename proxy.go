@@ -0,0 +1,75 @@
+package gpoll
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/net/proxy"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"net"
+)
+
+// A SOCKS5 proxy the remote is reached through, for pollers in networks without direct egress or egressing through
+// e.g. Tor. Works for both SSH and HTTP(S) remotes.
+//
+// HTTP(S) remotes are reached through the proxy by the HTTP client of the poller. go-git resolves SSH remotes for the
+// whole process, so the proxy is used for every SSH remote on the same host, and the last poller configured for a host
+// wins. Remotes without a proxy are connected to directly, or through the proxies in the environment as before.
+type ProxyConfig struct {
+	// The address of the SOCKS5 proxy as host:port. If not set, the remote isn't reached through a proxy.
+	Address string
+
+	// The user to authenticate to the proxy as. If not set, the proxy is used without authentication.
+	Username string
+
+	// The password to authenticate to the proxy with.
+	Password string
+}
+
+type contextDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// Opens connections through the SOCKS5 proxy.
+type socksDialer struct {
+	proxy.Dialer
+}
+
+func newSocksDialer(config TransportConfig, forward proxy.Dialer) (*socksDialer, error) {
+	if _, _, err := net.SplitHostPort(config.Proxy.Address); err != nil {
+		return nil, errors.New("the address of a proxy must be host:port")
+	}
+	var auth *proxy.Auth
+	if config.Proxy.Username != "" {
+		auth = &proxy.Auth{User: config.Proxy.Username, Password: config.Proxy.Password}
+	}
+	d, err := proxy.SOCKS5("tcp", config.Proxy.Address, auth, forward)
+	if err != nil {
+		return nil, err
+	}
+	return &socksDialer{Dialer: d}, nil
+}
+
+func (s *socksDialer) dial(target string) (net.Conn, error) {
+	return s.Dial("tcp", target)
+}
+
+func (s *socksDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d, ok := s.Dialer.(proxy.ContextDialer); ok {
+		return d.DialContext(ctx, network, addr)
+	}
+	return s.Dial(network, addr)
+}
+
+func (s *socksDialer) close() {}
+
+// Check the dialer can be used for the remote, returning it if it's an HTTP(S) remote.
+func httpDialer(ep *transport.Endpoint, config TransportConfig, d contextDialer) (contextDialer, error) {
+	if ep.Protocol != "http" && ep.Protocol != "https" {
+		if config.Proxy.Address != "" {
+			return nil, errors.New("a proxy can only be used with ssh and http(s) remotes, not " + ep.Protocol)
+		}
+		// Nothing to resolve e.g. for file remotes.
+		return nil, nil
+	}
+	return d, nil
+}
@@ -0,0 +1,153 @@
+package gpoll
+
+import "errors"
+
+// A commit withheld from delivery.
+type QuarantinedCommit struct {
+	// The withheld commit.
+	Commit CommitDiff
+
+	// Why the commit was quarantined. Empty if it is only held behind an older quarantined commit.
+	Reason string
+
+	// Whether the commit was released and is awaiting delivery.
+	Released bool
+}
+
+var (
+	ErrNotQuarantined       = errors.New("no quarantined commit with that sha")
+	ErrNotOldestQuarantined = errors.New("older commits are still quarantined")
+)
+
+func (p *poller) Quarantine() []QuarantinedCommit {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	held := make([]QuarantinedCommit, len(p.held))
+	copy(held, p.held)
+	return held
+}
+
+func (p *poller) Release(sha string) (err error) {
+	defer func() {
+		p.auditOperation("release", map[string]string{"sha": sha}, err)
+	}()
+	p.lock.Lock()
+	i := p.heldIndex(sha)
+	if i < 0 {
+		p.lock.Unlock()
+		return ErrNotQuarantined
+	}
+	if i > 0 {
+		p.lock.Unlock()
+		return ErrNotOldestQuarantined
+	}
+	p.held[0].Released = true
+	p.heldDecided = true
+	p.lock.Unlock()
+
+	p.Trigger()
+	return nil
+}
+
+func (p *poller) Discard(sha string) (err error) {
+	defer func() {
+		p.auditOperation("discard", map[string]string{"sha": sha}, err)
+	}()
+	p.lock.Lock()
+	i := p.heldIndex(sha)
+	if i < 0 {
+		p.lock.Unlock()
+		return ErrNotQuarantined
+	}
+	p.held = append(p.held[:i:i], p.held[i+1:]...)
+	if i == 0 {
+		p.heldDecided = true
+	}
+	p.lock.Unlock()
+
+	p.Trigger()
+	return nil
+}
+
+func (p *poller) heldIndex(sha string) int {
+	for i, h := range p.held {
+		if h.Commit.To.Sha == sha {
+			return i
+		}
+	}
+	return -1
+}
+
+// Takes the held commits once the oldest was released or discarded. Returns the released commit, which is delivered
+// as is, and the commits held behind it, which are checked again in order.
+// Holds a commit that couldn't be decided because polling stopped. The held commits are checked again once polling
+// resumes.
+func (p *poller) holdUndecided(commit CommitDiff) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.held = append(p.held, QuarantinedCommit{Commit: commit, Reason: "interrupted before a decision was made"})
+	p.heldDecided = true
+}
+
+func (p *poller) takeHeld() ([]CommitDiff, []CommitDiff) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.heldDecided {
+		return nil, nil
+	}
+	p.heldDecided = false
+
+	released := make([]CommitDiff, 0, 1)
+	readmit := make([]CommitDiff, 0, len(p.held))
+	for i, h := range p.held {
+		if i == 0 && h.Released {
+			released = append(released, h.Commit)
+			continue
+		}
+		readmit = append(readmit, h.Commit)
+	}
+	p.held = nil
+	return released, readmit
+}
+
+// Drops the commits held behind a policy violation or failed validation once the branch is force pushed to a history
+// without the offending commit, e.g. to replace it with a re-signed commit. The new history is diffed from the commit
+// before the offending one, which is where delivery stopped, and returned in place of the changes. The changes are
+// returned as they are if nothing is held or the offending commit is still in the new history.
+func (p *poller) replaceHeld(changes []CommitDiff) ([]CommitDiff, error) {
+	if len(changes) == 0 {
+		return changes, nil
+	}
+	p.lock.RLock()
+	if len(p.held) == 0 {
+		p.lock.RUnlock()
+		return changes, nil
+	}
+	offending := p.held[0].Commit
+	p.lock.RUnlock()
+
+	diffs, err := p.diffCommits(offending.From.Sha, changes[len(changes)-1].To.Sha)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range diffs {
+		if d.To.Sha == offending.To.Sha {
+			return changes, nil
+		}
+	}
+
+	p.lock.Lock()
+	p.held = nil
+	p.heldDecided = false
+	p.lock.Unlock()
+	return diffs, nil
+}
+
+func (p *poller) diffCommits(from, to string) ([]CommitDiff, error) {
+	p.repoLock.Lock()
+	defer p.repoLock.Unlock()
+	if p.repo == nil {
+		return nil, ErrNotStarted
+	}
+	return p.git.DiffCommits(p.repo, from, to)
+}
@@ -0,0 +1,89 @@
+package gpoll
+
+import (
+	"fmt"
+	"gopkg.in/src-d/go-git.v4"
+	"os"
+	"path/filepath"
+)
+
+// Emitted after a poll when the CloneDirectory still exceeds the storage Quota after its objects were compacted.
+type QuotaExceeded struct {
+	// The size of the CloneDirectory in bytes after compacting.
+	Usage int64
+
+	// The Quota in bytes.
+	Quota int64
+}
+
+func (q QuotaExceeded) EventType() EventType {
+	return EventTypeQuotaExceeded
+}
+
+func (q QuotaExceeded) String() string {
+	return fmt.Sprintf("clone uses %d bytes which exceeds the quota of %d bytes", q.Usage, q.Quota)
+}
+
+// Prunes unreachable loose objects and repacks every other object into a single packfile, like git gc.
+func (g *gitImpl) Compact(repo *git.Repository) error {
+	if err := repo.Prune(git.PruneOptions{Handler: repo.DeleteObject}); err != nil {
+		return err
+	}
+	if err := repo.RepackObjects(&git.RepackConfig{}); err != nil {
+		return err
+	}
+	// The storage caches the indexes of the packfiles that were just replaced.
+	if s, ok := repo.Storer.(interface{ Reindex() }); ok {
+		s.Reindex()
+	}
+	return nil
+}
+
+// Measures the clone and compacts it if it exceeds the Quota, emitting a QuotaExceeded event if it still does.
+func (p *poller) enforceQuota() {
+	quota := p.config.Git.Storage.Quota
+	if quota <= 0 || p.config.Git.Storage.Type != StorageTypeFilesystem {
+		return
+	}
+
+	// Objects that open snapshots read may be unreachable e.g. after a force push, so they are kept until released.
+	p.lock.RLock()
+	compact := p.results.snapshots == 0
+	p.lock.RUnlock()
+
+	p.repoLock.Lock()
+	defer p.repoLock.Unlock()
+	dir := p.cloneDirectory()
+	usage, err := dirSize(dir)
+	if err == nil && usage > quota && compact {
+		if err = p.git.Compact(p.repo); err == nil {
+			usage, err = dirSize(dir)
+		}
+	}
+	if err != nil {
+		p.onError(err)
+		return
+	}
+
+	p.lock.Lock()
+	p.diskUsage = usage
+	p.lock.Unlock()
+	if usage > quota {
+		p.emit(QuotaExceeded{Usage: usage, Quota: quota})
+	}
+}
+
+// Sums the sizes of every file within the directory.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,150 @@
+package gpoll
+
+import (
+	"sync"
+	"time"
+)
+
+// The number of receipts kept for Receipt, oldest dropped first.
+const receiptCapacity = 1000
+
+// Traces the delivery of a commit from the poll that found it through every handler it was passed to and every sink it
+// was delivered to, e.g. to explain what caused a restart downstream.
+type Receipt struct {
+	// The PollID of the poll that found the commit.
+	PollID string `json:"poll_id"`
+
+	// The ID of the delivered CommitDiff.
+	CommitID string `json:"commit_id"`
+
+	Sha      string `json:"sha"`
+	Sequence uint64 `json:"sequence"`
+
+	// When the poll received the commit from the remote.
+	ReceivedAt time.Time `json:"received_at"`
+
+	// When delivery of the commit began.
+	DeliveredAt time.Time `json:"delivered_at"`
+
+	// Every handler the commit was passed to, in the order they were called.
+	Handlers []HandlerInvocation `json:"handlers,omitempty"`
+
+	// Every sink the commit was delivered to.
+	Sinks []SinkDelivery `json:"sinks,omitempty"`
+}
+
+// A call of a handler with a commit.
+type HandlerInvocation struct {
+	// The handler, either handler for the HandleCommit of the PollConfig or handler:<name> for a named Handler.
+	Target string `json:"target"`
+
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+
+	// Whether the handler exceeded the HandlerTimeout.
+	TimedOut bool `json:"timed_out,omitempty"`
+}
+
+// A delivery of a commit to a sink.
+type SinkDelivery struct {
+	// The sink, either channel or outbox.
+	Sink string `json:"sink"`
+
+	// When the commit was last attempted to be delivered.
+	At time.Time `json:"at"`
+
+	// The number of attempts so far.
+	Attempts int `json:"attempts"`
+
+	// Why the last attempt failed. Empty once delivered.
+	Error string `json:"error,omitempty"`
+}
+
+func (r Receipt) copy() Receipt {
+	r.Handlers = append([]HandlerInvocation(nil), r.Handlers...)
+	r.Sinks = append([]SinkDelivery(nil), r.Sinks...)
+	return r
+}
+
+// Get the receipt of the delivered commit with the ID. Only the most recent 1000 receipts are kept.
+func (p *poller) Receipt(eventID string) (Receipt, bool) {
+	return p.receipts.get(eventID)
+}
+
+// The receipts of the most recently delivered commits.
+type receiptLog struct {
+	lock     sync.Mutex
+	receipts map[string]*Receipt
+	order    []string
+	last     *Receipt
+}
+
+func newReceiptLog() *receiptLog {
+	return &receiptLog{receipts: make(map[string]*Receipt)}
+}
+
+func (r *receiptLog) add(receipt Receipt) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.receipts[receipt.CommitID] = &receipt
+	r.order = append(r.order, receipt.CommitID)
+	r.last = &receipt
+	if len(r.order) > receiptCapacity {
+		delete(r.receipts, r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+func (r *receiptLog) handled(id string, invocations []HandlerInvocation) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if receipt, ok := r.receipts[id]; ok {
+		receipt.Handlers = invocations
+	}
+}
+
+// Records an attempt to deliver the commit with the ID to the sink.
+func (r *receiptLog) deliveredTo(id, sink string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	receipt, ok := r.receipts[id]
+	if !ok {
+		return
+	}
+	var d *SinkDelivery
+	for i := range receipt.Sinks {
+		if receipt.Sinks[i].Sink == sink {
+			d = &receipt.Sinks[i]
+		}
+	}
+	if d == nil {
+		receipt.Sinks = append(receipt.Sinks, SinkDelivery{Sink: sink})
+		d = &receipt.Sinks[len(receipt.Sinks)-1]
+	}
+	d.At = time.Now()
+	d.Attempts++
+	d.Error = ""
+	if err != nil {
+		d.Error = err.Error()
+	}
+}
+
+func (r *receiptLog) get(id string) (Receipt, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	receipt, ok := r.receipts[id]
+	if !ok {
+		return Receipt{}, false
+	}
+	return receipt.copy(), true
+}
+
+func (r *receiptLog) latest() *Receipt {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.last == nil {
+		return nil
+	}
+	c := r.last.copy()
+	return &c
+}
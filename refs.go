@@ -0,0 +1,97 @@
+package gpoll
+
+import (
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+	"sort"
+	"time"
+)
+
+// Every ref advertised by the remote at a point in time.
+type RefSnapshot struct {
+	// When the snapshot was taken.
+	Taken time.Time
+
+	// The sha of every ref keyed by its full name e.g. refs/heads/master.
+	Refs map[string]string
+}
+
+// List every ref on the remote without cloning anything. Compare snapshots with DiffRefs to observe everything
+// happening on the remote independent of any branch.
+func SnapshotRefs(config GitConfig) (RefSnapshot, error) {
+	g, err := newGit(config)
+	if err != nil {
+		return RefSnapshot{}, err
+	}
+
+	return g.(*gitImpl).snapshotRemote(config.Remote)
+}
+
+func (p *poller) RefSnapshot() (RefSnapshot, error) {
+	p.repoLock.Lock()
+	defer p.repoLock.Unlock()
+	if p.repo == nil {
+		return RefSnapshot{}, ErrNotStarted
+	}
+	refs, err := p.git.RemoteRefs(p.repo)
+	if err != nil {
+		return RefSnapshot{}, err
+	}
+	return RefSnapshot{
+		Taken: time.Now(),
+		Refs:  refs,
+	}, nil
+}
+
+// Get every ref that was created, updated or deleted between the snapshots, sorted by name.
+func DiffRefs(from, to RefSnapshot) []RefChange {
+	names := make([]string, 0, len(to.Refs)+len(from.Refs))
+	for name := range to.Refs {
+		names = append(names, name)
+	}
+	for name := range from.Refs {
+		if _, ok := to.Refs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := make([]RefChange, 0)
+	for _, name := range names {
+		if from.Refs[name] != to.Refs[name] {
+			changes = append(changes, RefChange{
+				Ref:  name,
+				From: from.Refs[name],
+				To:   to.Refs[name],
+			})
+		}
+	}
+	return changes
+}
+
+func (g *gitImpl) snapshotRemote(remote string) (RefSnapshot, error) {
+	return g.snapshot(git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: remoteName,
+		URLs: []string{remote},
+	}))
+}
+
+func (g *gitImpl) snapshot(rem *git.Remote) (RefSnapshot, error) {
+	rfs, err := g.listRemote(rem)
+	if err != nil {
+		return RefSnapshot{}, err
+	}
+
+	refs := make(map[string]string, len(rfs))
+	for _, r := range rfs {
+		if r.Type() == plumbing.HashReference {
+			refs[r.Name().String()] = r.Hash().String()
+		}
+	}
+	return RefSnapshot{
+		Taken: time.Now(),
+		Refs:  refs,
+	}, nil
+}
@@ -0,0 +1,60 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type RefsTest struct {
+	serverSuite
+}
+
+func (s *RefsTest) TestRequiresStart() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{})
+
+	// -- When
+	//
+	_, err := p.RefSnapshot()
+
+	// -- Then
+	//
+	s.Equal(gpoll.ErrNotStarted, err)
+}
+
+func (s *RefsTest) TestDiffsSnapshots() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{})
+	s.start(p)
+	defer p.StopAndWait()
+	before, err := p.RefSnapshot()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	// -- When
+	//
+	s.NoError(s.server.CreateBranch("feature"))
+	sha := s.commit("add a", map[string]string{"a.txt": "a"})
+	after, err := p.RefSnapshot()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	// -- Then
+	//
+	changes := gpoll.DiffRefs(before, after)
+	if s.Len(changes, 2) {
+		s.Equal("refs/heads/feature", changes[0].Ref)
+		s.Equal(before.Refs["refs/heads/master"], changes[0].To)
+		s.Equal("refs/heads/master", changes[1].Ref)
+		s.Equal(sha, changes[1].To)
+	}
+}
+
+func TestRefs(t *testing.T) {
+	suite.Run(t, new(RefsTest))
+}
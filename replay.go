@@ -0,0 +1,86 @@
+package gpoll
+
+import (
+	"sync"
+	"time"
+)
+
+type ReplayConfig struct {
+	// The maximum number of delivered commits to keep. If not set, or negative, no commits are kept and Events always
+	// returns nothing.
+	Size int `validate:"min=0"`
+
+	// The maximum amount of time a delivered commit is kept for. Expired commits are dropped by the janitor configured
+	// through the RetentionConfig. Defaults to keeping commits until they are pushed out by newer ones.
+	MaxAge time.Duration
+}
+
+// A fixed size ring of the most recently delivered commits.
+type replayBuffer struct {
+	lock    sync.RWMutex
+	config  ReplayConfig
+	entries []CommitDiff
+	start   int
+	count   int
+}
+
+func newReplayBuffer(config ReplayConfig) *replayBuffer {
+	// A negative Size only gets this far if validation is skipped, and keeps nothing like an unset one.
+	if config.Size < 0 {
+		config.Size = 0
+	}
+	return &replayBuffer{
+		config:  config,
+		entries: make([]CommitDiff, config.Size),
+	}
+}
+
+func (r *replayBuffer) add(commit CommitDiff) {
+	if r.config.Size <= 0 {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	idx := (r.start + r.count) % len(r.entries)
+	r.entries[idx] = commit
+	if r.count < len(r.entries) {
+		r.count++
+	} else {
+		r.start = (r.start + 1) % len(r.entries)
+	}
+}
+
+// Returns all kept commits with a sequence greater than since, oldest first.
+func (r *replayBuffer) since(since uint64) []CommitDiff {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	commits := make([]CommitDiff, 0)
+	for i := 0; i < r.count; i++ {
+		c := r.entries[(r.start+i)%len(r.entries)]
+		if c.Sequence <= since {
+			continue
+		}
+		if r.config.MaxAge > 0 && time.Since(c.ReceivedAt) > r.config.MaxAge {
+			continue
+		}
+		commits = append(commits, c)
+	}
+	return commits
+}
+
+// Drops the kept commits that are older than the MaxAge.
+func (r *replayBuffer) prune() {
+	if r.config.MaxAge <= 0 || r.config.Size <= 0 {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for r.count > 0 && time.Since(r.entries[r.start].ReceivedAt) > r.config.MaxAge {
+		r.entries[r.start] = CommitDiff{}
+		r.start = (r.start + 1) % len(r.entries)
+		r.count--
+	}
+}
@@ -0,0 +1,96 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type ReplayTest struct {
+	serverSuite
+}
+
+func (s *ReplayTest) TestKeepsMostRecentCommits() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{Replay: gpoll.ReplayConfig{Size: 2}})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	shas := make([]string, 0)
+	for _, f := range []string{"a.txt", "b.txt", "c.txt"} {
+		s.commit("add "+f, map[string]string{f: f})
+		shas = append(shas, s.receive(c).To.Sha)
+	}
+
+	// -- Then
+	//
+	events := p.Events(0)
+	s.Require().Len(events, 2)
+	s.Equal(shas[1:], []string{events[0].To.Sha, events[1].To.Sha})
+	since := p.Events(events[0].Sequence)
+	s.Require().Len(since, 1)
+	s.Equal(shas[2], since[0].To.Sha)
+	s.Empty(p.Events(events[1].Sequence))
+}
+
+func (s *ReplayTest) TestDropsExpiredCommits() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{Replay: gpoll.ReplayConfig{Size: 10, MaxAge: 100 * time.Millisecond}})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	s.commit("add a", map[string]string{"a.txt": "a"})
+	s.receive(c)
+
+	// -- Then
+	//
+	s.Len(p.Events(0), 1)
+	s.Eventually(func() bool {
+		return len(p.Events(0)) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *ReplayTest) TestDisabledByDefault() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	s.commit("add a", map[string]string{"a.txt": "a"})
+	s.receive(c)
+
+	// -- Then
+	//
+	s.Empty(p.Events(0))
+}
+
+func (s *ReplayTest) TestNegativeSizeKeepsNothingWithoutValidation() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{Replay: gpoll.ReplayConfig{Size: -1}, SkipValidation: true})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	s.commit("add a", map[string]string{"a.txt": "a"})
+	s.receive(c)
+
+	// -- Then
+	//
+	s.Empty(p.Events(0))
+}
+
+func TestReplay(t *testing.T) {
+	suite.Run(t, new(ReplayTest))
+}
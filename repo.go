@@ -0,0 +1,18 @@
+package gpoll
+
+import (
+	"gopkg.in/src-d/go-git.v4"
+)
+
+func (p *poller) Repository() *git.Repository {
+	return p.repository()
+}
+
+func (p *poller) WithRepo(f func(repo *git.Repository) error) error {
+	p.repoLock.Lock()
+	defer p.repoLock.Unlock()
+	if p.repo == nil {
+		return ErrNotStarted
+	}
+	return f(p.repo)
+}
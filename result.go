@@ -0,0 +1,229 @@
+package gpoll
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// The outcome of applying a delivered commit downstream.
+type Outcome int
+
+const (
+	// The commit was applied successfully.
+	OutcomeSuccess Outcome = iota
+
+	// The commit failed to apply.
+	OutcomeFailure
+)
+
+func (o Outcome) String() string {
+	if o == OutcomeSuccess {
+		return "success"
+	}
+	return "failure"
+}
+
+// The result of applying a delivered commit, as reported through ReportResult.
+type Result struct {
+	// The ID of the delivered CommitDiff.
+	EventID string
+
+	// The commit that was applied.
+	Commit Commit
+
+	// Whether the commit was applied successfully.
+	Outcome Outcome
+
+	// Details of the outcome e.g. why it failed.
+	Message string
+
+	// When the result was reported.
+	ReportedAt time.Time
+}
+
+// Reports the result of applying a commit back to the git provider e.g. as a commit status.
+type CommitStatusReporter interface {
+	// Report the result for the commit on the branch.
+	ReportStatus(branch string, result Result) error
+}
+
+var ErrUnknownEvent = errors.New("no delivered commit with that event ID is awaiting a result")
+
+// Tracks delivered commits until a result is reported for them.
+type resultTracker struct {
+	retention RetentionConfig
+	awaiting  map[string]awaitingResult
+	// The IDs in awaiting, oldest first.
+	order []string
+
+	last      *Result
+	succeeded uint64
+	failed    uint64
+
+	// The number of commits awaiting a result whose Snapshot hasn't been released.
+	snapshots int
+}
+
+type awaitingResult struct {
+	commit      Commit
+	deliveredAt time.Time
+	snapshot    *snapshot
+}
+
+func newResultTracker(retention RetentionConfig) *resultTracker {
+	return &resultTracker{
+		retention: retention,
+		awaiting:  make(map[string]awaitingResult),
+	}
+}
+
+func (r *resultTracker) delivered(id string, commit Commit, s *snapshot) {
+	r.awaiting[id] = awaitingResult{
+		commit:      commit,
+		deliveredAt: time.Now(),
+		snapshot:    s,
+	}
+	if s != nil {
+		r.snapshots++
+	}
+	r.order = append(r.order, id)
+	if len(r.order) > r.retention.MaxAwaitingResults {
+		r.forget(r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+// Stops awaiting a result for the commit, releasing its Snapshot.
+func (r *resultTracker) forget(id string) {
+	if s := r.awaiting[id].snapshot; s != nil {
+		s.release()
+		r.snapshots--
+	}
+	delete(r.awaiting, id)
+}
+
+// Forgets commits that have been awaiting a result for longer than the retention allows.
+func (r *resultTracker) prune() {
+	if r.retention.AwaitingResultsMaxAge <= 0 {
+		return
+	}
+	for len(r.order) > 0 && time.Since(r.awaiting[r.order[0]].deliveredAt) > r.retention.AwaitingResultsMaxAge {
+		r.forget(r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+func (r *resultTracker) report(id string, outcome Outcome, message string) (*Result, error) {
+	a, ok := r.awaiting[id]
+	if !ok {
+		return nil, ErrUnknownEvent
+	}
+	r.forget(id)
+	for i, o := range r.order {
+		if o == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+
+	result := &Result{
+		EventID:    id,
+		Commit:     a.commit,
+		Outcome:    outcome,
+		Message:    message,
+		ReportedAt: time.Now(),
+	}
+	r.last = result
+	if outcome == OutcomeSuccess {
+		r.succeeded++
+	} else {
+		r.failed++
+	}
+	return result, nil
+}
+
+func (p *poller) ReportResult(eventID string, outcome Outcome, message string) error {
+	p.lock.Lock()
+	result, err := p.results.report(eventID, outcome, message)
+	p.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if p.config.CommitStatus != nil {
+		if err := p.config.CommitStatus.ReportStatus(p.config.Git.Branch, *result); err != nil {
+			p.onError(err)
+		}
+	}
+	return nil
+}
+
+// Create a CommitStatusReporter backed by the GitHub commit status API. The statusContext distinguishes the status
+// from others on the same commit e.g. "deploy/production". The token requires write access to commit statuses.
+func NewGitHubCommitStatusReporter(owner, repo, token, statusContext string) CommitStatusReporter {
+	return &gitHubCommitStatus{
+		baseUrl: "https://api.github.com",
+		owner:   owner,
+		repo:    repo,
+		token:   token,
+		context: statusContext,
+		client:  http.DefaultClient,
+	}
+}
+
+type gitHubCommitStatus struct {
+	baseUrl string
+	owner   string
+	repo    string
+	token   string
+	context string
+	client  *http.Client
+}
+
+type gitHubStatus struct {
+	State       string `json:"state"`
+	Description string `json:"description,omitempty"`
+	Context     string `json:"context"`
+}
+
+func (g *gitHubCommitStatus) ReportStatus(_ string, result Result) error {
+	// GitHub rejects descriptions longer than 140 characters.
+	description := result.Message
+	if len(description) > 140 {
+		description = description[:137] + "..."
+	}
+	body, err := json.Marshal(gitHubStatus{
+		State:       result.Outcome.String(),
+		Description: description,
+		Context:     g.context,
+	})
+	if err != nil {
+		return err
+	}
+
+	u := fmt.Sprintf("%s/repos/%s/%s/statuses/%s", g.baseUrl, g.owner, g.repo, result.Commit.Sha)
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("github commit status request failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
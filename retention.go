@@ -0,0 +1,96 @@
+package gpoll
+
+import "time"
+
+type RetentionConfig struct {
+	// How often state past its retention is pruned. Defaults to a minute.
+	Interval time.Duration
+
+	// The maximum number of delivered commits that are remembered while awaiting a result through ReportResult. Once
+	// exceeded, the oldest are forgotten. Defaults to 1024.
+	MaxAwaitingResults int
+
+	// How long a delivered commit is remembered while awaiting a result. Defaults to remembering it until it is pushed
+	// out by the MaxAwaitingResults.
+	AwaitingResultsMaxAge time.Duration
+
+	// The maximum number of entries kept in the head log of the Checkpoint Store if it's a PrunableHeadLogStore e.g.
+	// NewFileCheckpointStore. Once exceeded, the oldest are removed. Defaults to no limit.
+	MaxHeadLogEntries int
+
+	// How long entries are kept in the head log of the Checkpoint Store if it's a PrunableHeadLogStore. Defaults to
+	// keeping them forever.
+	HeadLogMaxAge time.Duration
+
+	// The maximum number of records kept by the audit Sink if it's a PrunableAuditSink e.g. NewFileAuditSink. Once
+	// exceeded, the oldest are removed. Defaults to no limit.
+	MaxAuditRecords int
+
+	// How long records are kept by the audit Sink if it's a PrunableAuditSink. Defaults to keeping them forever.
+	AuditMaxAge time.Duration
+}
+
+const (
+	defaultRetentionInterval  = time.Minute
+	defaultMaxAwaitingResults = 1024
+)
+
+// Periodically prunes state past its retention until the loop exits.
+func (p *poller) janitor(done chan struct{}) {
+	ticker := time.NewTicker(p.config.Retention.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.prune()
+		case <-done:
+			return
+		}
+	}
+}
+
+func (p *poller) prune() {
+	p.replay.prune()
+
+	p.lock.Lock()
+	p.results.prune()
+	p.lock.Unlock()
+
+	config := p.config.Retention
+	if store, ok := p.config.Checkpoint.Store.(PrunableHeadLogStore); ok && (config.MaxHeadLogEntries > 0 || config.HeadLogMaxAge > 0) {
+		if err := store.PruneHeads(retainSince(config.HeadLogMaxAge), config.MaxHeadLogEntries); err != nil {
+			p.onError(err)
+		}
+	}
+	if sink, ok := p.config.Audit.Sink.(PrunableAuditSink); ok && (config.MaxAuditRecords > 0 || config.AuditMaxAge > 0) {
+		p.auditLock.Lock()
+		err := sink.PruneAudit(retainSince(config.AuditMaxAge), config.MaxAuditRecords)
+		p.auditLock.Unlock()
+		if err != nil {
+			p.onError(err)
+		}
+	}
+}
+
+// When entries kept for the max age must be newer than. Zero, keeping every entry, if there's no max age.
+func retainSince(maxAge time.Duration) time.Time {
+	if maxAge <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-maxAge)
+}
+
+// The index of the first of n entries, oldest first, that's kept when removing those from before the time and then the
+// oldest beyond the max. A zero time or max doesn't remove by it.
+func retainFrom(n int, at func(i int) time.Time, before time.Time, max int) int {
+	i := 0
+	if !before.IsZero() {
+		for i < n && at(i).Before(before) {
+			i++
+		}
+	}
+	if max > 0 && n-i > max {
+		i = n - max
+	}
+	return i
+}
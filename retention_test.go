@@ -0,0 +1,136 @@
+package gpoll_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type RetentionTest struct {
+	serverSuite
+
+	dir string
+}
+
+func (s *RetentionTest) SetupTest() {
+	s.serverSuite.SetupTest()
+	dir, err := ioutil.TempDir("", "gpoll")
+	s.Require().NoError(err)
+	s.dir = dir
+}
+
+func (s *RetentionTest) TearDownTest() {
+	s.serverSuite.TearDownTest()
+	_ = os.RemoveAll(s.dir)
+}
+
+func (s *RetentionTest) TestPrunesHeadLogByAgeAndCount() {
+	// -- Given
+	//
+	store := gpoll.NewFileCheckpointStore(filepath.Join(s.dir, "checkpoint")).(gpoll.PrunableHeadLogStore)
+	now := time.Now()
+	for i, sha := range []string{"a", "b", "c", "d"} {
+		s.NoError(store.AppendHead(gpoll.HeadLogEntry{Sha: sha, At: now.Add(time.Duration(i-3) * time.Hour)}))
+	}
+
+	// -- When
+	//
+	s.NoError(store.PruneHeads(now.Add(-150*time.Minute), 0))
+	afterAge, _ := store.Heads()
+	s.NoError(store.PruneHeads(time.Time{}, 1))
+	afterCount, _ := store.Heads()
+
+	// -- Then
+	//
+	s.Equal([]string{"b", "c", "d"}, headShas(afterAge))
+	s.Equal([]string{"d"}, headShas(afterCount))
+}
+
+func (s *RetentionTest) TestPrunesFileAuditSink() {
+	// -- Given
+	//
+	fp := filepath.Join(s.dir, "audit.log")
+	sink, err := gpoll.NewFileAuditSink(fp)
+	s.Require().NoError(err)
+	defer sink.Close()
+	now := time.Now()
+	old := gpoll.AuditRecord{Time: now.Add(-2 * time.Hour), Action: gpoll.AuditActionStart}
+	b, err := json.Marshal(old)
+	s.Require().NoError(err)
+	_, err = sink.Write(append(b, '\n'))
+	s.Require().NoError(err)
+	_, err = sink.Write([]byte(gpoll.AuditRecord{Time: now.Add(-2 * time.Hour), Action: gpoll.AuditActionStop}.CEF() + "\n"))
+	s.Require().NoError(err)
+	recent := gpoll.AuditRecord{Time: now, Action: gpoll.AuditActionStop}
+	_, err = sink.Write([]byte(recent.CEF() + "\n"))
+	s.Require().NoError(err)
+
+	// -- When
+	//
+	s.NoError(sink.(gpoll.PrunableAuditSink).PruneAudit(now.Add(-time.Hour), 0))
+	_, err = sink.Write([]byte("after\n"))
+	s.Require().NoError(err)
+
+	// -- Then
+	//
+	b, err = ioutil.ReadFile(fp)
+	s.Require().NoError(err)
+	s.Equal(recent.CEF()+"\nafter\n", string(b))
+}
+
+func (s *RetentionTest) TestJanitorPrunesHeadLogAndAudit() {
+	// -- Given
+	//
+	store := gpoll.NewFileCheckpointStore(filepath.Join(s.dir, "checkpoint"))
+	fp := filepath.Join(s.dir, "audit.log")
+	sink, err := gpoll.NewFileAuditSink(fp)
+	s.Require().NoError(err)
+	defer sink.Close()
+	p := s.newPoller(gpoll.PollConfig{
+		// Only poll when triggered so polls stop adding audit records once the commits are received.
+		Interval:   time.Hour,
+		Checkpoint: gpoll.CheckpointConfig{Store: store},
+		Audit:      gpoll.AuditConfig{Sink: sink},
+		Retention: gpoll.RetentionConfig{
+			Interval:          10 * time.Millisecond,
+			MaxHeadLogEntries: 2,
+			MaxAuditRecords:   3,
+		},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	for _, f := range []string{"a.txt", "b.txt", "c.txt"} {
+		s.commit("add "+f, map[string]string{f: f})
+		p.Trigger()
+		s.receive(c)
+	}
+
+	// -- Then
+	//
+	s.Eventually(func() bool {
+		heads, err := p.HeadLog()
+		b, _ := ioutil.ReadFile(fp)
+		return err == nil && len(heads) <= 2 && bytes.Count(b, []byte("\n")) <= 3
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func headShas(entries []gpoll.HeadLogEntry) []string {
+	shas := make([]string, len(entries))
+	for i, e := range entries {
+		shas[i] = e.Sha
+	}
+	return shas
+}
+
+func TestRetention(t *testing.T) {
+	suite.Run(t, new(RetentionTest))
+}
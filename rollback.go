@@ -0,0 +1,51 @@
+package gpoll
+
+import "time"
+
+// Deliver a synthetic CommitDiff containing the changes that take the last delivered commit back to the older revision,
+// either a sha or a tag, so consumers can apply the rollback through their usual handler. The CommitDiff is marked as a
+// Rollback. Commits made after the rollback are still diffed against their parent, so pin to the revision with PinTo
+// to keep them from being delivered until the rollback is resolved. Returns ErrNotStarted if the poller isn't running,
+// or stops before the CommitDiff is sent on its channel.
+func (p *poller) RollbackTo(revision string) (err error) {
+	defer func() {
+		p.auditOperation("rollback", map[string]string{"revision": revision}, err)
+	}()
+	p.lock.RLock()
+	current, ctx := p.lastDelivered.Sha, p.pollCtx
+	p.lock.RUnlock()
+	if ctx == nil {
+		return ErrNotStarted
+	}
+
+	diff, err := p.rollbackDiff(current, revision)
+	if err != nil {
+		return err
+	}
+	for i := range diff.Changes {
+		diff.Changes[i].Filepath = p.formatPath(diff.Changes[i].Filepath)
+	}
+	diff.Rollback = true
+	diff.ReceivedAt = time.Now()
+
+	return p.deliver(ctx, []CommitDiff{*diff})
+}
+
+// Diffs the current sha back to the revision.
+func (p *poller) rollbackDiff(current, revision string) (*CommitDiff, error) {
+	p.repoLock.Lock()
+	defer p.repoLock.Unlock()
+	if p.repo == nil {
+		return nil, ErrNotStarted
+	}
+	to, err := p.git.ResolveRevision(p.repo, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := p.git.ResolveRevision(p.repo, current)
+	if err != nil {
+		return nil, err
+	}
+	return p.git.Diff(from, to)
+}
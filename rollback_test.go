@@ -0,0 +1,138 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type RollbackTest struct {
+	serverSuite
+}
+
+func (s *RollbackTest) TestRequiresStart() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{})
+
+	// -- When
+	//
+	err := p.RollbackTo("HEAD")
+
+	// -- Then
+	//
+	s.Equal(gpoll.ErrNotStarted, err)
+}
+
+func (s *RollbackTest) TestDeliversRollbackToRevision() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{
+		Replay: gpoll.ReplayConfig{Size: 10, MaxAge: time.Hour},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+	base, err := s.server.Head()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	bad := s.commit("add a", map[string]string{"a.txt": "a"})
+	s.Equal(bad, s.receive(c).To.Sha)
+
+	// -- When
+	//
+	s.NoError(p.RollbackTo(base))
+
+	// -- Then
+	//
+	commit := s.receive(c)
+	s.True(commit.Rollback)
+	s.Equal(bad, commit.From.Sha)
+	s.Equal(base, commit.To.Sha)
+	if s.Len(commit.Changes, 1) {
+		s.Equal("a.txt", filepath.Base(commit.Changes[0].Filepath))
+		s.Equal(gpoll.ChangeTypeDelete, commit.Changes[0].ChangeType)
+	}
+	s.False(commit.ReceivedAt.IsZero())
+
+	replayed := p.Events(0)
+	if s.Len(replayed, 2) {
+		s.True(replayed[1].Rollback)
+	}
+}
+
+func (s *RollbackTest) TestRollsBackFromStartBeforeAnythingIsDelivered() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{
+		Policies: gpoll.PolicyConfig{MaxChangedFiles: 1},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+	base, err := s.server.Head()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	s.commit("add a and b", map[string]string{"a.txt": "a", "b.txt": "b"})
+	s.Eventually(func() bool {
+		return len(p.Quarantine()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// -- When
+	//
+	s.NoError(p.RollbackTo(base))
+
+	// -- Then
+	//
+	commit := s.receive(c)
+	s.True(commit.Rollback)
+	s.Equal(base, commit.From.Sha)
+	s.Equal(base, commit.To.Sha)
+	s.Empty(commit.Changes)
+}
+
+func (s *RollbackTest) TestStopUnblocksRollbackWhileChannelIsFull() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{})
+	s.start(p)
+	base := s.commit("add a", map[string]string{"a.txt": "a"})
+	// The channel buffers the first commit, so the poller waits to send the second.
+	blocked := s.commit("add b", map[string]string{"b.txt": "b"})
+	s.Eventually(func() bool {
+		return p.Status().LastDelivered.Sha == blocked
+	}, 5*time.Second, 10*time.Millisecond)
+	rolledBack := make(chan error, 1)
+	go func() {
+		rolledBack <- p.RollbackTo(base)
+	}()
+
+	// -- When
+	//
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		p.StopAndWait()
+	}()
+
+	// -- Then
+	//
+	select {
+	case err := <-rolledBack:
+		s.Equal(gpoll.ErrNotStarted, err)
+	case <-time.After(5 * time.Second):
+		s.FailNow("the rollback was not unblocked")
+	}
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for the poller to stop")
+	}
+	s.Equal(gpoll.ErrNotStarted, p.RollbackTo(base))
+}
+
+func TestRollback(t *testing.T) {
+	suite.Run(t, new(RollbackTest))
+}
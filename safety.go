@@ -0,0 +1,112 @@
+package gpoll
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// Why a path would be unsafe to write to disk.
+type PathWarningKind int
+
+const (
+	// The path points outside of the root of the repo e.g. it contains "..".
+	PathWarningTraversal PathWarningKind = iota
+
+	// The path is a symbolic link pointing outside of the root of the repo.
+	PathWarningSymlinkEscape
+
+	// The path only differs in case from another path in the same commit, so the two collide on case-insensitive
+	// filesystems e.g. a case-only rename.
+	PathWarningCaseCollision
+)
+
+// Emitted when a path changed in a commit would be unsafe to write to disk. The commit is still delivered, so anything
+// writing changes to disk should check for these first.
+type PathWarning struct {
+	// The commit containing the path.
+	Commit Commit
+
+	// The repo relative path of the file.
+	Filepath string
+
+	// What makes the path unsafe.
+	Kind PathWarningKind
+
+	// Details of the problem e.g. the target of an escaping symlink.
+	Detail string
+}
+
+func (p PathWarning) EventType() EventType {
+	return EventTypePathWarning
+}
+
+func (p PathWarning) String() string {
+	return fmt.Sprintf("unsafe path %s in commit %s: %s", p.Filepath, p.Commit.Sha, p.Detail)
+}
+
+// Checks the paths changed by a commit for anything that would be unsafe to write to disk.
+func checkPaths(commit CommitDiff, relativePath func(string) string) []PathWarning {
+	warnings := make([]PathWarning, 0)
+	folded := make(map[string]string)
+	for _, c := range commit.Changes {
+		fp := relativePath(c.Filepath)
+		if escapesRoot(fp) {
+			warnings = append(warnings, PathWarning{
+				Commit:   commit.To,
+				Filepath: fp,
+				Kind:     PathWarningTraversal,
+				Detail:   "path escapes the root of the repo",
+			})
+		}
+
+		if c.Symlink != "" && symlinkEscapes(fp, c.Symlink) {
+			warnings = append(warnings, PathWarning{
+				Commit:   commit.To,
+				Filepath: fp,
+				Kind:     PathWarningSymlinkEscape,
+				Detail:   fmt.Sprintf("symlink target %s escapes the root of the repo", c.Symlink),
+			})
+		}
+
+		key := strings.ToLower(fp)
+		if other, ok := folded[key]; ok && other != fp {
+			warnings = append(warnings, PathWarning{
+				Commit:   commit.To,
+				Filepath: fp,
+				Kind:     PathWarningCaseCollision,
+				Detail:   fmt.Sprintf("collides with %s on case-insensitive filesystems", other),
+			})
+		}
+		folded[key] = fp
+	}
+	return warnings
+}
+
+// Whether the slash separated path points outside of the root of the repo. Backslashes are treated as separators too
+// since they are on Windows.
+func escapesRoot(fp string) bool {
+	fp = strings.Replace(fp, `\`, "/", -1)
+	if isAbs(fp) {
+		return true
+	}
+	clean := path.Clean(fp)
+	return clean == ".." || strings.HasPrefix(clean, "../")
+}
+
+// Whether the target of the symlink at the slash separated path points outside of the root of the repo. An absolute
+// target always does.
+func symlinkEscapes(fp, target string) bool {
+	target = strings.Replace(target, `\`, "/", -1)
+	return isAbs(target) || escapesRoot(path.Join(path.Dir(fp), target))
+}
+
+// Whether the slash separated path is absolute, including paths starting with a Windows drive e.g. C:/.
+func isAbs(fp string) bool {
+	if path.IsAbs(fp) {
+		return true
+	}
+	drive := len(fp) >= 2 && fp[1] == ':' && unicode.IsLetter(rune(fp[0]))
+	return drive && (len(fp) == 2 || fp[2] == '/')
+}
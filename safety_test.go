@@ -0,0 +1,90 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type SafetyTest struct {
+	serverSuite
+}
+
+func (s *SafetyTest) TestCheckPaths() {
+	tests := []struct {
+		name     string
+		change   gpoll.FileChange
+		expected []gpoll.PathWarningKind
+	}{
+		{"safe", gpoll.FileChange{Filepath: "a/b.txt"}, nil},
+		{"colon", gpoll.FileChange{Filepath: "a:b.txt"}, nil},
+		{"traversal", gpoll.FileChange{Filepath: "a/../../b.txt"}, []gpoll.PathWarningKind{gpoll.PathWarningTraversal}},
+		{"backslash traversal", gpoll.FileChange{Filepath: `a\..\..\b.txt`},
+			[]gpoll.PathWarningKind{gpoll.PathWarningTraversal}},
+		{"absolute", gpoll.FileChange{Filepath: "/etc/passwd"}, []gpoll.PathWarningKind{gpoll.PathWarningTraversal}},
+		{"safe symlink", gpoll.FileChange{Filepath: "a/link", Symlink: "../b.txt"}, nil},
+		{"escaping symlink", gpoll.FileChange{Filepath: "a/link", Symlink: "../../etc/passwd"},
+			[]gpoll.PathWarningKind{gpoll.PathWarningSymlinkEscape}},
+		{"absolute symlink", gpoll.FileChange{Filepath: "link", Symlink: "/etc/passwd"},
+			[]gpoll.PathWarningKind{gpoll.PathWarningSymlinkEscape}},
+		{"drive symlink", gpoll.FileChange{Filepath: "link", Symlink: `C:\Windows`},
+			[]gpoll.PathWarningKind{gpoll.PathWarningSymlinkEscape}},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			// -- When
+			//
+			warnings := gpoll.CheckPaths(gpoll.CommitDiff{Changes: []gpoll.FileChange{test.change}})
+
+			// -- Then
+			//
+			kinds := make([]gpoll.PathWarningKind, 0)
+			for _, w := range warnings {
+				kinds = append(kinds, w.Kind)
+			}
+			if test.expected == nil {
+				s.Empty(kinds)
+			} else {
+				s.Equal(test.expected, kinds)
+			}
+		})
+	}
+}
+
+func (s *SafetyTest) TestWarnsOfCaseCollisions() {
+	// -- Given
+	//
+	warnings := make(chan gpoll.PathWarning, 10)
+	p := s.newPoller(gpoll.PollConfig{
+		HandleEvent: func(event gpoll.Event) {
+			if w, ok := event.(gpoll.PathWarning); ok {
+				select {
+				case warnings <- w:
+				default:
+				}
+			}
+		},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	sha := s.commit("add readme", map[string]string{"README.md": "a", "readme.md": "b"})
+
+	// -- Then
+	//
+	s.Equal(sha, s.receive(c).To.Sha)
+	select {
+	case w := <-warnings:
+		s.Equal(gpoll.PathWarningCaseCollision, w.Kind)
+		s.Equal(sha, w.Commit.Sha)
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for a path warning")
+	}
+}
+
+func TestSafety(t *testing.T) {
+	suite.Run(t, new(SafetyTest))
+}
@@ -0,0 +1,132 @@
+package gpoll
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+type SecretScanConfig struct {
+	// Named regular expressions that are matched line by line against the content of every file created or updated in
+	// a commit e.g. {"aws-access-key": "AKIA[0-9A-Z]{16}"}.
+	Rules map[string]string
+
+	// Function for scanning the content of every file created or updated in a commit. Use this to plug in an external
+	// secret scanner. Runs in addition to the Rules.
+	Scanner ScanSecretsFunc
+
+	// Whether a commit with findings should halt delivery in the same way as a PolicyActionHalt. A commit that fails to
+	// be scanned, e.g. because the Scanner failed, is held as well. If false, findings are only reported.
+	Block bool
+}
+
+// Scans the content of the file at the repo relative path fp and returns all secrets found.
+type ScanSecretsFunc func(fp string, content []byte) ([]SecretMatch, error)
+
+// A potential secret found within a file.
+type SecretMatch struct {
+	// The name of the rule that matched.
+	Rule string
+
+	// The line number of the match, starting at 1.
+	Line int
+}
+
+// Emitted when a secret is found within a commit.
+type SecurityFinding struct {
+	// The commit containing the secret.
+	Commit Commit
+
+	// The repo relative path of the file containing the secret.
+	Filepath string
+
+	// The secret that was found. The secret's value is never included.
+	Match SecretMatch
+
+	// Whether delivery was halted because of the finding.
+	Blocked bool
+}
+
+func (s SecurityFinding) EventType() EventType {
+	return EventTypeSecurityFinding
+}
+
+func (s SecurityFinding) String() string {
+	return fmt.Sprintf("commit %s contains a potential %s secret at %s:%d", s.Commit.Sha, s.Match.Rule, s.Filepath,
+		s.Match.Line)
+}
+
+func compileSecretRules(rules map[string]string) (map[string]*regexp.Regexp, error) {
+	compiled := make(map[string]*regexp.Regexp, len(rules))
+	for name, rule := range rules {
+		r, err := regexp.Compile(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret rule %s: %s", name, err.Error())
+		}
+		compiled[name] = r
+	}
+	return compiled, nil
+}
+
+func scanWithRules(rules map[string]*regexp.Regexp, content []byte) []SecretMatch {
+	matches := make([]SecretMatch, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	line := 0
+	for scanner.Scan() {
+		line++
+		for name, r := range rules {
+			if r.Match(scanner.Bytes()) {
+				matches = append(matches, SecretMatch{
+					Rule: name,
+					Line: line,
+				})
+			}
+		}
+	}
+	return matches
+}
+
+// Scans every created or updated file in the commit for secrets and returns whether the commit should be blocked.
+func (p *poller) scanSecrets(commit CommitDiff) (bool, error) {
+	config := p.config.SecretScanning
+	if len(p.secretRules) == 0 && config.Scanner == nil {
+		return false, nil
+	}
+
+	found := false
+	for _, c := range commit.Changes {
+		if c.ChangeType == ChangeTypeDelete {
+			continue
+		}
+
+		fp := p.relativePath(c.Filepath)
+		p.repoLock.Lock()
+		content, err := p.git.ReadFile(p.repo, commit.To.Sha, fp)
+		p.repoLock.Unlock()
+		if err != nil {
+			return false, err
+		}
+
+		matches := scanWithRules(p.secretRules, content)
+		if config.Scanner != nil {
+			ms, err := config.Scanner(fp, content)
+			if err != nil {
+				return false, err
+			}
+			matches = append(matches, ms...)
+		}
+
+		for _, m := range matches {
+			found = true
+			p.emit(SecurityFinding{
+				Commit:   commit.To,
+				Filepath: fp,
+				Match:    m,
+				Blocked:  config.Block,
+			})
+		}
+	}
+	return found && config.Block, nil
+}
@@ -0,0 +1,146 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/gpolltest/server"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// A suite polling a fresh gpolltest Server per test.
+type serverSuite struct {
+	suite.Suite
+
+	server *server.Server
+}
+
+func (s *serverSuite) SetupTest() {
+	srv, err := server.New()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	s.server = srv
+}
+
+func (s *serverSuite) TearDownTest() {
+	s.server.Close()
+}
+
+// Create a poller of the server with the config, failing the test on error.
+func (s *serverSuite) newPoller(config gpoll.PollConfig) gpoll.Poller {
+	if config.Git.Remote == "" {
+		config.Git = s.server.GitConfig()
+	}
+	if config.Interval == 0 {
+		config.Interval = 10 * time.Millisecond
+	}
+	p, err := gpoll.NewPoller(config)
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	return p
+}
+
+// Start the poller, failing the test on error.
+func (s *serverSuite) start(p gpoll.Poller) chan gpoll.CommitDiff {
+	c, err := p.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	return c
+}
+
+// Commit the files to the server, failing the test on error.
+func (s *serverSuite) commit(message string, files map[string]string) string {
+	sha, err := s.server.Commit(message, files)
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	return sha
+}
+
+func (s *serverSuite) receive(c chan gpoll.CommitDiff) gpoll.CommitDiff {
+	select {
+	case commit := <-c:
+		return commit
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for a commit")
+	}
+	return gpoll.CommitDiff{}
+}
+
+// Assert nothing is received within the duration.
+func (s *serverSuite) receiveNone(c chan gpoll.CommitDiff, d time.Duration) {
+	select {
+	case commit := <-c:
+		s.Failf("unexpected commit", "received %s", commit.To.Sha)
+	case <-time.After(d):
+	}
+}
+
+// Proxies a server, counting the requests for its refs. While hanging, requests block until they're cancelled.
+type front struct {
+	*httptest.Server
+
+	// The git config of the server reached through the front.
+	config    gpoll.GitConfig
+	refs      int32
+	hanging   int32
+	cancelled chan struct{}
+}
+
+func newFront(srv *server.Server) (*front, error) {
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		return nil, err
+	}
+	f := &front{cancelled: make(chan struct{}, 1)}
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: target.Scheme, Host: target.Host})
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&f.hanging) == 1 {
+			<-r.Context().Done()
+			select {
+			case f.cancelled <- struct{}{}:
+			default:
+			}
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/info/refs") {
+			atomic.AddInt32(&f.refs, 1)
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	f.config = srv.GitConfig()
+	f.config.Remote = f.URL + target.Path
+	return f, nil
+}
+
+// Make every following request hang until it's cancelled.
+func (f *front) hang() {
+	atomic.StoreInt32(&f.hanging, 1)
+}
+
+// Stop hanging, serving every following request.
+func (f *front) resume() {
+	atomic.StoreInt32(&f.hanging, 0)
+}
+
+// The number of times the refs were requested.
+func (f *front) refsRequested() int {
+	return int(atomic.LoadInt32(&f.refs))
+}
+
+// Create a front of the server, failing the test on error.
+func (s *serverSuite) newFront(srv *server.Server) *front {
+	f, err := newFront(srv)
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	return f
+}
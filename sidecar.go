@@ -0,0 +1,205 @@
+package gpoll
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var errNoPipes = errors.New("named pipes are not supported on this platform")
+
+type SidecarConfig struct {
+	// A Unix domain socket to listen on. Every connected client receives every line. Exactly one of Socket or Pipe is
+	// required.
+	Socket string
+
+	// A named pipe to write to. Created if it doesn't exist. Opening the pipe blocks until a reader opens it. Once the
+	// reader goes away, the pipe is reopened on the next line and lines are dropped until a reader opens it again.
+	Pipe string
+
+	// How long writing a line may take. A client of the Socket that takes longer is disconnected so it can't hold up
+	// the others. Defaults to 5s.
+	WriteTimeout time.Duration
+
+	// Called when a line can't be written.
+	OnError func(err error)
+}
+
+// Writes every commit and event as newline delimited JSON to a Unix domain socket or a named pipe so processes written
+// in any language can consume them. Use HandleCommit as the HandleCommit and HandleEvent as the HandleEvent of a
+// PollConfig.
+type Sidecar struct {
+	config  SidecarConfig
+	lock    sync.Mutex
+	clients map[net.Conn]struct{}
+	// The named pipe being written to. nil if writing to a socket.
+	pipe     *os.File
+	listener net.Listener
+	closed   bool
+}
+
+// A single line written by the Sidecar.
+type sidecarMessage struct {
+	// Either commit or event.
+	Type   string      `json:"type"`
+	Commit *CommitDiff `json:"commit,omitempty"`
+	// The name of the event type e.g. policy-violation.
+	Event string      `json:"event,omitempty"`
+	Data  interface{} `json:"data,omitempty"`
+}
+
+// Create a Sidecar from config, listening on its Socket or opening its Pipe.
+func NewSidecar(config SidecarConfig) (*Sidecar, error) {
+	if (config.Socket == "") == (config.Pipe == "") {
+		return nil, errors.New("exactly one of Socket or Pipe is required")
+	}
+	if config.WriteTimeout == 0 {
+		config.WriteTimeout = 5 * time.Second
+	}
+
+	s := &Sidecar{
+		config:  config,
+		clients: make(map[net.Conn]struct{}),
+	}
+	if config.Pipe != "" {
+		return s, s.openPipe()
+	}
+	return s, s.listen()
+}
+
+// Write the commit as a line.
+func (s *Sidecar) HandleCommit(commit CommitDiff) {
+	s.write(sidecarMessage{Type: "commit", Commit: &commit})
+}
+
+// Write the event as a line. Errors within the event are written as their messages.
+func (s *Sidecar) HandleEvent(event Event) {
+	s.write(sidecarMessage{Type: "event", Event: event.EventType().String(), Data: eventData(event)})
+}
+
+// Disconnect every client and stop listening or close the pipe.
+func (s *Sidecar) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	for conn := range s.clients {
+		_ = conn.Close()
+		delete(s.clients, conn)
+	}
+	if s.config.Pipe != "" {
+		if s.pipe == nil {
+			return nil
+		}
+		return s.pipe.Close()
+	}
+	return s.listener.Close()
+}
+
+func (s *Sidecar) listen() error {
+	// Remove a socket left behind by a previous run. Anything else at the path is left for Listen to fail on.
+	if fi, err := os.Lstat(s.config.Socket); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(s.config.Socket); err != nil {
+			return err
+		}
+	}
+	l, err := net.Listen("unix", s.config.Socket)
+	if err != nil {
+		return err
+	}
+	s.listener = l
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.lock.Lock()
+			if s.closed {
+				_ = conn.Close()
+			} else {
+				s.clients[conn] = struct{}{}
+			}
+			s.lock.Unlock()
+		}
+	}()
+	return nil
+}
+
+func (s *Sidecar) openPipe() error {
+	if _, err := os.Stat(s.config.Pipe); os.IsNotExist(err) {
+		if err := mkfifo(s.config.Pipe); err != nil {
+			return err
+		}
+	}
+
+	// Opening a named pipe for writing blocks until a reader opens it.
+	f, err := os.OpenFile(s.config.Pipe, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	s.pipe = f
+	return nil
+}
+
+func (s *Sidecar) write(m sidecarMessage) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		s.onError(err)
+		return
+	}
+	b = append(b, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return
+	}
+	if s.config.Pipe != "" {
+		s.writePipe(b)
+	}
+	for conn := range s.clients {
+		_ = conn.SetWriteDeadline(time.Now().Add(s.config.WriteTimeout))
+		if _, err := conn.Write(b); err != nil {
+			// The client went away or is too slow to keep up.
+			_ = conn.Close()
+			delete(s.clients, conn)
+		}
+	}
+}
+
+// Writes the line to the pipe, reopening it if its reader went away. Must hold the lock.
+func (s *Sidecar) writePipe(b []byte) {
+	if s.pipe == nil {
+		// Opened without blocking so the line is dropped rather than waiting for a reader.
+		f, err := os.OpenFile(s.config.Pipe, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+		if err != nil {
+			s.onError(err)
+			return
+		}
+		s.pipe = f
+	}
+
+	// Not every platform can time out writes to a pipe, in which case the write blocks.
+	_ = s.pipe.SetWriteDeadline(time.Now().Add(s.config.WriteTimeout))
+	if _, err := s.pipe.Write(b); err != nil {
+		s.onError(err)
+		if errors.Is(err, syscall.EPIPE) {
+			_ = s.pipe.Close()
+			s.pipe = nil
+		}
+	}
+}
+
+func (s *Sidecar) onError(err error) {
+	if s.config.OnError != nil {
+		s.config.OnError(err)
+	}
+}
@@ -0,0 +1,226 @@
+package gpoll_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type SidecarTest struct {
+	suite.Suite
+
+	dir    string
+	socket string
+}
+
+func (s *SidecarTest) SetupTest() {
+	dir, err := ioutil.TempDir("", "gpoll")
+	s.Require().NoError(err)
+	s.dir = dir
+	s.socket = filepath.Join(dir, "sidecar.sock")
+}
+
+func (s *SidecarTest) TearDownTest() {
+	_ = os.RemoveAll(s.dir)
+}
+
+func (s *SidecarTest) TestWritesErrorsAsMessages() {
+	// -- Given
+	//
+	sc := s.newSidecar(time.Second)
+	defer sc.Close()
+	lines := s.connect()
+
+	// -- When
+	//
+	sc.HandleEvent(gpoll.Quarantined{Err: errors.New("bad commit")})
+
+	// -- Then
+	//
+	var m struct {
+		Event string
+		Data  struct {
+			Err string
+		}
+	}
+	s.Require().NoError(json.Unmarshal(s.receive(lines), &m))
+	s.Equal("quarantined", m.Event)
+	s.Equal("bad commit", m.Data.Err)
+}
+
+func (s *SidecarTest) TestDropsSlowClient() {
+	// -- Given
+	//
+	sc := s.newSidecar(50 * time.Millisecond)
+	defer sc.Close()
+	slow, err := net.Dial("unix", s.socket)
+	s.Require().NoError(err)
+	defer slow.Close()
+	lines := s.connect()
+	message := strings.Repeat("a", 64*1024)
+
+	// -- When
+	//
+	start := time.Now()
+	for i := 0; i < 64; i++ {
+		sc.HandleCommit(gpoll.CommitDiff{ID: "commit", To: gpoll.Commit{Message: message}})
+		s.receive(lines)
+	}
+
+	// -- Then
+	//
+	s.Less(int64(time.Since(start)), int64(5*time.Second))
+	_, err = ioutil.ReadAll(slow)
+	s.NoError(err)
+}
+
+func (s *SidecarTest) TestRequiresOneOutput() {
+	// -- When
+	//
+	_, none := gpoll.NewSidecar(gpoll.SidecarConfig{})
+	_, both := gpoll.NewSidecar(gpoll.SidecarConfig{Socket: s.socket, Pipe: s.socket})
+
+	// -- Then
+	//
+	s.Error(none)
+	s.Error(both)
+}
+
+func (s *SidecarTest) TestLeavesFileThatIsNotSocket() {
+	// -- Given
+	//
+	s.Require().NoError(ioutil.WriteFile(s.socket, []byte("data"), 0600))
+
+	// -- When
+	//
+	_, err := gpoll.NewSidecar(gpoll.SidecarConfig{Socket: s.socket})
+
+	// -- Then
+	//
+	s.Error(err)
+	b, err := ioutil.ReadFile(s.socket)
+	s.NoError(err)
+	s.Equal("data", string(b))
+}
+
+func (s *SidecarTest) TestReplacesStaleSocket() {
+	// -- Given
+	//
+	l, err := net.Listen("unix", s.socket)
+	s.Require().NoError(err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	s.Require().NoError(l.Close())
+
+	// -- When
+	//
+	sc := s.newSidecar(time.Second)
+	defer sc.Close()
+
+	// -- Then
+	//
+	lines := s.connect()
+	sc.HandleCommit(gpoll.CommitDiff{ID: "commit"})
+	s.Contains(string(s.receive(lines)), `"commit"`)
+}
+
+func (s *SidecarTest) TestReopensPipeAfterReaderGoesAway() {
+	if runtime.GOOS == "windows" {
+		s.T().Skip("named pipes are not supported on this platform")
+	}
+	// -- Given
+	//
+	pipe := filepath.Join(s.dir, "sidecar.pipe")
+	errs := make(chan error, 10)
+	created := make(chan *gpoll.Sidecar, 1)
+	go func() {
+		sc, err := gpoll.NewSidecar(gpoll.SidecarConfig{Pipe: pipe, OnError: func(err error) { errs <- err }})
+		s.NoError(err)
+		created <- sc
+	}()
+	s.Eventually(func() bool {
+		_, err := os.Stat(pipe)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	first, err := os.OpenFile(pipe, os.O_RDONLY, 0)
+	s.Require().NoError(err)
+	sc := <-created
+	s.Require().NotNil(sc)
+	defer sc.Close()
+	sc.HandleCommit(gpoll.CommitDiff{ID: "first"})
+	line, err := bufio.NewReader(first).ReadString('\n')
+	s.Require().NoError(err)
+	s.Contains(line, `"first"`)
+
+	// -- When
+	//
+	s.Require().NoError(first.Close())
+	sc.HandleCommit(gpoll.CommitDiff{ID: "dropped"})
+	second, err := os.OpenFile(pipe, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	s.Require().NoError(err)
+	defer second.Close()
+	sc.HandleCommit(gpoll.CommitDiff{ID: "second"})
+
+	// -- Then
+	//
+	select {
+	case err := <-errs:
+		s.True(errors.Is(err, syscall.EPIPE), err.Error())
+	default:
+		s.Fail("the broken pipe was not reported")
+	}
+	line, err = bufio.NewReader(second).ReadString('\n')
+	s.Require().NoError(err)
+	s.Contains(line, `"second"`)
+}
+
+func (s *SidecarTest) newSidecar(timeout time.Duration) *gpoll.Sidecar {
+	sc, err := gpoll.NewSidecar(gpoll.SidecarConfig{Socket: s.socket, WriteTimeout: timeout})
+	s.Require().NoError(err)
+	return sc
+}
+
+// Connect a client that reads every line into the returned channel.
+func (s *SidecarTest) connect() chan []byte {
+	conn, err := net.Dial("unix", s.socket)
+	s.Require().NoError(err)
+	lines := make(chan []byte, 100)
+	go func() {
+		defer conn.Close()
+		r := bufio.NewReaderSize(conn, 1<<20)
+		for {
+			b, err := r.ReadBytes('\n')
+			if err != nil {
+				return
+			}
+			lines <- b
+		}
+	}()
+	// Wait for the sidecar to accept the client.
+	time.Sleep(50 * time.Millisecond)
+	return lines
+}
+
+func (s *SidecarTest) receive(lines chan []byte) []byte {
+	select {
+	case b := <-lines:
+		return b
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for a line")
+	}
+	return nil
+}
+
+func TestSidecar(t *testing.T) {
+	suite.Run(t, new(SidecarTest))
+}
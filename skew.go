@@ -0,0 +1,83 @@
+package gpoll
+
+import (
+	"sort"
+	"time"
+)
+
+const defaultFutureSkew = 5 * time.Minute
+
+// Tolerances for the timestamps of commits made on machines with a wrong clock. Commits dated beyond them are flagged
+// through Commit.ClockSkew, and ordered as if they were made when received under the time based CommitOrders, so bad
+// author clocks don't corrupt downstream logic relying on commit times.
+type ClockSkewConfig struct {
+	// How far after it was received a commit may be dated. Defaults to 5 minutes.
+	Future time.Duration
+
+	// How far before it was received a commit may be dated. Defaults to not flagging commits for being old, since
+	// commits are routinely pushed long after they were made.
+	Past time.Duration
+
+	// Don't detect skew at all.
+	Disabled bool
+}
+
+// How far the time is outside the tolerances around the receivedAt. Positive if in the future, zero if within them.
+func (c ClockSkewConfig) skew(when, receivedAt time.Time) time.Duration {
+	offset := when.Sub(receivedAt)
+	if offset > c.Future {
+		return offset
+	}
+	if c.Past > 0 && -offset > c.Past {
+		return offset
+	}
+	return 0
+}
+
+// Flags the commits dated outside the tolerances around when they were received, and moves them to when they were
+// received under the time based orders.
+func (p *poller) detectClockSkew(changes []CommitDiff, receivedAt time.Time) {
+	config := p.config.ClockSkew
+	if config.Disabled {
+		return
+	}
+	skewed := false
+	for i := range changes {
+		to := &changes[i].To
+		skew := config.skew(to.When, receivedAt)
+		if committed := config.skew(to.Committer.When, receivedAt); abs(committed) > abs(skew) {
+			skew = committed
+		}
+		to.ClockSkew = skew
+		skewed = skewed || skew != 0
+	}
+	if !skewed {
+		return
+	}
+
+	var when func(c Commit) time.Time
+	switch p.config.Git.Order {
+	case CommitOrderCommitTime:
+		when = func(c Commit) time.Time { return c.Committer.When }
+	case CommitOrderAuthorTime:
+		when = func(c Commit) time.Time { return c.Author.When }
+	default:
+		return
+	}
+	normalized := func(c Commit) time.Time {
+		if c.ClockSkew != 0 {
+			return receivedAt
+		}
+		return when(c)
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return normalized(changes[i].To).Before(normalized(changes[j].To))
+	})
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,348 @@
+package gpoll
+
+import (
+	"bytes"
+	"errors"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/helper/chroot"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var ErrSnapshotReleased = errors.New("the snapshot was released once a result was reported for its commit")
+
+// The most symlinks followed when resolving a path, as per Linux.
+const maxSymlinks = 40
+
+// A read-only view of the files of the repo at the To commit of a delivered CommitDiff. Reads go straight to the git
+// objects of the clone so the view is unaffected by later polls.
+type snapshot struct {
+	p        *poller
+	commit   Commit
+	released int32
+}
+
+func (p *poller) newSnapshot(commit Commit) *snapshot {
+	return &snapshot{
+		p:      p,
+		commit: commit,
+	}
+}
+
+// Called once the commit is no longer awaiting a result.
+func (s *snapshot) release() {
+	atomic.StoreInt32(&s.released, 1)
+}
+
+// Calls f with the tree of the commit while holding the repoLock.
+func (s *snapshot) read(f func(repo *git.Repository, tree *object.Tree) error) error {
+	if atomic.LoadInt32(&s.released) == 1 {
+		return ErrSnapshotReleased
+	}
+	s.p.repoLock.Lock()
+	defer s.p.repoLock.Unlock()
+	if s.p.repo == nil {
+		return ErrNotStarted
+	}
+	c, err := s.p.repo.CommitObject(plumbing.NewHash(s.commit.Sha))
+	if err != nil {
+		return err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return err
+	}
+	return f(s.p.repo, tree)
+}
+
+func (s *snapshot) Open(filename string) (billy.File, error) {
+	return s.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (s *snapshot) OpenFile(filename string, flag int, _ os.FileMode) (billy.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, billy.ErrReadOnly
+	}
+	var content []byte
+	err := s.read(func(repo *git.Repository, tree *object.Tree) error {
+		_, e, err := follow(repo, tree, "open", filename)
+		if err != nil {
+			return err
+		}
+		if e.Mode == filemode.Dir || e.Mode == filemode.Submodule {
+			return &os.PathError{Op: "open", Path: filename, Err: errors.New("is a directory")}
+		}
+		content, err = readBlob(repo, e.Hash)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotFile{
+		name:   filename,
+		Reader: bytes.NewReader(content),
+	}, nil
+}
+
+func (s *snapshot) Stat(filename string) (os.FileInfo, error) {
+	var info os.FileInfo
+	err := s.read(func(repo *git.Repository, tree *object.Tree) error {
+		name, e, err := follow(repo, tree, "stat", filename)
+		if err != nil {
+			return err
+		}
+		info, err = s.info(repo, name, e)
+		return err
+	})
+	return info, err
+}
+
+func (s *snapshot) Lstat(filename string) (os.FileInfo, error) {
+	var info os.FileInfo
+	err := s.read(func(repo *git.Repository, tree *object.Tree) error {
+		name := cleanSnapshotPath(filename)
+		e, err := lookup(tree, "lstat", name)
+		if err != nil {
+			return err
+		}
+		info, err = s.info(repo, name, e)
+		return err
+	})
+	return info, err
+}
+
+func (s *snapshot) ReadDir(dirname string) ([]os.FileInfo, error) {
+	var infos []os.FileInfo
+	err := s.read(func(repo *git.Repository, tree *object.Tree) error {
+		name, e, err := follow(repo, tree, "readdir", dirname)
+		if err != nil {
+			return err
+		}
+		if e.Mode != filemode.Dir {
+			return &os.PathError{Op: "readdir", Path: dirname, Err: errors.New("not a directory")}
+		}
+		dir := tree
+		if name != "" {
+			if dir, err = tree.Tree(name); err != nil {
+				return err
+			}
+		}
+		infos = make([]os.FileInfo, 0, len(dir.Entries))
+		for i := range dir.Entries {
+			info, err := s.info(repo, path.Join(name, dir.Entries[i].Name), &dir.Entries[i])
+			if err != nil {
+				return err
+			}
+			infos = append(infos, info)
+		}
+		return nil
+	})
+	return infos, err
+}
+
+func (s *snapshot) Readlink(link string) (string, error) {
+	var target string
+	err := s.read(func(repo *git.Repository, tree *object.Tree) error {
+		e, err := lookup(tree, "readlink", cleanSnapshotPath(link))
+		if err != nil {
+			return err
+		}
+		if e.Mode != filemode.Symlink {
+			return &os.PathError{Op: "readlink", Path: link, Err: errors.New("not a symlink")}
+		}
+		b, err := readBlob(repo, e.Hash)
+		target = string(b)
+		return err
+	})
+	return target, err
+}
+
+func (s *snapshot) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (s *snapshot) Chroot(p string) (billy.Filesystem, error) {
+	return chroot.New(s, p), nil
+}
+
+func (s *snapshot) Root() string {
+	return "/"
+}
+
+func (s *snapshot) Capabilities() billy.Capability {
+	return billy.ReadCapability | billy.SeekCapability
+}
+
+func (s *snapshot) Create(string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (s *snapshot) Rename(string, string) error {
+	return billy.ErrReadOnly
+}
+
+func (s *snapshot) Remove(string) error {
+	return billy.ErrReadOnly
+}
+
+func (s *snapshot) TempFile(string, string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (s *snapshot) MkdirAll(string, os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+func (s *snapshot) Symlink(string, string) error {
+	return billy.ErrReadOnly
+}
+
+// Describes the entry at the path. Files are dated by the commit.
+func (s *snapshot) info(repo *git.Repository, name string, e *object.TreeEntry) (os.FileInfo, error) {
+	mode, err := e.Mode.ToOSFileMode()
+	if err != nil {
+		return nil, err
+	}
+	var size int64
+	if e.Mode.IsFile() {
+		blob, err := repo.BlobObject(e.Hash)
+		if err != nil {
+			return nil, err
+		}
+		size = blob.Size
+	}
+	base := path.Base(name)
+	if name == "" {
+		base = "/"
+	}
+	return &snapshotFileInfo{
+		name:    base,
+		size:    size,
+		mode:    mode,
+		modTime: s.commit.When,
+	}, nil
+}
+
+// The path relative to the root of the repo with forward slashes. Empty for the root.
+func cleanSnapshotPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+// Finds the entry at the path, which must be clean. The root is a directory entry of its own.
+func lookup(tree *object.Tree, op, name string) (*object.TreeEntry, error) {
+	if name == "" {
+		return &object.TreeEntry{Mode: filemode.Dir, Hash: tree.Hash}, nil
+	}
+	e, err := tree.FindEntry(name)
+	if err == object.ErrEntryNotFound || err == object.ErrDirectoryNotFound || err == object.ErrFileNotFound {
+		return nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return e, err
+}
+
+// Finds the entry at the path, following symlinks within the repo. Returns the path the entry was found at.
+func follow(repo *git.Repository, tree *object.Tree, op, filename string) (string, *object.TreeEntry, error) {
+	name := cleanSnapshotPath(filename)
+	for i := 0; i < maxSymlinks; i++ {
+		e, err := lookup(tree, op, name)
+		if err != nil || e.Mode != filemode.Symlink {
+			return name, e, err
+		}
+		target, err := readBlob(repo, e.Hash)
+		if err != nil {
+			return "", nil, err
+		}
+		if path.IsAbs(string(target)) {
+			return "", nil, &os.PathError{Op: op, Path: filename, Err: billy.ErrCrossedBoundary}
+		}
+		name = path.Join(path.Dir(name), string(target))
+		if name == ".." || strings.HasPrefix(name, "../") {
+			return "", nil, &os.PathError{Op: op, Path: filename, Err: billy.ErrCrossedBoundary}
+		}
+		name = cleanSnapshotPath(name)
+	}
+	return "", nil, &os.PathError{Op: op, Path: filename, Err: errors.New("too many levels of symbolic links")}
+}
+
+func readBlob(repo *git.Repository, h plumbing.Hash) ([]byte, error) {
+	blob, err := repo.BlobObject(h)
+	if err != nil {
+		return nil, err
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// A file of a snapshot, read into memory when opened.
+type snapshotFile struct {
+	*bytes.Reader
+	name string
+}
+
+func (s *snapshotFile) Name() string {
+	return s.name
+}
+
+func (s *snapshotFile) Write([]byte) (int, error) {
+	return 0, billy.ErrReadOnly
+}
+
+func (s *snapshotFile) Truncate(int64) error {
+	return billy.ErrReadOnly
+}
+
+func (s *snapshotFile) Close() error {
+	return nil
+}
+
+func (s *snapshotFile) Lock() error {
+	return nil
+}
+
+func (s *snapshotFile) Unlock() error {
+	return nil
+}
+
+type snapshotFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (s *snapshotFileInfo) Name() string {
+	return s.name
+}
+
+func (s *snapshotFileInfo) Size() int64 {
+	return s.size
+}
+
+func (s *snapshotFileInfo) Mode() os.FileMode {
+	return s.mode
+}
+
+func (s *snapshotFileInfo) ModTime() time.Time {
+	return s.modTime
+}
+
+func (s *snapshotFileInfo) IsDir() bool {
+	return s.mode.IsDir()
+}
+
+func (s *snapshotFileInfo) Sys() interface{} {
+	return nil
+}
@@ -0,0 +1,206 @@
+package gpoll
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"math/big"
+	"strings"
+)
+
+// Restricts the algorithms negotiated with SSH remotes and the types of keys offered to them, e.g. to meet a policy
+// forbidding SHA-1. Every list is in order of preference and defaults to everything golang.org/x/crypto/ssh supports.
+type SshCryptoConfig struct {
+	// Key exchange algorithms e.g. curve25519-sha256@libssh.org or ecdh-sha2-nistp256.
+	KeyExchanges []string
+
+	// Ciphers e.g. aes128-gcm@openssh.com or aes256-ctr.
+	Ciphers []string
+
+	// MAC algorithms e.g. hmac-sha2-256-etm@openssh.com.
+	MACs []string
+
+	// The host key algorithms accepted from the remote e.g. ssh-ed25519 or ecdsa-sha2-nistp256. Leave out ssh-rsa to
+	// refuse RSA host keys, which are signed with SHA-1.
+	HostKeyAlgorithms []string
+
+	// The types of SSH keys offered to the remote e.g. ssh-ed25519 or ecdsa-sha2-nistp256. Leave out ssh-rsa to never
+	// sign with SHA-1. The SshKey and SshKeys must be of an allowed type while keys of other types in the SshKeyDir are
+	// skipped.
+	KeyTypes []string
+}
+
+var (
+	sshKeyExchanges = []string{
+		"curve25519-sha256@libssh.org", "ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group14-sha1", "diffie-hellman-group1-sha1",
+	}
+	sshCiphers = []string{
+		"aes128-gcm@openssh.com", "chacha20-poly1305@openssh.com", "aes128-ctr", "aes192-ctr", "aes256-ctr",
+		"arcfour256", "arcfour128", "arcfour", "aes128-cbc", "3des-cbc",
+	}
+	sshMACs = []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-256", "hmac-sha1", "hmac-sha1-96",
+	}
+	sshKeyTypes = []string{
+		ssh.KeyAlgoED25519, ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521, ssh.KeyAlgoRSA,
+		ssh.KeyAlgoDSA,
+	}
+	sshHostKeyAlgorithms = append([]string{
+		ssh.CertAlgoED25519v01, ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01,
+		ssh.CertAlgoRSAv01, ssh.CertAlgoDSAv01,
+	}, sshKeyTypes...)
+)
+
+// An SshCryptoConfig allowing only algorithms approved under FIPS 140-2: NIST curves, AES and SHA-2. Keys must be
+// ECDSA.
+func FIPSSshCrypto() SshCryptoConfig {
+	return SshCryptoConfig{
+		KeyExchanges:      []string{"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521"},
+		Ciphers:           []string{"aes128-gcm@openssh.com", "aes128-ctr", "aes192-ctr", "aes256-ctr"},
+		MACs:              []string{"hmac-sha2-256-etm@openssh.com", "hmac-sha2-256"},
+		HostKeyAlgorithms: []string{ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521},
+		KeyTypes:          []string{ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521},
+	}
+}
+
+func (s SshCryptoConfig) isSet() bool {
+	return len(s.KeyExchanges) > 0 || len(s.Ciphers) > 0 || len(s.MACs) > 0 || len(s.HostKeyAlgorithms) > 0 ||
+		len(s.KeyTypes) > 0
+}
+
+// Checks that every algorithm is supported so a typo doesn't surface as a failed handshake.
+func (s SshCryptoConfig) validate() error {
+	lists := []struct {
+		name      string
+		given     []string
+		supported []string
+	}{
+		{"key exchange", s.KeyExchanges, sshKeyExchanges},
+		{"cipher", s.Ciphers, sshCiphers},
+		{"MAC", s.MACs, sshMACs},
+		{"host key algorithm", s.HostKeyAlgorithms, sshHostKeyAlgorithms},
+		{"key type", s.KeyTypes, sshKeyTypes},
+	}
+	for _, l := range lists {
+		for _, a := range l.given {
+			if !containsString(l.supported, a) {
+				return fmt.Errorf("unsupported ssh %s %s, must be one of %s", l.name, a, strings.Join(l.supported, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// Whether keys of the type may be offered to the remote.
+func (s SshCryptoConfig) allowsKey(signer ssh.Signer) bool {
+	return len(s.KeyTypes) == 0 || containsString(s.KeyTypes, signer.PublicKey().Type())
+}
+
+func (s SshCryptoConfig) apply(c *ssh.ClientConfig) {
+	if len(s.KeyExchanges) > 0 {
+		c.KeyExchanges = s.KeyExchanges
+	}
+	if len(s.Ciphers) > 0 {
+		c.Ciphers = s.Ciphers
+	}
+	if len(s.MACs) > 0 {
+		c.MACs = s.MACs
+	}
+	if len(s.HostKeyAlgorithms) > 0 {
+		c.HostKeyAlgorithms = s.HostKeyAlgorithms
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses a private key in any format supported by golang.org/x/crypto/ssh, as well as ECDSA keys in the OpenSSH format
+// written by ssh-keygen since OpenSSH 7.8, which it can't parse.
+func parseSshKey(key []byte) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey(key)
+	if err == nil {
+		return signer, nil
+	}
+	block, _ := pem.Decode(key)
+	if block == nil || block.Type != "OPENSSH PRIVATE KEY" {
+		return nil, err
+	}
+	ecKey, ecErr := parseOpenSshEcdsaKey(block.Bytes)
+	if ecErr != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(ecKey)
+}
+
+const openSshKeyMagic = "openssh-key-v1\x00"
+
+// Parses an unencrypted ECDSA key in the openssh-key-v1 format, as described in PROTOCOL.key of OpenSSH.
+func parseOpenSshEcdsaKey(b []byte) (*ecdsa.PrivateKey, error) {
+	if !strings.HasPrefix(string(b), openSshKeyMagic) {
+		return nil, errors.New("not an openssh private key")
+	}
+	var envelope struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}
+	if err := ssh.Unmarshal(b[len(openSshKeyMagic):], &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.CipherName != "none" || envelope.NumKeys != 1 {
+		return nil, errors.New("encrypted openssh private keys and files of several keys are not supported")
+	}
+
+	var priv struct {
+		Check1  uint32
+		Check2  uint32
+		KeyType string
+		Curve   string
+		Pub     []byte
+		D       *big.Int
+		Rest    []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(envelope.PrivKeyBlock, &priv); err != nil {
+		return nil, err
+	}
+	if priv.Check1 != priv.Check2 {
+		return nil, errors.New("openssh private key checkint mismatch")
+	}
+
+	var curve elliptic.Curve
+	switch priv.KeyType {
+	case ssh.KeyAlgoECDSA256:
+		curve = elliptic.P256()
+	case ssh.KeyAlgoECDSA384:
+		curve = elliptic.P384()
+	case ssh.KeyAlgoECDSA521:
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported openssh private key type %s", priv.KeyType)
+	}
+	x, y := elliptic.Unmarshal(curve, priv.Pub)
+	if x == nil {
+		return nil, errors.New("invalid ecdsa public key")
+	}
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y},
+		D:         priv.D,
+	}
+	if cx, cy := curve.ScalarBaseMult(priv.D.Bytes()); cx.Cmp(x) != 0 || cy.Cmp(y) != 0 {
+		return nil, errors.New("ecdsa private key doesn't match its public key")
+	}
+	return key, nil
+}
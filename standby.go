@@ -0,0 +1,188 @@
+package gpoll
+
+import (
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/idxfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem/dotgit"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultStandbyInterval = time.Minute
+
+var ErrNoStandby = errors.New("no standby clone is ready to be promoted")
+
+type StandbyConfig struct {
+	// Where a second clone of the repo is kept as a warm standby. It must differ from the CloneDirectory. The standby is
+	// promoted to replace the clone being polled as soon as a poll fails because that clone is corrupted, avoiding a
+	// full clone of a large repo. If not set, there is no standby.
+	Directory string
+
+	// How often the standby is fetched and brought to the same commit as the clone being polled. Defaults to 1m.
+	Interval time.Duration
+}
+
+// Emitted when the standby clone is promoted to replace the clone being polled.
+type Failover struct {
+	// Where the promoted clone is kept. Paths formatted as per FilepathModeCloneAbsolute are joined onto it from then on.
+	Directory string
+
+	// The commit the promoted clone was at.
+	Sha string
+
+	// The error that revealed the corruption. nil if promoted through PromoteStandby.
+	Err error
+}
+
+func (f Failover) EventType() EventType {
+	return EventTypeFailover
+}
+
+func (f Failover) String() string {
+	if f.Err == nil {
+		return fmt.Sprintf("promoted the standby clone in %s at %s", f.Directory, f.Sha)
+	}
+	return fmt.Sprintf("promoted the standby clone in %s at %s after the clone was corrupted: %s", f.Directory, f.Sha,
+		f.Err.Error())
+}
+
+// Whether the error was caused by missing or unreadable objects in the local clone.
+func isCorrupted(err error) bool {
+	if err == nil {
+		return false
+	}
+	var packErr *packfile.Error
+	return errors.As(err, &packErr) ||
+		errors.Is(err, plumbing.ErrObjectNotFound) ||
+		errors.Is(err, packfile.ErrReferenceDeltaNotFound) ||
+		errors.Is(err, packfile.ErrInvalidDelta) ||
+		errors.Is(err, idxfile.ErrMalformedIdxFile) ||
+		errors.Is(err, dotgit.ErrPackfileNotFound) ||
+		errors.Is(err, dotgit.ErrIdxNotFound) ||
+		errors.Is(err, dotgit.ErrPackedRefsBadFormat) ||
+		errors.Is(err, dotgit.ErrSymRefTargetNotFound) ||
+		errors.Is(err, zlib.ErrChecksum) ||
+		errors.Is(err, zlib.ErrHeader)
+}
+
+// Get the directory of the clone being polled, which changes once the standby is promoted.
+func (p *poller) cloneDirectory() string {
+	return p.directory.Load().(string)
+}
+
+// Keeps the standby up to date at the Standby Interval until done is closed, cloning it first if needed.
+func (p *poller) maintainStandby(done chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.goroutines.Go("standby-cancel", func() {
+		<-done
+		cancel()
+	})
+
+	ticker := time.NewTicker(p.config.Standby.Interval)
+	defer ticker.Stop()
+	for {
+		if err := p.refreshStandby(ctx); err != nil && ctx.Err() == nil {
+			p.onError(err)
+		}
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+// Fetches into the standby and resets it to the commit the clone being polled is at, so promoting it changes nothing
+// about what is delivered next.
+func (p *poller) refreshStandby(ctx context.Context) error {
+	p.standbyLock.Lock()
+	defer p.standbyLock.Unlock()
+
+	if p.standby == nil {
+		repo, err := p.git.Clone(ctx, p.config.Git.Remote, p.config.Git.Branch, p.standbyDirectory)
+		if err != nil {
+			return err
+		}
+		p.standby = repo
+	} else if err := p.git.Fetch(p.standby); err != nil {
+		return err
+	}
+
+	p.repoLock.Lock()
+	head, err := p.git.HeadCommit(p.repo)
+	p.repoLock.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := p.git.Reset(p.standby, p.config.Git.Branch, head.Hash.String()); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.standbyUpdatedAt = time.Now()
+	return nil
+}
+
+func (p *poller) PromoteStandby() error {
+	return p.promoteStandby(nil)
+}
+
+// Promotes the standby if the poll failed because the clone being polled is corrupted.
+func (p *poller) failover(err error) {
+	if p.config.Standby.Directory == "" || !isCorrupted(err) {
+		return
+	}
+	if err := p.promoteStandby(err); err != nil {
+		p.onError(err)
+	}
+}
+
+// Replaces the clone being polled with the standby. On the filesystem, the git directory of the replaced clone is
+// removed so a new standby is cloned in its place.
+func (p *poller) promoteStandby(cause error) error {
+	p.standbyLock.Lock()
+	defer p.standbyLock.Unlock()
+	if p.standby == nil {
+		return ErrNoStandby
+	}
+
+	head, err := p.git.HeadCommit(p.standby)
+	if err != nil {
+		return err
+	}
+
+	// The repo is only ever replaced while holding both locks, so it can be read while holding either.
+	p.repoLock.Lock()
+	p.lock.Lock()
+	replaced := p.cloneDirectory()
+	p.repo = p.standby
+	p.directory.Store(p.standbyDirectory)
+	p.standbyUpdatedAt = time.Time{}
+	p.lock.Unlock()
+	p.repoLock.Unlock()
+
+	promoted := p.standbyDirectory
+	p.standby = nil
+	p.standbyDirectory = replaced
+	if p.config.Git.Storage.Type == StorageTypeFilesystem {
+		if err := os.RemoveAll(filepath.Join(replaced, git.GitDirName)); err != nil {
+			p.onError(err)
+		}
+	}
+
+	p.emit(Failover{
+		Directory: promoted,
+		Sha:       head.Hash.String(),
+		Err:       cause,
+	})
+	return nil
+}
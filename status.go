@@ -0,0 +1,193 @@
+package gpoll
+
+import (
+	"gopkg.in/src-d/go-git.v4"
+	"sync/atomic"
+	"time"
+)
+
+// A snapshot of the state of a Poller.
+type Status struct {
+	// The remote being polled.
+	Remote string
+
+	// The branch being polled.
+	Branch string
+
+	// Whether the poller has been started and not yet stopped.
+	Running bool
+
+	// Whether polling is paused.
+	Paused bool
+
+	// When the last poll finished.
+	LastPoll time.Time
+
+	// The error returned by the last poll. nil if the last poll succeeded.
+	LastError error
+
+	// The number of polls in a row that failed. Reset by a successful poll.
+	ConsecutiveFailures int
+
+	// When the remote became unreachable. Zero if the remote is reachable.
+	UnreachableSince time.Time
+
+	// When the initial clone first failed while starting with StartDegraded. Zero unless it is being retried.
+	DegradedSince time.Time
+
+	// Whether the remote had no commits when starting and the poller is waiting for the first one.
+	AwaitingFirstCommit bool
+
+	// When the Standby clone was last brought up to date. Zero if there is no standby or it hasn't been cloned yet.
+	StandbyUpdatedAt time.Time
+
+	// The size in bytes of the CloneDirectory as of the last poll. Only measured if a storage Quota is set.
+	DiskUsage int64
+
+	// The last commit that was delivered, or the head of the clone when started if nothing was delivered since.
+	LastDelivered Commit
+
+	// The Sequence of the last commit that was delivered.
+	Sequence uint64
+
+	// The number of commits withheld from delivery because of policy violations or failed validation. See Quarantine.
+	Held int
+
+	// The sha delivery is pinned to through PinTo. Empty if not pinned.
+	PinnedTo string
+
+	// The number of commits seen on the remote that have not been delivered yet e.g. while pinned or debouncing.
+	Lag int
+
+	// The number of commits waiting to be handled, including the Lag and those being delivered.
+	QueueDepth int
+
+	// The 95th percentile of how long the most recently delivered commits waited between being received from the
+	// remote and being handled. See SlowConsumerConfig.
+	DeliveryWaitP95 time.Duration
+
+	// The receipt of the last delivered commit. nil if nothing has been delivered.
+	LastReceipt *Receipt
+
+	// The last result reported through ReportResult. nil if none has been reported.
+	LastResult *Result
+
+	// The sha of the last commit handled by each named Handler, keyed by its name.
+	Checkpoints map[string]string
+
+	// The number of delivered commits reported as applied successfully.
+	Succeeded uint64
+
+	// The number of delivered commits reported as failing to apply.
+	Failed uint64
+
+	// When polling resumes after exceeding the Budget. Zero or in the past unless throttled.
+	ThrottledUntil time.Time
+
+	// The number of delivered commits whose Snapshot hasn't been released yet.
+	OpenSnapshots int
+
+	// The number of errors dropped from the channel of Errors because nothing received them before it filled up. See
+	// Errors.
+	DroppedErrors uint64
+
+	// The number of running goroutines spawned by the poller keyed by what they do e.g. loop or handler. Empty once
+	// StopAndWait returns, unless a handler ignored the cancellation of its context.
+	ActiveGoroutines map[string]int
+}
+
+func (p *poller) Status() Status {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	checkpoints := make(map[string]string, len(p.checkpoints))
+	for name, sha := range p.checkpoints {
+		checkpoints[name] = sha
+	}
+
+	return Status{
+		Remote:              redactRemote(p.config.Git.Remote),
+		Branch:              p.config.Git.Branch,
+		Running:             p.running,
+		Paused:              p.paused,
+		LastPoll:            p.lastPoll,
+		LastError:           p.lastError,
+		ConsecutiveFailures: p.consecutiveFailures,
+		UnreachableSince:    p.unreachableSince,
+		DegradedSince:       p.degradedSince,
+		AwaitingFirstCommit: p.awaitingFirstCommit,
+		StandbyUpdatedAt:    p.standbyUpdatedAt,
+		DiskUsage:           p.diskUsage,
+		LastDelivered:       p.lastDelivered,
+		Sequence:            p.sequence,
+		Held:                len(p.held),
+		PinnedTo:            p.pinnedTo,
+		Lag:                 p.lag,
+		QueueDepth:          p.queueDepth(),
+		DeliveryWaitP95:     p.waits.p95(),
+		Checkpoints:         checkpoints,
+		LastResult:          p.results.last,
+		LastReceipt:         p.receipts.latest(),
+		Succeeded:           p.results.succeeded,
+		Failed:              p.results.failed,
+		ThrottledUntil:      p.throttledUntil,
+		OpenSnapshots:       p.results.snapshots,
+		DroppedErrors:       atomic.LoadUint64(&p.droppedErrors),
+		ActiveGoroutines:    p.goroutines.counts(),
+	}
+}
+
+func (p *poller) Pause() {
+	p.lock.Lock()
+	p.paused = true
+	p.lock.Unlock()
+	p.auditOperation("pause", nil, nil)
+}
+
+func (p *poller) Resume() {
+	p.lock.Lock()
+	p.paused = false
+	p.lock.Unlock()
+	p.auditOperation("resume", nil, nil)
+}
+
+func (p *poller) isPaused() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.paused
+}
+
+func (p *poller) stopped() {
+	p.audit(AuditRecord{Action: AuditActionStop, Success: true})
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.running = false
+	close(p.done)
+	// Discard a Stop that arrived as the loop exited on its own so it doesn't stop the next Start.
+	select {
+	case <-p.closer:
+	default:
+	}
+}
+
+func (p *poller) onPoll(err error) {
+	if p.config.OnPoll == nil {
+		return
+	}
+	if err == git.NoErrAlreadyUpToDate {
+		err = nil
+	}
+	p.config.OnPoll(p.redact(err))
+}
+
+func (p *poller) recordPoll(err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.lastPoll = time.Now()
+	p.lastError = p.redact(err)
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		p.consecutiveFailures++
+	} else {
+		p.consecutiveFailures = 0
+	}
+}
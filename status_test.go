@@ -0,0 +1,45 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type StatusTest struct {
+	serverSuite
+}
+
+func (s *StatusTest) TestOnPollReportsSuccessfulPolls() {
+	// -- Given
+	//
+	polls := make(chan error, 100)
+	p := s.newPoller(gpoll.PollConfig{
+		OnPoll: func(err error) {
+			select {
+			case polls <- err:
+			default:
+			}
+		},
+	})
+
+	// -- When
+	//
+	s.start(p)
+	defer p.StopAndWait()
+
+	// -- Then
+	//
+	select {
+	case err := <-polls:
+		s.NoError(err)
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for a poll")
+	}
+	s.False(p.Status().LastPoll.IsZero())
+}
+
+func TestStatus(t *testing.T) {
+	suite.Run(t, new(StatusTest))
+}
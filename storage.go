@@ -0,0 +1,99 @@
+package gpoll
+
+import (
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+	"path/filepath"
+	"strings"
+)
+
+// Where the clone of the repo is kept.
+type StorageType int
+
+const (
+	// The git objects and worktree are kept in memory.
+	StorageTypeMemory StorageType = iota
+
+	// The git objects and worktree are kept on disk within the CloneDirectory. Use this for large repos that don't fit
+	// in memory.
+	StorageTypeFilesystem
+)
+
+type StorageConfig struct {
+	// Where the clone is kept. Defaults to StorageTypeMemory.
+	Type StorageType
+
+	// Memory-map packfiles rather than reading them through file descriptors. Pages of the packfiles are then loaded by
+	// the OS on demand and can be evicted under memory pressure rather than counting towards the memory of the process.
+	// Only used with StorageTypeFilesystem. On platforms other than Unix, packfiles are read through file descriptors as
+	// usual instead.
+	MemoryMap bool
+
+	// The maximum size in bytes of the cache of decoded git objects. Only used with StorageTypeFilesystem. Defaults to
+	// 96MiB.
+	ObjectCacheSize int64
+
+	// The maximum size in bytes of the CloneDirectory, including the worktree, to keep pollers from filling shared
+	// volumes. Whenever a poll leaves the clone over the quota, unreachable objects are pruned and the rest repacked,
+	// like git gc, and a QuotaExceeded event is emitted if the clone still doesn't fit. Compacting waits for every
+	// CommitDiff.Snapshot to be released. Only used with StorageTypeFilesystem. If not set, there is no quota.
+	Quota int64
+
+	// Where git objects are kept when shared with other repos e.g. forks of the same upstream. Only used with
+	// StorageTypeMemory. If not set, the repo's objects are its own.
+	Pool *ObjectPool
+}
+
+// Create the storage for the git objects and the filesystem for the worktree. The worktree is nil if noCheckout is set.
+func newStorage(config StorageConfig, directory string, noCheckout bool) (storage.Storer, billy.Filesystem) {
+	if config.Type == StorageTypeMemory {
+		var s storage.Storer = memory.NewStorage()
+		if config.Pool != nil {
+			s = config.Pool.newStorage()
+		}
+		if noCheckout {
+			return s, nil
+		}
+		return s, memfs.New()
+	}
+
+	size := cache.DefaultMaxSize
+	if config.ObjectCacheSize > 0 {
+		size = cache.FileSize(config.ObjectCacheSize)
+	}
+
+	root := filepath.Join(directory, ".git")
+	var dot billy.Filesystem = osfs.New(root)
+	if config.MemoryMap {
+		dot = &mmapFs{Filesystem: dot, root: root}
+	}
+	s := filesystem.NewStorageWithOptions(dot, cache.NewObjectLRU(size), filesystem.Options{
+		// Memory-mapped packfiles are mapped once and kept open.
+		KeepDescriptors: config.MemoryMap,
+	})
+	if noCheckout {
+		return s, nil
+	}
+	return s, osfs.New(directory)
+}
+
+// Memory-maps packfiles opened for reading. Every other file, and packfiles that can't be mapped, are opened as usual.
+type mmapFs struct {
+	billy.Filesystem
+	// The directory the filesystem is rooted at. The files of the filesystem only carry their path relative to it.
+	root string
+}
+
+func (m *mmapFs) Open(filename string) (billy.File, error) {
+	if strings.HasSuffix(filename, ".pack") {
+		if f, err := mmapFile(filepath.Join(m.root, filepath.FromSlash(filename)), filename); err == nil {
+			return f, nil
+		}
+	}
+	return m.Filesystem.Open(filename)
+}
@@ -0,0 +1,53 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+type StorageTest struct {
+	serverSuite
+}
+
+func (s *StorageTest) TestMemoryMapsPackfilesOfClone() {
+	// -- Given
+	//
+	if runtime.GOOS == "windows" {
+		s.T().Skip("packfiles are only memory-mapped on unix")
+	}
+	dir := s.T().TempDir()
+	config := s.server.GitConfig()
+	config.CloneDirectory = dir
+	config.Storage = gpoll.StorageConfig{Type: gpoll.StorageTypeFilesystem, MemoryMap: true}
+	p := s.newPoller(gpoll.PollConfig{Git: config})
+	s.start(p)
+	p.StopAndWait()
+	packs, err := filepath.Glob(filepath.Join(dir, ".git", "objects", "pack", "*.pack"))
+	s.Require().NoError(err)
+	s.Require().NotEmpty(packs)
+	name, err := filepath.Rel(filepath.Join(dir, ".git"), packs[0])
+	s.Require().NoError(err)
+
+	// -- When
+	//
+	f, err := gpoll.OpenMemoryMapped(dir, filepath.ToSlash(name))
+
+	// -- Then
+	//
+	s.Require().NoError(err)
+	defer f.Close()
+	s.True(gpoll.IsMemoryMapped(f))
+	expected, err := ioutil.ReadFile(packs[0])
+	s.Require().NoError(err)
+	actual, err := ioutil.ReadAll(f)
+	s.Require().NoError(err)
+	s.Equal(expected, actual)
+}
+
+func TestStorage(t *testing.T) {
+	suite.Run(t, new(StorageTest))
+}
@@ -0,0 +1,166 @@
+package gpoll
+
+import (
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"path"
+	"sort"
+	"strings"
+)
+
+const tagRefPrefix = "refs/tags/"
+
+// The order in which the RefChanges of tags found by the same poll are emitted.
+type TagOrder int
+
+const (
+	// Tags are emitted in the order of their names, like every other ref.
+	TagOrderName TagOrder = iota
+
+	// Tags named after semantic versions e.g. v1.10.0 are emitted in order of precedence, so v1.9.0 comes before
+	// v1.10.0 and v2.0.0-rc.1 before v2.0.0. Tags that aren't versions are emitted after them, in the order of their
+	// names.
+	TagOrderSemver
+)
+
+type TagConfig struct {
+	// Only emit RefChanges for annotated tags, ignoring lightweight tags, following the common convention of annotating
+	// release tags. Changes to branches are still emitted.
+	AnnotatedOnly bool
+
+	// The order in which tags found by the same poll are emitted. Branches are always emitted first. Defaults to
+	// TagOrderName.
+	Order TagOrder
+
+	// Ignore tags created or moved at a semantic version lower than the newest version seen on the remote, e.g. patch
+	// tags backported to older release lines, so that the versions emitted only ever increase. Tags existing when the
+	// poller starts count as seen. Deleted tags and tags that aren't versions are always emitted.
+	Monotonic bool
+
+	// Patterns, as per path.Match, of tags that are emitted under Monotonic even if older than the newest version, e.g.
+	// v1.4.* to follow the hotfix stream of a supported release.
+	AllowOlder []string
+}
+
+// A tag on the remote as seen in Mirror mode.
+type Tag struct {
+	// The name of the tag without the refs/tags/ prefix e.g. v1.0.0.
+	Name string
+
+	// Whether the tag points to a tag object carrying a tagger and message rather than directly to a commit.
+	Annotated bool
+
+	// The sha of the tag object. Empty if the tag is lightweight.
+	Object string
+
+	// The sha of the commit the tag points to, through the tag object if it is annotated.
+	Target string
+
+	// Who created the tag. Only set if the tag is annotated.
+	Tagger Author
+
+	// The message of the tag. Only set if the tag is annotated.
+	Message string
+}
+
+// Describes the tag with the name pointing at the sha, which is either a tag object or a commit.
+func (g *gitImpl) Tag(repo *git.Repository, name, sha string) (*Tag, error) {
+	h := plumbing.NewHash(sha)
+	t, err := repo.TagObject(h)
+	if err == plumbing.ErrObjectNotFound {
+		return &Tag{
+			Name:   name,
+			Target: sha,
+		}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	c, err := t.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &Tag{
+		Name:      name,
+		Annotated: true,
+		Object:    sha,
+		Target:    c.Hash.String(),
+		Tagger: Author{
+			Name:  t.Tagger.Name,
+			Email: t.Tagger.Email,
+			When:  t.Tagger.When,
+		},
+		Message: t.Message,
+	}, nil
+}
+
+// Describes the tag of a RefChange, from the sha it now points to or, if deleted, the sha it pointed to. Returns
+// whether the change is to be emitted as per the TagConfig.
+func (p *poller) describeTag(change *RefChange) (bool, error) {
+	if !strings.HasPrefix(change.Ref, tagRefPrefix) {
+		return true, nil
+	}
+	sha := change.To
+	if sha == "" {
+		sha = change.From
+	}
+
+	p.repoLock.Lock()
+	tag, err := p.git.Tag(p.repo, strings.TrimPrefix(change.Ref, tagRefPrefix), sha)
+	p.repoLock.Unlock()
+	if err != nil {
+		return false, err
+	}
+	change.Tag = tag
+	return tag.Annotated || !p.config.Tags.AnnotatedOnly, nil
+}
+
+// Sorts the changes as per the TagOrder. The changes must already be sorted by name.
+func (p *poller) orderRefChanges(changes []RefChange) {
+	if p.config.Tags.Order != TagOrderSemver {
+		return
+	}
+	type key struct {
+		rank    int
+		version version
+	}
+	keys := make(map[string]key, len(changes))
+	for _, c := range changes {
+		if !strings.HasPrefix(c.Ref, tagRefPrefix) {
+			keys[c.Ref] = key{rank: 0}
+		} else if v, ok := parseVersion(strings.TrimPrefix(c.Ref, tagRefPrefix)); ok {
+			keys[c.Ref] = key{rank: 1, version: v}
+		} else {
+			keys[c.Ref] = key{rank: 2}
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := keys[changes[i].Ref], keys[changes[j].Ref]
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		return a.rank == 1 && a.version.compare(b.version) < 0
+	})
+}
+
+// Whether the change is emitted as per the Monotonic policy, recording the version of the tag if it is the newest.
+func (p *poller) admitTag(change RefChange) bool {
+	config := p.config.Tags
+	if !config.Monotonic || change.Tag == nil || change.To == "" {
+		return true
+	}
+	v, ok := parseVersion(change.Tag.Name)
+	if !ok {
+		return true
+	}
+	if p.newestTag != nil && v.compare(*p.newestTag) < 0 {
+		for _, pattern := range config.AllowOlder {
+			if ok, _ := path.Match(pattern, change.Tag.Name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	p.newestTag = &v
+	return true
+}
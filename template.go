@@ -0,0 +1,48 @@
+package gpoll
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+)
+
+// A text/template over a CommitDiff formatting messages about commits e.g. the body of a Webhook posting to a chat.
+type CommitTemplate struct {
+	t *template.Template
+}
+
+var commitTemplateFuncs = template.FuncMap{
+	"short": func(sha string) string {
+		if len(sha) > 7 {
+			return sha[:7]
+		}
+		return sha
+	},
+	"firstLine": func(s string) string {
+		return strings.SplitN(strings.TrimSpace(s), "\n", 2)[0]
+	},
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Parse the text as a template executed with a CommitDiff. On top of the builtin functions, templates can call short
+// to abbreviate a sha, firstLine to get the subject of a commit message and json to encode a value as JSON e.g.
+// {"text": {{json (printf "%s %s" (short .To.Sha) (firstLine .To.Message))}}}.
+func NewCommitTemplate(text string) (*CommitTemplate, error) {
+	t, err := template.New("commit").Funcs(commitTemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &CommitTemplate{t: t}, nil
+}
+
+// Render the template for the commit.
+func (c *CommitTemplate) Execute(commit CommitDiff) (string, error) {
+	b := &strings.Builder{}
+	if err := c.t.Execute(b, commit); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
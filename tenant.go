@@ -0,0 +1,362 @@
+package gpoll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A team sharing a MultiPoller. The repos of a tenant are namespaced under its name, kept within its Directory and
+// bounded by its Limits, so one tenant can't see or starve the repos of another.
+type Tenant struct {
+	// Uniquely identifies the tenant. The ID of each of its repos is prefixed by it as <name>/<id>, see TenantRepoID.
+	// Required and may not contain a slash.
+	Name string
+
+	// Where the clones and checkpoints of the tenant's repos are kept, each in a directory named after the repo's ID.
+	// Repos are cloned and checkpointed there unless configured otherwise, and may not use directories outside of it.
+	// Required.
+	Directory string
+
+	Limits TenantLimits
+}
+
+type TenantLimits struct {
+	// The most repos the tenant may have. Defaults to no limit.
+	MaxRepos int
+
+	// The shortest polling Interval of the tenant's repos, bounding how often the tenant polls. Defaults to no limit.
+	MinInterval time.Duration
+
+	// The most calls per second of the handlers of the tenant's repos, counting commits and events alike. Once
+	// exceeded, handlers are called at the rate, holding up polling of the tenant's repos rather than dropping anything.
+	// Defaults to no limit.
+	MaxEventsPerSecond float64
+
+	// The Budget of each of the tenant's repos that doesn't set its own. Defaults to no limit.
+	Budget BudgetConfig
+}
+
+var ErrTenantNotFound = errors.New("tenant not found")
+
+// Returned when adding a repo to a tenant would exceed one of its TenantLimits.
+type TenantLimitError struct {
+	// The name of the tenant.
+	Tenant string
+
+	// The limit that would be exceeded.
+	Limit string
+}
+
+func (t *TenantLimitError) Error() string {
+	return fmt.Sprintf("tenant %s exceeds its %s limit", t.Tenant, t.Limit)
+}
+
+// Get the ID of the repo of the tenant within the MultiPoller.
+func TenantRepoID(tenant, id string) string {
+	return tenant + "/" + id
+}
+
+type tenant struct {
+	Tenant
+	events *rateLimiter
+}
+
+func (m *multiPoller) AddTenant(t Tenant) error {
+	if t.Name == "" || strings.Contains(t.Name, "/") {
+		return fmt.Errorf("tenant name %q must be set and may not contain a slash", t.Name)
+	}
+	if t.Directory == "" {
+		return fmt.Errorf("tenant %s requires a directory", t.Name)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.tenants[t.Name]; ok {
+		return fmt.Errorf("tenant %s already exists", t.Name)
+	}
+	tn := &tenant{Tenant: t}
+	if t.Limits.MaxEventsPerSecond > 0 {
+		tn.events = newRateLimiter(t.Limits.MaxEventsPerSecond)
+	}
+	m.tenants[t.Name] = tn
+	return nil
+}
+
+func (m *multiPoller) AddTenantRepo(name, id string, config PollConfig) error {
+	if id == "" || strings.Contains(id, "/") || strings.Contains(id, "..") {
+		return fmt.Errorf("repo id %q of tenant %s must be set and may not contain a slash or ..", id, name)
+	}
+
+	m.lock.RLock()
+	t, ok := m.tenants[name]
+	m.lock.RUnlock()
+	if !ok {
+		return ErrTenantNotFound
+	}
+
+	interval := config.Interval
+	if interval == 0 {
+		interval = 30 * time.Second
+	}
+	if interval < t.Limits.MinInterval {
+		return &TenantLimitError{Tenant: name, Limit: "min interval"}
+	}
+
+	dir := filepath.Join(t.Directory, id)
+	if !isWithin(t.Directory, dir) {
+		return fmt.Errorf("repo %s of tenant %s must be within its directory", id, name)
+	}
+	if config.Git.CloneDirectory == "" {
+		config.Git.CloneDirectory = filepath.Join(dir, "clone")
+	} else if !isWithin(t.Directory, config.Git.CloneDirectory) {
+		return fmt.Errorf("the clone directory of repo %s must be within the directory of tenant %s", id, name)
+	}
+	if config.Checkpoint.Store == nil {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		config.Checkpoint.Store = NewFileCheckpointStore(filepath.Join(dir, "checkpoint"))
+	}
+	if !config.Budget.enabled() {
+		config.Budget = t.Limits.Budget
+	}
+	if config.GoroutineLabel == "" {
+		config.GoroutineLabel = TenantRepoID(name, id)
+	}
+	if t.events != nil {
+		throttle(t.events, &config)
+	}
+
+	// Checked again while adding so concurrent adds can't exceed the limit.
+	repoID := TenantRepoID(name, id)
+	return m.addRepo(repoID, config, func() error {
+		if t.Limits.MaxRepos > 0 && len(m.tenantRepos(name)) >= t.Limits.MaxRepos {
+			return &TenantLimitError{Tenant: name, Limit: "max repos"}
+		}
+		m.owners[repoID] = name
+		return nil
+	})
+}
+
+func (m *multiPoller) ListTenantRepos(name string) ([]string, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if _, ok := m.tenants[name]; !ok {
+		return nil, ErrTenantNotFound
+	}
+	return m.tenantRepos(name), nil
+}
+
+// The IDs of the tenant's repos without the prefix of the tenant, sorted. Must be called while holding the lock.
+func (m *multiPoller) tenantRepos(name string) []string {
+	prefix := TenantRepoID(name, "")
+	ids := make([]string, 0)
+	for _, id := range m.sortedIDs() {
+		if m.owners[id] == name {
+			ids = append(ids, strings.TrimPrefix(id, prefix))
+		}
+	}
+	return ids
+}
+
+// Whether the path is the directory or within it.
+func isWithin(dir, fp string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(fp))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Makes every handler of the config wait for the limiter.
+func throttle(limiter *rateLimiter, config *PollConfig) {
+	if h := config.HandleCommitContext; h != nil {
+		config.HandleCommitContext = func(ctx context.Context, commit CommitDiff) {
+			if limiter.wait(ctx) == nil {
+				h(ctx, commit)
+			}
+		}
+	}
+	if h := config.HandleCommit; h != nil {
+		config.HandleCommit = func(commit CommitDiff) {
+			_ = limiter.wait(context.Background())
+			h(commit)
+		}
+	}
+	if h := config.HandleEvent; h != nil {
+		config.HandleEvent = func(event Event) {
+			_ = limiter.wait(context.Background())
+			h(event)
+		}
+	}
+	handlers := make([]Handler, len(config.Handlers))
+	for i, handler := range config.Handlers {
+		h := handler.Handle
+		handler.Handle = func(ctx context.Context, commit CommitDiff) {
+			if limiter.wait(ctx) == nil {
+				h(ctx, commit)
+			}
+		}
+		handlers[i] = handler
+	}
+	config.Handlers = handlers
+}
+
+// A token bucket allowing bursts of up to a second's worth of events.
+type rateLimiter struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	burst := math.Max(1, rate)
+	return &rateLimiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// Waits until an event may pass or the context is done.
+func (r *rateLimiter) wait(ctx context.Context) error {
+	for {
+		r.lock.Lock()
+		now := time.Now()
+		r.tokens = math.Min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.rate)
+		r.last = now
+		if r.tokens >= 1 {
+			r.tokens--
+			r.lock.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - r.tokens) / r.rate * float64(time.Second))
+		r.lock.Unlock()
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Create an http.Handler exposing JSON endpoints for tenants to operate their own repos of the MultiPoller. Every
+// request must carry one of the tokens as a bearer token in the Authorization header, which are mapped to the name of
+// the tenant they belong to. Repos are identified by their ID within the tenant and the repos of other tenants are
+// never found.
+//
+// The following endpoints are exposed:
+//
+//	GET  /repos        the IDs of the tenant's repos
+//	GET  /status       the Status of the repo in the repo query param
+//	POST /pause        pause polling the repo in the repo query param
+//	POST /resume       resume polling the repo in the repo query param
+func NewTenantAdminHandler(m MultiPoller, tokens map[string]string) http.Handler {
+	a := &tenantAdmin{
+		m:      m,
+		tokens: tokens,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos", a.method(http.MethodGet, a.repos))
+	mux.HandleFunc("/status", a.method(http.MethodGet, a.repo(a.status)))
+	mux.HandleFunc("/pause", a.method(http.MethodPost, a.repo(a.pause)))
+	mux.HandleFunc("/resume", a.method(http.MethodPost, a.repo(a.resume)))
+	a.mux = mux
+
+	return a
+}
+
+type tenantAdmin struct {
+	m      MultiPoller
+	tokens map[string]string
+	mux    *http.ServeMux
+}
+
+type tenantContextKey struct{}
+
+func (a *tenantAdmin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, ok := a.tenant(r)
+	if !ok {
+		writeJson(w, http.StatusUnauthorized, adminError{Error: "unauthorized"})
+		return
+	}
+	a.mux.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantContextKey{}, name)))
+}
+
+// The name of the tenant whose token the request carries.
+func (a *tenantAdmin) tenant(r *http.Request) (string, bool) {
+	for token, name := range a.tokens {
+		if hasBearerToken(r, token) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (a *tenantAdmin) method(method string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			writeJson(w, http.StatusMethodNotAllowed, adminError{Error: "method not allowed"})
+			return
+		}
+		h(w, r)
+	}
+}
+
+// Calls h with the ID of the tenant's repo in the repo query param within the MultiPoller.
+func (a *tenantAdmin) repo(h func(w http.ResponseWriter, id string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := r.URL.Query().Get("repo")
+		if repo == "" {
+			writeJson(w, http.StatusBadRequest, adminError{Error: "repo is required"})
+			return
+		}
+		h(w, TenantRepoID(r.Context().Value(tenantContextKey{}).(string), repo))
+	}
+}
+
+func (a *tenantAdmin) repos(w http.ResponseWriter, r *http.Request) {
+	ids, err := a.m.ListTenantRepos(r.Context().Value(tenantContextKey{}).(string))
+	if err != nil {
+		writeJson(w, http.StatusNotFound, adminError{Error: err.Error()})
+		return
+	}
+	writeJson(w, http.StatusOK, ids)
+}
+
+func (a *tenantAdmin) status(w http.ResponseWriter, id string) {
+	s, err := a.m.Status(id)
+	if err != nil {
+		writeJson(w, http.StatusNotFound, adminError{Error: err.Error()})
+		return
+	}
+	writeJson(w, http.StatusOK, toAdminStatus(s))
+}
+
+func (a *tenantAdmin) pause(w http.ResponseWriter, id string) {
+	if err := a.m.PauseRepo(id); err != nil {
+		writeJson(w, http.StatusNotFound, adminError{Error: err.Error()})
+		return
+	}
+	a.status(w, id)
+}
+
+func (a *tenantAdmin) resume(w http.ResponseWriter, id string) {
+	if err := a.m.ResumeRepo(id); err != nil {
+		writeJson(w, http.StatusNotFound, adminError{Error: err.Error()})
+		return
+	}
+	a.status(w, id)
+}
@@ -0,0 +1,102 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type TenantTest struct {
+	serverSuite
+
+	dir string
+}
+
+func (s *TenantTest) SetupTest() {
+	s.serverSuite.SetupTest()
+	dir, err := ioutil.TempDir("", "gpoll")
+	s.Require().NoError(err)
+	s.dir = dir
+}
+
+func (s *TenantTest) TearDownTest() {
+	s.serverSuite.TearDownTest()
+	_ = os.RemoveAll(s.dir)
+}
+
+func (s *TenantTest) newMultiPoller(tenants ...string) gpoll.MultiPoller {
+	m, err := gpoll.NewMultiPoller(nil)
+	s.Require().NoError(err)
+	for _, name := range tenants {
+		s.Require().NoError(m.AddTenant(gpoll.Tenant{Name: name, Directory: filepath.Join(s.dir, name)}))
+	}
+	return m
+}
+
+func (s *TenantTest) TestRejectsRepoIDsEscapingTenant() {
+	// -- Given
+	//
+	m := s.newMultiPoller("payments")
+
+	// -- When
+	//
+	errs := make([]error, 0)
+	for _, id := range []string{"", "a/b", "..", "a..b", "../search"} {
+		errs = append(errs, m.AddTenantRepo("payments", id, gpoll.PollConfig{Git: s.server.GitConfig()}))
+	}
+
+	// -- Then
+	//
+	for _, err := range errs {
+		s.Error(err)
+	}
+	s.Empty(m.ListRepos())
+}
+
+func (s *TenantTest) TestRejectsReposWithSlashOutsideTenants() {
+	// -- Given
+	//
+	m := s.newMultiPoller("payments")
+
+	// -- When
+	//
+	owned := m.AddRepo(gpoll.TenantRepoID("payments", "config"), gpoll.PollConfig{Git: s.server.GitConfig()})
+	unowned := m.AddRepo(gpoll.TenantRepoID("search", "config"), gpoll.PollConfig{Git: s.server.GitConfig()})
+
+	// -- Then
+	//
+	s.Error(owned)
+	s.Error(unowned)
+	s.Empty(m.ListRepos())
+}
+
+func (s *TenantTest) TestListsOnlyReposOwnedByTenant() {
+	// -- Given
+	//
+	m := s.newMultiPoller("payments", "search")
+	s.Require().NoError(m.AddRepo("shared", gpoll.PollConfig{Git: s.server.GitConfig()}))
+	s.Require().NoError(m.AddTenantRepo("payments", "config", gpoll.PollConfig{Git: s.server.GitConfig()}))
+	s.Require().NoError(m.AddTenantRepo("search", "index", gpoll.PollConfig{Git: s.server.GitConfig()}))
+
+	// -- When
+	//
+	s.Require().NoError(m.RemoveRepo(gpoll.TenantRepoID("search", "index")))
+
+	// -- Then
+	//
+	payments, err := m.ListTenantRepos("payments")
+	s.NoError(err)
+	s.Equal([]string{"config"}, payments)
+	search, err := m.ListTenantRepos("search")
+	s.NoError(err)
+	s.Empty(search)
+	_, err = m.ListTenantRepos("missing")
+	s.Equal(gpoll.ErrTenantNotFound, err)
+}
+
+func TestTenant(t *testing.T) {
+	suite.Run(t, new(TenantTest))
+}
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/gpolltest/server"
+	"strings"
+	"sync"
+	"time"
+)
+
+func (s *Server) TestWritesAuditRecords() {
+	// -- Given
+	//
+	sink := &lockedBuffer{}
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      s.server.GitConfig(),
+		Interval: 10 * time.Millisecond,
+		Audit:    gpoll.AuditConfig{Sink: sink, Actor: "deployer"},
+		Handlers: []gpoll.Handler{{Name: "config", Handle: func(context.Context, gpoll.CommitDiff) {}}},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	// -- When
+	//
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+	s.Equal(sha, s.receive(c).To.Sha)
+	poller.Pause()
+	poller.StopAndWait()
+
+	// -- Then
+	//
+	records := map[gpoll.AuditAction][]gpoll.AuditRecord{}
+	for _, line := range strings.Split(strings.TrimSpace(sink.String()), "\n") {
+		var record gpoll.AuditRecord
+		if !s.NoError(json.Unmarshal([]byte(line), &record)) {
+			s.FailNow(line)
+		}
+		s.Equal("deployer", record.Actor)
+		s.Equal(s.server.URL, record.Remote)
+		records[record.Action] = append(records[record.Action], record)
+	}
+	s.Len(records[gpoll.AuditActionStart], 1)
+	s.Len(records[gpoll.AuditActionStop], 1)
+	s.NotEmpty(records[gpoll.AuditActionCredentialUse])
+	s.Equal(server.Username, records[gpoll.AuditActionCredentialUse][0].Details["username"])
+	s.True(records[gpoll.AuditActionCredentialUse][0].Success)
+	if s.Len(records[gpoll.AuditActionDeliver], 1) {
+		s.Equal(sha, records[gpoll.AuditActionDeliver][0].Sha)
+		s.Equal([]string{"channel", "handler:config"}, records[gpoll.AuditActionDeliver][0].Targets)
+	}
+	if s.Len(records[gpoll.AuditActionOperate], 1) {
+		s.Equal("pause", records[gpoll.AuditActionOperate][0].Details["operation"])
+		s.Contains(records[gpoll.AuditActionOperate][0].CEF(), "CEF:0|gpoll|gpoll|1|operate|operate|3|")
+		s.Contains(records[gpoll.AuditActionOperate][0].CEF(), "suser=deployer")
+	}
+}
+
+// A bytes.Buffer that is safe to write to from the poller while the test reads it.
+type lockedBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (l *lockedBuffer) Write(b []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.buf.Write(b)
+}
+
+func (l *lockedBuffer) String() string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.buf.String()
+}
@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"github.com/eddieowens/gpoll"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+func (s *Server) TestResumesDeliveryFromCheckpoint() {
+	// -- Given
+	//
+	dir, err := ioutil.TempDir("", "gpoll")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	checkpoint, err := s.server.Head()
+	s.NoError(err)
+	store := gpoll.NewFileCheckpointStore(dir + "/checkpoint")
+	s.NoError(store.Save(checkpoint))
+
+	missed, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+
+	events := make(chan gpoll.Event, 10)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:         s.server.GitConfig(),
+		Interval:    10 * time.Millisecond,
+		Checkpoint:  gpoll.CheckpointConfig{Store: store},
+		HandleEvent: func(event gpoll.Event) { events <- event },
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	// -- When
+	//
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+
+	// -- Then
+	//
+	r := (<-events).(gpoll.Reconciliation)
+	s.Equal(gpoll.CheckpointRelationCloneAhead, r.Relation)
+	s.Equal(missed, r.Clone)
+	s.Equal(missed, r.Remote)
+
+	s.Equal(missed, s.receive(c).To.Sha)
+	saved, err := store.Load()
+	s.NoError(err)
+	s.Equal(missed, saved)
+}
+
+func (s *Server) TestVerifiesExpectedInitialSha() {
+	// -- Given
+	//
+	initial, err := s.server.Head()
+	s.NoError(err)
+	_, err = s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+	head, err := s.server.Head()
+	s.NoError(err)
+
+	unknown := strings.Repeat("ab", 20)
+	for _, expected := range []string{initial, head, unknown} {
+		poller, err := gpoll.NewPoller(gpoll.PollConfig{
+			Git:                s.server.GitConfig(),
+			ExpectedInitialSha: expected,
+		})
+		if !s.NoError(err) {
+			s.FailNow(err.Error())
+		}
+
+		// -- When
+		//
+		_, err = poller.StartAsync()
+
+		// -- Then
+		//
+		if expected == unknown {
+			s.Equal(&gpoll.InitialShaMismatchError{Expected: unknown, Head: head}, err)
+			s.False(poller.Status().Running)
+		} else {
+			s.NoError(err)
+		}
+		poller.StopAndWait()
+	}
+
+	_, err = gpoll.NewPoller(gpoll.PollConfig{Git: s.server.GitConfig(), ExpectedInitialSha: "abc"})
+	s.Error(err)
+}
+
+func (s *Server) TestLogsObservedAndDeliveredHeads() {
+	// -- Given
+	//
+	dir, err := ioutil.TempDir("", "gpoll")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:        s.server.GitConfig(),
+		Interval:   10 * time.Millisecond,
+		Checkpoint: gpoll.CheckpointConfig{Store: gpoll.NewFileCheckpointStore(dir + "/checkpoint")},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+
+	// -- When
+	//
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+	s.receive(c)
+
+	// -- Then
+	//
+	heads, err := poller.HeadLog()
+	s.NoError(err)
+	if s.Len(heads, 2) {
+		s.Equal(gpoll.HeadLogEntry{Kind: gpoll.HeadLogKindObserved, Sha: sha, At: heads[0].At}, heads[0])
+		s.Equal(gpoll.HeadLogEntry{Kind: gpoll.HeadLogKindDelivered, Sha: sha, At: heads[1].At}, heads[1])
+		s.False(heads[1].At.Before(heads[0].At))
+	}
+
+	stored, err := gpoll.NewFileCheckpointStore(dir + "/checkpoint").(gpoll.HeadLogStore).Heads()
+	s.NoError(err)
+	s.Len(stored, 2)
+}
@@ -0,0 +1,253 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/gpolltest/server"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func (s *Server) TestMapsAuthorsThroughRepoMailmap() {
+	// -- Given
+	//
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      s.server.GitConfig(),
+		Interval: 10 * time.Millisecond,
+		Mailmap: gpoll.MailmapConfig{
+			Mailmap:        "Release Bot <bot@example.com>\n",
+			UseRepoMailmap: true,
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+
+	// -- When
+	//
+	mailmap := "Jane Doe <jane@example.com> " + server.Username + " <" + server.Username + "@example.com>\n"
+	_, err = s.server.Commit("add mailmap", map[string]string{".mailmap": mailmap})
+	s.NoError(err)
+
+	// -- Then
+	//
+	commit := s.receive(c)
+	s.Equal(gpoll.Author{Name: "Jane Doe", Email: "jane@example.com", When: commit.To.Author.When},
+		commit.To.Author)
+}
+
+func (s *Server) TestSuppressesLineEndingNormalization() {
+	// -- Given
+	//
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:           s.server.GitConfig(),
+		Interval:      10 * time.Millisecond,
+		Normalization: gpoll.NormalizationConfig{Suppress: true},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+
+	created, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\r\nb: 2\r\n"})
+	s.NoError(err)
+	s.Equal(created, s.receive(c).To.Sha)
+
+	// -- When
+	//
+	_, err = s.server.Commit("renormalize", map[string]string{
+		"a.yaml":         "a: 1\nb: 2\n",
+		".gitattributes": "* text=auto\n",
+	})
+	s.NoError(err)
+	changed, err := s.server.Commit("change config", map[string]string{"a.yaml": "a: 2\nb: 2\n"})
+	s.NoError(err)
+
+	// -- Then
+	//
+	commit := s.receive(c)
+	s.Equal(changed, commit.To.Sha)
+	s.False(commit.Normalization)
+}
+
+func (s *Server) TestSnapshotsReadFilesAtDeliveredCommit() {
+	// -- Given
+	//
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:       s.server.GitConfig(),
+		Interval:  10 * time.Millisecond,
+		Snapshots: true,
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+
+	_, err = s.server.Commit("add config", map[string]string{"config/app.yaml": "a: 1\n"})
+	s.NoError(err)
+	first := s.receive(c)
+
+	// -- When
+	//
+	_, err = s.server.Commit("update readme", map[string]string{"README.md": "# changed\n"})
+	s.NoError(err)
+	second := s.receive(c)
+
+	// -- Then
+	//
+	f, err := first.Snapshot.Open("README.md")
+	if s.NoError(err) {
+		b, err := ioutil.ReadAll(f)
+		s.NoError(err)
+		s.Equal("# gpolltest\n", string(b))
+		s.NoError(f.Close())
+	}
+	entries, err := first.Snapshot.ReadDir("/")
+	if s.NoError(err) && s.Len(entries, 2) {
+		s.Equal("README.md", entries[0].Name())
+		s.Equal("config", entries[1].Name())
+		s.True(entries[1].IsDir())
+	}
+	info, err := first.Snapshot.Stat("config/app.yaml")
+	if s.NoError(err) {
+		s.Equal(int64(5), info.Size())
+	}
+	_, err = first.Snapshot.Create("new.yaml")
+	s.Error(err)
+	s.Equal(2, poller.Status().OpenSnapshots)
+
+	s.NoError(poller.ReportResult(first.ID, gpoll.OutcomeSuccess, ""))
+	_, err = first.Snapshot.Open("README.md")
+	s.Equal(gpoll.ErrSnapshotReleased, err)
+	f, err = second.Snapshot.Open("README.md")
+	if s.NoError(err) {
+		b, err := ioutil.ReadAll(f)
+		s.NoError(err)
+		s.Equal("# changed\n", string(b))
+	}
+	s.Equal(1, poller.Status().OpenSnapshots)
+}
+
+func (s *Server) TestFlagsClockSkewedCommits() {
+	// -- Given
+	//
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:       s.server.GitConfig(),
+		Interval:  10 * time.Millisecond,
+		ClockSkew: gpoll.ClockSkewConfig{Past: 24 * time.Hour},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+
+	// -- When
+	//
+	future, err := s.server.CommitAt(time.Now().Add(48*time.Hour), "add a", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+	futureCommit := s.receive(c)
+	past, err := s.server.CommitAt(time.Now().Add(-48*time.Hour), "add b", map[string]string{"b.yaml": "b: 1\n"})
+	s.NoError(err)
+	pastCommit := s.receive(c)
+	sha, err := s.server.Commit("add c", map[string]string{"c.yaml": "c: 1\n"})
+	s.NoError(err)
+	commit := s.receive(c)
+
+	// -- Then
+	//
+	s.Equal(future, futureCommit.To.Sha)
+	s.InDelta(48*time.Hour, futureCommit.To.ClockSkew, float64(time.Minute))
+	s.Equal(past, pastCommit.To.Sha)
+	s.InDelta(-48*time.Hour, pastCommit.To.ClockSkew, float64(time.Minute))
+	s.Equal(sha, commit.To.Sha)
+	s.Zero(commit.To.ClockSkew)
+}
+
+func (s *Server) TestEmitsChecksumManifests() {
+	// -- Given
+	//
+	manifests := make(chan *gpoll.Manifest, 10)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:       s.server.GitConfig(),
+		Interval:  10 * time.Millisecond,
+		Manifests: true,
+		FileChangeFilter: func(change gpoll.FileChange) bool {
+			return strings.HasSuffix(change.Filepath, ".yaml")
+		},
+		HandleEvent: func(event gpoll.Event) {
+			if m, ok := event.(*gpoll.Manifest); ok {
+				manifests <- m
+			}
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+
+	// -- When
+	//
+	sha, err := s.server.Commit("add config", map[string]string{"config/a.yaml": "a: 1\n"})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	commit := s.receive(c)
+
+	// -- Then
+	//
+	sum := sha256.Sum256([]byte("a: 1\n"))
+	if s.NotNil(commit.Manifest) {
+		s.Equal(sha, commit.Manifest.Sha)
+		s.Equal(map[string]string{"config/a.yaml": hex.EncodeToString(sum[:])}, commit.Manifest.Files)
+		s.Equal(hex.EncodeToString(sum[:])+"  config/a.yaml\n", string(commit.Manifest.Bytes()))
+	}
+	select {
+	case m := <-manifests:
+		s.Equal(sha, m.Sha)
+		s.Equal(commit.Manifest.Digest, m.Digest)
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for the manifest")
+	}
+
+	dir, err := ioutil.TempDir("", "gpoll")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	s.NoError(os.MkdirAll(filepath.Join(dir, "config"), 0755))
+	s.NoError(ioutil.WriteFile(filepath.Join(dir, "config", "a.yaml"), []byte("a: 1\n"), 0644))
+	s.NoError(commit.Manifest.Verify(dir))
+	s.NoError(ioutil.WriteFile(filepath.Join(dir, "config", "a.yaml"), []byte("a: 2\n"), 0644))
+	mismatch := &gpoll.ManifestMismatchError{}
+	if s.True(errors.As(commit.Manifest.Verify(dir), &mismatch)) {
+		s.Equal([]string{"config/a.yaml"}, mismatch.Changed)
+	}
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"context"
+	"github.com/eddieowens/gpoll"
+	"gopkg.in/yaml.v2"
+	"time"
+)
+
+type filterConfig struct {
+	Filter *gpoll.Expression `yaml:"filter"`
+	Route  *gpoll.Expression `yaml:"route"`
+}
+
+func (s *Server) TestFiltersAndRoutesThroughExpressions() {
+	// -- Given
+	//
+	var config filterConfig
+	err := yaml.Unmarshal([]byte(`
+filter: change.path.matches("k8s/**") && commit.author.email.endsWith("@example.com")
+route: change.type == "create" && !change.path.startsWith("k8s/base/")
+`), &config)
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	s.Error(yaml.Unmarshal([]byte(`filter: change.size.matches("*")`), &filterConfig{}))
+	s.Error(yaml.Unmarshal([]byte(`filter: commit.branch == "master"`), &filterConfig{}))
+
+	routed := make(chan gpoll.CommitDiff, 10)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:          s.server.GitConfig(),
+		Interval:     10 * time.Millisecond,
+		FilepathMode: gpoll.FilepathModeRepoRelative,
+		Filter:       config.Filter,
+		Handlers: []gpoll.Handler{
+			{
+				Name:   "created",
+				Filter: config.Route,
+				Handle: func(_ context.Context, commit gpoll.CommitDiff) {
+					routed <- commit
+				},
+			},
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+
+	// -- When
+	//
+	_, err = s.server.Commit("add manifests", map[string]string{
+		"k8s/base/deploy.yaml":  "kind: Deployment\n",
+		"k8s/prod/service.yaml": "kind: Service\n",
+		"docs/k8s.md":           "# k8s\n",
+	})
+	s.NoError(err)
+	_, err = s.server.Commit("update base", map[string]string{"k8s/base/deploy.yaml": "kind: StatefulSet\n"})
+	s.NoError(err)
+
+	// -- Then
+	//
+	first := s.receive(c)
+	if s.Len(first.Changes, 2) {
+		s.Equal("k8s/base/deploy.yaml", first.Changes[0].Filepath)
+		s.Equal("k8s/prod/service.yaml", first.Changes[1].Filepath)
+	}
+	second := s.receive(c)
+	s.Len(second.Changes, 1)
+
+	// The initial clone is routed as init changes, which the route doesn't match, so only the first commit is routed.
+	select {
+	case commit := <-routed:
+		s.Equal(first.To.Sha, commit.To.Sha)
+		s.Equal([]gpoll.FileChange{{Filepath: "k8s/prod/service.yaml", ChangeType: gpoll.ChangeTypeCreate, Size: 14}},
+			commit.Changes)
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for the routed commit")
+	}
+	s.Len(routed, 0)
+}
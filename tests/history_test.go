@@ -0,0 +1,345 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/gpolltest/server"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func (s *Server) TestBackfillsPathToHandlers() {
+	// -- Given
+	//
+	first, err := s.server.Commit("add a", map[string]string{"a/1.yaml": "a: 1\n"})
+	s.NoError(err)
+	parent, err := s.server.Commit("add b", map[string]string{"b/1.yaml": "b: 1\n"})
+	s.NoError(err)
+	second, err := s.server.Commit("update a and b", map[string]string{"a/1.yaml": "a: 2\n", "b/1.yaml": "b: 2\n"})
+	s.NoError(err)
+
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:          s.server.GitConfig(),
+		Interval:     10 * time.Millisecond,
+		FilepathMode: gpoll.FilepathModeRepoRelative,
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	_, err = poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+
+	handled := make([]gpoll.CommitDiff, 0)
+	s.NoError(poller.AddHandler(gpoll.Handler{
+		Name: "a",
+		Handle: func(_ context.Context, commit gpoll.CommitDiff) {
+			handled = append(handled, commit)
+		},
+	}))
+	checkpoint := poller.Status().Checkpoints["a"]
+
+	// -- When
+	//
+	err = poller.Backfill("a/", time.Time{})
+
+	// -- Then
+	//
+	s.NoError(err)
+	if s.Len(handled, 2) {
+		s.Equal(first, handled[0].To.Sha)
+		s.Equal(second, handled[1].To.Sha)
+		s.Equal(parent, handled[1].From.Sha)
+		for _, commit := range handled {
+			s.True(commit.Backfill)
+			if s.Len(commit.Changes, 1) {
+				s.Equal("a/1.yaml", commit.Changes[0].Filepath)
+			}
+		}
+	}
+	s.Equal(checkpoint, poller.Status().Checkpoints["a"])
+
+	handled = handled[:0]
+	s.NoError(poller.Backfill("a", time.Now().Add(time.Hour)))
+	s.Empty(handled)
+}
+
+func (s *Server) TestSwitchesToDefaultBranchWhenBranchIsDeleted() {
+	// -- Given
+	//
+	s.NoError(s.server.CreateBranch("release"))
+	git := s.server.GitConfig()
+	git.Branch = "release"
+	missing := make(chan gpoll.BranchMissing, 10)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:           git,
+		Interval:      10 * time.Millisecond,
+		BranchMissing: gpoll.BranchMissingSwitchToDefault,
+		HandleEvent: func(event gpoll.Event) {
+			if e, ok := event.(gpoll.BranchMissing); ok {
+				missing <- e
+			}
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+
+	// -- When
+	//
+	s.NoError(s.server.DeleteBranch("release"))
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+
+	// -- Then
+	//
+	var event gpoll.BranchMissing
+	select {
+	case event = <-missing:
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for the branch to be missing")
+	}
+	s.Equal("release", event.Branch)
+	s.Equal(server.Branch, event.SwitchedTo)
+	s.Equal(sha, s.receive(c).To.Sha)
+	s.Equal(server.Branch, poller.Status().Branch)
+}
+
+func (s *Server) TestStopsWhenBranchIsDeleted() {
+	// -- Given
+	//
+	s.NoError(s.server.CreateBranch("release"))
+	git := s.server.GitConfig()
+	git.Branch = "release"
+	errs := make(chan error, 10)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:           git,
+		Interval:      10 * time.Millisecond,
+		BranchMissing: gpoll.BranchMissingStop,
+		OnError: func(err error) {
+			errs <- err
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	_, err = poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+
+	// -- When
+	//
+	s.NoError(s.server.DeleteBranch("release"))
+
+	// -- Then
+	//
+	var stopErr error
+	select {
+	case stopErr = <-errs:
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for the poller to stop")
+	}
+	missing := &gpoll.BranchMissingError{}
+	if s.True(errors.As(stopErr, &missing)) {
+		s.Equal("release", missing.Branch)
+	}
+	s.Eventually(func() bool {
+		return !poller.Status().Running
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *Server) TestBootstrapsEmptyRemote() {
+	// -- Given
+	//
+	srv, err := server.NewEmpty()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "gpoll")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	git := srv.GitConfig()
+	git.CloneDirectory = dir
+	inits := make(chan gpoll.CommitDiff, 10)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      git,
+		Interval: 10 * time.Millisecond,
+		HandleCommit: func(commit gpoll.CommitDiff) {
+			if len(commit.Changes) > 0 && commit.Changes[0].ChangeType == gpoll.ChangeTypeInit {
+				inits <- commit
+			}
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+	s.Eventually(func() bool {
+		return poller.Status().AwaitingFirstCommit
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// -- When
+	//
+	first, err := srv.Commit("first commit", map[string]string{"README.md": "# first\n"})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	// -- Then
+	//
+	select {
+	case init := <-inits:
+		s.Equal(first, init.To.Sha)
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for the first commit")
+	}
+	s.False(poller.Status().AwaitingFirstCommit)
+
+	second, err := srv.Commit("second commit", map[string]string{"README.md": "# second\n"})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	s.Equal(second, s.receive(c).To.Sha)
+}
+
+func (s *Server) TestDeliversHistoryReplacedByOrphanBranch() {
+	// -- Given
+	//
+	before, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	replaced := make(chan gpoll.HistoryReplaced, 10)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      s.server.GitConfig(),
+		Interval: 10 * time.Millisecond,
+		HandleEvent: func(event gpoll.Event) {
+			if h, ok := event.(gpoll.HistoryReplaced); ok {
+				replaced <- h
+			}
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+
+	// -- When
+	//
+	orphan, err := s.server.CommitOrphan("start over", map[string]string{"b.yaml": "b: 1\n"})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	// -- Then
+	//
+	commit := s.receive(c)
+	s.True(commit.HistoryReplaced)
+	s.Equal(before, commit.From.Sha)
+	s.Equal(orphan, commit.To.Sha)
+	changes := map[string]gpoll.ChangeType{}
+	for _, change := range commit.Changes {
+		changes[filepath.Base(change.Filepath)] = change.ChangeType
+	}
+	s.Equal(map[string]gpoll.ChangeType{
+		"README.md": gpoll.ChangeTypeDelete,
+		"a.yaml":    gpoll.ChangeTypeDelete,
+		"b.yaml":    gpoll.ChangeTypeCreate,
+	}, changes)
+	select {
+	case h := <-replaced:
+		s.Equal(orphan, h.To.Sha)
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for the history to be replaced")
+	}
+
+	next, err := s.server.Commit("add more", map[string]string{"c.yaml": "c: 1\n"})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	commit = s.receive(c)
+	s.False(commit.HistoryReplaced)
+	s.Equal(next, commit.To.Sha)
+}
+
+func (s *Server) TestDeliversForcePushedHistory() {
+	// -- Given
+	//
+	base, err := s.server.Head()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	replaced := make(chan gpoll.HistoryReplaced, 10)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      s.server.GitConfig(),
+		Interval: 10 * time.Millisecond,
+		HandleEvent: func(event gpoll.Event) {
+			if h, ok := event.(gpoll.HistoryReplaced); ok {
+				replaced <- h
+			}
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+	dropped, err := s.server.Commit("add a", map[string]string{"a.yaml": "a: 1\n"})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	s.Equal(dropped, s.receive(c).To.Sha)
+
+	// -- When
+	//
+	if !s.NoError(s.server.Reset(base)) {
+		s.FailNow("failed to reset the branch")
+	}
+	rebased, err := s.server.Commit("add b", map[string]string{"b.yaml": "b: 1\n"})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	// -- Then
+	//
+	commit := s.receive(c)
+	s.False(commit.HistoryReplaced)
+	s.Equal(dropped, commit.From.Sha)
+	s.Equal(rebased, commit.To.Sha)
+	changes := map[string]gpoll.ChangeType{}
+	for _, change := range commit.Changes {
+		changes[filepath.Base(change.Filepath)] = change.ChangeType
+	}
+	s.Equal(map[string]gpoll.ChangeType{
+		"a.yaml": gpoll.ChangeTypeDelete,
+		"b.yaml": gpoll.ChangeTypeCreate,
+	}, changes)
+	s.Empty(replaced)
+}
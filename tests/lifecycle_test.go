@@ -0,0 +1,375 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/gpolltest"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func (s *Server) TestDeliversCommitsFoundThroughPoll() {
+	// -- Given
+	//
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:          s.server.GitConfig(),
+		Interval:     time.Hour,
+		PollCacheTTL: time.Hour,
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+
+	// -- When
+	//
+	polled, err := poller.Poll()
+	s.NoError(err)
+	cached, err := poller.Poll()
+	s.NoError(err)
+	poller.Trigger()
+
+	// -- Then
+	//
+	if s.Len(polled, 1) {
+		s.Equal(sha, polled[0].To.Sha)
+	}
+	s.Equal(polled, cached)
+	s.Equal(sha, s.receive(c).To.Sha)
+}
+
+func (s *Server) TestRestartsAfterStop() {
+	// -- Given
+	//
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{Git: s.server.GitConfig(), Interval: 10 * time.Millisecond})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	poller.Stop()
+
+	_, err = poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	_, err = poller.StartAsync()
+	s.Equal(gpoll.ErrAlreadyStarted, err)
+
+	// -- When
+	//
+	poller.StopAndWait()
+	poller.Stop()
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+
+	// -- Then
+	//
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+	s.Equal(sha, s.receive(c).To.Sha)
+}
+
+func (s *Server) TestLeavesNoGoroutinesRunningAfterStopAndWait() {
+	// -- Given
+	//
+	dir, err := ioutil.TempDir("", "gpoll")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := gpoll.NewFileOutboxStore(dir + "/outbox.json")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	handled := make(chan string, 1)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      s.server.GitConfig(),
+		Interval: 10 * time.Millisecond,
+		HandleCommit: func(commit gpoll.CommitDiff) {
+			if commit.To.Sha != commit.From.Sha {
+				handled <- commit.To.Sha
+			}
+		},
+		Outbox: gpoll.OutboxConfig{
+			Store:   store,
+			Deliver: func(context.Context, gpoll.CommitDiff) error { return nil },
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = poller.StartAsyncContext(ctx)
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	s.NotEmpty(poller.Status().ActiveGoroutines)
+
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+
+	// -- When
+	//
+	s.Equal(sha, <-handled)
+
+	// -- Then
+	//
+	s.NoError(gpolltest.VerifyNoLeaks(poller, 0))
+}
+
+func (s *Server) TestStartsDegradedUntilRemoteRecovers() {
+	// -- Given
+	//
+	up := make(chan struct{})
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-up:
+			s.server.ServeHTTP(w, r)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer proxy.Close()
+
+	config := s.server.GitConfig()
+	config.Remote = proxy.URL + "/repo.git"
+	events := make(chan gpoll.Event, 16)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:           config,
+		Interval:      10 * time.Millisecond,
+		StartDegraded: true,
+		HandleEvent: func(event gpoll.Event) {
+			switch event.EventType() {
+			case gpoll.EventTypeDegraded, gpoll.EventTypeRecovered:
+				events <- event
+			}
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	// -- When
+	//
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+
+	// -- Then
+	//
+	s.Equal(gpoll.EventTypeDegraded, (<-events).EventType())
+	s.False(poller.Status().DegradedSince.IsZero())
+
+	close(up)
+	for event := range events {
+		if event.EventType() == gpoll.EventTypeRecovered {
+			s.GreaterOrEqual(event.(gpoll.Recovered).Attempts, 1)
+			break
+		}
+	}
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+	s.Equal(sha, s.receive(c).To.Sha)
+	s.True(poller.Status().DegradedSince.IsZero())
+}
+
+func (s *Server) TestStopsRetryingDegradedStart() {
+	// -- Given
+	//
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer proxy.Close()
+
+	config := s.server.GitConfig()
+	config.Remote = proxy.URL + "/repo.git"
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{Git: config, StartDegraded: true})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	_, err = poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	// -- When
+	//
+	poller.StopAndWait()
+
+	// -- Then
+	//
+	s.NoError(gpolltest.VerifyNoLeaks(poller, time.Second))
+	_, err = poller.StartAsync()
+	s.NoError(err)
+	poller.StopAndWait()
+}
+
+func (s *Server) TestWarnsOfSlowConsumer() {
+	// -- Given
+	//
+	slow := make(chan gpoll.SlowConsumer, 1)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      s.server.GitConfig(),
+		Interval: 10 * time.Millisecond,
+		HandleCommit: func(gpoll.CommitDiff) {
+			time.Sleep(50 * time.Millisecond)
+		},
+		SlowConsumer: gpoll.SlowConsumerConfig{Threshold: 20 * time.Millisecond},
+		HandleEvent: func(event gpoll.Event) {
+			if e, ok := event.(gpoll.SlowConsumer); ok {
+				slow <- e
+			}
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+
+	// -- When
+	//
+	_, err = s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+	s.receive(c)
+
+	// -- Then
+	//
+	event := <-slow
+	s.Greater(int64(event.P95), int64(event.Threshold))
+	s.Equal(20*time.Millisecond, event.Threshold)
+	s.Equal(0, event.QueueDepth)
+	s.GreaterOrEqual(int64(poller.Status().DeliveryWaitP95), int64(50*time.Millisecond))
+}
+
+func (s *Server) TestDeliversLoadWithoutLossOrReordering() {
+	if testing.Short() {
+		s.T().Skip("pushes a thousand commits")
+	}
+
+	// -- Given
+	//
+	load := gpolltest.LoadConfig{
+		Commits:   1000,
+		BatchSize: 50,
+		Pause:     5 * time.Millisecond,
+		MaxHeap:   256 << 20,
+	}
+
+	// -- When
+	//
+	report, err := gpolltest.RunLoad(s.server, gpoll.PollConfig{Interval: 10 * time.Millisecond}, load)
+
+	// -- Then
+	//
+	s.NoError(err)
+	s.Equal(1000, report.Commits)
+	s.Greater(report.Throughput, 0.0)
+	s.T().Logf("delivered %d commits in %s (%.0f/s), heap peaked at %d MiB", report.Commits, report.Duration,
+		report.Throughput, report.MaxHeap>>20)
+}
+
+func (s *Server) TestCancelsHungFetches() {
+	// -- Given
+	//
+	faults := &gpoll.Faults{}
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:           s.server.GitConfig(),
+		Interval:      time.Hour,
+		FaultInjector: faults,
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	_, err = poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+	s.Eventually(func() bool {
+		return !poller.Status().LastPoll.IsZero()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// -- When
+	//
+	faults.SetDelay(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, pollErr := poller.PollContext(ctx)
+	poller.Trigger()
+
+	// -- Then
+	//
+	s.True(errors.Is(pollErr, context.DeadlineExceeded))
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stopCancel()
+	s.NoError(poller.StopContext(stopCtx))
+	s.False(poller.Status().Running)
+}
+
+func (s *Server) TestReportsFailedPolls() {
+	// -- Given
+	//
+	faults := &gpoll.Faults{}
+	handled := make(chan error, 10)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:           s.server.GitConfig(),
+		Interval:      10 * time.Millisecond,
+		FaultInjector: faults,
+		OnError: func(err error) {
+			handled <- err
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	_, err = poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+
+	// -- When
+	//
+	faults.FailNext(3, nil)
+
+	// -- Then
+	//
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-poller.Errors():
+			s.True(errors.Is(err, gpoll.ErrInjectedFault))
+		case <-time.After(5 * time.Second):
+			s.FailNow("timed out waiting for an error")
+		}
+		s.True(errors.Is(<-handled, gpoll.ErrInjectedFault))
+	}
+	s.Eventually(func() bool {
+		status := poller.Status()
+		return status.LastError == nil && status.ConsecutiveFailures == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Len(poller.Errors(), 0)
+}
@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"errors"
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/gpolltest/server"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func (s *Server) TestIsolatesTenantsOfMultiPoller() {
+	// -- Given
+	//
+	dir, err := ioutil.TempDir("", "gpoll")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	m, err := gpoll.NewMultiPoller(nil)
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	s.NoError(m.AddTenant(gpoll.Tenant{
+		Name:      "payments",
+		Directory: filepath.Join(dir, "payments"),
+		Limits:    gpoll.TenantLimits{MaxRepos: 1, MinInterval: 10 * time.Millisecond, MaxEventsPerSecond: 100},
+	}))
+	s.NoError(m.AddTenant(gpoll.Tenant{Name: "search", Directory: filepath.Join(dir, "search")}))
+	if !s.NoError(m.Start()) {
+		s.FailNow("failed to start")
+	}
+	defer m.Stop()
+
+	config := s.server.GitConfig()
+	config.CloneDirectory = ""
+	config.Storage = gpoll.StorageConfig{Type: gpoll.StorageTypeFilesystem}
+	commits := make(chan gpoll.CommitDiff, 10)
+
+	// -- When
+	//
+	err = m.AddTenantRepo("payments", "config", gpoll.PollConfig{
+		Git:      config,
+		Interval: 10 * time.Millisecond,
+		HandleCommit: func(commit gpoll.CommitDiff) {
+			commits <- commit
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	s.NoError(m.AddTenantRepo("search", "config", gpoll.PollConfig{Git: config, Interval: 10 * time.Millisecond}))
+
+	// -- Then
+	//
+	limit := &gpoll.TenantLimitError{}
+	s.True(errors.As(m.AddTenantRepo("payments", "other", gpoll.PollConfig{Git: config}), &limit))
+	s.Equal("max repos", limit.Limit)
+	s.NoError(m.AddTenantRepo("search", "fast", gpoll.PollConfig{Git: config, Interval: time.Millisecond}))
+	s.NoError(m.RemoveRepo(gpoll.TenantRepoID("search", "fast")))
+	s.True(errors.As(m.AddTenantRepo("payments", "config", gpoll.PollConfig{Git: config, Interval: time.Millisecond}), &limit))
+	s.Equal("min interval", limit.Limit)
+	escaped := config
+	escaped.CloneDirectory = filepath.Join(dir, "search", "clone")
+	s.Error(m.AddTenantRepo("payments", "escaped", gpoll.PollConfig{Git: escaped}))
+
+	s.Equal([]string{"payments/config", "search/config"}, m.ListRepos())
+	repos, err := m.ListTenantRepos("payments")
+	s.NoError(err)
+	s.Equal([]string{"config"}, repos)
+	_, err = os.Stat(filepath.Join(dir, "payments", "config", "clone", ".git"))
+	s.NoError(err)
+
+	s.Equal(gpoll.ChangeTypeInit, s.receive(commits).Changes[0].ChangeType)
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+	s.Equal(sha, s.receive(commits).To.Sha)
+
+	srv := httptest.NewServer(gpoll.NewTenantAdminHandler(m, map[string]string{"p-token": "payments"}))
+	defer srv.Close()
+	get := func(path, token string) int {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if !s.NoError(err) {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	s.Equal(http.StatusOK, get("/status?repo=config", "p-token"))
+	s.Equal(http.StatusNotFound, get("/status?repo=../search/config", "p-token"))
+	s.Equal(http.StatusUnauthorized, get("/repos", "s-token"))
+}
+
+func (s *Server) TestThrottlesPollingOverBudget() {
+	// -- Given
+	//
+	exceeded := make(chan gpoll.BudgetExceeded, 10)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      s.server.GitConfig(),
+		Interval: 10 * time.Millisecond,
+		Budget:   gpoll.BudgetConfig{CPUTime: time.Nanosecond, Window: time.Second},
+		HandleEvent: func(event gpoll.Event) {
+			if e, ok := event.(gpoll.BudgetExceeded); ok {
+				exceeded <- e
+			}
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+
+	var event gpoll.BudgetExceeded
+	select {
+	case event = <-exceeded:
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for the budget to be exceeded")
+	}
+
+	// -- When
+	//
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+
+	// -- Then
+	//
+	s.Equal(gpoll.EventTypeBudgetExceeded, event.EventType())
+	s.Equal(event.ThrottledUntil, poller.Status().ThrottledUntil)
+	select {
+	case <-c:
+		if time.Now().Before(event.ThrottledUntil) {
+			s.Fail("delivered a commit while throttled")
+		}
+	case <-time.After(time.Until(event.ThrottledUntil) - 50*time.Millisecond):
+	}
+	s.Equal(sha, s.receive(c).To.Sha)
+}
+
+func (s *Server) TestMultiplexesPollerGroup() {
+	// -- Given
+	//
+	other, err := server.New()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer other.Close()
+	group, err := gpoll.NewPollerGroup(map[string]gpoll.PollConfig{
+		"a": {Git: s.server.GitConfig(), Interval: 10 * time.Millisecond, Manifests: true},
+		"b": {Git: other.GitConfig(), Interval: 10 * time.Millisecond},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := group.Start()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	_, err = group.Start()
+	s.Equal(gpoll.ErrGroupStarted, err)
+
+	// -- When
+	//
+	_, err = s.server.Commit("add a", map[string]string{"a.txt": "a"})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	_, err = other.Commit("add b", map[string]string{"b.txt": "b"})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	// -- Then
+	//
+	commits := make(map[string]string)
+	manifests := 0
+	for len(commits) < 2 || manifests == 0 {
+		select {
+		case e := <-c:
+			if e.Commit != nil {
+				commits[e.Repo] = filepath.Base(e.Commit.Changes[0].Filepath)
+			} else if e.Event != nil && e.Event.EventType() == gpoll.EventTypeManifest {
+				s.Equal("a", e.Repo)
+				manifests++
+			}
+		case <-time.After(5 * time.Second):
+			s.FailNow("timed out waiting for the group")
+		}
+	}
+	s.Equal(map[string]string{"a": "a.txt", "b": "b.txt"}, commits)
+	s.Equal([]string{"a", "b"}, group.Repos())
+	p, err := group.Poller("b")
+	if s.NoError(err) {
+		s.True(p.Status().Running)
+	}
+
+	group.Stop()
+	for range c {
+	}
+	s.False(p.Status().Running)
+}
@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/gpolltest/server"
+	"net"
+	"strings"
+	"time"
+)
+
+func (s *Server) TestPollsThroughBastion() {
+	// -- Given
+	//
+	if !s.NoError(s.server.StartSsh()) {
+		s.FailNow("failed to serve ssh")
+	}
+	bastion, err := server.NewBastion()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer bastion.Close()
+	s.T().Setenv("SSH_KNOWN_HOSTS", s.server.KnownHosts)
+
+	git := s.server.SshGitConfig()
+	git.Transport.Bastion = gpoll.BastionConfig{
+		Host:       bastion.Addr,
+		User:       bastion.User,
+		SshKey:     bastion.SshKey,
+		KnownHosts: bastion.KnownHosts,
+	}
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      git,
+		Interval: 10 * time.Millisecond,
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+
+	// -- When
+	//
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+
+	// -- Then
+	//
+	s.Equal(sha, s.receive(c).To.Sha)
+	s.Greater(bastion.Forwarded(), 0)
+}
+
+func (s *Server) TestPollsThroughSocksProxy() {
+	// -- Given
+	//
+	if !s.NoError(s.server.StartSsh()) {
+		s.FailNow("failed to serve ssh")
+	}
+	socks, err := server.NewSocks("poller", "socks-secret")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer socks.Close()
+	s.T().Setenv("SSH_KNOWN_HOSTS", s.server.KnownHosts)
+
+	proxy := gpoll.ProxyConfig{Address: socks.Addr, Username: socks.Username, Password: socks.Password}
+	var channels []chan gpoll.CommitDiff
+	for _, git := range []gpoll.GitConfig{s.server.GitConfig(), s.server.SshGitConfig()} {
+		git.Transport.Proxy = proxy
+		poller, err := gpoll.NewPoller(gpoll.PollConfig{
+			Git:      git,
+			Interval: 10 * time.Millisecond,
+		})
+		if !s.NoError(err) {
+			s.FailNow(err.Error())
+		}
+		c, err := poller.StartAsync()
+		if !s.NoError(err) {
+			s.FailNow(err.Error())
+		}
+		defer poller.StopAndWait()
+		channels = append(channels, c)
+	}
+
+	// -- When
+	//
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+
+	// -- Then
+	//
+	for _, c := range channels {
+		s.Equal(sha, s.receive(c).To.Sha)
+	}
+	s.GreaterOrEqual(socks.Connected(), 2)
+}
+
+func (s *Server) TestResolvesRemoteWithDNSServers() {
+	// -- Given
+	//
+	dns, err := server.NewDNS(map[string][]net.IP{"git.gpolltest": {net.ParseIP("127.0.0.1")}})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer dns.Close()
+
+	git := s.server.GitConfig()
+	git.Remote = strings.Replace(git.Remote, "127.0.0.1", "git.gpolltest", 1)
+	git.Transport.IPFamily = gpoll.IPFamilyIPv4Only
+	git.Transport.DNSServers = []string{dns.Addr}
+	git.Transport.DNSTimeout = time.Second
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      git,
+		Interval: 10 * time.Millisecond,
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+
+	// -- When
+	//
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+
+	// -- Then
+	//
+	s.Equal(sha, s.receive(c).To.Sha)
+	s.Greater(dns.Queries(), 0)
+}
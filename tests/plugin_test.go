@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"errors"
+	"github.com/eddieowens/gpoll"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Serves the plugin protocol when the test binary is started as a plugin by TestRunsPluginFiltersAndHandlers.
+func TestPluginProcess(t *testing.T) {
+	if os.Getenv("GPOLL_TEST_PLUGIN") != "1" {
+		return
+	}
+	err := gpoll.ServePlugin(gpoll.PluginHandlers{
+		HandleCommit: func(commit gpoll.CommitDiff) error {
+			if strings.Contains(commit.To.Message, "reject") {
+				return errors.New("rejected " + commit.To.Sha)
+			}
+			return nil
+		},
+		FilterChange: func(change gpoll.FileChange) (bool, error) {
+			return strings.HasSuffix(change.Filepath, ".yaml"), nil
+		},
+	})
+	if err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func (s *Server) TestRunsPluginFiltersAndHandlers() {
+	// -- Given
+	//
+	errs := make(chan error, 10)
+	plugin, err := gpoll.NewPlugin(gpoll.PluginConfig{
+		Command: []string{os.Args[0], "-test.run=^TestPluginProcess$"},
+		Env:     []string{"GPOLL_TEST_PLUGIN=1"},
+		OnError: func(err error) {
+			errs <- err
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer plugin.Close()
+
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:                 s.server.GitConfig(),
+		Interval:            10 * time.Millisecond,
+		FilepathMode:        gpoll.FilepathModeRepoRelative,
+		FileChangeFilter:    plugin.FilterChange,
+		HandleCommitContext: plugin.HandleCommit,
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+
+	// -- When
+	//
+	_, err = s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n", "notes.txt": "notes\n"})
+	s.NoError(err)
+	rejected, err := s.server.Commit("reject this", map[string]string{"b.yaml": "b: 1\n"})
+	s.NoError(err)
+
+	// -- Then
+	//
+	first := s.receive(c)
+	s.Equal([]gpoll.FileChange{{Filepath: "a.yaml", ChangeType: gpoll.ChangeTypeCreate, Size: 5}}, first.Changes)
+	s.Equal(rejected, s.receive(c).To.Sha)
+	select {
+	case err := <-errs:
+		s.Contains(err.Error(), "rejected "+rejected)
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for the plugin to reject the commit")
+	}
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/eddieowens/gpoll"
+	"strings"
+	"time"
+)
+
+func (s *Server) TestReleasesQuarantinedCommitsInOrder() {
+	// -- Given
+	//
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      s.server.GitConfig(),
+		Interval: 10 * time.Millisecond,
+		Validation: gpoll.ValidationConfig{
+			Validate: func(_ context.Context, commit gpoll.CommitDiff) error {
+				if strings.HasPrefix(commit.To.Message, "bad") {
+					return errors.New("bad commit")
+				}
+				return nil
+			},
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+
+	bad, err := s.server.Commit("bad config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+	good, err := s.server.Commit("good config", map[string]string{"b.yaml": "b: 1\n"})
+	s.NoError(err)
+	s.Eventually(func() bool {
+		return len(poller.Quarantine()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// -- When
+	//
+	err = poller.Release(good)
+	s.Equal(gpoll.ErrNotOldestQuarantined, err)
+	err = poller.Release(bad)
+	s.NoError(err)
+
+	// -- Then
+	//
+	s.Equal(bad, s.receive(c).To.Sha)
+	s.Equal(good, s.receive(c).To.Sha)
+	s.Empty(poller.Quarantine())
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/gpolltest/server"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type Server struct {
+	suite.Suite
+
+	server *server.Server
+}
+
+func (s *Server) SetupTest() {
+	srv, err := server.New()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	s.server = srv
+}
+
+func (s *Server) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *Server) TestDeliversPushedCommits() {
+	s.testDeliversPushedCommits(s.server.GitConfig())
+}
+
+func (s *Server) testDeliversPushedCommits(config gpoll.GitConfig) {
+	// -- Given
+	//
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:          config,
+		Interval:     10 * time.Millisecond,
+		FilepathMode: gpoll.FilepathModeRepoRelative,
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+
+	// -- When
+	//
+	created, err := s.server.Commit("add config", map[string]string{"config/app.yaml": "a: 1\n"})
+	s.NoError(err)
+	removed, err := s.server.Remove("remove readme", "README.md")
+	s.NoError(err)
+
+	// -- Then
+	//
+	first := s.receive(c)
+	s.Equal(created, first.To.Sha)
+	s.Equal([]gpoll.FileChange{{Filepath: "config/app.yaml", ChangeType: gpoll.ChangeTypeCreate, Size: 5}},
+		first.Changes)
+
+	second := s.receive(c)
+	s.Equal(removed, second.To.Sha)
+	s.Equal([]gpoll.FileChange{{Filepath: "README.md", ChangeType: gpoll.ChangeTypeDelete}}, second.Changes)
+
+	last, ok := poller.LastChange("config/app.yaml")
+	s.True(ok)
+	s.Equal(created, last.Commit.Sha)
+	s.Equal(gpoll.ChangeTypeCreate, last.ChangeType)
+
+	history, err := poller.History("README.md", 0)
+	s.NoError(err)
+	if s.Len(history, 2) {
+		s.Equal(removed, history[0].Sha)
+	}
+}
+
+func (s *Server) receive(c chan gpoll.CommitDiff) gpoll.CommitDiff {
+	select {
+	case commit := <-c:
+		return commit
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for a commit")
+	}
+	return gpoll.CommitDiff{}
+}
+
+func TestServer(t *testing.T) {
+	suite.Run(t, new(Server))
+}
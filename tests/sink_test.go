@@ -0,0 +1,268 @@
+package tests
+
+import (
+	"context"
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/contentsync"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func (s *Server) TestOutboxRetriesSignedWebhookUntilAcknowledged() {
+	// -- Given
+	//
+	dir, err := ioutil.TempDir("", "gpoll")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	received := make(chan *http.Request, 1)
+	attempts := 0
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(gpoll.WebhookSignatureHeader) != gpoll.SignWebhook("secret", body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- r
+	}))
+	defer sink.Close()
+
+	store, err := gpoll.NewFileOutboxStore(dir + "/outbox.json")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	webhook := gpoll.NewWebhook(gpoll.WebhookConfig{
+		Url:            sink.URL,
+		Secret:         "secret",
+		InitialBackoff: time.Millisecond,
+	})
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      s.server.GitConfig(),
+		Interval: 10 * time.Millisecond,
+		Outbox:   gpoll.OutboxConfig{Store: store, Deliver: webhook.Deliver},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+
+	// -- When
+	//
+	_, err = s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+	commit := s.receive(c)
+
+	// -- Then
+	//
+	select {
+	case r := <-received:
+		s.Equal(commit.ID, r.Header.Get(gpoll.WebhookDeliveryHeader))
+		s.Equal("commit", r.Header.Get(gpoll.WebhookEventHeader))
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for the webhook")
+	}
+	s.Eventually(func() bool {
+		pending, err := store.Pending()
+		return err == nil && len(pending) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *Server) TestWebhookRendersCommitTemplate() {
+	// -- Given
+	//
+	received := make(chan string, 10)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		select {
+		case received <- string(body):
+		default:
+		}
+	}))
+	defer sink.Close()
+
+	tmpl, err := gpoll.NewCommitTemplate(
+		`{"text": {{json (printf "%s %s" (short .To.Sha) (firstLine .To.Message))}}}` +
+			`{{range .Changes}} {{.ChangeType}}:{{.Filepath}}{{end}}`)
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	webhook := gpoll.NewWebhook(gpoll.WebhookConfig{
+		Url:      sink.URL,
+		Template: tmpl,
+	})
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:                 s.server.GitConfig(),
+		Interval:            10 * time.Millisecond,
+		FilepathMode:        gpoll.FilepathModeRepoRelative,
+		HandleCommitContext: webhook.HandleCommit,
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+
+	// -- When
+	//
+	sha, err := s.server.Commit("add config\n\nwith details", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+	for s.receive(c).To.Sha != sha {
+	}
+
+	// -- Then
+	//
+	expected := `{"text": "` + sha[:7] + ` add config"} create:a.yaml`
+	for {
+		select {
+		case body := <-received:
+			if body == expected {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			s.FailNow("timed out waiting for the webhook")
+		}
+	}
+}
+
+type cacheClient struct {
+	batches chan []string
+}
+
+func (c *cacheClient) Invalidate(_ context.Context, keys []string) error {
+	c.batches <- keys
+	return nil
+}
+
+func (s *Server) TestBatchesCacheInvalidations() {
+	// -- Given
+	//
+	client := &cacheClient{batches: make(chan []string, 10)}
+	invalidator := gpoll.NewCacheInvalidator(gpoll.CacheInvalidatorConfig{
+		Client: client,
+		Keys: func(change gpoll.FileChange) []string {
+			page := "/" + strings.TrimSuffix(filepath.Base(change.Filepath), ".md")
+			return []string{page, "/index"}
+		},
+		BatchSize: 2,
+	})
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:                 s.server.GitConfig(),
+		Interval:            10 * time.Millisecond,
+		HandleCommitContext: invalidator.HandleCommit,
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+
+	// -- When
+	//
+	_, err = s.server.Commit("add pages", map[string]string{"a.md": "# a\n", "b.md": "# b\n"})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	s.receive(c)
+
+	// -- Then
+	//
+	batches := make([][]string, 0)
+	for len(batches) < 2 {
+		select {
+		case b := <-client.batches:
+			batches = append(batches, b)
+		case <-time.After(5 * time.Second):
+			s.FailNow("timed out waiting for the cache to be invalidated")
+		}
+	}
+	s.Equal([][]string{{"/a", "/b"}, {"/index"}}, batches)
+	s.Len(client.batches, 0)
+}
+
+func (s *Server) TestSyncsRenderedContent() {
+	// -- Given
+	//
+	out, err := ioutil.TempDir("", "gpoll")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(out)
+	_, err = s.server.Commit("add page", map[string]string{"content/a.md": "a\n"})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	errs := make(chan error, 10)
+	site := contentsync.New(contentsync.Config{
+		Dir: "content",
+		Renderer: contentsync.RenderFunc(func(_ context.Context, path string, content []byte) ([]contentsync.Output, error) {
+			return []contentsync.Output{{
+				Path:        strings.TrimSuffix(path, ".md") + ".html",
+				Content:     []byte("<p>" + strings.TrimSpace(string(content)) + "</p>"),
+				ContentType: "text/html",
+			}}, nil
+		}),
+		Publisher: &contentsync.DirPublisher{Dir: out},
+		OnError: func(err error) {
+			errs <- err
+		},
+	})
+	config := gpoll.PollConfig{
+		Git:      s.server.GitConfig(),
+		Interval: 10 * time.Millisecond,
+	}
+	site.Install(&config)
+	poller, err := gpoll.NewPoller(config)
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+	read := func(name string) string {
+		b, _ := ioutil.ReadFile(filepath.Join(out, name))
+		return string(b)
+	}
+	s.Equal("<p>a</p>", read("a.html"))
+
+	// -- When
+	//
+	_, err = s.server.Commit("add another page", map[string]string{"content/b.md": "b\n", "other.md": "other\n"})
+	s.NoError(err)
+	s.receive(c)
+	_, err = s.server.Remove("remove page", "content/a.md")
+	s.NoError(err)
+	s.receive(c)
+
+	// -- Then
+	//
+	s.Equal("<p>b</p>", read("b.html"))
+	s.Empty(read("a.html"))
+	s.Empty(read("other.html"))
+	s.Len(errs, 0)
+}
@@ -0,0 +1,272 @@
+package tests
+
+import (
+	"context"
+	"github.com/eddieowens/gpoll"
+	"gopkg.in/src-d/go-git.v4"
+	gitconfig "gopkg.in/src-d/go-git.v4/config"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+func (s *Server) TestDeliversPushedCommitsWithoutCheckout() {
+	config := s.server.GitConfig()
+	config.NoCheckout = true
+	s.testDeliversPushedCommits(config)
+}
+
+func (s *Server) TestDeliversPushedCommitsWithMemoryMappedStorage() {
+	dir, err := ioutil.TempDir("", "gpoll")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	config := s.server.GitConfig()
+	config.CloneDirectory = dir
+	config.Storage = gpoll.StorageConfig{Type: gpoll.StorageTypeFilesystem, MemoryMap: true}
+	s.testDeliversPushedCommits(config)
+}
+
+func (s *Server) TestResumesInterruptedClone() {
+	dir, err := ioutil.TempDir("", "gpoll")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	config := s.server.GitConfig()
+	config.CloneDirectory = dir
+	config.Storage = gpoll.StorageConfig{Type: gpoll.StorageTypeFilesystem}
+
+	// A clone interrupted while fetching leaves behind an initialized repo without the branch.
+	repo, err := git.PlainInit(dir, false)
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	_, err = repo.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{config.Remote}})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	s.testDeliversPushedCommits(config)
+}
+
+func (s *Server) TestStartContextBoundsClone() {
+	// -- Given
+	//
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{Git: s.server.GitConfig()})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// -- When
+	//
+	_, err = poller.StartAsyncContext(ctx)
+
+	// -- Then
+	//
+	s.Error(err)
+}
+
+func (s *Server) TestSharesObjectPoolBetweenRepos() {
+	// -- Given
+	//
+	pool := gpoll.NewObjectPool()
+	config := s.server.GitConfig()
+	config.Storage = gpoll.StorageConfig{Pool: pool}
+
+	first, err := gpoll.NewPoller(gpoll.PollConfig{Git: config, Interval: 10 * time.Millisecond})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c1, err := first.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer first.Stop()
+	objects := pool.Len()
+
+	// -- When
+	//
+	second, err := gpoll.NewPoller(gpoll.PollConfig{Git: config, Interval: 10 * time.Millisecond})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c2, err := second.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer second.Stop()
+
+	// -- Then
+	//
+	s.Equal(objects, pool.Len())
+
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+	s.Equal(sha, s.receive(c1).To.Sha)
+	s.Equal(sha, s.receive(c2).To.Sha)
+}
+
+func (s *Server) TestPromotesStandbyWhenCloneIsCorrupted() {
+	// -- Given
+	//
+	dir, err := ioutil.TempDir("", "gpoll")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	config := s.server.GitConfig()
+	config.CloneDirectory = dir + "/primary"
+	config.Storage = gpoll.StorageConfig{Type: gpoll.StorageTypeFilesystem}
+	failovers := make(chan gpoll.Failover, 1)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      config,
+		Interval: 10 * time.Millisecond,
+		Standby:  gpoll.StandbyConfig{Directory: dir + "/standby", Interval: 10 * time.Millisecond},
+		HandleEvent: func(event gpoll.Event) {
+			if f, ok := event.(gpoll.Failover); ok {
+				failovers <- f
+			}
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+	s.Eventually(func() bool { return !poller.Status().StandbyUpdatedAt.IsZero() }, 5*time.Second, 10*time.Millisecond)
+
+	// -- When
+	//
+	s.NoError(os.RemoveAll(config.CloneDirectory + "/.git/objects"))
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+
+	// -- Then
+	//
+	failover := <-failovers
+	s.Equal(dir+"/standby", failover.Directory)
+	s.Error(failover.Err)
+	s.Equal(sha, s.receive(c).To.Sha)
+
+	s.Eventually(func() bool { return !poller.Status().StandbyUpdatedAt.IsZero() }, 5*time.Second, 10*time.Millisecond)
+	s.NoError(poller.PromoteStandby())
+	failover = <-failovers
+	s.Equal(config.CloneDirectory, failover.Directory)
+	s.Nil(failover.Err)
+}
+
+func (s *Server) TestCleansUpClonesOnStop() {
+	// -- Given
+	//
+	dir, err := ioutil.TempDir("", "gpoll")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	config := s.server.GitConfig()
+	config.CloneDirectory = dir + "/primary"
+	config.Storage = gpoll.StorageConfig{Type: gpoll.StorageTypeFilesystem}
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:           config,
+		Interval:      10 * time.Millisecond,
+		Standby:       gpoll.StandbyConfig{Directory: dir + "/standby", Interval: 10 * time.Millisecond},
+		CleanupOnStop: true,
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	_, err = poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	s.Eventually(func() bool { return !poller.Status().StandbyUpdatedAt.IsZero() }, 5*time.Second, 10*time.Millisecond)
+	s.DirExists(dir + "/primary")
+	s.DirExists(dir + "/standby")
+
+	// -- When
+	//
+	poller.StopAndWait()
+
+	// -- Then
+	//
+	for _, d := range []string{dir + "/primary", dir + "/standby"} {
+		_, err := os.Stat(d)
+		s.True(os.IsNotExist(err), d)
+	}
+	s.Nil(poller.Repository())
+
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+	s.Equal(sha, s.receive(c).To.Sha)
+
+	_, err = gpoll.NewPoller(gpoll.PollConfig{Git: s.server.GitConfig(), CleanupOnStop: true})
+	s.Error(err)
+}
+
+func (s *Server) TestCompactsCloneExceedingQuota() {
+	// -- Given
+	//
+	dir, err := ioutil.TempDir("", "gpoll")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	config := s.server.GitConfig()
+	config.CloneDirectory = dir
+	config.Storage = gpoll.StorageConfig{Type: gpoll.StorageTypeFilesystem, Quota: 1}
+	exceeded := make(chan gpoll.QuotaExceeded, 1)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      config,
+		Interval: 10 * time.Millisecond,
+		HandleEvent: func(event gpoll.Event) {
+			if e, ok := event.(gpoll.QuotaExceeded); ok {
+				select {
+				case exceeded <- e:
+				default:
+				}
+			}
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.StopAndWait()
+
+	// -- When
+	//
+	event := <-exceeded
+
+	// -- Then
+	//
+	s.Equal(int64(1), event.Quota)
+	s.Greater(event.Usage, int64(1))
+	s.Greater(poller.Status().DiskUsage, int64(1))
+
+	for i := 0; i < 3; i++ {
+		sha, err := s.server.Commit("update config", map[string]string{"a.yaml": strings.Repeat("a", i+1)})
+		s.NoError(err)
+		s.Equal(sha, s.receive(c).To.Sha)
+	}
+}
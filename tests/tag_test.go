@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/gpolltest/server"
+	"time"
+)
+
+func (s *Server) TestDescribesAnnotatedTagsInMirrorMode() {
+	// -- Given
+	//
+	config := s.server.GitConfig()
+	config.Mirror = true
+	changes := make(chan gpoll.RefChange, 4)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      config,
+		Interval: 10 * time.Millisecond,
+		Tags:     gpoll.TagConfig{AnnotatedOnly: true},
+		HandleEvent: func(event gpoll.Event) {
+			if c, ok := event.(gpoll.RefChange); ok {
+				changes <- c
+			}
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	_, err = poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+	s.Eventually(func() bool { return !poller.Status().LastPoll.IsZero() }, 5*time.Second, 10*time.Millisecond)
+
+	// -- When
+	//
+	head, err := s.server.Head()
+	s.NoError(err)
+	_, err = s.server.Tag("nightly", "")
+	s.NoError(err)
+	object, err := s.server.Tag("v1.0.0", "release 1.0.0")
+	s.NoError(err)
+
+	// -- Then
+	//
+	created := <-changes
+	s.Equal("refs/tags/v1.0.0", created.Ref)
+	s.Equal(object, created.To)
+	if s.NotNil(created.Tag) {
+		s.Equal("v1.0.0", created.Tag.Name)
+		s.True(created.Tag.Annotated)
+		s.Equal(object, created.Tag.Object)
+		s.Equal(head, created.Tag.Target)
+		s.Equal(server.Username, created.Tag.Tagger.Name)
+		s.Equal("release 1.0.0\n", created.Tag.Message)
+	}
+
+	s.NoError(s.server.DeleteTag("nightly"))
+	s.NoError(s.server.DeleteTag("v1.0.0"))
+	deleted := <-changes
+	s.Equal("refs/tags/v1.0.0", deleted.Ref)
+	s.Empty(deleted.To)
+	if s.NotNil(deleted.Tag) {
+		s.True(deleted.Tag.Annotated)
+	}
+	s.Empty(changes)
+}
+
+func (s *Server) TestOrdersTagsBySemverAndIgnoresOlderVersions() {
+	// -- Given
+	//
+	_, err := s.server.Tag("v1.2.0", "release 1.2.0")
+	s.NoError(err)
+
+	config := s.server.GitConfig()
+	config.Mirror = true
+	changes := make(chan gpoll.RefChange, 8)
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      config,
+		Interval: 10 * time.Millisecond,
+		Tags: gpoll.TagConfig{
+			Order:      gpoll.TagOrderSemver,
+			Monotonic:  true,
+			AllowOlder: []string{"v1.1.*"},
+		},
+		HandleEvent: func(event gpoll.Event) {
+			if c, ok := event.(gpoll.RefChange); ok {
+				changes <- c
+			}
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	_, err = poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+	s.Eventually(func() bool { return !poller.Status().LastPoll.IsZero() }, 5*time.Second, 10*time.Millisecond)
+
+	// -- When
+	//
+	// Paused so that every tag is found by the same poll once any poll in flight has finished.
+	poller.Pause()
+	time.Sleep(50 * time.Millisecond)
+	for _, name := range []string{"v1.10.0", "v1.3.0", "v1.1.5", "v1.0.1", "latest", "v1.3.0-rc.1"} {
+		_, err := s.server.Tag(name, "")
+		s.NoError(err)
+	}
+	poller.Resume()
+
+	// -- Then
+	//
+	for _, expected := range []string{"v1.1.5", "v1.3.0-rc.1", "v1.3.0", "v1.10.0", "latest"} {
+		s.Equal("refs/tags/"+expected, (<-changes).Ref)
+	}
+	s.Empty(changes)
+}
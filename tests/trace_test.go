@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/eddieowens/gpoll"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+func (s *Server) TestTracesDeliveriesWithReceipts() {
+	// -- Given
+	//
+	dir, err := ioutil.TempDir("", "gpoll")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	store, err := gpoll.NewFileOutboxStore(dir + "/outbox.json")
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	attempts := 0
+	audit := &lockedBuffer{}
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      s.server.GitConfig(),
+		Interval: 10 * time.Millisecond,
+		Audit:    gpoll.AuditConfig{Sink: audit},
+		Handlers: []gpoll.Handler{{Name: "config", Handle: func(context.Context, gpoll.CommitDiff) {}}},
+		Outbox: gpoll.OutboxConfig{
+			Store: store,
+			Deliver: func(context.Context, gpoll.CommitDiff) error {
+				attempts++
+				if attempts == 1 {
+					return errors.New("sink unavailable")
+				}
+				return nil
+			},
+			RetryInterval: 10 * time.Millisecond,
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	c, err := poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	// -- When
+	//
+	sha, err := s.server.Commit("add config", map[string]string{"a.yaml": "a: 1\n"})
+	s.NoError(err)
+	commit := s.receive(c)
+
+	// -- Then
+	//
+	s.NotEmpty(commit.PollID)
+	var receipt gpoll.Receipt
+	sinks := map[string]gpoll.SinkDelivery{}
+	s.Eventually(func() bool {
+		receipt, _ = poller.Receipt(commit.ID)
+		for _, d := range receipt.Sinks {
+			sinks[d.Sink] = d
+		}
+		return len(sinks) == 2 && sinks["outbox"].Error == ""
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Equal(commit.PollID, receipt.PollID)
+	s.Equal(sha, receipt.Sha)
+	if s.Len(receipt.Handlers, 1) {
+		s.Equal("handler:config", receipt.Handlers[0].Target)
+	}
+	s.Equal(1, sinks["channel"].Attempts)
+	s.Equal(2, sinks["outbox"].Attempts)
+	if last := poller.Status().LastReceipt; s.NotNil(last) {
+		s.Equal(commit.ID, last.CommitID)
+	}
+
+	poller.StopAndWait()
+	for _, line := range strings.Split(strings.TrimSpace(audit.String()), "\n") {
+		var record gpoll.AuditRecord
+		s.NoError(json.Unmarshal([]byte(line), &record))
+		if record.Action == gpoll.AuditActionDeliver && s.NotNil(record.Receipt) {
+			s.Equal(commit.PollID, record.Details["poll"])
+			s.Equal(commit.PollID, record.Receipt.PollID)
+			s.Equal("handler:config", record.Receipt.Handlers[0].Target)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"errors"
+	"github.com/eddieowens/gpoll"
+	"time"
+)
+
+type appConfig struct {
+	A int `yaml:"a"`
+}
+
+func (s *Server) TestWatchDecodesChangedFiles() {
+	// -- Given
+	//
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      s.server.GitConfig(),
+		Interval: 10 * time.Millisecond,
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	changes := make(chan gpoll.Change[appConfig], 10)
+	w, err := gpoll.Watch(poller, gpoll.WatchConfig[appConfig]{
+		Path: "config",
+		Validate: func(v appConfig) error {
+			if v.A < 0 {
+				return errors.New("a must not be negative")
+			}
+			return nil
+		},
+		OnChange: func(change gpoll.Change[appConfig]) {
+			changes <- change
+		},
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer w.Close()
+
+	_, err = poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+
+	// -- When
+	//
+	_, err = s.server.Commit("add config", map[string]string{"config/app.yaml": "a: 1\n"})
+	s.NoError(err)
+	first := s.receiveChange(changes)
+	_, err = s.server.Commit("break config", map[string]string{"config/app.yaml": "a: -1\n"})
+	s.NoError(err)
+	second := s.receiveChange(changes)
+
+	// -- Then
+	//
+	s.Equal("config/app.yaml", first.Path)
+	s.Equal(appConfig{A: 1}, first.New)
+	s.NoError(first.Err)
+
+	s.Equal(appConfig{A: 1}, second.Old)
+	s.Error(second.Err)
+	v, ok := w.Get("config/app.yaml")
+	s.True(ok)
+	s.Equal(appConfig{A: 1}, v)
+}
+
+func (s *Server) receiveChange(c chan gpoll.Change[appConfig]) gpoll.Change[appConfig] {
+	select {
+	case change := <-c:
+		return change
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for a change")
+	}
+	return gpoll.Change[appConfig]{}
+}
+
+func (s *Server) TestValueFollowsCommittedKey() {
+	// -- Given
+	//
+	poller, err := gpoll.NewPoller(gpoll.PollConfig{
+		Git:      s.server.GitConfig(),
+		Interval: 10 * time.Millisecond,
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+
+	v, err := gpoll.NewValue(poller, gpoll.ValueConfig[bool]{
+		Path: "flags.json",
+		Key:  "features.checkout",
+	})
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer v.Close()
+	c, unsubscribe := v.Subscribe()
+	defer unsubscribe()
+
+	_, err = poller.StartAsync()
+	if !s.NoError(err) {
+		s.FailNow(err.Error())
+	}
+	defer poller.Stop()
+
+	// -- When
+	//
+	_, err = s.server.Commit("enable checkout", map[string]string{"flags.json": `{"features": {"checkout": true}}`})
+	s.NoError(err)
+
+	// -- Then
+	//
+	select {
+	case enabled := <-c:
+		s.True(enabled)
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for a value")
+	}
+	s.True(v.Get())
+}
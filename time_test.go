@@ -0,0 +1,45 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/eddieowens/gpoll/gpolltest/server"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type TimeTest struct {
+	serverSuite
+}
+
+func (s *TimeTest) TestPreservesCommitTimezones() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{})
+	c := s.start(p)
+	defer p.StopAndWait()
+	when := time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("IST", 5*60*60+30*60))
+
+	// -- When
+	//
+	before := time.Now()
+	sha, err := s.server.CommitAt(when, "add a", map[string]string{"a.txt": "a"})
+	s.Require().NoError(err)
+	commit := s.receive(c)
+
+	// -- Then
+	//
+	s.Equal(sha, commit.To.Sha)
+	s.True(when.Equal(commit.To.Author.When))
+	_, offset := commit.To.Author.When.Zone()
+	s.Equal(5*60*60+30*60, offset)
+	_, offset = commit.To.Committer.When.Zone()
+	s.Equal(5*60*60+30*60, offset)
+	s.Equal(server.Username, commit.To.Author.Name)
+	s.False(commit.ReceivedAt.Before(before))
+	s.False(commit.ReceivedAt.After(time.Now()))
+}
+
+func TestTime(t *testing.T) {
+	suite.Run(t, new(TimeTest))
+}
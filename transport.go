@@ -0,0 +1,323 @@
+package gpoll
+
+import (
+	"context"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	gitssh "gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// How a poller connects to its remote. Apart from SSH remotes reached through a tunnel, which is shared per host as
+// described on BastionConfig, the config only applies to the poller it's set on.
+//
+// go-git can't be handed a transport per fetch or clone, so creating the first poller of an HTTP(S) remote installs a
+// transport for the http and https protocols in go-git's process wide table through client.InstallProtocol. It sends the
+// requests of pollers through their own HTTP client and passes every other request on to the transport it replaced, so
+// other go-git users in the process are unaffected. Installing another http or https transport after the first poller
+// is created replaces it though, after which the config no longer applies to HTTP(S) remotes. UninstallHttpTransport
+// puts back the transports it replaced.
+type TransportConfig struct {
+	// The maximum amount of time to establish a connection to the remote, including the SSH or TLS handshake. Defaults
+	// to no timeout.
+	ConnectTimeout time.Duration
+
+	// The maximum amount of time a single git operation e.g. a fetch may take before it is abandoned and fails.
+	// Prevents a poller on a flaky network from hanging indefinitely. Defaults to no timeout.
+	OperationTimeout time.Duration
+
+	// The interval between TCP keepalive probes on connections to the remote. SSH remotes with a KeepAlive are
+	// connected to through a tunnel like those with a Bastion. Defaults to Go's default.
+	KeepAlive time.Duration
+
+	// A jump host SSH remotes are reached through.
+	Bastion BastionConfig
+
+	// A SOCKS5 proxy the remote is reached through. With a Bastion, the jump host is reached through the proxy.
+	Proxy ProxyConfig
+
+	// Which IP versions the remote is connected over, and in which order they are tried. Defaults to IPFamilyAny.
+	IPFamily IPFamily
+
+	// The DNS servers resolving the remote, as ip or ip:port, tried in order. Defaults to the servers of the system.
+	// With a Proxy, only the address of the proxy is resolved here and the remote is resolved by the proxy.
+	DNSServers []string
+
+	// The maximum amount of time a single attempt to resolve the remote may take, so a server that doesn't answer
+	// e.g. AAAA queries doesn't hang the fetch. Defaults to the timeout of the system resolver.
+	DNSTimeout time.Duration
+
+	// Restrictions on the algorithms and key types used with SSH remotes e.g. FIPSSshCrypto.
+	SshCrypto SshCryptoConfig
+}
+
+// go-git looks up the transport of a remote in a process wide table that it reads without locking and its fetch and
+// clone options can't carry one, so a single transport dispatching to the HTTP client of each poller is installed by the
+// first poller created. Requests of any other go-git user are passed on to the transport it replaced.
+var (
+	httpTransportLock sync.Mutex
+	// The transports replaced by the installed one keyed by protocol. nil if it isn't installed.
+	replacedHttpTransports map[string]transport.Transport
+)
+
+func installHttpTransport() {
+	httpTransportLock.Lock()
+	defer httpTransportLock.Unlock()
+	if replacedHttpTransports != nil {
+		return
+	}
+	replacedHttpTransports = make(map[string]transport.Transport)
+	for _, protocol := range []string{"http", "https"} {
+		next, ok := client.Protocols[protocol]
+		if !ok {
+			next = githttp.DefaultClient
+		}
+		replacedHttpTransports[protocol] = next
+		client.InstallProtocol(protocol, httpProtocol{next: next})
+	}
+}
+
+// Put back the http and https transports of go-git that were replaced when the first poller of an HTTP(S) remote was
+// created, e.g. before handing go-git over to other code once every poller is stopped. A transport installed by
+// something else in the meantime is left alone. Pollers of HTTP(S) remotes still work afterwards, but their
+// TransportConfig no longer applies until another poller of an HTTP(S) remote is created, which installs the transport
+// again. Like client.InstallProtocol, it must not be called while go-git is in use.
+func UninstallHttpTransport() {
+	httpTransportLock.Lock()
+	defer httpTransportLock.Unlock()
+	for protocol, next := range replacedHttpTransports {
+		if _, ok := client.Protocols[protocol].(httpProtocol); ok {
+			client.InstallProtocol(protocol, next)
+		}
+	}
+	replacedHttpTransports = nil
+}
+
+// A transport.Transport sending the requests of each poller through its own HTTP client, which is carried by its
+// auth method. Any other auth method is sent through the next transport.
+type httpProtocol struct {
+	next transport.Transport
+}
+
+func (h httpProtocol) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	c, auth := h.clientOf(auth)
+	return c.NewUploadPackSession(ep, auth)
+}
+
+func (h httpProtocol) NewReceivePackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
+	c, auth := h.clientOf(auth)
+	return c.NewReceivePackSession(ep, auth)
+}
+
+func (h httpProtocol) clientOf(auth transport.AuthMethod) (transport.Transport, transport.AuthMethod) {
+	a, ok := auth.(*httpClientAuth)
+	if !ok {
+		return h.next, auth
+	}
+	if a.AuthMethod == nil {
+		return githttp.NewClient(a.client), nil
+	}
+	return githttp.NewClient(a.client), a.AuthMethod
+}
+
+// Carries the HTTP client of a poller alongside its auth method, which may be nil.
+type httpClientAuth struct {
+	transport.AuthMethod
+	client *http.Client
+}
+
+func (h *httpClientAuth) Name() string {
+	if h.AuthMethod == nil {
+		return "none"
+	}
+	return h.AuthMethod.Name()
+}
+
+func (h *httpClientAuth) String() string {
+	if h.AuthMethod == nil {
+		return h.Name()
+	}
+	return h.AuthMethod.String()
+}
+
+// Keeps the auth method usable by go-git's own HTTP client should the protocols be replaced.
+func (h *httpClientAuth) SetAuth(r *http.Request) {
+	if a, ok := h.AuthMethod.(githttp.AuthMethod); ok {
+		a.SetAuth(r)
+	}
+}
+
+// go-git doesn't cancel the requests of HTTP(S) remotes with the context of an operation, so they're sent with it by the
+// HTTP client of the auth method instead.
+func authContext(ctx context.Context, auth transport.AuthMethod) transport.AuthMethod {
+	if a, ok := auth.(*httpClientAuth); ok {
+		return a.withContext(ctx)
+	}
+	return auth
+}
+
+// Sends the requests of the auth method with the context so they're cancelled once it's done.
+func (h *httpClientAuth) withContext(ctx context.Context) *httpClientAuth {
+	c := *h.client
+	rt := c.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	c.Transport = contextRoundTripper{ctx: ctx, rt: rt}
+	return &httpClientAuth{AuthMethod: h.AuthMethod, client: &c}
+}
+
+type contextRoundTripper struct {
+	ctx context.Context
+	rt  http.RoundTripper
+}
+
+func (c contextRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return c.rt.RoundTrip(req.WithContext(c.ctx))
+}
+
+// The auth method as configured, without the HTTP client of the poller.
+func unwrapAuth(auth transport.AuthMethod) transport.AuthMethod {
+	if a, ok := auth.(*httpClientAuth); ok {
+		return a.AuthMethod
+	}
+	return auth
+}
+
+// Create the HTTP client of a poller, connecting through the dialer if set. Returns the default client if nothing
+// about the connection is configured.
+func newHttpClient(config TransportConfig, dialer contextDialer) *http.Client {
+	if dialer == nil && config.ConnectTimeout <= 0 && config.KeepAlive <= 0 {
+		return http.DefaultClient
+	}
+	proxy := http.ProxyFromEnvironment
+	if _, socks := dialer.(*socksDialer); socks {
+		// Requests go straight to the SOCKS5 proxy rather than through the proxies in the environment.
+		proxy = nil
+	}
+	if dialer == nil {
+		dialer = &net.Dialer{
+			Timeout:   config.ConnectTimeout,
+			KeepAlive: config.KeepAlive,
+		}
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:               proxy,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: config.ConnectTimeout,
+		},
+	}
+}
+
+// Applies the transport config to the auth method. The auth method of an HTTP(S) remote carries the HTTP client of the
+// poller, which is nil for other remotes.
+func applyTransport(config TransportConfig, auth transport.AuthMethod, hostKeyAddr string, httpClient *http.Client) transport.AuthMethod {
+	if httpClient != nil {
+		return &httpClientAuth{AuthMethod: auth, client: httpClient}
+	}
+
+	if sshAuth, ok := auth.(gitssh.AuthMethod); ok && (config.ConnectTimeout > 0 || config.SshCrypto.isSet() || hostKeyAddr != "") {
+		return &sshConfigAuth{
+			AuthMethod:  sshAuth,
+			timeout:     config.ConnectTimeout,
+			crypto:      config.SshCrypto,
+			hostKeyAddr: hostKeyAddr,
+		}
+	}
+	return auth
+}
+
+// Applies a connection timeout and the SshCrypto restrictions to an SSH auth method, and verifies host keys against the
+// hostKeyAddr if set.
+type sshConfigAuth struct {
+	gitssh.AuthMethod
+	timeout     time.Duration
+	crypto      SshCryptoConfig
+	hostKeyAddr string
+}
+
+func (s *sshConfigAuth) ClientConfig() (*ssh.ClientConfig, error) {
+	c, err := s.AuthMethod.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	c.Timeout = s.timeout
+	s.crypto.apply(c)
+	if verify := c.HostKeyCallback; s.hostKeyAddr != "" && verify != nil {
+		// The remote is dialled through the local end of a bastion tunnel rather than its own address.
+		c.HostKeyCallback = func(_ string, remote net.Addr, key ssh.PublicKey) error {
+			return verify(s.hostKeyAddr, remote, key)
+		}
+	}
+	return c, nil
+}
+
+// Create a context bounded by the OperationTimeout.
+func (g *gitImpl) operationContext() (context.Context, context.CancelFunc) {
+	return g.operationContextFrom(context.Background())
+}
+
+// Bounds the operation by both the parent context and the OperationTimeout.
+func (g *gitImpl) operationContextFrom(parent context.Context) (context.Context, context.CancelFunc) {
+	if g.transport.OperationTimeout > 0 {
+		return context.WithTimeout(parent, g.transport.OperationTimeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// Lists the remote's refs, refreshing the credentials if they were rejected.
+func (g *gitImpl) listRemote(rem *git.Remote) ([]*plumbing.Reference, error) {
+	return g.listRemoteContext(context.Background(), rem)
+}
+
+// Like listRemote but also bounded by the context.
+func (g *gitImpl) listRemoteContext(ctx context.Context, rem *git.Remote) ([]*plumbing.Reference, error) {
+	var refs []*plumbing.Reference
+	err := g.withAuth(func(auth transport.AuthMethod) error {
+		var err error
+		refs, err = g.listRemoteWith(ctx, rem, auth)
+		return err
+	})
+	return refs, err
+}
+
+// go-git can't cancel a listing, so the requests of HTTP(S) remotes are sent with the context and any other listing is
+// abandoned once it's done. The listing runs on the goroutines of the poller so it's counted, but it isn't waited for
+// when stopping since an abandoned listing e.g. over a hung SSH connection may never return.
+func (g *gitImpl) listRemoteWith(ctx context.Context, rem *git.Remote, auth transport.AuthMethod) ([]*plumbing.Reference, error) {
+	ctx, cancel := g.operationContextFrom(ctx)
+	defer cancel()
+	auth = authContext(ctx, auth)
+
+	type result struct {
+		refs []*plumbing.Reference
+		err  error
+	}
+	c := make(chan result, 1)
+	list := func() {
+		refs, err := rem.List(&git.ListOptions{
+			Auth: auth,
+		})
+		c <- result{refs: refs, err: err}
+	}
+	if g.goroutines != nil {
+		g.goroutines.Go(listRemoteGoroutine, list)
+	} else {
+		go list()
+	}
+
+	select {
+	case r := <-c:
+		return r.refs, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
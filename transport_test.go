@@ -0,0 +1,171 @@
+package gpoll_test
+
+import (
+	"errors"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type TransportTest struct {
+	serverSuite
+}
+
+func (s *TransportTest) TestProxyOnlyAppliesToItsPoller() {
+	// -- Given
+	//
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	closed := l.Addr().String()
+	s.Require().NoError(l.Close())
+
+	config := s.server.GitConfig()
+	config.Transport.Proxy.Address = closed
+	proxied := s.newPoller(gpoll.PollConfig{Git: config})
+	direct := s.newPoller(gpoll.PollConfig{})
+
+	// -- When
+	//
+	_, proxiedErr := proxied.StartAsync()
+	_, directErr := direct.StartAsync()
+	defer direct.StopAndWait()
+
+	// -- Then
+	//
+	s.Error(proxiedErr)
+	s.NoError(directErr)
+}
+
+func (s *TransportTest) TestTimedOutPollIsCancelled() {
+	// -- Given
+	//
+	f := s.newFront(s.server)
+	defer f.Close()
+	config := f.config
+	config.Transport.OperationTimeout = 200 * time.Millisecond
+	p := s.newPoller(gpoll.PollConfig{Git: config, Interval: time.Hour})
+	s.start(p)
+	f.hang()
+
+	// -- When
+	//
+	_, err := p.Poll()
+
+	// -- Then
+	//
+	s.Error(err)
+	select {
+	case <-f.cancelled:
+	case <-time.After(5 * time.Second):
+		s.FailNow("the request was not cancelled")
+	}
+	stopped := make(chan struct{})
+	go func() {
+		p.StopAndWait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		s.Fail("timed out waiting for the poller to stop")
+	}
+}
+
+func (s *TransportTest) TestTimeoutsOnlyApplyToTheirPoller() {
+	// -- Given
+	//
+	f := s.newFront(s.server)
+	defer f.Close()
+	short := f.config
+	short.Transport.OperationTimeout = 100 * time.Millisecond
+	long := f.config
+	long.Transport.OperationTimeout = 1500 * time.Millisecond
+	shortPoller := s.newPoller(gpoll.PollConfig{Git: short, Interval: time.Hour})
+	longPoller := s.newPoller(gpoll.PollConfig{Git: long, Interval: time.Hour})
+	s.start(shortPoller)
+	defer shortPoller.StopAndWait()
+	s.start(longPoller)
+	defer longPoller.StopAndWait()
+	f.hang()
+
+	// -- When
+	//
+	took := make(chan time.Duration, 1)
+	go func() {
+		start := time.Now()
+		_, _ = longPoller.Poll()
+		took <- time.Since(start)
+	}()
+	start := time.Now()
+	_, err := shortPoller.Poll()
+	shortTook := time.Since(start)
+
+	// -- Then
+	//
+	s.Error(err)
+	s.True(shortTook < time.Second, "took %s", shortTook)
+	select {
+	case longTook := <-took:
+		s.True(longTook >= long.Transport.OperationTimeout, "took %s", longTook)
+	case <-time.After(5 * time.Second):
+		s.FailNow("the poll did not time out")
+	}
+}
+
+// A transport counting the sessions opened through it, which all fail.
+type countingTransport struct {
+	sessions int32
+}
+
+func (c *countingTransport) NewUploadPackSession(*transport.Endpoint, transport.AuthMethod) (transport.UploadPackSession, error) {
+	atomic.AddInt32(&c.sessions, 1)
+	return nil, errors.New("counted")
+}
+
+func (c *countingTransport) NewReceivePackSession(*transport.Endpoint, transport.AuthMethod) (transport.ReceivePackSession, error) {
+	atomic.AddInt32(&c.sessions, 1)
+	return nil, errors.New("counted")
+}
+
+func (s *TransportTest) TestOtherGoGitUsersAreRoutedToReplacedTransport() {
+	// -- Given
+	//
+	gpoll.UninstallHttpTransport()
+	replaced := &countingTransport{}
+	client.InstallProtocol("http", replaced)
+	defer func() {
+		gpoll.UninstallHttpTransport()
+		client.InstallProtocol("http", githttp.DefaultClient)
+	}()
+	p := s.newPoller(gpoll.PollConfig{})
+	s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	rem := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{s.server.URL}})
+	_, listErr := rem.List(&git.ListOptions{})
+	_, pollErr := p.Poll()
+
+	// -- Then
+	//
+	s.EqualError(listErr, "counted")
+	s.NoError(pollErr)
+	s.Equal(int32(1), atomic.LoadInt32(&replaced.sessions))
+
+	gpoll.UninstallHttpTransport()
+	s.Equal(replaced, client.Protocols["http"])
+}
+
+func TestTransport(t *testing.T) {
+	suite.Run(t, new(TransportTest))
+}
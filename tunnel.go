@@ -0,0 +1,186 @@
+package gpoll
+
+import (
+	"fmt"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	gitssh "gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// Opens connections to the remote for a tunnel.
+type tunnelDialer interface {
+	dial(target string) (net.Conn, error)
+	close()
+}
+
+// Resolves the address of SSH remotes, sending those reached through a jump host, proxy or the configured DNS servers to
+// their tunnel and leaving
+// the rest to the ssh_config of the user.
+type tunnelResolver struct {
+	lock     sync.RWMutex
+	tunnels  map[string]*tunnel
+	fallback interface {
+		Get(alias, key string) string
+	}
+}
+
+var (
+	tunnels            = &tunnelResolver{tunnels: make(map[string]*tunnel)}
+	installTunnelsOnce sync.Once
+)
+
+func (r *tunnelResolver) Get(alias, key string) string {
+	r.lock.RLock()
+	t, ok := r.tunnels[alias]
+	r.lock.RUnlock()
+	if !ok {
+		if r.fallback == nil {
+			return ""
+		}
+		return r.fallback.Get(alias, key)
+	}
+
+	addr, err := t.address()
+	if err != nil {
+		// An empty address makes go-git connect directly, which fails just as well on a network needing the tunnel.
+		return ""
+	}
+	host, port, _ := net.SplitHostPort(addr)
+	switch key {
+	case "Hostname":
+		return host
+	case "Port":
+		return port
+	}
+	return ""
+}
+
+// Route connections to the remote through its jump host and/or proxy, resolving it with the configured DNS servers.
+// Returns the host:port of the remote that host keys are verified against, which is empty for HTTP(S) remotes, and the
+// dialer the HTTP client of an HTTP(S) remote connects with.
+//
+// go-git resolves the address of SSH remotes for the whole process, so connections are tunnelled through a listener on
+// the loopback interface that go-git is pointed at instead of the remote.
+func useTunnel(remote string, config TransportConfig) (string, contextDialer, error) {
+	ep, err := transport.NewEndpoint(remote)
+	if err != nil {
+		return "", nil, err
+	}
+	if ep.Protocol != "ssh" && config.Bastion.Host != "" {
+		return "", nil, fmt.Errorf("a bastion can only be used with ssh remotes, not %s", ep.Protocol)
+	}
+
+	resolver, err := newResolvingDialer(config)
+	if err != nil {
+		return "", nil, err
+	}
+	var d interface {
+		tunnelDialer
+		contextDialer
+	} = resolver
+	if config.Proxy.Address != "" {
+		if d, err = newSocksDialer(config, resolver); err != nil {
+			return "", nil, err
+		}
+	}
+	if ep.Protocol != "ssh" {
+		hd, err := httpDialer(ep, config, d)
+		return "", hd, err
+	}
+	port := ep.Port
+	if port <= 0 {
+		port = gitssh.DefaultPort
+	}
+
+	var dialer tunnelDialer = d
+	if config.Bastion.Host != "" {
+		if dialer, err = newBastionDialer(config, d); err != nil {
+			return "", nil, err
+		}
+	}
+
+	installTunnelsOnce.Do(func() {
+		tunnels.fallback = gitssh.DefaultSSHConfig
+		gitssh.DefaultSSHConfig = tunnels
+	})
+	target := net.JoinHostPort(ep.Host, strconv.Itoa(port))
+	t := &tunnel{
+		target: target,
+		dialer: dialer,
+	}
+	tunnels.lock.Lock()
+	old := tunnels.tunnels[ep.Host]
+	tunnels.tunnels[ep.Host] = t
+	tunnels.lock.Unlock()
+	if old != nil {
+		old.close()
+	}
+	return target, nil, nil
+}
+
+// Tunnels connections from a listener on the loopback interface to the remote.
+type tunnel struct {
+	target string
+	dialer tunnelDialer
+
+	lock     sync.Mutex
+	listener net.Listener
+}
+
+// The address of the listener of the tunnel, starting it on first use.
+func (t *tunnel) address() (string, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.listener == nil {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return "", err
+		}
+		t.listener = l
+		go t.serve(l)
+	}
+	return t.listener.Addr().String(), nil
+}
+
+func (t *tunnel) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go t.forward(conn)
+	}
+}
+
+// Pipes the connection to the remote.
+func (t *tunnel) forward(conn net.Conn) {
+	defer conn.Close()
+	remote, err := t.dialer.dial(t.target)
+	if err != nil {
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(remote, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(conn, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+func (t *tunnel) close() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.listener != nil {
+		_ = t.listener.Close()
+	}
+	t.dialer.close()
+}
@@ -0,0 +1,83 @@
+package gpoll
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Checks a commit before it is delivered e.g. a schema check of the config files it changed. Returning an error fails
+// the commit as per the ValidationConfig's FailAction. The context is cancelled once the ValidationConfig's Timeout is
+// exceeded, which must be honored for the Timeout to have any effect. It's also cancelled when the poller stops, in
+// which case the commit is validated again once polling resumes.
+type ValidateFunc func(ctx context.Context, commit CommitDiff) error
+
+type ValidationConfig struct {
+	// Function that every commit must pass before it is delivered. If not set, every commit is delivered.
+	Validate ValidateFunc
+
+	// The maximum amount of time validation may take, after which the context passed to Validate is cancelled and the
+	// commit fails validation. Validate runs within the poll loop, so the timeout only bounds a Validate that returns
+	// once its context is done. Defaults to no timeout.
+	Timeout time.Duration
+
+	// What to do with a commit that fails validation. Defaults to PolicyActionHalt, quarantining the commit and holding
+	// every commit after it so delivery doesn't advance past it. PolicyActionSkip drops the failing commit without
+	// quarantining it, so it can't be released, and continues delivering the commits after it. Only the Quarantined
+	// event is left of a dropped commit.
+	FailAction PolicyAction
+}
+
+// Emitted when a commit fails validation, whether it's quarantined or dropped.
+type Quarantined struct {
+	// The commit that failed validation.
+	Commit CommitDiff
+
+	// Why the commit failed validation.
+	Err error
+
+	// What the poller did with the commit.
+	Action PolicyAction
+}
+
+func (q Quarantined) EventType() EventType {
+	return EventTypeQuarantined
+}
+
+func (q Quarantined) String() string {
+	return fmt.Sprintf("commit %s failed validation: %s", q.Commit.To.Sha, q.Err.Error())
+}
+
+// Validates the commit. A failure is returned as a Quarantined event. Returns false if the context is cancelled, e.g.
+// because the poller is stopping, before the commit is validated.
+func (p *poller) validate(parent context.Context, commit CommitDiff) (*Quarantined, bool) {
+	config := p.config.Validation
+	if config.Validate == nil {
+		return nil, true
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if config.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, config.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	defer cancel()
+
+	err := config.Validate(ctx, commit)
+	if parent.Err() != nil {
+		return nil, false
+	}
+	if err == nil && ctx.Err() == context.DeadlineExceeded {
+		err = ctx.Err()
+	}
+	if err == nil {
+		return nil, true
+	}
+	return &Quarantined{
+		Commit: commit,
+		Err:    err,
+		Action: config.FailAction,
+	}, true
+}
@@ -0,0 +1,167 @@
+package gpoll_test
+
+import (
+	"context"
+	"errors"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type ValidateTest struct {
+	serverSuite
+}
+
+// Fails commits changing a file named bad.txt.
+func rejectBad(ctx context.Context, commit gpoll.CommitDiff) error {
+	for _, c := range commit.Changes {
+		if filepath.Base(c.Filepath) == "bad.txt" {
+			return errors.New("bad file")
+		}
+	}
+	return nil
+}
+
+func (s *ValidateTest) TestHaltQuarantinesAndHoldsLaterCommits() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{
+		Validation: gpoll.ValidationConfig{Validate: rejectBad},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	bad := s.commit("add bad", map[string]string{"bad.txt": "bad"})
+	good := s.commit("add good", map[string]string{"good.txt": "good"})
+
+	// -- Then
+	//
+	s.Eventually(func() bool {
+		return len(p.Quarantine()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	held := p.Quarantine()
+	s.Equal(bad, held[0].Commit.To.Sha)
+	s.Equal("bad file", held[0].Reason)
+	s.Equal(good, held[1].Commit.To.Sha)
+	s.receiveNone(c, 100*time.Millisecond)
+
+	s.NoError(p.Release(bad))
+	s.Equal(bad, s.receive(c).To.Sha)
+	s.Equal(good, s.receive(c).To.Sha)
+}
+
+func (s *ValidateTest) TestSkipDropsFailingCommit() {
+	// -- Given
+	//
+	var lock sync.Mutex
+	failed := make([]gpoll.Quarantined, 0)
+	p := s.newPoller(gpoll.PollConfig{
+		Validation: gpoll.ValidationConfig{Validate: rejectBad, FailAction: gpoll.PolicyActionSkip},
+		HandleEvent: func(event gpoll.Event) {
+			if q, ok := event.(gpoll.Quarantined); ok {
+				lock.Lock()
+				defer lock.Unlock()
+				failed = append(failed, q)
+			}
+		},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	bad := s.commit("add bad", map[string]string{"bad.txt": "bad"})
+	good := s.commit("add good", map[string]string{"good.txt": "good"})
+
+	// -- Then
+	//
+	commit := s.receive(c)
+	s.Equal(good, commit.To.Sha)
+	s.Equal(bad, commit.From.Sha)
+	s.Empty(p.Quarantine())
+	lock.Lock()
+	defer lock.Unlock()
+	if s.Len(failed, 1) {
+		s.Equal(bad, failed[0].Commit.To.Sha)
+		s.Equal(gpoll.PolicyActionSkip, failed[0].Action)
+	}
+}
+
+func (s *ValidateTest) TestTimeoutCancelsValidation() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{
+		Validation: gpoll.ValidationConfig{
+			Validate: func(ctx context.Context, commit gpoll.CommitDiff) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			Timeout: 50 * time.Millisecond,
+		},
+	})
+	s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	sha := s.commit("add a", map[string]string{"a.txt": "a"})
+
+	// -- Then
+	//
+	s.Eventually(func() bool {
+		held := p.Quarantine()
+		return len(held) == 1 && held[0].Commit.To.Sha == sha
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Equal(context.DeadlineExceeded.Error(), p.Quarantine()[0].Reason)
+}
+
+func (s *ValidateTest) TestStopInterruptsValidationUntilRestart() {
+	// -- Given
+	//
+	// Only the first validation blocks, every later one passes.
+	entered := make(chan struct{}, 1)
+	p := s.newPoller(gpoll.PollConfig{
+		Validation: gpoll.ValidationConfig{
+			Validate: func(ctx context.Context, commit gpoll.CommitDiff) error {
+				select {
+				case entered <- struct{}{}:
+					<-ctx.Done()
+					return ctx.Err()
+				default:
+					return nil
+				}
+			},
+		},
+	})
+	s.start(p)
+	sha := s.commit("add a", map[string]string{"a.txt": "a"})
+	s.Eventually(func() bool {
+		return len(entered) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// -- When
+	//
+	p.StopAndWait()
+
+	// -- Then
+	//
+	held := p.Quarantine()
+	if s.Len(held, 1) {
+		s.Equal(sha, held[0].Commit.To.Sha)
+		s.Equal("interrupted before a decision was made", held[0].Reason)
+	}
+
+	c := s.start(p)
+	defer p.StopAndWait()
+	s.Equal(sha, s.receive(c).To.Sha)
+	s.Empty(p.Quarantine())
+}
+
+func TestValidate(t *testing.T) {
+	suite.Run(t, new(ValidateTest))
+}
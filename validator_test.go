@@ -0,0 +1,75 @@
+package gpoll_test
+
+import (
+	"context"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/go-playground/validator.v9"
+	"strings"
+	"testing"
+)
+
+type ValidatorTest struct {
+	suite.Suite
+}
+
+func (s *ValidatorTest) TestUsesCustomValidator() {
+	// -- Given
+	//
+	v := validator.New()
+	v.RegisterStructValidation(func(sl validator.StructLevel) {
+		git := sl.Current().Interface().(gpoll.GitConfig)
+		if !strings.HasPrefix(git.Remote, "https://git.corp.example.com/") {
+			sl.ReportError(git.Remote, "Remote", "Remote", "corpremote", "")
+		}
+	}, gpoll.GitConfig{})
+	config := func(remote string) gpoll.PollConfig {
+		return gpoll.PollConfig{
+			Git:       gpoll.GitConfig{Remote: remote, Auth: gpoll.GitAuthConfig{Username: "jane", Password: "secret"}},
+			Validator: v,
+		}
+	}
+
+	// -- When
+	//
+	_, rejected := gpoll.NewPoller(config("https://github.com/eddieowens/gpoll.git"))
+	_, allowed := gpoll.NewPoller(config("https://git.corp.example.com/infra.git"))
+
+	// -- Then
+	//
+	if s.Error(rejected) {
+		s.Contains(rejected.Error(), "corpremote")
+	}
+	s.NoError(allowed)
+}
+
+func (s *ValidatorTest) TestSkipsValidation() {
+	// -- Given
+	//
+	config := gpoll.PollConfig{
+		Git: gpoll.GitConfig{
+			Remote: "https://github.com/eddieowens/gpoll.git",
+			Auth:   gpoll.GitAuthConfig{Username: "jane", Password: "secret"},
+		},
+		// Missing the Paths it requires.
+		VirtualRepos: []gpoll.VirtualRepo{{
+			Name:         "config",
+			HandleCommit: func(ctx context.Context, commit gpoll.CommitDiff) {},
+		}},
+	}
+
+	// -- When
+	//
+	_, validated := gpoll.NewPoller(config)
+	config.SkipValidation = true
+	_, skipped := gpoll.NewPoller(config)
+
+	// -- Then
+	//
+	s.Error(validated)
+	s.NoError(skipped)
+}
+
+func TestValidator(t *testing.T) {
+	suite.Run(t, new(ValidatorTest))
+}
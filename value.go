@@ -0,0 +1,155 @@
+package gpoll
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type ValueConfig[T any] struct {
+	// The slash separated path of the file relative to the root of the repo. Required.
+	Path string
+
+	// A dot separated key within the file whose value is used e.g. features.checkout. Defaults to the whole file.
+	Key string
+
+	// The value until a valid value is committed, and once the file is deleted.
+	Default T
+
+	// Validates a committed value. Invalid values are ignored, keeping the current value.
+	Validate func(v T) error
+
+	// Called with every committed value that failed to decode or validate.
+	OnError func(err error)
+}
+
+// The latest committed and validated value of a file, or of a key within it, e.g. a feature flag kept in git. The value
+// is swapped atomically whenever a commit changes it.
+type Value[T any] struct {
+	watcher *Watcher[T]
+	config  ValueConfig[T]
+	current atomic.Value
+
+	lock        sync.Mutex
+	subscribers map[chan T]struct{}
+}
+
+// Create a Value kept up to date through the poller. See Watch.
+func NewValue[T any](poller Poller, config ValueConfig[T]) (*Value[T], error) {
+	v := &Value[T]{
+		config:      config,
+		subscribers: make(map[chan T]struct{}),
+	}
+	v.current.Store(config.Default)
+
+	w, err := Watch(poller, WatchConfig[T]{
+		Path:     config.Path,
+		Decode:   decodeKey[T](config.Key),
+		Validate: config.Validate,
+		OnChange: v.onChange,
+	})
+	if err != nil {
+		return nil, err
+	}
+	v.watcher = w
+	return v, nil
+}
+
+// Get the current value.
+func (v *Value[T]) Get() T {
+	return v.current.Load().(T)
+}
+
+// Get a channel receiving every new value. Only the latest value is kept for a subscriber that falls behind. Call the
+// returned function to unsubscribe.
+func (v *Value[T]) Subscribe() (<-chan T, func()) {
+	c := make(chan T, 1)
+	v.lock.Lock()
+	v.subscribers[c] = struct{}{}
+	v.lock.Unlock()
+
+	return c, func() {
+		v.lock.Lock()
+		defer v.lock.Unlock()
+		delete(v.subscribers, c)
+	}
+}
+
+// Stop updating the value.
+func (v *Value[T]) Close() {
+	if v.watcher != nil {
+		v.watcher.Close()
+	}
+}
+
+func (v *Value[T]) onChange(change Change[T]) {
+	if change.Err != nil {
+		if v.config.OnError != nil {
+			v.config.OnError(change.Err)
+		}
+		return
+	}
+
+	value := change.New
+	if change.Deleted {
+		value = v.config.Default
+	}
+	v.current.Store(value)
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	for c := range v.subscribers {
+		// Replace a value the subscriber hasn't received yet.
+		select {
+		case <-c:
+		default:
+		}
+		c <- value
+	}
+}
+
+// Decodes the value of the dot separated key within the file. The whole file is decoded if the key is empty.
+func decodeKey[T any](key string) func(fp string, content []byte, v *T) error {
+	if key == "" {
+		return decodeByExtension[T]
+	}
+	return func(fp string, content []byte, v *T) error {
+		if path.Ext(fp) == ".json" {
+			var doc interface{}
+			if err := json.Unmarshal(content, &doc); err != nil {
+				return err
+			}
+			for _, k := range strings.Split(key, ".") {
+				m, ok := doc.(map[string]interface{})
+				if doc, ok = m[k]; !ok {
+					return fmt.Errorf("key %s not found in %s", key, fp)
+				}
+			}
+			b, err := json.Marshal(doc)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal(b, v)
+		}
+
+		var doc interface{}
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			return err
+		}
+		for _, k := range strings.Split(key, ".") {
+			m, ok := doc.(map[interface{}]interface{})
+			if doc, ok = m[k]; !ok {
+				return fmt.Errorf("key %s not found in %s", key, fp)
+			}
+		}
+		b, err := yaml.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		return yaml.Unmarshal(b, v)
+	}
+}
@@ -0,0 +1,50 @@
+package gpoll
+
+import "context"
+
+// A subset of the paths in the polled repo that is consumed as if it were a repo of its own e.g. a single service
+// within a monorepo. Every virtual repo shares the poller's clone and fetches but receives only the commits touching its
+// paths, through its own handler with its own checkpoint.
+type VirtualRepo struct {
+	// Uniquely identifies the virtual repo amongst the poller's Handlers. Required.
+	Name string `validate:"required"`
+
+	// Path patterns making up the virtual repo. Patterns use path.Match syntax against the path relative to the root of
+	// the repo, and a pattern naming a directory matches everything beneath it. Required.
+	Paths []string `validate:"required,min=1"`
+
+	// Function for filtering out FileChanges within the virtual repo. Applied after the Paths.
+	FileChangeFilter FileChangeFilterFunc
+
+	// Function that is called with every commit touching the virtual repo. Only the changes within the virtual repo are
+	// included. Required.
+	HandleCommit HandleCommitContextFunc `validate:"required"`
+
+	// A sha or tag to backfill the virtual repo from. See Handler.
+	Baseline string
+}
+
+// Create the Handler delivering the commits touching the virtual repo.
+func (p *poller) virtualRepoHandler(v VirtualRepo) Handler {
+	return Handler{
+		Name:     v.Name,
+		Baseline: v.Baseline,
+		Handle: func(ctx context.Context, commit CommitDiff) {
+			changes := make([]FileChange, 0)
+			for _, c := range commit.Changes {
+				if !matchesAny(v.Paths, p.relativePath(c.Filepath)) {
+					continue
+				}
+				if v.FileChangeFilter != nil && !v.FileChangeFilter(c) {
+					continue
+				}
+				changes = append(changes, c)
+			}
+			if len(changes) == 0 {
+				return
+			}
+			commit.Changes = changes
+			v.HandleCommit(ctx, commit)
+		},
+	}
+}
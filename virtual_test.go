@@ -0,0 +1,130 @@
+package gpoll_test
+
+import (
+	"context"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+	"time"
+)
+
+type VirtualTest struct {
+	serverSuite
+}
+
+// A virtual repo sending the commits it receives on the channel.
+func virtualRepo(name string, c chan gpoll.CommitDiff, paths ...string) gpoll.VirtualRepo {
+	return gpoll.VirtualRepo{
+		Name:  name,
+		Paths: paths,
+		HandleCommit: func(ctx context.Context, commit gpoll.CommitDiff) {
+			c <- commit
+		},
+	}
+}
+
+func (s *VirtualTest) TestDeliversOnlyChangesWithinEachRepo() {
+	// -- Given
+	//
+	billing, search := make(chan gpoll.CommitDiff, 10), make(chan gpoll.CommitDiff, 10)
+	p := s.newPoller(gpoll.PollConfig{
+		VirtualRepos: []gpoll.VirtualRepo{
+			virtualRepo("billing", billing, "services/billing"),
+			virtualRepo("search", search, "services/search"),
+		},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	both := s.commit("add both", map[string]string{
+		"services/billing/a.yaml": "a",
+		"services/search/b.yaml":  "b",
+	})
+	s.receive(c)
+	billingOnly := s.commit("change billing", map[string]string{"services/billing/a.yaml": "b"})
+	s.receive(c)
+
+	// -- Then
+	//
+	commit := s.receive(billing)
+	s.Equal(both, commit.To.Sha)
+	s.assertChanged(commit, "services/billing/a.yaml")
+	commit = s.receive(billing)
+	s.Equal(billingOnly, commit.To.Sha)
+	s.assertChanged(commit, "services/billing/a.yaml")
+
+	commit = s.receive(search)
+	s.Equal(both, commit.To.Sha)
+	s.assertChanged(commit, "services/search/b.yaml")
+	s.receiveNone(search, 100*time.Millisecond)
+}
+
+func (s *VirtualTest) TestFiltersAndCheckpointsEachRepo() {
+	// -- Given
+	//
+	infra := make(chan gpoll.CommitDiff, 10)
+	repo := virtualRepo("infra", infra, "infra/*.tf")
+	repo.FileChangeFilter = func(change gpoll.FileChange) bool {
+		return !strings.HasSuffix(change.Filepath, "_test.tf")
+	}
+	p := s.newPoller(gpoll.PollConfig{VirtualRepos: []gpoll.VirtualRepo{repo}})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	// Neither file is within the repo once filtered.
+	s.commit("add tests", map[string]string{"infra/a_test.tf": "a", "infra/nested/b.tf": "b"})
+	s.receive(c)
+	sha := s.commit("add main", map[string]string{"infra/main.tf": "main"})
+	s.receive(c)
+
+	// -- Then
+	//
+	commit := s.receive(infra)
+	s.Equal(sha, commit.To.Sha)
+	s.assertChanged(commit, "infra/main.tf")
+	s.receiveNone(infra, 100*time.Millisecond)
+	s.Eventually(func() bool {
+		return p.Status().Checkpoints["infra"] == sha
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *VirtualTest) TestRequiresNameAndPaths() {
+	// -- Given
+	//
+	c := make(chan gpoll.CommitDiff)
+
+	// -- When
+	//
+	_, unnamed := gpoll.NewPoller(gpoll.PollConfig{
+		Git:          s.server.GitConfig(),
+		VirtualRepos: []gpoll.VirtualRepo{virtualRepo("", c, "a")},
+	})
+	_, pathless := gpoll.NewPoller(gpoll.PollConfig{
+		Git:          s.server.GitConfig(),
+		VirtualRepos: []gpoll.VirtualRepo{virtualRepo("a", c)},
+	})
+
+	// -- Then
+	//
+	s.Error(unnamed)
+	s.Error(pathless)
+}
+
+// Assert the commit changed exactly the paths, relative to the root of the clone.
+func (s *VirtualTest) assertChanged(commit gpoll.CommitDiff, paths ...string) {
+	if !s.Len(commit.Changes, len(paths)) {
+		return
+	}
+	for i, c := range commit.Changes {
+		s.True(strings.HasSuffix(c.Filepath, "/"+paths[i]), "%s is not %s", c.Filepath, paths[i])
+	}
+}
+
+func TestVirtual(t *testing.T) {
+	suite.Run(t, new(VirtualTest))
+}
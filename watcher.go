@@ -0,0 +1,258 @@
+package gpoll
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type WatchConfig[T any] struct {
+	// The slash separated path, relative to the root of the repo, of the file or directory of files to watch. Every file
+	// beneath a directory is decoded into its own value. Required.
+	Path string
+
+	// Decodes the content of the file at the slash separated path into v. Defaults to JSON for .json files and YAML for
+	// every other file.
+	Decode func(fp string, content []byte, v *T) error
+
+	// Validates a decoded value. An invalid value is delivered alongside its error but doesn't replace the current value.
+	Validate func(v T) error
+
+	// Called with every change to a watched file.
+	OnChange func(change Change[T])
+}
+
+// A change to the decoded value of a watched file.
+type Change[T any] struct {
+	// The slash separated path of the file relative to the root of the repo.
+	Path string
+
+	// The commit that changed the file.
+	Commit Commit
+
+	// The value before the change. The zero value if the file didn't exist or had no valid value.
+	Old T
+
+	// The value after the change. The zero value if the file was deleted or couldn't be decoded.
+	New T
+
+	// Whether the file was deleted.
+	Deleted bool
+
+	// The error decoding or validating the new value, in which case the Old value is kept as the current value.
+	Err error
+}
+
+// Watches a file or directory of files within the polled repo, decoding each file into a T whenever it changes. Use
+// the repo as a typed source of config.
+type Watcher[T any] struct {
+	poller Poller
+	config WatchConfig[T]
+	name   string
+
+	// Serializes updates in case a handler times out while still reading.
+	updateLock sync.Mutex
+	lock       sync.RWMutex
+	// The current valid value of each file.
+	values map[string]T
+	// The blob of each file as of the last handled commit.
+	blobs map[string]plumbing.Hash
+}
+
+// Watch the file or directory through the poller. The watcher receives commits as a Handler, so if the poller is
+// already running, the files are read from the last delivered commit first. Otherwise they are read once the poller
+// starts.
+func Watch[T any](poller Poller, config WatchConfig[T]) (*Watcher[T], error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("watch requires a path")
+	}
+	config.Path = strings.Trim(path.Clean(config.Path), "/")
+	if config.Decode == nil {
+		config.Decode = decodeByExtension[T]
+	}
+
+	w := &Watcher[T]{
+		poller: poller,
+		config: config,
+		name:   "watch:" + config.Path,
+		values: make(map[string]T),
+		blobs:  make(map[string]plumbing.Hash),
+	}
+
+	if repo := poller.Repository(); repo != nil {
+		h := poller.Status().LastDelivered.Sha
+		if h == "" {
+			head, err := repo.Head()
+			if err != nil {
+				return nil, err
+			}
+			h = head.Hash().String()
+		}
+		w.update(h)
+	}
+
+	if err := poller.AddHandler(Handler{Name: w.name, Handle: w.handle}); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Get the current valid value of the file at the slash separated path. The path is the Path of the WatchConfig when
+// watching a single file.
+func (w *Watcher[T]) Get(fp string) (T, bool) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	v, ok := w.values[fp]
+	return v, ok
+}
+
+// Get the current valid value of every watched file, keyed by slash separated path.
+func (w *Watcher[T]) Values() map[string]T {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	values := make(map[string]T, len(w.values))
+	for fp, v := range w.values {
+		values[fp] = v
+	}
+	return values
+}
+
+// Stop watching.
+func (w *Watcher[T]) Close() {
+	w.poller.RemoveHandler(w.name)
+}
+
+func (w *Watcher[T]) handle(_ context.Context, commit CommitDiff) {
+	w.update(commit.To.Sha)
+}
+
+// Reads the watched files as of the sha and delivers a Change for each that differs from the last read.
+func (w *Watcher[T]) update(sha string) {
+	w.updateLock.Lock()
+	defer w.updateLock.Unlock()
+	var commit Commit
+	blobs := make(map[string]plumbing.Hash)
+	contents := make(map[string][]byte)
+	err := w.poller.WithRepo(func(repo *git.Repository) error {
+		c, err := repo.CommitObject(plumbing.NewHash(sha))
+		if err != nil {
+			return err
+		}
+		commit = *toCommit(c)
+
+		files, err := watchedFiles(c, w.config.Path)
+		if err != nil {
+			return err
+		}
+		w.lock.RLock()
+		defer w.lock.RUnlock()
+		for _, f := range files {
+			blobs[f.Name] = f.Hash
+			if w.blobs[f.Name] == f.Hash {
+				continue
+			}
+			content, err := fileContents(f)
+			if err != nil {
+				return err
+			}
+			contents[f.Name] = content
+		}
+		return nil
+	})
+	if err != nil {
+		w.notify(Change[T]{Path: w.config.Path, Commit: commit, Err: err})
+		return
+	}
+
+	changes := make([]Change[T], 0, len(contents))
+	w.lock.Lock()
+	for fp := range w.blobs {
+		if _, ok := blobs[fp]; !ok {
+			changes = append(changes, Change[T]{Path: fp, Commit: commit, Old: w.values[fp], Deleted: true})
+			delete(w.values, fp)
+		}
+	}
+	for fp, content := range contents {
+		change := Change[T]{Path: fp, Commit: commit, Old: w.values[fp]}
+		var v T
+		change.Err = w.config.Decode(fp, content, &v)
+		if change.Err == nil && w.config.Validate != nil {
+			change.Err = w.config.Validate(v)
+		}
+		if change.Err == nil {
+			change.New = v
+			w.values[fp] = v
+		}
+		changes = append(changes, change)
+	}
+	w.blobs = blobs
+	w.lock.Unlock()
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	for _, c := range changes {
+		w.notify(c)
+	}
+}
+
+func (w *Watcher[T]) notify(change Change[T]) {
+	if w.config.OnChange != nil {
+		w.config.OnChange(change)
+	}
+}
+
+// Get the file at the path within the commit, or every file beneath it if it's a directory. Empty if the path doesn't
+// exist.
+func watchedFiles(c *object.Commit, fp string) ([]*object.File, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	f, err := tree.File(fp)
+	if err == nil {
+		return []*object.File{f}, nil
+	} else if err != object.ErrFileNotFound {
+		return nil, err
+	}
+
+	dir, err := tree.Tree(fp)
+	if err == object.ErrDirectoryNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	files := make([]*object.File, 0)
+	err = dir.Files().ForEach(func(f *object.File) error {
+		f.Name = path.Join(fp, f.Name)
+		files = append(files, f)
+		return nil
+	})
+	return files, err
+}
+
+func fileContents(f *object.File) ([]byte, error) {
+	r, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func decodeByExtension[T any](fp string, content []byte, v *T) error {
+	if path.Ext(fp) == ".json" {
+		return json.Unmarshal(content, v)
+	}
+	return yaml.Unmarshal(content, v)
+}
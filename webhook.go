@@ -0,0 +1,261 @@
+package gpoll
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// The header carrying the HMAC-SHA256 of the body, formatted as sha256=<hex> like GitHub's X-Hub-Signature-256.
+	WebhookSignatureHeader = "X-Gpoll-Signature-256"
+
+	// The header carrying the unique ID of the delivery. Retries of the same delivery share the ID.
+	WebhookDeliveryHeader = "X-Gpoll-Delivery"
+
+	// The header carrying what is delivered, either commit or the name of the event type e.g. policy-violation.
+	WebhookEventHeader = "X-Gpoll-Event"
+)
+
+type WebhookConfig struct {
+	// The URL that commits and events are POSTed to as JSON. Required.
+	Url string `validate:"required"`
+
+	// The secret every body is signed with. The signature is sent in the WebhookSignatureHeader. If not set, bodies
+	// aren't signed.
+	Secret string
+
+	// The maximum number of times a failed delivery is retried. Set to -1 to never retry. Defaults to 5.
+	MaxRetries int
+
+	// How long to wait before the first retry. Every retry after it waits twice as long as the one before. Defaults to
+	// 1s.
+	InitialBackoff time.Duration
+
+	// The maximum amount of time to wait between retries. Defaults to 1m.
+	MaxBackoff time.Duration
+
+	// Called when a delivery fails for good, after every retry.
+	OnError func(err error)
+
+	// The time zone that the LocalWhen and LocalReceivedAt of every delivered commit are in. Defaults to leaving them
+	// unset.
+	Location *time.Location
+
+	// Renders the body of every delivered commit in place of the JSON encoded CommitDiff e.g. to post messages to a
+	// chat, see NewCommitTemplate. Events are still delivered as JSON.
+	Template *CommitTemplate
+
+	// The Content-Type of the bodies rendered by the Template. Defaults to application/json.
+	ContentType string
+}
+
+// Delivers commits and events to an HTTP endpoint, signing every body so the receiver can verify it came from the
+// poller. Use HandleCommit as the HandleCommitContext and HandleEvent as the HandleEvent of a PollConfig. A delivery
+// is only successful once the endpoint responds with a 2xx status. Call Stop after stopping the poller to wait for the
+// events still being delivered.
+type Webhook struct {
+	config WebhookConfig
+	client *http.Client
+	// Cancels the deliveries of events.
+	ctx    context.Context
+	cancel context.CancelFunc
+	lock   sync.Mutex
+	events sync.WaitGroup
+	// The number of events being delivered.
+	pending int
+	stopped bool
+}
+
+// Create a Webhook from config.
+func NewWebhook(config WebhookConfig) *Webhook {
+	if config.MaxRetries == 0 {
+		config.MaxRetries = 5
+	} else if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.InitialBackoff == 0 {
+		config.InitialBackoff = time.Second
+	}
+	if config.MaxBackoff == 0 {
+		config.MaxBackoff = time.Minute
+	}
+	if config.ContentType == "" {
+		config.ContentType = "application/json"
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Webhook{
+		config: config,
+		client: http.DefaultClient,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Deliver the commit, identified by its ID, retrying until it succeeds, the retries run out or the context is done.
+func (w *Webhook) HandleCommit(ctx context.Context, commit CommitDiff) {
+	if err := w.Deliver(ctx, commit); err != nil {
+		w.onError(err)
+	}
+}
+
+// Deliver the commit like HandleCommit, returning the error once the retries run out. Use as the Deliver of an
+// OutboxConfig.
+func (w *Webhook) Deliver(ctx context.Context, commit CommitDiff) error {
+	id := commit.ID
+	if id == "" {
+		id = newDeliveryID()
+	}
+	commit = commit.In(w.config.Location)
+	if w.config.Template == nil {
+		return w.Send(ctx, id, "commit", commit)
+	}
+	body, err := w.config.Template.Execute(commit)
+	if err != nil {
+		return err
+	}
+	return w.send(ctx, id, "commit", w.config.ContentType, []byte(body))
+}
+
+// Deliver the event in the background so polling isn't held up by retries. Errors within the event are delivered as
+// their messages. Events handled after Stop are dropped.
+func (w *Webhook) HandleEvent(event Event) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.stopped {
+		return
+	}
+	w.pending++
+	w.events.Add(1)
+	go func() {
+		defer func() {
+			w.lock.Lock()
+			w.pending--
+			w.lock.Unlock()
+			w.events.Done()
+		}()
+		if err := w.Send(w.ctx, newDeliveryID(), event.EventType().String(), eventData(event)); err != nil {
+			w.onError(err)
+		}
+	}()
+}
+
+// The number of events being delivered in the background.
+func (w *Webhook) Pending() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.pending
+}
+
+// Stop delivering events, waiting for the events being delivered to succeed or run out of retries.
+func (w *Webhook) Stop() {
+	_ = w.StopContext(context.Background())
+}
+
+// Stop delivering events like Stop. If the ctx is done before the events are delivered, their deliveries are cancelled
+// and the ctx's error is returned.
+func (w *Webhook) StopContext(ctx context.Context) error {
+	w.lock.Lock()
+	w.stopped = true
+	w.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.events.Wait()
+		close(done)
+	}()
+	defer w.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Deliver v as JSON, retrying with exponential backoff until it succeeds, the retries run out or the context is done.
+func (w *Webhook) Send(ctx context.Context, deliveryID, kind string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return w.send(ctx, deliveryID, kind, "application/json", body)
+}
+
+func (w *Webhook) send(ctx context.Context, deliveryID, kind, contentType string, body []byte) error {
+	var err error
+	backoff := w.config.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		err = w.post(ctx, deliveryID, kind, contentType, body)
+		if err == nil || attempt >= w.config.MaxRetries {
+			return err
+		}
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("delivery %s cancelled after %s", deliveryID, err.Error())
+		case <-t.C:
+		}
+		backoff *= 2
+		if backoff > w.config.MaxBackoff {
+			backoff = w.config.MaxBackoff
+		}
+	}
+}
+
+func (w *Webhook) post(ctx context.Context, deliveryID, kind, contentType string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.config.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(WebhookDeliveryHeader, deliveryID)
+	req.Header.Set(WebhookEventHeader, kind)
+	if w.config.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(w.config.Secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook delivery %s failed with status %d", deliveryID, resp.StatusCode)
+	}
+	return nil
+}
+
+func (w *Webhook) onError(err error) {
+	if w.config.OnError != nil {
+		w.config.OnError(err)
+	}
+}
+
+// Get the signature of the body as sent in the WebhookSignatureHeader. Receivers verify a delivery by comparing the
+// header to the signature of the body they received using hmac.Equal.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
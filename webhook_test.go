@@ -0,0 +1,145 @@
+package gpoll_test
+
+import (
+	"context"
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type WebhookTest struct {
+	suite.Suite
+}
+
+func (s *WebhookTest) TestSignsBody() {
+	// -- Given
+	//
+	verified := make(chan bool, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		verified <- r.Header.Get(gpoll.WebhookSignatureHeader) == gpoll.SignWebhook("secret", body)
+	}))
+	defer srv.Close()
+	w := gpoll.NewWebhook(gpoll.WebhookConfig{Url: srv.URL, Secret: "secret"})
+
+	// -- When
+	//
+	err := w.Deliver(context.Background(), gpoll.CommitDiff{ID: "a"})
+
+	// -- Then
+	//
+	s.NoError(err)
+	s.True(<-verified)
+}
+
+func (s *WebhookTest) TestNeverRetries() {
+	// -- Given
+	//
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	w := gpoll.NewWebhook(gpoll.WebhookConfig{Url: srv.URL, MaxRetries: -1, InitialBackoff: time.Millisecond})
+
+	// -- When
+	//
+	err := w.Deliver(context.Background(), gpoll.CommitDiff{ID: "a"})
+
+	// -- Then
+	//
+	s.Error(err)
+	s.Equal(int32(1), atomic.LoadInt32(&attempts))
+}
+
+func (s *WebhookTest) TestTemplateContentType() {
+	// -- Given
+	//
+	contentTypes := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentTypes <- r.Header.Get("Content-Type")
+	}))
+	defer srv.Close()
+	tmpl, err := gpoll.NewCommitTemplate(`{{.To.Sha}}`)
+	s.Require().NoError(err)
+	w := gpoll.NewWebhook(gpoll.WebhookConfig{Url: srv.URL, Template: tmpl, ContentType: "text/plain"})
+
+	// -- When
+	//
+	err = w.Deliver(context.Background(), gpoll.CommitDiff{ID: "a"})
+
+	// -- Then
+	//
+	s.NoError(err)
+	s.Equal("text/plain", <-contentTypes)
+}
+
+func (s *WebhookTest) TestStopWaitsForEvents() {
+	// -- Given
+	//
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	w := gpoll.NewWebhook(gpoll.WebhookConfig{Url: srv.URL})
+	w.HandleEvent(gpoll.Reachable{})
+	s.Equal(1, w.Pending())
+
+	// -- When
+	//
+	stopped := make(chan struct{})
+	go func() {
+		w.Stop()
+		close(stopped)
+	}()
+
+	// -- Then
+	//
+	select {
+	case <-stopped:
+		s.FailNow("stopped before the event was delivered")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		s.FailNow("timed out waiting for the webhook to stop")
+	}
+	s.Equal(0, w.Pending())
+	w.HandleEvent(gpoll.Reachable{})
+	s.Equal(0, w.Pending())
+}
+
+func (s *WebhookTest) TestStopContextCancelsEvents() {
+	// -- Given
+	//
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	w := gpoll.NewWebhook(gpoll.WebhookConfig{Url: srv.URL, InitialBackoff: time.Hour})
+	w.HandleEvent(gpoll.Reachable{})
+
+	// -- When
+	//
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := w.StopContext(ctx)
+
+	// -- Then
+	//
+	s.Equal(context.DeadlineExceeded, err)
+	s.Equal(0, w.Pending())
+}
+
+func TestWebhook(t *testing.T) {
+	suite.Run(t, new(WebhookTest))
+}
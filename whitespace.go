@@ -0,0 +1,49 @@
+package gpoll
+
+import (
+	"bytes"
+	"path"
+	"unicode"
+)
+
+// Which whitespace is ignored when deciding whether a file changed.
+type WhitespaceMode int
+
+const (
+	// Ignore every change to whitespace, e.g. for JSON where formatting carries no meaning.
+	WhitespaceModeAll WhitespaceMode = iota
+
+	// Ignore whitespace at the end of lines and blank lines, keeping changes to indentation, e.g. for YAML where
+	// indentation carries meaning.
+	WhitespaceModeTrailing
+)
+
+// Whether an updated file only changed in whitespace ignored for its file extension, in which case it isn't included in
+// the commit.
+func (p *poller) isWhitespaceChange(from, to string, c FileChange) (bool, error) {
+	mode, ok := p.config.IgnoreWhitespace[path.Ext(c.Filepath)]
+	if !ok || c.ChangeType != ChangeTypeUpdate {
+		return false, nil
+	}
+	normalize := stripWhitespace
+	if mode == WhitespaceModeTrailing {
+		normalize = stripTrailingWhitespace
+	}
+	return p.sameContent(from, to, c.Filepath, normalize)
+}
+
+func stripWhitespace(b []byte) []byte {
+	return bytes.Join(bytes.Fields(b), nil)
+}
+
+func stripTrailingWhitespace(b []byte) []byte {
+	lines := bytes.Split(b, []byte("\n"))
+	kept := make([][]byte, 0, len(lines))
+	for _, l := range lines {
+		l = bytes.TrimRightFunc(l, unicode.IsSpace)
+		if len(l) > 0 {
+			kept = append(kept, l)
+		}
+	}
+	return bytes.Join(kept, []byte("\n"))
+}
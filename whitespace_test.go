@@ -0,0 +1,91 @@
+package gpoll_test
+
+import (
+	"github.com/eddieowens/gpoll"
+	"github.com/stretchr/testify/suite"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+type WhitespaceTest struct {
+	serverSuite
+}
+
+// The base names of the files changed by the commit, sorted.
+func changedFiles(commit gpoll.CommitDiff) []string {
+	files := make([]string, 0, len(commit.Changes))
+	for _, c := range commit.Changes {
+		files = append(files, filepath.Base(c.Filepath))
+	}
+	sort.Strings(files)
+	return files
+}
+
+func (s *WhitespaceTest) TestIgnoresWhitespaceOnlyChangesPerExtension() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{
+		IgnoreWhitespace: map[string]gpoll.WhitespaceMode{
+			".json": gpoll.WhitespaceModeAll,
+			".yaml": gpoll.WhitespaceModeTrailing,
+		},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+	s.commit("add config", map[string]string{
+		"a.json": `{"a":1}`,
+		"a.yaml": "a:\n  b: 1\n",
+		"a.txt":  "a b",
+	})
+	s.receive(c)
+
+	// -- When
+	//
+	s.commit("reformat", map[string]string{
+		"a.json": "{\n  \"a\": 1\n}\n",
+		"a.yaml": "a:   \n  b: 1\n\n",
+	})
+	reformatted := s.receive(c)
+	s.commit("reindent", map[string]string{
+		"a.json": "{ \"a\" : 1 }",
+		"a.yaml": "a:\n    b: 1\n",
+		"a.txt":  "a  b",
+	})
+	reindented := s.receive(c)
+	s.commit("change", map[string]string{"a.json": `{"a": 2}`})
+	changed := s.receive(c)
+
+	// -- Then
+	//
+	s.Equal("reformat", reformatted.To.Message)
+	s.Empty(reformatted.Changes)
+	s.Equal("reindent", reindented.To.Message)
+	s.Equal([]string{"a.txt", "a.yaml"}, changedFiles(reindented))
+	s.Equal("change", changed.To.Message)
+	s.Equal([]string{"a.json"}, changedFiles(changed))
+}
+
+func (s *WhitespaceTest) TestKeepsCreatedFiles() {
+	// -- Given
+	//
+	p := s.newPoller(gpoll.PollConfig{
+		IgnoreWhitespace: map[string]gpoll.WhitespaceMode{".json": gpoll.WhitespaceModeAll},
+	})
+	c := s.start(p)
+	defer p.StopAndWait()
+
+	// -- When
+	//
+	sha := s.commit("add blank", map[string]string{"blank.json": " \n"})
+
+	// -- Then
+	//
+	commit := s.receive(c)
+	s.Equal(sha, commit.To.Sha)
+	s.Equal([]string{"blank.json"}, changedFiles(commit))
+}
+
+func TestWhitespace(t *testing.T) {
+	suite.Run(t, new(WhitespaceTest))
+}